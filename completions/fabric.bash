@@ -17,7 +17,7 @@ _fabric() {
    fi
 
   # Define all possible options/flags
-  local opts="--pattern -p --variable -v --context -C --session --attachment -a --setup -S --temperature -t --topp -T --stream -s --presencepenalty -P --raw -r --frequencypenalty -F --listpatterns -l --listmodels -L --listcontexts -x --listsessions -X --updatepatterns -U --copy -c --model -m --vendor -V --modelContextLength --output -o --output-session --latest -n --changeDefaultModel -d --youtube -y --playlist --transcript --transcript-with-timestamps --comments --metadata --yt-dlp-args --language -g --scrape_url -u --scrape_question -q --seed -e --thinking --wipecontext -w --wipesession -W --printcontext --printsession --readability --input-has-vars --no-variable-replacement --dry-run --serve --serveOllama --address --api-key --config --search --search-location --image-file --image-size --image-quality --image-compression --image-background --suppress-think --think-start-tag --think-end-tag --disable-responses-api --transcribe-file --transcribe-model --split-media-file --voice --list-gemini-voices --notification --notification-command --debug --version --listextensions --addextension --rmextension --strategy --liststrategies --listvendors --shell-complete-list --help -h"
+  local opts="--pattern -p --variable -v --context -C --refresh-context --session --attachment -a --setup -S --temperature -t --topp -T --stream -s --presencepenalty -P --raw -r --frequencypenalty -F --listpatterns -l --listmodels -L --listcontexts -x --listsessions -X --long --pattern-vars --updatepatterns -U --copy -c --model -m --vendor -V --modelContextLength --output -o --output-session --latest -n --changeDefaultModel -d --youtube -y --playlist --transcript --transcript-with-timestamps --comments --metadata --yt-dlp-args --language -g --scrape_url -u --scrape_question -q --seed -e --thinking --wipecontext -w --wipesession -W --printcontext --printsession --fork-session --at --rewind --edit-message --regenerate --export-session --import-session --format --readability --input-has-vars --no-variable-replacement --dry-run --serve --serveOllama --address --api-key --config --search --search-location --tool --usage --fallback --schema --chain --compare --compare-format --test-pattern --test-mode --test-judge --junit --lint-patterns --lint-shadowed --no-cache --cache-stats --search-db --migrate-storage --create-key-file --rekey --gc-sessions --pin-session --unpin-session --image-file --image-size --image-quality --image-compression --image-background --suppress-think --think-start-tag --think-end-tag --disable-responses-api --transcribe-file --transcribe-model --split-media-file --voice --list-gemini-voices --notification --notification-command --debug --version --listextensions --addextension --rmextension --strategy --liststrategies --listvendors --shell-complete-list --help -h"
 
   # Helper function for dynamic completions
   _fabric_get_list() {
@@ -26,7 +26,7 @@ _fabric() {
 
   # Handle completions based on the previous word
   case "${prev}" in
-  -p | --pattern)
+  -p | --pattern | --pattern-vars | --test-pattern)
     COMPREPLY=($(compgen -W "$(_fabric_get_list --listpatterns)" -- "${cur}"))
     return 0
     ;;
@@ -58,10 +58,14 @@ _fabric() {
     COMPREPLY=($(compgen -W "$(_fabric_get_list --listcontexts)" -- "${cur}"))
     return 0
     ;;
-  --printsession)
+  --printsession | --export-session)
     COMPREPLY=($(compgen -W "$(_fabric_get_list --listsessions)" -- "${cur}"))
     return 0
     ;;
+  --format)
+    COMPREPLY=($(compgen -W "md html jsonl openai" -- "${cur}"))
+    return 0
+    ;;
   --thinking)
     COMPREPLY=($(compgen -W "off low medium high" -- "${cur}"))
     return 0
@@ -82,12 +86,20 @@ _fabric() {
     COMPREPLY=($(compgen -W "$(_fabric_get_list --list-transcription-models)" -- "${cur}"))
     return 0
     ;;
+  --compare-format)
+    COMPREPLY=($(compgen -W "markdown json" -- "${cur}"))
+    return 0
+    ;;
+  --test-mode)
+    COMPREPLY=($(compgen -W "live record recorded mock" -- "${cur}"))
+    return 0
+    ;;
   --debug)
     COMPREPLY=($(compgen -W "0 1 2 3" -- "${cur}"))
     return 0
     ;;
   # Options requiring file/directory paths
-  -a | --attachment | -o | --output | --config | --addextension | --image-file | --transcribe-file)
+  -a | --attachment | -o | --output | --config | --addextension | --schema | --chain | --junit | --image-file | --transcribe-file)
     _filedir
     return 0
     ;;
@@ -105,7 +117,7 @@ _fabric() {
     return 0
     ;;
   # Options requiring simple arguments (no specific completion logic here)
-  -v | --variable | -t | --temperature | -T | --topp | -P | --presencepenalty | -F | --frequencypenalty | --modelContextLength | -n | --latest | -y | --youtube | --yt-dlp-args | -g | --language | -u | --scrape_url | -q | --scrape_question | -e | --seed | --address | --api-key | --search-location | --image-compression | --think-start-tag | --think-end-tag | --notification-command)
+  -v | --variable | -t | --temperature | -T | --topp | -P | --presencepenalty | -F | --frequencypenalty | --modelContextLength | -n | --latest | -y | --youtube | --yt-dlp-args | -g | --language | -u | --scrape_url | -q | --scrape_question | -e | --seed | --address | --api-key | --search-location | --tool | --fallback | --test-judge | --image-compression | --think-start-tag | --think-end-tag | --notification-command)
     # No specific completion suggestions, user types the value
     return 0
     ;;
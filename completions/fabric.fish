@@ -69,7 +69,7 @@ function __fabric_register_completions
         complete -c $cmd -s F -l frequencypenalty -d "Set frequency penalty (default: 0.0)"
         complete -c $cmd -s m -l model -d "Choose model" -a "(__fabric_get_models)"
         complete -c $cmd -s V -l vendor -d "Specify vendor for chosen model (e.g., -V \"LM Studio\" -m openai/gpt-oss-20b)" -a "(__fabric_get_vendors)"
-        complete -c $cmd -l modelContextLength -d "Model context length (only affects ollama)"
+        complete -c $cmd -l modelContextLength -d "Model context length, used to fit sessions into the model and as the Ollama context size"
         complete -c $cmd -s o -l output -d "Output to file" -r
         complete -c $cmd -s n -l latest -d "Number of latest patterns to list (default: 0)"
         complete -c $cmd -s y -l youtube -d "YouTube video or play list URL to grab transcript, comments from it"
@@ -82,10 +82,36 @@ function __fabric_register_completions
         complete -c $cmd -s W -l wipesession -d "Wipe session" -a "(__fabric_get_sessions)"
         complete -c $cmd -l printcontext -d "Print context" -a "(__fabric_get_contexts)"
         complete -c $cmd -l printsession -d "Print session" -a "(__fabric_get_sessions)"
+        complete -c $cmd -l fork-session -d "Copy --session into a new session with this name, up to the message given with --at"
+        complete -c $cmd -l at -d "Number of messages --fork-session keeps, counted from 1 as --printsession shows them"
+        complete -c $cmd -l rewind -d "Drop the last N turns of --session"
+        complete -c $cmd -l edit-message -d "Replace user message N of --session with the input and drop the messages after it"
+        complete -c $cmd -l export-session -d "Export a session in the --format, to the output file or stdout" -a "(__fabric_get_sessions)"
+        complete -c $cmd -l import-session -d "Import an OpenAI chat JSON, ChatGPT export or JSONL file as the --session" -r
+        complete -c $cmd -l format -d "Session format of --export-session and --output-session" -a "md html jsonl openai"
+        complete -c $cmd -l search-db -d "Search the sessions, contexts and patterns and print the best matches"
+        complete -c $cmd -l migrate-storage -d "Copy the session and context files into the SQLite database of the sqlite storage backend"
+        complete -c $cmd -l create-key-file -r -d "Write a new key file that derives the encryption key from a passphrase read from stdin"
+        complete -c $cmd -l rekey -r -d "Encrypt the sessions and contexts again with the key in this file, or decrypt them with 'none'"
+        complete -c $cmd -l gc-sessions -d "Remove the sessions beyond the limits of the sessions section of config.yaml, or list them with --dry-run"
+        complete -c $cmd -l pin-session -d "Exempt a session from removal by the session limits" -a "(__fabric_get_sessions)"
+        complete -c $cmd -l unpin-session -d "Make a pinned session subject to the session limits again" -a "(__fabric_get_sessions)"
         complete -c $cmd -l address -d "The address to bind the REST API (default: :8080)"
         complete -c $cmd -l api-key -d "API key used to secure server routes"
         complete -c $cmd -l config -d "Path to YAML config file" -r -a "*.yaml *.yml"
         complete -c $cmd -l search-location -d "Set location for web search results (e.g., 'America/Los_Angeles')"
+        complete -c $cmd -l tool -d "Let the model call a tool from ~/.config/fabric/tools (repeatable, 'all' for every tool)"
+        complete -c $cmd -l fallback -d "Comma separated Vendor|model list to try in order when the model fails"
+        complete -c $cmd -l schema -d "JSON Schema file the response must match" -r -a "*.json"
+        complete -c $cmd -l chain -d "Run patterns in order, each on the output of the one before (list or pipeline YAML)" -a "(__fabric_get_patterns)"
+        complete -c $cmd -l compare -d "Send the request to several models at once and show their answers side by side (Vendor|model,...)"
+        complete -c $cmd -l compare-format -d "Output format of --compare" -a "markdown json"
+        complete -c $cmd -l test-pattern -d "Run the test fixtures of a pattern" -a "(__fabric_get_patterns)"
+        complete -c $cmd -l test-mode -d "How --test-pattern gets its answers" -a "live record recorded mock"
+        complete -c $cmd -l test-judge -d "Vendor|model grading the rubrics of --test-pattern"
+        complete -c $cmd -l junit -d "Write the results of --test-pattern as JUnit XML" -r
+        complete -c $cmd -l lint-patterns -d "Check patterns for undefined variables, unknown plugins and extensions and a missing {{input}}"
+        complete -c $cmd -l lint-shadowed -d "With --lint-patterns, also warn about custom patterns that replace built-in ones"
         complete -c $cmd -l image-file -d "Save generated image to specified file path (e.g., 'output.png')" -r -a "*.png *.webp *.jpeg *.jpg"
         complete -c $cmd -l image-size -d "Image dimensions: 1024x1024, 1536x1024, 1024x1536, auto (default: auto)" -a "1024x1024 1536x1024 1024x1536 auto"
         complete -c $cmd -l image-quality -d "Image quality: low, medium, high, auto (default: auto)" -a "low medium high auto"
@@ -103,6 +129,7 @@ function __fabric_register_completions
         complete -c $cmd -l notification-command -d "Custom command to run for notifications (overrides built-in notifications)"
 
         # Boolean flags (no arguments)
+        complete -c $cmd -l refresh-context -d "Run the refresh command of the context definition of --context before it is read"
         complete -c $cmd -s S -l setup -d "Run setup for all reconfigurable parts of fabric"
         complete -c $cmd -s s -l stream -d "Stream"
         complete -c $cmd -s r -l raw -d "Use the defaults of the model without sending chat options. Only affects OpenAI-compatible providers. Anthropic models always use smart parameter selection to comply with model-specific requirements."
@@ -110,9 +137,12 @@ function __fabric_register_completions
         complete -c $cmd -s L -l listmodels -d "List all available models"
         complete -c $cmd -s x -l listcontexts -d "List all contexts"
         complete -c $cmd -s X -l listsessions -d "List all sessions"
+        complete -c $cmd -l long -d "With --listsessions, show the message count, size and last modification of each session; with --listpatterns, the tags, model and description of each pattern"
+        complete -c $cmd -l pattern-vars -d "Print the variables a pattern takes, with their types, values and defaults" -a "(__fabric_get_patterns)"
         complete -c $cmd -s U -l updatepatterns -d "Update patterns"
         complete -c $cmd -s c -l copy -d "Copy to clipboard"
         complete -c $cmd -l output-session -d "Output the entire session to the output file"
+        complete -c $cmd -l regenerate -d "Get a new answer to the last request of --session in place of the one it has"
         complete -c $cmd -s d -l changeDefaultModel -d "Change default model"
         complete -c $cmd -l playlist -d "Prefer playlist over video if both ids are present in the URL"
         complete -c $cmd -l transcript -d "Grab transcript from YouTube video and send to chat"
@@ -125,6 +155,9 @@ function __fabric_register_completions
        complete -c $cmd -l no-variable-replacement -d "Disable pattern variable replacement"
        complete -c $cmd -l dry-run -d "Show what would be sent to the model without actually sending it"
         complete -c $cmd -l search -d "Enable web search tool for supported models (Anthropic, OpenAI, Gemini)"
+        complete -c $cmd -l usage -d "Print token usage and cost of the request to stderr"
+        complete -c $cmd -l no-cache -d "Do not answer from or write to the response cache"
+        complete -c $cmd -l cache-stats -d "Print statistics of the response cache"
         complete -c $cmd -l serve -d "Serve the Fabric Rest API"
         complete -c $cmd -l serveOllama -d "Serve the Fabric Rest API with ollama endpoints"
         complete -c $cmd -l version -d "Print current version"
@@ -423,6 +423,10 @@ const docTemplate = `{
                 "patternName": {
                     "type": "string"
                 },
+                "sessionName": {
+                    "description": "Optional session to continue and save the answer to",
+                    "type": "string"
+                },
                 "strategyName": {
                     "description": "Optional strategy name",
                     "type": "string"
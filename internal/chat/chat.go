@@ -51,6 +51,20 @@ type ToolCall struct {
 	Function FunctionCall `json:"function"`
 }
 
+// FunctionDefinition describes a function the model may call. Parameters is a
+// JSON Schema object describing the arguments.
+type FunctionDefinition struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Strict      bool           `json:"strict,omitempty"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+type Tool struct {
+	Type     ToolType            `json:"type"`
+	Function *FunctionDefinition `json:"function,omitempty"`
+}
+
 type ChatCompletionMessage struct {
 	Role             string            `json:"role"`
 	Content          string            `json:"content,omitempty"`
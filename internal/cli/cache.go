@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/danielmiessler/fabric/internal/core"
+	"github.com/danielmiessler/fabric/internal/i18n"
+	debuglog "github.com/danielmiessler/fabric/internal/log"
+	"github.com/danielmiessler/fabric/internal/plugins/ai"
+)
+
+// newResponseCache returns the response cache in the cache directory of the
+// fabric config directory.
+func newResponseCache(registry *core.PluginRegistry, currentFlags *Flags) *ai.ResponseCache {
+	return ai.NewResponseCache(filepath.Join(registry.Db.Dir, "cache"), currentFlags.Cache)
+}
+
+// flushResponseCache writes the counters of the response cache when fabric is
+// done. They only serve the report, so failing to write them is not an error.
+func flushResponseCache(cache *ai.ResponseCache) {
+	if err := cache.Flush(); err != nil {
+		debuglog.Debug(debuglog.Basic, "Could not update the cache counters: %v\n", err)
+	}
+}
+
+// printCacheStats reports what the response cache holds and how often it answered.
+func printCacheStats(w io.Writer, cache *ai.ResponseCache, enabled bool) (err error) {
+	var stats ai.CacheStats
+	if stats, err = cache.Stats(); err != nil {
+		return
+	}
+	config := cache.Config()
+
+	state := i18n.T("cache_disabled")
+	if enabled {
+		state = i18n.T("cache_enabled")
+	}
+	hitRate := 0.0
+	if requests := stats.Hits + stats.Misses; requests > 0 {
+		hitRate = float64(stats.Hits) / float64(requests) * 100
+	}
+	fmt.Fprintf(w, i18n.T("cache_stats_dir")+"\n", cache.Dir(), state, config.TTL)
+	fmt.Fprintf(w, i18n.T("cache_stats_entries")+"\n", stats.Entries, stats.Expired,
+		float64(stats.Bytes)/(1024*1024), config.MaxSizeMB)
+	fmt.Fprintf(w, i18n.T("cache_stats_hits")+"\n", stats.Hits, stats.Misses, hitRate)
+	fmt.Fprintf(w, i18n.T("cache_stats_saved")+"\n", stats.SavedInputTokens, stats.SavedOutputTokens)
+	return
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/core"
+	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/i18n"
+	"github.com/danielmiessler/fabric/internal/util"
+)
+
+// handleChainProcessing runs the patterns of --chain in one process, each on
+// the output of the one before, and prints the output of the last step.
+func handleChainProcessing(currentFlags *Flags, registry *core.PluginRegistry, messageTools string) (err error) {
+	if currentFlags.Pattern != "" {
+		return fmt.Errorf("%s", i18n.T("chain_pattern_conflict"))
+	}
+	if messageTools != "" {
+		currentFlags.AppendMessage(messageTools)
+	}
+
+	var pipeline *core.Pipeline
+	if ext := strings.ToLower(currentFlags.Chain); strings.HasSuffix(ext, ".yaml") || strings.HasSuffix(ext, ".yml") {
+		var pipelinePath string
+		if pipelinePath, err = util.GetAbsolutePath(currentFlags.Chain); err != nil {
+			return
+		}
+		if pipeline, err = core.LoadPipeline(pipelinePath); err != nil {
+			return
+		}
+	} else if pipeline, err = core.ParseChain(currentFlags.Chain); err != nil {
+		return
+	}
+
+	var chatReq *domain.ChatRequest
+	if chatReq, err = currentFlags.BuildChatRequest(strings.Join(os.Args[1:], " ")); err != nil {
+		return
+	}
+	if chatReq.Language == "" {
+		chatReq.Language = registry.Language.DefaultLanguage.Value
+	}
+	var chatOptions *domain.ChatOptions
+	if chatOptions, err = currentFlags.BuildChatOptions(); err != nil {
+		return
+	}
+
+	runner := core.NewPipelineRunner(registry.Db, func(step *core.PipelineStep) (*core.Chatter, error) {
+		return chainStepChatter(currentFlags, registry, step)
+	})
+	runner.Stream = currentFlags.Stream
+	runner.Sink = core.NewTextSink(os.Stdout, chatOptions)
+	runner.OnStep = func(index int, result *core.StepResult) {
+		printStepTiming(index, len(pipeline.Steps), result)
+	}
+
+	// Ctrl-C stops the running step
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	results, err := runner.Run(ctx, pipeline, chatReq, chatOptions)
+	stop()
+	if err != nil {
+		return
+	}
+
+	last := results[len(results)-1]
+	result := last.Output
+	if !currentFlags.Stream || currentFlags.SuppressThink || last.Skipped {
+		fmt.Println(result)
+	}
+
+	if currentFlags.Copy {
+		if err = CopyToClipboard(result); err != nil {
+			return
+		}
+	}
+	if currentFlags.Output != "" {
+		err = CreateOutputFile(result, currentFlags.Output)
+	}
+	return
+}
+
+// chainStepChatter returns the chatter for a step. A step without a model
+// uses the model of FABRIC_MODEL_<PATTERN> or of the front matter of its
+// pattern, then the one of the command line.
+func chainStepChatter(currentFlags *Flags, registry *core.PluginRegistry, step *core.PipelineStep) (*core.Chatter, error) {
+	vendor, model := step.Vendor, step.Model
+	if model == "" {
+		var patternVendor string
+		if patternVendor, model = registry.PatternModel(step.Pattern); patternVendor != "" {
+			vendor = patternVendor
+		}
+	}
+	if model == "" {
+		model = currentFlags.Model
+		if vendor == "" {
+			vendor = currentFlags.Vendor
+		}
+	}
+	if before, after, found := strings.Cut(model, "|"); found {
+		vendor, model = before, after
+	}
+	return registry.GetChatter(model, currentFlags.ModelContextLength, vendor, "", false, currentFlags.DryRun)
+}
+
+// printStepTiming reports a finished step on stderr, so it stays out of the output.
+func printStepTiming(index, total int, result *core.StepResult) {
+	if result.Skipped {
+		fmt.Fprintf(os.Stderr, i18n.T("chain_step_skipped")+"\n", index+1, total, result.Pattern)
+		return
+	}
+	fmt.Fprintf(os.Stderr, i18n.T("chain_step_done")+"\n", index+1, total, result.Pattern,
+		result.Vendor, result.Model, result.Duration.Round(time.Millisecond))
+}
@@ -1,10 +1,13 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"github.com/danielmiessler/fabric/internal/core"
@@ -20,16 +23,12 @@ func handleChatProcessing(currentFlags *Flags, registry *core.PluginRegistry, me
 	if messageTools != "" {
 		currentFlags.AppendMessage(messageTools)
 	}
-	// Check for pattern-specific model via environment variable
+	// Check for pattern-specific model via environment variable or front matter
 	if currentFlags.Pattern != "" && currentFlags.Model == "" {
-		envVar := "FABRIC_MODEL_" + strings.ToUpper(strings.ReplaceAll(currentFlags.Pattern, "-", "_"))
-		if modelSpec := os.Getenv(envVar); modelSpec != "" {
-			parts := strings.SplitN(modelSpec, "|", 2)
-			if len(parts) == 2 {
-				currentFlags.Vendor = parts[0]
-				currentFlags.Model = parts[1]
-			} else {
-				currentFlags.Model = modelSpec
+		if vendor, model := registry.PatternModel(currentFlags.Pattern); model != "" {
+			currentFlags.Model = model
+			if vendor != "" {
+				currentFlags.Vendor = vendor
 			}
 		}
 	}
@@ -40,6 +39,16 @@ func handleChatProcessing(currentFlags *Flags, registry *core.PluginRegistry, me
 		return
 	}
 
+	// Check for pattern-specific fallbacks the same way, then in config.yaml
+	if currentFlags.Pattern != "" && currentFlags.Fallback == "" {
+		currentFlags.Fallback = registry.PatternFallback(currentFlags.Pattern)
+	}
+	if currentFlags.Fallback != "" && !currentFlags.DryRun {
+		if chatter.Fallbacks, err = registry.GetFallbacks(currentFlags.Fallback); err != nil {
+			return
+		}
+	}
+
 	var session *fsdb.Session
 	var chatReq *domain.ChatRequest
 	if chatReq, err = currentFlags.BuildChatRequest(strings.Join(os.Args[1:], " ")); err != nil {
@@ -53,6 +62,17 @@ func handleChatProcessing(currentFlags *Flags, registry *core.PluginRegistry, me
 	if chatOptions, err = currentFlags.BuildChatOptions(); err != nil {
 		return
 	}
+	chatter.Sink = core.NewTextSink(os.Stdout, chatOptions)
+
+	if len(currentFlags.Tools) > 0 {
+		toolNames := currentFlags.Tools
+		if slices.Contains(toolNames, "all") {
+			toolNames = nil
+		}
+		if chatOptions.Tools, err = registry.Tools.Definitions(toolNames...); err != nil {
+			return
+		}
+	}
 
 	// Check if user is requesting audio output or using a TTS model
 	isAudioOutput := currentFlags.Output != "" && IsAudioFormat(currentFlags.Output)
@@ -87,7 +107,11 @@ func handleChatProcessing(currentFlags *Flags, registry *core.PluginRegistry, me
 		chatOptions.AudioFormat = "wav" // Default to WAV format
 	}
 
-	if session, err = chatter.Send(chatReq, chatOptions); err != nil {
+	// Ctrl-C stops the generation; the chatter keeps the partial response in the session
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	session, err = chatter.Send(ctx, chatReq, chatOptions)
+	stop()
+	if err != nil {
 		return
 	}
 
@@ -113,8 +137,11 @@ func handleChatProcessing(currentFlags *Flags, registry *core.PluginRegistry, me
 	// if the output flag is set, create an output file
 	if currentFlags.Output != "" {
 		if currentFlags.OutputSession {
-			sessionAsString := session.String()
-			err = CreateOutputFile(sessionAsString, currentFlags.Output)
+			var exported []byte
+			if exported, err = session.Export(sessionFormat(currentFlags)); err != nil {
+				return
+			}
+			err = CreateOutputFile(string(exported), currentFlags.Output)
 		} else {
 			// For TTS models, we need to handle audio output differently
 			if isTTSModel && isAudioOutput {
@@ -133,6 +160,10 @@ func handleChatProcessing(currentFlags *Flags, registry *core.PluginRegistry, me
 		}
 	}
 
+	if currentFlags.ShowUsage {
+		printUsage(os.Stderr, session, chatReq.PatternName, chatOptions.Model)
+	}
+
 	// Send notification if requested
 	if chatOptions.Notification {
 		if err = sendNotification(chatOptions, chatReq.PatternName, result); err != nil {
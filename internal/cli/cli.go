@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/danielmiessler/fabric/internal/core"
 	"github.com/danielmiessler/fabric/internal/i18n"
@@ -52,6 +53,35 @@ func Cli(version string) (err error) {
 	// Configure OpenAI Responses API setting based on CLI flag
 	if registry != nil {
 		configureOpenAIResponsesAPI(registry, currentFlags.DisableResponsesAPI)
+		// Retries and rate limits are configured in the retry section of config.yaml
+		registry.Retry = currentFlags.Retry
+		// Long sessions are fitted to the model as set in the context_window section of config.yaml
+		if err = currentFlags.ContextWindow.Validate(); err != nil {
+			return
+		}
+		registry.ContextWindow = currentFlags.ContextWindow
+		// Patterns fall back to the models of the pattern_fallbacks section of config.yaml
+		registry.PatternFallbacks = currentFlags.PatternFallbacks
+		// Context definitions run their refresh command only when asked to
+		registry.Db.Contexts.Refresh = currentFlags.RefreshContext
+		// The response cache is opt-in, in the cache section of config.yaml. It
+		// would keep the answers of encrypted sessions in plaintext.
+		if currentFlags.Cache.Enabled && !currentFlags.NoCache && registry.Db.Encrypted() {
+			debuglog.Log("The response cache is off while sessions and contexts are encrypted, it would keep answers unencrypted\n")
+		} else if currentFlags.Cache.Enabled && !currentFlags.NoCache {
+			registry.Cache = newResponseCache(registry, currentFlags)
+			defer flushResponseCache(registry.Cache)
+		}
+		// Old sessions are removed as set in the sessions section of config.yaml
+		if err = currentFlags.Sessions.Validate(); err != nil {
+			return
+		}
+		// The session of --session is kept, since this command is about to use it.
+		if currentFlags.Sessions.GCOnStartup && currentFlags.Sessions.IsSet() && !currentFlags.GCSessions {
+			if _, gcErr := registry.Db.Sessions.Collect(currentFlags.Sessions, time.Now(), false, currentFlags.Session); gcErr != nil {
+				debuglog.Log("Could not remove old sessions: %v\n", gcErr)
+			}
+		}
 	}
 
 	// Handle setup and server commands
@@ -75,11 +105,26 @@ func Cli(version string) (err error) {
 		return
 	}
 
+	// Handle session forking, rewinding and editing
+	if handled, err = handleSessionCommands(currentFlags, registry.Db); err != nil || handled {
+		return
+	}
+
 	// Handle extension commands
 	if handled, err = handleExtensionCommands(currentFlags, registry); err != nil || handled {
 		return
 	}
 
+	// Handle pattern tests and lints
+	if currentFlags.TestPattern != "" {
+		err = handleTestPattern(currentFlags, registry)
+		return
+	}
+	if currentFlags.LintPatterns {
+		err = handleLintPatterns(currentFlags, registry)
+		return
+	}
+
 	// Handle transcription if specified
 	if currentFlags.TranscribeFile != "" {
 		var transcriptionMessage string
@@ -110,6 +155,14 @@ func Cli(version string) (err error) {
 	}
 
 	// Handle chat processing
+	if currentFlags.Compare != "" {
+		err = handleCompareProcessing(currentFlags, registry, messageTools)
+		return
+	}
+	if currentFlags.Chain != "" {
+		err = handleChainProcessing(currentFlags, registry, messageTools)
+		return
+	}
 	err = handleChatProcessing(currentFlags, registry, messageTools)
 	return
 }
@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/core"
+	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/i18n"
+)
+
+// handleCompareProcessing sends the request to every model of --compare at
+// the same time and prints their answers side by side.
+func handleCompareProcessing(currentFlags *Flags, registry *core.PluginRegistry, messageTools string) (err error) {
+	if currentFlags.Chain != "" {
+		return fmt.Errorf("%s", i18n.T("compare_chain_conflict"))
+	}
+	if currentFlags.CompareFormat != "markdown" && currentFlags.CompareFormat != "json" {
+		return fmt.Errorf(i18n.T("compare_invalid_format"), currentFlags.CompareFormat)
+	}
+	if messageTools != "" {
+		currentFlags.AppendMessage(messageTools)
+	}
+
+	var candidates []core.Fallback
+	if candidates, err = registry.GetVendorModels(currentFlags.Compare, currentFlags.DryRun); err != nil {
+		return
+	}
+	if len(candidates) == 0 {
+		return fmt.Errorf("%s", i18n.T("compare_no_models"))
+	}
+
+	// The chatter of the first model builds the session shared by all of them
+	var chatter *core.Chatter
+	if chatter, err = registry.GetChatter(candidates[0].Model, currentFlags.ModelContextLength,
+		candidates[0].Vendor.GetName(), currentFlags.Strategy, false, currentFlags.DryRun); err != nil {
+		return
+	}
+
+	var chatReq *domain.ChatRequest
+	if chatReq, err = currentFlags.BuildChatRequest(strings.Join(os.Args[1:], " ")); err != nil {
+		return
+	}
+	if chatReq.Language == "" {
+		chatReq.Language = registry.Language.DefaultLanguage.Value
+	}
+	var chatOptions *domain.ChatOptions
+	if chatOptions, err = currentFlags.BuildChatOptions(); err != nil {
+		return
+	}
+
+	// Ctrl-C stops all models
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	results, err := chatter.Compare(ctx, chatReq, chatOptions, candidates)
+	stop()
+	if err != nil {
+		return
+	}
+
+	var result string
+	if currentFlags.CompareFormat == "json" {
+		if result, err = formatCompareJSON(results); err != nil {
+			return
+		}
+	} else {
+		result = formatCompareMarkdown(results)
+	}
+	fmt.Println(result)
+
+	if currentFlags.Copy {
+		if err = CopyToClipboard(result); err != nil {
+			return
+		}
+	}
+	if currentFlags.Output != "" {
+		err = CreateOutputFile(result, currentFlags.Output)
+	}
+	return
+}
+
+// formatCompareMarkdown renders the results as a table with a column per model.
+func formatCompareMarkdown(results []*core.CompareResult) string {
+	var b strings.Builder
+	rows := [][]string{{""}, {"**" + i18n.T("compare_latency") + "**"}, {"**" + i18n.T("compare_tokens") + "**"},
+		{"**" + i18n.T("compare_cost") + "**"}, {"**" + i18n.T("compare_response") + "**"}}
+	for _, result := range results {
+		latency, tokens, cost := result.Latency.Round(time.Millisecond).String(), "-", "-"
+		if result.Usage != nil {
+			tokens = fmt.Sprintf("%d / %d", result.Usage.InputTokens, result.Usage.OutputTokens)
+			if result.Usage.Cost > 0 {
+				cost = fmt.Sprintf("$%.6f", result.Usage.Cost)
+			}
+		}
+		response := result.Content
+		if result.Err != nil {
+			response = fmt.Sprintf(i18n.T("compare_error"), result.Err)
+		}
+		rows[0] = append(rows[0], result.Vendor+"|"+result.Model)
+		rows[1] = append(rows[1], latency)
+		rows[2] = append(rows[2], tokens)
+		rows[3] = append(rows[3], cost)
+		rows[4] = append(rows[4], response)
+	}
+
+	for i, row := range rows {
+		b.WriteString("|")
+		for _, cell := range row {
+			b.WriteString(" " + escapeTableCell(cell) + " |")
+		}
+		b.WriteString("\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", len(row)) + "\n")
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// escapeTableCell keeps text on one line of a Markdown table.
+func escapeTableCell(text string) string {
+	text = strings.ReplaceAll(strings.TrimSpace(text), "|", `\|`)
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\n", "<br>")
+}
+
+type compareOutput struct {
+	Vendor    string                `json:"vendor"`
+	Model     string                `json:"model"`
+	Content   string                `json:"content"`
+	LatencyMs int64                 `json:"latency_ms"`
+	Usage     *domain.UsageMetadata `json:"usage,omitempty"`
+	Error     string                `json:"error,omitempty"`
+}
+
+// formatCompareJSON renders the results as a JSON array, one object per model.
+func formatCompareJSON(results []*core.CompareResult) (ret string, err error) {
+	outputs := make([]compareOutput, 0, len(results))
+	for _, result := range results {
+		output := compareOutput{
+			Vendor:    result.Vendor,
+			Model:     result.Model,
+			Content:   result.Content,
+			LatencyMs: result.Latency.Milliseconds(),
+			Usage:     result.Usage,
+		}
+		if result.Err != nil {
+			output.Error = result.Err.Error()
+		}
+		outputs = append(outputs, output)
+	}
+	var data []byte
+	if data, err = json.MarshalIndent(outputs, "", "  "); err == nil {
+		ret = string(data)
+	}
+	return
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/core"
+	"github.com/danielmiessler/fabric/internal/domain"
+)
+
+func compareResults() []*core.CompareResult {
+	return []*core.CompareResult{
+		{Vendor: "OpenAI", Model: "gpt-4o", Content: "line one\nline | two", Latency: 1500 * time.Millisecond,
+			Usage: &domain.UsageMetadata{InputTokens: 10, OutputTokens: 20, Cost: 0.0005}},
+		{Vendor: "Ollama", Model: "qwen3", Latency: 20 * time.Millisecond, Err: errors.New("connection refused")},
+	}
+}
+
+func TestFormatCompareMarkdown(t *testing.T) {
+	lines := strings.Split(formatCompareMarkdown(compareResults()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Expected a header, a separator and four rows, got %q", lines)
+	}
+	if lines[0] != `|  | OpenAI\|gpt-4o | Ollama\|qwen3 |` {
+		t.Errorf("Unexpected header %q", lines[0])
+	}
+	if !strings.Contains(lines[3], "10 / 20") || !strings.Contains(lines[4], "$0.000500") {
+		t.Errorf("Expected the tokens and cost of the first model, got %q and %q", lines[3], lines[4])
+	}
+	if !strings.Contains(lines[5], `line one<br>line \| two`) || !strings.Contains(lines[5], "connection refused") {
+		t.Errorf("Expected the responses on one line and the error, got %q", lines[5])
+	}
+}
+
+func TestFormatCompareJSON(t *testing.T) {
+	output, err := formatCompareJSON(compareResults())
+	if err != nil {
+		t.Fatalf("formatCompareJSON failed: %v", err)
+	}
+	var parsed []map[string]any
+	if err := json.Unmarshal([]byte(output), &parsed); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(parsed) != 2 || parsed[0]["latency_ms"] != float64(1500) || parsed[1]["error"] != "connection refused" {
+		t.Errorf("Unexpected output %v", parsed)
+	}
+	if _, ok := parsed[1]["usage"]; ok {
+		t.Error("Expected no usage for the failed model")
+	}
+}
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/danielmiessler/fabric/internal/i18n"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+// rekeyNone as the --rekey key file saves the sessions and contexts without encryption.
+const rekeyNone = "none"
+
+// createKeyFile asks for a passphrase on stdin and writes the key file of the --create-key-file, from which it derives the key.
+func createKeyFile(currentFlags *Flags) (err error) {
+	var passphrase string
+	if passphrase, err = readPassphrase(); err != nil {
+		return
+	}
+	if err = fsdb.CreateEncryptionKeyFile(currentFlags.CreateKeyFile, passphrase); err != nil {
+		return
+	}
+	fmt.Printf(i18n.T("encryption_key_file_created")+"\n", currentFlags.CreateKeyFile)
+	return
+}
+
+// readPassphrase asks for a passphrase on stdin.
+func readPassphrase() (ret string, err error) {
+	fmt.Fprint(os.Stderr, i18n.T("encryption_passphrase_prompt"))
+	if ret, err = bufio.NewReader(os.Stdin).ReadString('\n'); err != nil && ret == "" {
+		return
+	}
+	return strings.TrimRight(ret, "\r\n"), nil
+}
+
+// rekey saves the sessions and contexts again with the key in the --rekey file, or without encryption.
+// The passphrase of a key file made by --create-key-file is that of ENCRYPTION_PASSPHRASE, or else read from stdin.
+func rekey(currentFlags *Flags, fabricDb *fsdb.Db) (err error) {
+	var key []byte
+	if currentFlags.Rekey != rekeyNone {
+		key, err = fsdb.ReadEncryptionKeyFile(currentFlags.Rekey, os.Getenv("ENCRYPTION_PASSPHRASE"))
+		if errors.Is(err, fsdb.ErrNoEncryptionPassphrase) {
+			var passphrase string
+			if passphrase, err = readPassphrase(); err != nil {
+				return
+			}
+			key, err = fsdb.ReadEncryptionKeyFile(currentFlags.Rekey, passphrase)
+		}
+		if err != nil {
+			return
+		}
+	}
+	var sessions, contexts int
+	if sessions, contexts, err = fabricDb.Rekey(key); err != nil {
+		return
+	}
+	if key == nil {
+		fmt.Printf(i18n.T("storage_decrypted")+"\n", sessions, contexts)
+	} else {
+		fmt.Printf(i18n.T("storage_rekeyed")+"\n", sessions, contexts, currentFlags.Rekey)
+	}
+	return
+}
@@ -13,9 +13,12 @@ import (
 	"strings"
 
 	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/core"
 	"github.com/danielmiessler/fabric/internal/domain"
 	"github.com/danielmiessler/fabric/internal/i18n"
 	debuglog "github.com/danielmiessler/fabric/internal/log"
+	"github.com/danielmiessler/fabric/internal/plugins/ai"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
 	"github.com/danielmiessler/fabric/internal/util"
 	"github.com/jessevdk/go-flags"
 	"golang.org/x/text/language"
@@ -29,6 +32,7 @@ type Flags struct {
 	Pattern                         string               `short:"p" long:"pattern" yaml:"pattern" description:"Choose a pattern from the available patterns" default:""`
 	PatternVariables                map[string]string    `short:"v" long:"variable" description:"Values for pattern variables, e.g. -v=#role:expert -v=#points:30"`
 	Context                         string               `short:"C" long:"context" description:"Choose a context from the available contexts" default:""`
+	RefreshContext                  bool                 `long:"refresh-context" description:"Run the refresh command of the context definition of --context before it is read"`
 	Session                         string               `long:"session" description:"Choose a session from the available sessions"`
 	Attachments                     []string             `short:"a" long:"attachment" description:"Attachment path or URL (e.g. for OpenAI image recognition messages)"`
 	Setup                           bool                 `short:"S" long:"setup" description:"Run setup for all reconfigurable parts of fabric"`
@@ -42,12 +46,14 @@ type Flags struct {
 	ListAllModels                   bool                 `short:"L" long:"listmodels" description:"List all available models"`
 	ListAllContexts                 bool                 `short:"x" long:"listcontexts" description:"List all contexts"`
 	ListAllSessions                 bool                 `short:"X" long:"listsessions" description:"List all sessions"`
+	Long                            bool                 `long:"long" description:"With --listsessions, show the message count, size and last modification of each session; with --listpatterns, the tags, model and description of each pattern"`
+	PatternVars                     string               `long:"pattern-vars" description:"Print the variables a pattern takes, with their types, values and defaults"`
 	UpdatePatterns                  bool                 `short:"U" long:"updatepatterns" description:"Update patterns"`
 	Message                         string               `hidden:"true" description:"Messages to send to chat"`
 	Copy                            bool                 `short:"c" long:"copy" description:"Copy to clipboard"`
 	Model                           string               `short:"m" long:"model" yaml:"model" description:"Choose model"`
 	Vendor                          string               `short:"V" long:"vendor" yaml:"vendor" description:"Specify vendor for the selected model (e.g., -V \"LM Studio\" -m openai/gpt-oss-20b)"`
-	ModelContextLength              int                  `long:"modelContextLength" yaml:"modelContextLength" description:"Model context length (only affects ollama)"`
+	ModelContextLength              int                  `long:"modelContextLength" yaml:"modelContextLength" description:"Model context length, used to fit sessions into the model and as the Ollama context size"`
 	Output                          string               `short:"o" long:"output" description:"Output to file" default:""`
 	OutputSession                   bool                 `long:"output-session" description:"Output the entire session (also a temporary one) to the output file"`
 	LatestPatterns                  string               `short:"n" long:"latest" description:"Number of latest patterns to list" default:"0"`
@@ -67,6 +73,14 @@ type Flags struct {
 	WipeSession                     string               `short:"W" long:"wipesession" description:"Wipe session"`
 	PrintContext                    string               `long:"printcontext" description:"Print context"`
 	PrintSession                    string               `long:"printsession" description:"Print session"`
+	ForkSession                     string               `long:"fork-session" description:"Copy --session into a new session with this name, up to the message given with --at"`
+	ForkAt                          int                  `long:"at" description:"Number of messages --fork-session keeps, counted from 1 as --printsession shows them (default: all)"`
+	Rewind                          int                  `long:"rewind" description:"Drop the last N turns of --session"`
+	EditMessage                     int                  `long:"edit-message" description:"Replace user message N of --session with the input and drop the messages after it"`
+	Regenerate                      bool                 `long:"regenerate" description:"Get a new answer to the last request of --session in place of the one it has"`
+	ExportSession                   string               `long:"export-session" description:"Export a session in the --format, to the output file or stdout"`
+	ImportSession                   string               `long:"import-session" description:"Import an OpenAI chat JSON, ChatGPT export or JSONL file as the --session, named after the file by default"`
+	Format                          string               `long:"format" description:"Session format of --export-session and --output-session: md, html, jsonl or openai (default: md). --import-session reads openai or jsonl and detects which by default"`
 	HtmlReadability                 bool                 `long:"readability" description:"Convert HTML input into a clean, readable view"`
 	InputHasVars                    bool                 `long:"input-has-vars" description:"Apply variables to user input"`
 	NoVariableReplacement           bool                 `long:"no-variable-replacement" description:"Disable pattern variable replacement"`
@@ -86,6 +100,28 @@ type Flags struct {
 	ShellCompleteOutput             bool                 `long:"shell-complete-list" description:"Output raw list without headers/formatting (for shell completion)"`
 	Search                          bool                 `long:"search" description:"Enable web search tool for supported models (Anthropic, OpenAI, Gemini)"`
 	SearchLocation                  string               `long:"search-location" description:"Set location for web search results (e.g., 'America/Los_Angeles')"`
+	Tools                           []string             `long:"tool" description:"Let the model call a tool from ~/.config/fabric/tools (repeatable, 'all' for every tool)"`
+	ShowUsage                       bool                 `long:"usage" yaml:"usage" description:"Print token usage and cost of the request to stderr"`
+	NoCache                         bool                 `long:"no-cache" description:"Do not answer from or write to the response cache"`
+	CacheStats                      bool                 `long:"cache-stats" description:"Print statistics of the response cache"`
+	SearchDb                        string               `long:"search-db" description:"Search the sessions, contexts and patterns and print the best matches"`
+	MigrateStorage                  bool                 `long:"migrate-storage" description:"Copy the session and context files into the SQLite database of the sqlite storage backend"`
+	CreateKeyFile                   string               `long:"create-key-file" description:"Write a new key file that derives the encryption key from a passphrase read from stdin"`
+	Rekey                           string               `long:"rekey" description:"Encrypt the sessions and contexts again with the key in this file, or decrypt them with 'none'"`
+	GCSessions                      bool                 `long:"gc-sessions" description:"Remove the sessions beyond the limits of the sessions section of config.yaml, or list them with --dry-run"`
+	PinSession                      string               `long:"pin-session" description:"Exempt a session from removal by the session limits"`
+	UnpinSession                    string               `long:"unpin-session" description:"Make a pinned session subject to the session limits again"`
+	Fallback                        string               `long:"fallback" yaml:"fallback" description:"Comma separated Vendor|model list to try in order when the model fails with a rate limit, server error or timeout"`
+	Schema                          string               `long:"schema" description:"JSON Schema file the response must match, replaces the schema.json of the pattern"`
+	Chain                           string               `long:"chain" description:"Run patterns in order, each on the output of the one before: a comma separated list or a pipeline YAML file"`
+	Compare                         string               `long:"compare" description:"Send the request to several models at once and show their answers side by side, e.g. OpenAI|gpt-4o,Ollama|qwen3"`
+	CompareFormat                   string               `long:"compare-format" description:"Output format of --compare: markdown or json" default:"markdown"`
+	TestPattern                     string               `long:"test-pattern" description:"Run the test fixtures of a pattern, the tests/*.yaml files in its directory"`
+	TestMode                        string               `long:"test-mode" description:"Run --test-pattern live, record (live, saving the answers as golden files), recorded (on the golden files) or mock (on the mock responses of the fixtures)" default:"live"`
+	TestJudge                       string               `long:"test-judge" description:"Vendor|model grading the rubrics of --test-pattern, by default the tested model"`
+	JUnit                           string               `long:"junit" description:"Write the results of --test-pattern to this file as JUnit XML"`
+	LintPatterns                    bool                 `long:"lint-patterns" description:"Check the patterns named after the flags, or all patterns, for undefined variables, unknown plugins and extensions and a missing {{input}}"`
+	LintShadowed                    bool                 `long:"lint-shadowed" description:"With --lint-patterns, also warn about custom patterns that replace built-in ones"`
 	ImageFile                       string               `long:"image-file" description:"Save generated image to specified file path (e.g., 'output.png')"`
 	ImageSize                       string               `long:"image-size" description:"Image dimensions: 1024x1024, 1536x1024, 1024x1536, auto (default: auto)"`
 	ImageQuality                    string               `long:"image-quality" description:"Image quality: low, medium, high, auto (default: auto)"`
@@ -105,6 +141,16 @@ type Flags struct {
 	NotificationCommand             string               `long:"notification-command" yaml:"notificationCommand" description:"Custom command to run for notifications (overrides built-in notifications)"`
 	Thinking                        domain.ThinkingLevel `long:"thinking" yaml:"thinking" description:"Set reasoning/thinking level (e.g., off, low, medium, high, or numeric tokens for Anthropic or Google Gemini)"`
 	Debug                           int                  `long:"debug" description:"Set debug level (0=off, 1=basic, 2=detailed, 3=trace)" default:"0"`
+	// The sections of config.yaml that have no flag
+	Retry            ai.RetryConfig           `yaml:"retry" no-flag:"true"`
+	Cache            ai.CacheConfig           `yaml:"cache" no-flag:"true"`
+	ContextWindow    core.ContextWindowConfig `yaml:"context_window" no-flag:"true"`
+	Sessions         fsdb.SessionRetention    `yaml:"sessions" no-flag:"true"`
+	PatternFallbacks map[string]string        `yaml:"pattern_fallbacks" no-flag:"true"`
+	// Args are the arguments after the flags; the last one is also the message
+	Args []string `no-flag:"true"`
+	// TemperatureSet tells that the temperature was set on the command line or in the config file
+	TemperatureSet bool `no-flag:"true"`
 }
 
 // Init Initialize flags. returns a Flags struct and an error
@@ -178,6 +224,8 @@ func Init() (ret *Flags, err error) {
 			return
 		}
 
+		ret.TemperatureSet = yamlFlags.TemperatureSet
+
 		// Apply YAML values where CLI flags weren't used
 		flagsVal := reflect.ValueOf(ret).Elem()
 		yamlVal := reflect.ValueOf(yamlFlags).Elem()
@@ -205,11 +253,14 @@ func Init() (ret *Flags, err error) {
 		}
 	}
 
+	ret.TemperatureSet = ret.TemperatureSet || usedFlags["temperature"]
+
 	// Handle stdin and messages
 	info, _ := os.Stdin.Stat()
 	pipedToStdin := (info.Mode() & os.ModeCharDevice) == 0
 
 	// Append positional arguments to the message (custom message)
+	ret.Args = args
 	if len(args) > 0 {
 		ret.Message = AppendMessage(ret.Message, args[len(args)-1])
 	}
@@ -309,6 +360,12 @@ func loadYAMLConfig(configPath string) (*Flags, error) {
 		return nil, fmt.Errorf(i18n.T("error_parsing_config_file"), err)
 	}
 
+	// The temperature of a config file wins over the front matter of patterns
+	var keys map[string]any
+	if yaml.Unmarshal(data, &keys) == nil {
+		_, config.TemperatureSet = keys["temperature"]
+	}
+
 	debuglog.Debug(debuglog.Detailed, "Config: %v\n", config)
 
 	return config, nil
@@ -439,6 +496,7 @@ func (o *Flags) BuildChatOptions() (ret *domain.ChatOptions, err error) {
 	ret = &domain.ChatOptions{
 		Model:               o.Model,
 		Temperature:         o.Temperature,
+		TemperatureSet:      o.TemperatureSet,
 		TopP:                o.TopP,
 		PresencePenalty:     o.PresencePenalty,
 		FrequencyPenalty:    o.FrequencyPenalty,
@@ -460,6 +518,16 @@ func (o *Flags) BuildChatOptions() (ret *domain.ChatOptions, err error) {
 		Notification:        o.Notification || o.NotificationCommand != "",
 		NotificationCommand: o.NotificationCommand,
 	}
+
+	if o.Schema != "" {
+		var schemaPath string
+		if schemaPath, err = util.GetAbsolutePath(o.Schema); err != nil {
+			return nil, err
+		}
+		if ret.Schema, err = domain.LoadSchema(schemaPath); err != nil {
+			return nil, err
+		}
+	}
 	return
 }
 
@@ -473,6 +541,7 @@ func (o *Flags) BuildChatRequest(Meta string) (ret *domain.ChatRequest, err erro
 		InputHasVars:          o.InputHasVars,
 		NoVariableReplacement: o.NoVariableReplacement,
 		Meta:                  Meta,
+		Regenerate:            o.Regenerate,
 	}
 
 	var message *chat.ChatCompletionMessage
@@ -535,7 +604,7 @@ func (o *Flags) AppendMessage(message string) {
 }
 
 func (o *Flags) IsChatRequest() (ret bool) {
-	ret = o.Message != "" || len(o.Attachments) > 0 || o.Context != "" || o.Session != "" || o.Pattern != ""
+	ret = o.Message != "" || len(o.Attachments) > 0 || o.Context != "" || o.Session != "" || o.Pattern != "" || o.Chain != "" || o.Compare != ""
 	return
 }
 
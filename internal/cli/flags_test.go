@@ -143,6 +143,7 @@ stream: true
 		flags, err := Init()
 		assert.NoError(t, err)
 		assert.Equal(t, 0.9, flags.Temperature)
+		assert.True(t, flags.TemperatureSet)
 		assert.Equal(t, "gpt-4", flags.Model)
 		assert.Equal(t, "analyze", flags.Pattern)
 		assert.True(t, flags.Stream)
@@ -157,12 +158,36 @@ stream: true
 		flags, err := Init()
 		assert.NoError(t, err)
 		assert.Equal(t, 0.7, flags.Temperature)
+		assert.True(t, flags.TemperatureSet)
 		assert.Equal(t, "gpt-3.5-turbo", flags.Model)
 		assert.Equal(t, "analyze", flags.Pattern) // unchanged from YAML
 		assert.True(t, flags.Stream)              // unchanged from YAML
 	})
 
-	// Test 3: Invalid YAML config
+	// Test 3: a temperature is set only when the CLI or the YAML has one
+	t.Run("Temperature set", func(t *testing.T) {
+		noTemperature := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(noTemperature, []byte("model: gpt-4\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		oldArgs := os.Args
+		defer func() { os.Args = oldArgs }()
+		os.Args = []string{"cmd", "--config", noTemperature}
+		flags, err := Init()
+		assert.NoError(t, err)
+		assert.False(t, flags.TemperatureSet)
+
+		os.Args = []string{"cmd", "--config", noTemperature, "-t", "0.7"}
+		flags, err = Init()
+		assert.NoError(t, err)
+		assert.True(t, flags.TemperatureSet)
+		opts, err := flags.BuildChatOptions()
+		assert.NoError(t, err)
+		assert.True(t, opts.TemperatureSet)
+	})
+
+	// Test 4: Invalid YAML config
 	t.Run("Invalid YAML config", func(t *testing.T) {
 		badConfig := `
 temperature: "not a float"
@@ -17,6 +17,7 @@ var flagDescriptionMap = map[string]string{
 	"pattern":                    "choose_pattern_from_available",
 	"variable":                   "pattern_variables_help",
 	"context":                    "choose_context_from_available",
+	"refresh-context":            "refresh_context",
 	"session":                    "choose_session_from_available",
 	"attachment":                 "attachment_path_or_url_help",
 	"setup":                      "run_setup_for_reconfigurable_parts",
@@ -30,6 +31,8 @@ var flagDescriptionMap = map[string]string{
 	"listmodels":                 "list_all_available_models",
 	"listcontexts":               "list_all_contexts",
 	"listsessions":               "list_all_sessions",
+	"long":                       "list_long",
+	"pattern-vars":               "pattern_vars",
 	"updatepatterns":             "update_patterns",
 	"copy":                       "copy_to_clipboard",
 	"model":                      "choose_model",
@@ -54,6 +57,14 @@ var flagDescriptionMap = map[string]string{
 	"wipesession":                "wipe_session",
 	"printcontext":               "print_context",
 	"printsession":               "print_session",
+	"fork-session":               "fork_session_into",
+	"at":                         "fork_session_at",
+	"rewind":                     "rewind_session_turns",
+	"edit-message":               "edit_session_message",
+	"regenerate":                 "regenerate_session_answer",
+	"export-session":             "export_session_in_format",
+	"import-session":             "import_session_from_file",
+	"format":                     "session_export_format",
 	"readability":                "convert_html_readability",
 	"input-has-vars":             "apply_variables_to_input",
 	"no-variable-replacement":    "disable_pattern_variable_replacement",
@@ -73,6 +84,28 @@ var flagDescriptionMap = map[string]string{
 	"shell-complete-list":        "output_raw_list_shell_completion",
 	"search":                     "enable_web_search_tool",
 	"search-location":            "set_location_web_search",
+	"tool":                       "enable_function_calling_tool",
+	"usage":                      "print_token_usage_and_cost",
+	"fallback":                   "fallback_vendors_and_models",
+	"schema":                     "json_schema_response_must_match",
+	"chain":                      "chain_patterns_or_pipeline",
+	"compare":                    "compare_models_side_by_side",
+	"compare-format":             "compare_output_format",
+	"test-pattern":               "test_pattern_fixtures",
+	"test-mode":                  "test_pattern_mode",
+	"test-judge":                 "test_pattern_judge",
+	"junit":                      "test_pattern_junit",
+	"lint-patterns":              "lint_patterns",
+	"lint-shadowed":              "lint_shadowed",
+	"no-cache":                   "no_cache_bypass",
+	"cache-stats":                "print_cache_stats",
+	"search-db":                  "search_sessions_contexts_patterns",
+	"migrate-storage":            "migrate_storage_to_sqlite",
+	"create-key-file":            "create_encryption_key_file",
+	"rekey":                      "rekey_sessions_contexts",
+	"gc-sessions":                "gc_sessions",
+	"pin-session":                "pin_session",
+	"unpin-session":              "unpin_session",
 	"image-file":                 "save_generated_image_to_file",
 	"image-size":                 "image_dimensions_help",
 	"image-quality":              "image_quality_help",
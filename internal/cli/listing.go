@@ -52,10 +52,19 @@ func handleListingCommands(currentFlags *Flags, fabricDb *fsdb.Db, registry *cor
 			return true, nil
 		}
 
+		if currentFlags.Long && !currentFlags.ShellCompleteOutput {
+			err = printPatternInfos(os.Stdout, fabricDb.Patterns, names)
+			return true, err
+		}
 		err = fabricDb.Patterns.ListNames(currentFlags.ShellCompleteOutput)
 		return true, err
 	}
 
+	if currentFlags.PatternVars != "" {
+		err = printPatternVariables(os.Stdout, fabricDb.Patterns, currentFlags.PatternVars)
+		return true, err
+	}
+
 	if currentFlags.ListAllModels {
 		var models *ai.VendorsModels
 		if models, err = registry.VendorManager.GetModels(); err != nil {
@@ -80,6 +89,10 @@ func handleListingCommands(currentFlags *Flags, fabricDb *fsdb.Db, registry *cor
 	}
 
 	if currentFlags.ListAllSessions {
+		if currentFlags.Long && !currentFlags.ShellCompleteOutput {
+			err = printSessionInfos(os.Stdout, fabricDb.Sessions)
+			return true, err
+		}
 		err = fabricDb.Sessions.ListNames(currentFlags.ShellCompleteOutput)
 		return true, err
 	}
@@ -105,6 +118,16 @@ func handleListingCommands(currentFlags *Flags, fabricDb *fsdb.Db, registry *cor
 		return true, nil
 	}
 
+	if currentFlags.CacheStats {
+		err = printCacheStats(os.Stdout, newResponseCache(registry, currentFlags), currentFlags.Cache.Enabled)
+		return true, err
+	}
+
+	if currentFlags.SearchDb != "" {
+		err = printSearchResults(os.Stdout, fabricDb, currentFlags.SearchDb)
+		return true, err
+	}
+
 	return false, nil
 }
 
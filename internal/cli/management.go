@@ -1,6 +1,10 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+
+	"github.com/danielmiessler/fabric/internal/i18n"
 	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
 )
 
@@ -22,6 +26,49 @@ func handleManagementCommands(currentFlags *Flags, fabricDb *fsdb.Db) (handled b
 		return true, err
 	}
 
+	if currentFlags.ExportSession != "" {
+		err = exportSession(currentFlags, fabricDb)
+		return true, err
+	}
+
+	if currentFlags.ImportSession != "" {
+		err = importSession(currentFlags, fabricDb)
+		return true, err
+	}
+
+	if currentFlags.MigrateStorage {
+		var sessions, contexts int
+		if sessions, contexts, err = fabricDb.MigrateToSQLite(); err == nil {
+			fmt.Printf(i18n.T("storage_migrated")+"\n", sessions, contexts, fabricDb.SQLite.Path)
+		}
+		return true, err
+	}
+
+	if currentFlags.CreateKeyFile != "" {
+		err = createKeyFile(currentFlags)
+		return true, err
+	}
+
+	if currentFlags.Rekey != "" {
+		err = rekey(currentFlags, fabricDb)
+		return true, err
+	}
+
+	if currentFlags.GCSessions {
+		err = collectSessions(os.Stdout, fabricDb.Sessions, currentFlags.Sessions, currentFlags.DryRun)
+		return true, err
+	}
+
+	if currentFlags.PinSession != "" {
+		err = fabricDb.Sessions.Pin(currentFlags.PinSession, true)
+		return true, err
+	}
+
+	if currentFlags.UnpinSession != "" {
+		err = fabricDb.Sessions.Pin(currentFlags.UnpinSession, false)
+		return true, err
+	}
+
 	if currentFlags.PrintContext != "" {
 		err = fabricDb.Contexts.PrintContext(currentFlags.PrintContext)
 		return true, err
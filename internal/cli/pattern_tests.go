@@ -0,0 +1,199 @@
+package cli
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/core"
+	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/i18n"
+)
+
+// The results of a fixture in the summary table and the JUnit report.
+const (
+	fixturePass  = "PASS"
+	fixtureFail  = "FAIL"
+	fixtureError = "ERROR"
+	fixtureSkip  = "SKIP"
+)
+
+// handleTestPattern runs the fixtures of --test-pattern, prints a summary
+// table and writes the JUnit report of --junit. It fails when a fixture does.
+func handleTestPattern(currentFlags *Flags, registry *core.PluginRegistry) (err error) {
+	pattern := currentFlags.TestPattern
+	var tester *core.PatternTester
+	if tester, err = core.NewPatternTester(registry.Db, currentFlags.TestMode, func() (*core.Chatter, error) {
+		return chainStepChatter(currentFlags, registry, &core.PipelineStep{Pattern: pattern})
+	}); err != nil {
+		return
+	}
+	if currentFlags.TestJudge != "" {
+		var judges []core.Fallback
+		if judges, err = registry.GetVendorModels(currentFlags.TestJudge, currentFlags.DryRun); err != nil {
+			return
+		}
+		if len(judges) == 0 {
+			return fmt.Errorf(i18n.T("test_pattern_invalid_judge"), currentFlags.TestJudge)
+		}
+		tester.Judge = &judges[0]
+	}
+
+	var chatReq *domain.ChatRequest
+	if chatReq, err = currentFlags.BuildChatRequest(""); err != nil {
+		return
+	}
+	tester.Language = chatReq.Language
+	if tester.Language == "" {
+		tester.Language = registry.Language.DefaultLanguage.Value
+	}
+	var chatOptions *domain.ChatOptions
+	if chatOptions, err = currentFlags.BuildChatOptions(); err != nil {
+		return
+	}
+
+	// Ctrl-C stops the running fixture
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	results, err := tester.Run(ctx, pattern, chatOptions)
+	stop()
+	if err != nil {
+		return
+	}
+
+	if err = printPatternTestResults(os.Stdout, results); err != nil {
+		return
+	}
+	if currentFlags.JUnit != "" {
+		var report []byte
+		if report, err = formatJUnit(pattern, results); err != nil {
+			return
+		}
+		if err = os.WriteFile(currentFlags.JUnit, report, 0644); err != nil {
+			return
+		}
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		err = fmt.Errorf(i18n.T("test_pattern_failed"), failed, len(results))
+	}
+	return
+}
+
+// fixtureStatus returns the result of a fixture and why it did not pass.
+func fixtureStatus(result *core.FixtureResult) (status string, details []string) {
+	switch {
+	case result.Skipped:
+		return fixtureSkip, []string{i18n.T("test_pattern_no_answer")}
+	case result.Err != nil:
+		return fixtureError, []string{result.Err.Error()}
+	}
+	status = fixturePass
+	for _, assertion := range result.Assertions {
+		if assertion.Err != nil {
+			status = fixtureFail
+			details = append(details, assertion.Err.Error())
+		} else if assertion.Skipped {
+			details = append(details, i18n.T("test_pattern_rubric_skipped"))
+		}
+	}
+	return
+}
+
+// printPatternTestResults prints a table with the result of each fixture,
+// followed by the count of each result.
+func printPatternTestResults(w io.Writer, results []*core.FixtureResult) error {
+	counts := map[string]int{}
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, result := range results {
+		status, details := fixtureStatus(result)
+		counts[status]++
+		fmt.Fprintf(table, "%s\t%s\t%v\t%s\n", result.Fixture.Name, status, result.Duration.Round(time.Millisecond),
+			strings.ReplaceAll(strings.Join(details, "; "), "\n", " "))
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n"+i18n.T("test_pattern_summary")+"\n", counts[fixturePass], counts[fixtureFail],
+		counts[fixtureError], counts[fixtureSkip])
+	return err
+}
+
+type junitTestSuite struct {
+	XMLName  xml.Name        `xml:"testsuite"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// formatJUnit renders the results as a JUnit XML test suite named after the
+// pattern, with a test case per fixture holding the answer as its output.
+func formatJUnit(pattern string, results []*core.FixtureResult) (ret []byte, err error) {
+	suite := junitTestSuite{Name: pattern, Tests: len(results)}
+	var total time.Duration
+	for _, result := range results {
+		total += result.Duration
+		status, details := fixtureStatus(result)
+		testCase := junitTestCase{
+			Name:      result.Fixture.Name,
+			Classname: pattern,
+			File:      result.Fixture.File(),
+			Time:      formatSeconds(result.Duration),
+			SystemOut: result.Output,
+		}
+		message := &junitMessage{Message: strings.Join(details, "; "), Text: strings.Join(details, "\n")}
+		switch status {
+		case fixtureFail:
+			suite.Failures++
+			testCase.Failure = message
+		case fixtureError:
+			suite.Errors++
+			testCase.Error = message
+		case fixtureSkip:
+			suite.Skipped++
+			testCase.Skipped = message
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Time = formatSeconds(total)
+
+	if ret, err = xml.MarshalIndent(suite, "", "  "); err != nil {
+		return nil, fmt.Errorf("could not write the JUnit report: %w", err)
+	}
+	return append([]byte(xml.Header), append(ret, '\n')...), nil
+}
+
+func formatSeconds(duration time.Duration) string {
+	return fmt.Sprintf("%.3f", duration.Seconds())
+}
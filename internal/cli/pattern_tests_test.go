@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/core"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+func patternTestResults() []*core.FixtureResult {
+	return []*core.FixtureResult{
+		{Fixture: &fsdb.PatternFixture{Name: "good"}, Output: "# SUMMARY", Duration: 1200 * time.Millisecond,
+			Assertions: []core.AssertionResult{{}, {Skipped: true}}},
+		{Fixture: &fsdb.PatternFixture{Name: "bad"}, Output: "# IDEAS", Duration: 800 * time.Millisecond,
+			Assertions: []core.AssertionResult{{Err: errors.New(`the answer does not contain "# SUMMARY"`)}}},
+		{Fixture: &fsdb.PatternFixture{Name: "broken"}, Err: errors.New("connection refused")},
+		{Fixture: &fsdb.PatternFixture{Name: "offline"}, Skipped: true},
+	}
+}
+
+func TestPrintPatternTestResults(t *testing.T) {
+	var out bytes.Buffer
+	if err := printPatternTestResults(&out, patternTestResults()); err != nil {
+		t.Fatalf("printPatternTestResults failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("Expected a row per fixture and a summary, got %q", lines)
+	}
+	for i, want := range []string{"PASS", "FAIL", "ERROR", "SKIP"} {
+		if fields := strings.Fields(lines[i]); len(fields) < 2 || fields[1] != want {
+			t.Errorf("Expected %s in row %d, got %q", want, i, lines[i])
+		}
+	}
+	if !strings.Contains(lines[1], "does not contain") || !strings.Contains(lines[5], "1") {
+		t.Errorf("Expected the failed assertion and the counts, got %q", lines)
+	}
+}
+
+func TestFormatJUnit(t *testing.T) {
+	report, err := formatJUnit("summarize", patternTestResults())
+	if err != nil {
+		t.Fatalf("formatJUnit failed: %v", err)
+	}
+	if !strings.HasPrefix(string(report), xml.Header) {
+		t.Errorf("Expected an XML header, got %q", report)
+	}
+	var suite junitTestSuite
+	if err = xml.Unmarshal(report, &suite); err != nil {
+		t.Fatalf("invalid JUnit report: %v", err)
+	}
+	if suite.Name != "summarize" || suite.Tests != 4 || suite.Failures != 1 || suite.Errors != 1 || suite.Skipped != 1 || suite.Time != "2.000" {
+		t.Errorf("Unexpected suite %+v", suite)
+	}
+	if suite.Cases[0].Failure != nil || suite.Cases[1].Failure == nil || suite.Cases[2].Error == nil || suite.Cases[3].Skipped == nil {
+		t.Errorf("Expected a failure, an error and a skip, got %+v", suite.Cases)
+	}
+	if suite.Cases[1].SystemOut != "# IDEAS" {
+		t.Errorf("Expected the answer as the output of the test case, got %q", suite.Cases[1].SystemOut)
+	}
+}
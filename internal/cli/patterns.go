@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/danielmiessler/fabric/internal/core"
+	"github.com/danielmiessler/fabric/internal/i18n"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+// printPatternInfos lists the patterns with the tags, model and description of
+// their front matter. Patterns whose front matter cannot be read show the error.
+func printPatternInfos(w io.Writer, patterns *fsdb.PatternsEntity, names []string) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		metadata, err := patterns.GetMetadata(name)
+		switch {
+		case err != nil:
+			fmt.Fprintf(table, "%s\t\t\t%v\n", name, err)
+		case metadata == nil:
+			fmt.Fprintf(table, "%s\t\t\t\n", name)
+		default:
+			vendor, model := metadata.PreferredModel()
+			if vendor != "" {
+				model = vendor + "|" + model
+			}
+			fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", name, strings.Join(metadata.Tags, ","), model, metadata.Description)
+		}
+	}
+	return table.Flush()
+}
+
+// printPatternVariables lists the variables a pattern takes with their type,
+// whether they are required or their default, and their description.
+func printPatternVariables(w io.Writer, patterns *fsdb.PatternsEntity, name string) (err error) {
+	var variables []fsdb.PatternVariable
+	if variables, err = patterns.GetVariables(name); err != nil {
+		return
+	}
+	if len(variables) == 0 {
+		fmt.Fprintf(w, i18n.T("pattern_no_variables")+"\n", name)
+		return
+	}
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, variable := range variables {
+		kind := variable.Type
+		if kind == "" {
+			kind = fsdb.VariableTypeString
+		}
+		if len(variable.Values) > 0 {
+			kind += "(" + strings.Join(variable.Values, "|") + ")"
+		}
+		var usage string
+		if variable.Required {
+			usage = i18n.T("pattern_variable_required")
+		} else {
+			usage = fmt.Sprintf(i18n.T("pattern_variable_default"), variable.Default)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", variable.Name, kind, usage, variable.Description)
+	}
+	return table.Flush()
+}
+
+// handleLintPatterns prints the issues of the patterns named after the flags,
+// or of all patterns, and fails when there is an error among them.
+func handleLintPatterns(currentFlags *Flags, registry *core.PluginRegistry) (err error) {
+	linter := fsdb.NewPatternLinter(registry.Db.Patterns)
+	linter.CheckExtension = registry.TemplateExtensions.CheckExtension
+	linter.Shadowed = currentFlags.LintShadowed
+
+	var issues []fsdb.LintIssue
+	if issues, err = linter.Lint(currentFlags.Args); err != nil {
+		return
+	}
+	if errors := printLintIssues(os.Stdout, issues); errors > 0 {
+		err = fmt.Errorf(i18n.T("lint_patterns_failed"), errors)
+	}
+	return
+}
+
+// printLintIssues prints the issues and a count of them, and returns the
+// count of errors.
+func printLintIssues(w io.Writer, issues []fsdb.LintIssue) int {
+	if len(issues) == 0 {
+		fmt.Fprintln(w, i18n.T("lint_patterns_clean"))
+		return 0
+	}
+	counts := map[string]int{}
+	patterns := map[string]bool{}
+	for _, issue := range issues {
+		fmt.Fprintln(w, issue)
+		counts[issue.Severity]++
+		patterns[issue.Pattern] = true
+	}
+	fmt.Fprintf(w, "\n"+i18n.T("lint_patterns_summary")+"\n", counts[fsdb.LintError], counts[fsdb.LintWarning], len(patterns))
+	return counts[fsdb.LintError]
+}
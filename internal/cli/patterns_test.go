@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+func TestPrintLintIssues(t *testing.T) {
+	var out bytes.Buffer
+	if errors := printLintIssues(&out, nil); errors != 0 || strings.TrimSpace(out.String()) == "" {
+		t.Fatalf("Expected a clean report, got %d errors and %q", errors, out.String())
+	}
+
+	out.Reset()
+	errors := printLintIssues(&out, []fsdb.LintIssue{
+		{Pattern: "summarize", File: "summarize/system.md", Line: 3, Column: 5, Severity: fsdb.LintError, Message: "unknown plugin namespace math"},
+		{Pattern: "summarize", File: "summarize/system.md", Severity: fsdb.LintWarning, Message: "no {{input}}"},
+		{Pattern: "extract_wisdom", File: "extract_wisdom/system.md", Line: 1, Column: 1, Severity: fsdb.LintWarning, Message: "variable lang is declared but not used"},
+	})
+	if errors != 1 {
+		t.Errorf("Expected 1 error, got %d", errors)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("Expected a line per issue and a summary, got %q", lines)
+	}
+	if lines[0] != "summarize/system.md:3:5: error: unknown plugin namespace math" || lines[1] != "summarize/system.md: warning: no {{input}}" {
+		t.Errorf("Expected the issues with their positions, got %q", lines)
+	}
+	if !strings.Contains(lines[4], "1") || !strings.Contains(lines[4], "2") {
+		t.Errorf("Expected the counts in the summary, got %q", lines[4])
+	}
+}
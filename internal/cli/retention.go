@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/i18n"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+// printSessionInfos lists the sessions with their message count, size and
+// last modification, the most recently saved first.
+func printSessionInfos(w io.Writer, sessions *fsdb.SessionsEntity) (err error) {
+	var infos []fsdb.SessionInfo
+	if infos, err = sessions.Infos(); err != nil {
+		return
+	}
+	if len(infos) == 0 {
+		fmt.Fprintf(w, i18n.T("no_items_found")+"\n", "Sessions")
+		return
+	}
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, info := range infos {
+		messages := fmt.Sprint(info.Messages)
+		if info.Err != nil {
+			messages = "?"
+		}
+		var pinned string
+		if info.Pinned {
+			pinned = i18n.T("session_pinned")
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", info.Name, messages, formatSize(info.Size),
+			info.ModTime.Local().Format(time.DateTime), pinned)
+	}
+	return table.Flush()
+}
+
+// collectSessions removes the sessions beyond the limits of retention, or
+// with dryRun only lists them.
+func collectSessions(w io.Writer, sessions *fsdb.SessionsEntity, retention fsdb.SessionRetention, dryRun bool) (err error) {
+	if !retention.IsSet() {
+		return fmt.Errorf("%s", i18n.T("session_retention_not_set"))
+	}
+	var removed []fsdb.SessionInfo
+	if removed, err = sessions.Collect(retention, time.Now(), dryRun); err != nil {
+		return
+	}
+	for _, info := range removed {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", info.Name, formatSize(info.Size), info.ModTime.Local().Format(time.DateTime))
+	}
+	if dryRun {
+		fmt.Fprintf(w, i18n.T("sessions_would_collect")+"\n", len(removed))
+	} else {
+		fmt.Fprintf(w, i18n.T("sessions_collected")+"\n", len(removed))
+	}
+	return
+}
+
+// formatSize prints a size in bytes, KB or MB.
+func formatSize(size int64) string {
+	switch {
+	case size >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1024*1024))
+	case size >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(size)/1024)
+	}
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/danielmiessler/fabric/internal/i18n"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+// searchLimit is how many matches --search-db prints.
+const searchLimit = 20
+
+// printSearchResults searches the sessions, contexts and patterns for query
+// and prints the best matches with a snippet of each.
+func printSearchResults(w io.Writer, fabricDb *fsdb.Db, query string) (err error) {
+	var results []fsdb.SearchResult
+	if results, err = fsdb.NewSearchIndex(fabricDb).Search(query, searchLimit); err != nil {
+		return
+	}
+	if len(results) == 0 {
+		fmt.Fprintf(w, i18n.T("search_no_results")+"\n", query)
+		return
+	}
+	for _, result := range results {
+		location := result.Kind + " " + result.Name
+		if result.Message > 0 {
+			location += fmt.Sprintf(" #%d [%s]", result.Message, result.Role)
+		}
+		snippet := strings.Join(strings.Fields(result.Snippet), " ")
+		fmt.Fprintf(w, "%s (%.2f)\n    %s\n", location, result.Score, snippet)
+	}
+	return
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/danielmiessler/fabric/internal/i18n"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+// handleSessionCommands forks, rewinds and edits the session given with --session, in that order.
+// The session is saved and the command is done, unless --regenerate asks for a new answer to it.
+// Returns (handled, error) where handled indicates if a command was processed and should exit
+func handleSessionCommands(currentFlags *Flags, fabricDb *fsdb.Db) (handled bool, err error) {
+	if currentFlags.ForkSession == "" && currentFlags.Rewind == 0 && currentFlags.EditMessage == 0 && !currentFlags.Regenerate {
+		return false, nil
+	}
+	if currentFlags.Session == "" {
+		return true, fmt.Errorf("%s", i18n.T("session_command_needs_session"))
+	}
+	if !fabricDb.Sessions.Exists(currentFlags.Session) {
+		return true, fmt.Errorf(i18n.T("session_does_not_exist"), currentFlags.Session)
+	}
+	if currentFlags.EditMessage != 0 && strings.TrimSpace(currentFlags.Message) == "" {
+		return true, fmt.Errorf("%s", i18n.T("edit_message_needs_input"))
+	}
+	if currentFlags.EditMessage == 0 && currentFlags.Regenerate && currentFlags.Message != "" {
+		return true, fmt.Errorf("%s", i18n.T("regenerate_takes_no_input"))
+	}
+
+	var session *fsdb.Session
+	if session, err = fabricDb.Sessions.Get(currentFlags.Session); err != nil {
+		return true, err
+	}
+
+	if currentFlags.ForkSession != "" {
+		if fabricDb.Sessions.Exists(currentFlags.ForkSession) {
+			return true, fmt.Errorf(i18n.T("session_already_exists"), currentFlags.ForkSession)
+		}
+		if session, err = session.Fork(currentFlags.ForkSession, currentFlags.ForkAt); err != nil {
+			return true, err
+		}
+	}
+
+	if currentFlags.Rewind != 0 {
+		if err = session.Rewind(currentFlags.Rewind); err != nil {
+			return true, err
+		}
+	}
+
+	if currentFlags.EditMessage != 0 {
+		if err = session.EditMessage(currentFlags.EditMessage, strings.TrimSpace(currentFlags.Message)); err != nil {
+			return true, err
+		}
+		// The input is in the session now and is not sent again as a new message
+		currentFlags.Message = ""
+	}
+
+	if err = fabricDb.Sessions.SaveSession(session); err != nil {
+		return true, err
+	}
+	if !currentFlags.Regenerate {
+		return true, nil
+	}
+
+	// The chat goes on with the session, which may be the fork
+	currentFlags.Session = session.Name
+	return false, nil
+}
+
+// exportSession writes the session in the --format to the output file, or to stdout.
+func exportSession(currentFlags *Flags, fabricDb *fsdb.Db) (err error) {
+	if !fabricDb.Sessions.Exists(currentFlags.ExportSession) {
+		return fmt.Errorf(i18n.T("session_does_not_exist"), currentFlags.ExportSession)
+	}
+	var session *fsdb.Session
+	if session, err = fabricDb.Sessions.Get(currentFlags.ExportSession); err != nil {
+		return
+	}
+	var content []byte
+	if content, err = session.Export(sessionFormat(currentFlags)); err != nil {
+		return
+	}
+	if currentFlags.Output != "" {
+		return CreateOutputFile(string(content), currentFlags.Output)
+	}
+	_, err = os.Stdout.Write(content)
+	return
+}
+
+// importSession stores the conversations of the --import-session file as sessions,
+// named by --session or after the file.
+func importSession(currentFlags *Flags, fabricDb *fsdb.Db) (err error) {
+	var data []byte
+	if data, err = os.ReadFile(currentFlags.ImportSession); err != nil {
+		return
+	}
+	name := currentFlags.Session
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(currentFlags.ImportSession), filepath.Ext(currentFlags.ImportSession))
+	}
+
+	var sessions []*fsdb.Session
+	if sessions, err = fsdb.ImportSessions(name, data, currentFlags.Format); err != nil {
+		return fmt.Errorf("could not import %s: %w", currentFlags.ImportSession, err)
+	}
+	// Nothing is imported when one of the names is taken
+	for _, session := range sessions {
+		if fabricDb.Sessions.Exists(session.Name) {
+			return fmt.Errorf(i18n.T("session_already_exists"), session.Name)
+		}
+	}
+	for _, session := range sessions {
+		if err = fabricDb.Sessions.SaveSession(session); err != nil {
+			return
+		}
+		fmt.Printf(i18n.T("session_imported")+"\n", session.Name, len(session.Messages))
+	}
+	return
+}
+
+// sessionFormat is the --format to export sessions in, Markdown by default.
+func sessionFormat(currentFlags *Flags) string {
+	if currentFlags.Format == "" {
+		return fsdb.SessionFormatMarkdown
+	}
+	return currentFlags.Format
+}
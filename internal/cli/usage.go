@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/i18n"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+// printUsage writes the usage of the last request and, for named sessions, the
+// session totals. The pattern is named so spend can be attributed to it.
+func printUsage(w io.Writer, session *fsdb.Session, patternName, model string) {
+	if session.LastUsage == nil {
+		fmt.Fprintln(w, i18n.T("usage_not_reported"))
+		return
+	}
+
+	label := i18n.T("usage_request")
+	if patternName != "" {
+		label = fmt.Sprintf(i18n.T("usage_request_pattern"), patternName)
+	}
+	fmt.Fprintln(w, formatUsage(label, session.LastUsage, model))
+
+	if session.Name != "" && session.Usage != nil {
+		fmt.Fprintln(w, formatUsage(fmt.Sprintf(i18n.T("usage_session_total"), session.Name), session.Usage, model))
+	}
+}
+
+func formatUsage(label string, usage *domain.UsageMetadata, model string) string {
+	cost := fmt.Sprintf(i18n.T("usage_cost_unknown"), model)
+	if usage.Cost > 0 {
+		cost = fmt.Sprintf("$%.6f", usage.Cost)
+	}
+	return fmt.Sprintf(i18n.T("usage_line"), label, usage.InputTokens, usage.CachedTokens,
+		usage.OutputTokens, usage.ReasoningTokens, cost)
+}
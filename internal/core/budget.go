@@ -0,0 +1,248 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+	debuglog "github.com/danielmiessler/fabric/internal/log"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+// Strategies for a session that does not fit the context window of the model.
+const (
+	ContextDropOldest = "drop_oldest"
+	ContextSummarize  = "summarize"
+	ContextRefuse     = "refuse"
+)
+
+// DefaultContextReserve is the number of tokens kept free for the answer when
+// the request sets no maximum.
+const DefaultContextReserve = 4096
+
+// DefaultContextWindows are the context windows of well known models, matched
+// by the longest prefix of the model name.
+var DefaultContextWindows = map[string]int{
+	"gpt-3.5":     16385,
+	"gpt-4":       8192,
+	"gpt-4-turbo": 128000,
+	"gpt-4o":      128000,
+	"gpt-4.1":     1047576,
+	"gpt-5":       400000,
+	"o1":          200000,
+	"o3":          200000,
+	"o4":          200000,
+	"claude":      200000,
+	"gemini":      1048576,
+	"grok":        131072,
+	"mistral":     128000,
+	"deepseek":    128000,
+	"llama3":      8192,
+	"llama3.1":    128000,
+	"llama3.2":    128000,
+	"llama3.3":    128000,
+	"qwen3":       32768,
+}
+
+// ContextWindowConfig configures how the messages of a session are kept
+// within the context window of the model. It is read from the
+// context_window section of config.yaml:
+//
+//	context_window:
+//	  strategy: summarize
+//	  summary_model: Ollama|qwen3
+//	  reserve: 8192
+//	  windows:
+//	    my-finetune: 32768
+type ContextWindowConfig struct {
+	// Strategy is drop_oldest, summarize or refuse, drop_oldest by default
+	Strategy string `yaml:"strategy"`
+	// SummaryModel summarizes older turns, as Vendor|model or a model name. It
+	// defaults to the model of the request.
+	SummaryModel string `yaml:"summary_model"`
+	// Reserve is the number of tokens kept free for the answer
+	Reserve int `yaml:"reserve"`
+	// Windows maps model names, or their prefixes, to their context windows
+	Windows map[string]int `yaml:"windows"`
+}
+
+// Validate checks the strategy.
+func (o ContextWindowConfig) Validate() error {
+	switch o.Strategy {
+	case "", ContextDropOldest, ContextSummarize, ContextRefuse:
+		return nil
+	}
+	return fmt.Errorf("unknown context window strategy %q, use %s, %s or %s",
+		o.Strategy, ContextDropOldest, ContextSummarize, ContextRefuse)
+}
+
+// Window returns the context window of model: contextLength when the request
+// sets it, then the configured window, then the default one. It is 0 when
+// unknown.
+func (o ContextWindowConfig) Window(model string, contextLength int) int {
+	if contextLength > 0 {
+		return contextLength
+	}
+	if window := matchWindow(o.Windows, model); window > 0 {
+		return window
+	}
+	return matchWindow(DefaultContextWindows, model)
+}
+
+func matchWindow(windows map[string]int, model string) (ret int) {
+	model = strings.ToLower(model)
+	longest := -1
+	for name, window := range windows {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(model, name) && len(name) > longest {
+			ret, longest = window, len(name)
+		}
+	}
+	return
+}
+
+const summaryRequest = "Summarize our conversation so far."
+
+const summaryPrompt = "Summarize the following conversation so that it can be continued from the summary alone. " +
+	"Keep every fact, decision, name, number and open question; leave out pleasantries. " +
+	"Reply with the summary only."
+
+// fitContextWindow keeps the messages sent for session within window, the
+// context window of the model. Only turns of the stored history give way, oldest
+// first; system messages and the turn of the request are always sent. Turns
+// that are dropped stay in the stored session, turns that are summarized are
+// replaced there by the summary.
+func (o *Chatter) fitContextWindow(ctx context.Context, session *fsdb.Session, opts *domain.ChatOptions, window int) (err error) {
+	if window == 0 {
+		return
+	}
+	reserve := opts.MaxTokens
+	if reserve <= 0 {
+		reserve = o.contextWindow.Reserve
+	}
+	if reserve <= 0 {
+		reserve = DefaultContextReserve
+	}
+	budget := window - reserve
+	if budget <= 0 {
+		budget = window / 2
+	}
+
+	total := domain.EstimateMessagesTokens(session.GetVendorMessages())
+	if total <= budget {
+		return
+	}
+	turns := historyTurns(session)
+	if len(turns) == 0 {
+		// Nothing can give way, so the vendor decides
+		return
+	}
+
+	strategy := o.contextWindow.Strategy
+	if strategy == "" {
+		strategy = ContextDropOldest
+	}
+	if strategy == ContextRefuse {
+		return fmt.Errorf("the session needs about %d tokens, more than the %d available in the %d token context window of %s; "+
+			"start a new session or set another context_window strategy", total, budget, window, o.model)
+	}
+
+	// A summary takes room as well, so summarizing leaves a quarter of the budget free
+	target := budget
+	if strategy == ContextSummarize {
+		target = budget * 3 / 4
+	}
+	var removed []*chat.ChatCompletionMessage
+	for _, turn := range turns {
+		if total <= target {
+			break
+		}
+		for _, message := range turn {
+			total -= domain.EstimateMessageTokens(message)
+		}
+		removed = append(removed, turn...)
+	}
+
+	if strategy == ContextDropOldest {
+		debuglog.Debug(debuglog.Basic, "Leaving out %d earlier messages to fit the context window of %s\n", len(removed), o.model)
+		session.Exclude(removed...)
+		return
+	}
+
+	var summary *chat.ChatCompletionMessage
+	var summarizer Fallback
+	if summary, summarizer, err = o.summarize(ctx, removed); err != nil {
+		return fmt.Errorf("could not summarize the session to fit the context window: %w", err)
+	}
+	debuglog.Debug(debuglog.Basic, "Summarized %d earlier messages to fit the context window of %s\n", len(removed), o.model)
+	session.Replace(removed, &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: summaryRequest}, summary)
+	metadata := session.Metadata(summary)
+	metadata.Vendor, metadata.Model = summarizer.Vendor.GetName(), summarizer.Model
+	return
+}
+
+// historyTurns returns the turns of session before the one of the request,
+// oldest first. A turn is a user message and the answers and tool results
+// that follow it; system and meta messages are not part of any.
+func historyTurns(session *fsdb.Session) (ret [][]*chat.ChatCompletionMessage) {
+	last := len(session.Messages) - 1
+	for last >= 0 && session.Messages[last].Role != chat.ChatMessageRoleUser {
+		last--
+	}
+	var turn []*chat.ChatCompletionMessage
+	for _, message := range session.Messages[:max(last, 0)] {
+		switch message.Role {
+		case chat.ChatMessageRoleSystem, domain.ChatMessageRoleMeta:
+			continue
+		case chat.ChatMessageRoleUser:
+			if len(turn) > 0 {
+				ret = append(ret, turn)
+			}
+			turn = nil
+		}
+		turn = append(turn, message)
+	}
+	if len(turn) > 0 {
+		ret = append(ret, turn)
+	}
+	return
+}
+
+// summarize asks the summary model for a summary of messages.
+func (o *Chatter) summarize(ctx context.Context, messages []*chat.ChatCompletionMessage) (
+	ret *chat.ChatCompletionMessage, summarizer Fallback, err error) {
+	summarizer = Fallback{Vendor: o.vendor, Model: o.model}
+	if o.getSummarizer != nil {
+		if summarizer, err = o.getSummarizer(); err != nil {
+			return
+		}
+	}
+
+	var transcript strings.Builder
+	for _, message := range messages {
+		content := message.Content
+		for _, part := range message.MultiContent {
+			if part.Type == chat.ChatMessagePartTypeText {
+				content += part.Text
+			}
+		}
+		fmt.Fprintf(&transcript, "%s: %s\n\n", message.Role, strings.TrimSpace(content))
+	}
+
+	var response *domain.ChatResponse
+	if response, err = summarizer.Vendor.Send(ctx, []*chat.ChatCompletionMessage{
+		{Role: chat.ChatMessageRoleSystem, Content: summaryPrompt},
+		{Role: chat.ChatMessageRoleUser, Content: transcript.String()},
+	}, &domain.ChatOptions{Model: summarizer.Model, Temperature: 0.2}); err != nil {
+		return
+	}
+	content := strings.TrimSpace(domain.StripThinkBlocks(response.Content, "<think>", "</think>"))
+	if content == "" {
+		err = fmt.Errorf("%s|%s returned an empty summary", summarizer.Vendor.GetName(), summarizer.Model)
+		return
+	}
+	ret = &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleAssistant, Content: content}
+	return
+}
@@ -0,0 +1,167 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+// newLongSession stores a session of three turns of about 100 tokens each
+func newLongSession(t *testing.T) *fsdb.Db {
+	t.Helper()
+	db := fsdb.NewDb(t.TempDir())
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	session := &fsdb.Session{Name: "long"}
+	session.Append(&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleSystem, Content: "be brief"})
+	for _, turn := range []string{"one", "two", "three"} {
+		session.Append(
+			&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: turn + strings.Repeat(" x", 100)},
+			&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleAssistant, Content: "answer " + turn + strings.Repeat(" y", 100)},
+		)
+	}
+	if err := db.Sessions.SaveSession(session); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	return db
+}
+
+// recordingVendor answers "ok" and keeps the messages it was sent
+func recordingVendor(sent *[]*chat.ChatCompletionMessage) *mockVendor {
+	return &mockVendor{sendFunc: func(_ context.Context, msgs []*chat.ChatCompletionMessage, _ *domain.ChatOptions) (*domain.ChatResponse, error) {
+		*sent = msgs
+		return &domain.ChatResponse{Content: "ok"}, nil
+	}}
+}
+
+func longSessionRequest() *domain.ChatRequest {
+	return &domain.ChatRequest{
+		SessionName: "long",
+		Message:     &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "next"},
+	}
+}
+
+func TestContextWindowConfig_Window(t *testing.T) {
+	config := ContextWindowConfig{Windows: map[string]int{"My-Model": 1000}}
+	if got := config.Window("my-model-v2", 0); got != 1000 {
+		t.Errorf("expected the configured window, got %d", got)
+	}
+	if got := config.Window("gpt-4o-mini", 0); got != 128000 {
+		t.Errorf("expected the longest matching default, got %d", got)
+	}
+	if got := config.Window("gpt-4o", 5000); got != 5000 {
+		t.Errorf("expected the context length to win, got %d", got)
+	}
+	if got := config.Window("unknown", 0); got != 0 {
+		t.Errorf("expected no window for an unknown model, got %d", got)
+	}
+	if err := (ContextWindowConfig{Strategy: "truncate"}).Validate(); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
+
+func TestChatter_Send_DropsOldestTurns(t *testing.T) {
+	db := newLongSession(t)
+	var sent []*chat.ChatCompletionMessage
+	chatter := &Chatter{db: db, vendor: recordingVendor(&sent), model: "test-model",
+		contextWindow: ContextWindowConfig{Reserve: 100, Windows: map[string]int{"test-model": 300}}}
+
+	if _, err := chatter.Send(context.Background(), longSessionRequest(), &domain.ChatOptions{}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	// The system message, the last turn and the request fit into 200 tokens
+	if len(sent) != 4 || sent[0].Role != chat.ChatMessageRoleSystem || !strings.HasPrefix(sent[1].Content, "three") {
+		t.Fatalf("expected the two oldest turns to be left out, got %d messages", len(sent))
+	}
+
+	session, err := db.Sessions.Get("long")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	if len(session.Messages) != 9 {
+		t.Errorf("expected dropped turns to stay in the session, got %d messages", len(session.Messages))
+	}
+}
+
+func TestChatter_Send_SummarizesOldestTurns(t *testing.T) {
+	db := newLongSession(t)
+	var sent, summarized []*chat.ChatCompletionMessage
+	summarizer := &mockVendor{sendFunc: func(_ context.Context, msgs []*chat.ChatCompletionMessage, _ *domain.ChatOptions) (*domain.ChatResponse, error) {
+		summarized = msgs
+		return &domain.ChatResponse{Content: "<think>hmm</think>the summary"}, nil
+	}}
+	chatter := &Chatter{db: db, vendor: recordingVendor(&sent), model: "test-model",
+		contextWindow: ContextWindowConfig{Strategy: ContextSummarize, Reserve: 100, Windows: map[string]int{"test-model": 380}},
+		getSummarizer: func() (Fallback, error) { return Fallback{Vendor: summarizer, Model: "small"}, nil },
+	}
+
+	if _, err := chatter.Send(context.Background(), longSessionRequest(), &domain.ChatOptions{}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(summarized) != 2 || !strings.Contains(summarized[1].Content, "answer two") || strings.Contains(summarized[1].Content, "three") {
+		t.Fatalf("expected the two oldest turns to be summarized, got %v", summarized)
+	}
+
+	session, err := db.Sessions.Get("long")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	// system, summary request and summary, the last turn, the request and its answer
+	if len(session.Messages) != 7 || session.Messages[1].Content != summaryRequest || session.Messages[2].Content != "the summary" {
+		t.Errorf("expected the summary to be saved in place of the turns, got %d messages", len(session.Messages))
+	}
+	if len(sent) != 6 {
+		t.Errorf("expected the summary to be sent, got %d messages", len(sent))
+	}
+}
+
+func TestChatter_Send_RefusesOversizedSession(t *testing.T) {
+	db := newLongSession(t)
+	var sent []*chat.ChatCompletionMessage
+	chatter := &Chatter{db: db, vendor: recordingVendor(&sent), model: "test-model",
+		contextWindow: ContextWindowConfig{Strategy: ContextRefuse, Reserve: 100, Windows: map[string]int{"test-model": 400}}}
+
+	if _, err := chatter.Send(context.Background(), longSessionRequest(), &domain.ChatOptions{}); err == nil {
+		t.Fatal("expected an error for a session beyond the context window")
+	}
+	if sent != nil {
+		t.Error("expected nothing to be sent")
+	}
+}
+
+func TestChatter_Send_KeepsHistoryOfLargeModel(t *testing.T) {
+	db := fsdb.NewDb(t.TempDir())
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	// About 3000 tokens, more than the Ollama context size but far from the window of the model
+	session := &fsdb.Session{Name: "long"}
+	for _, turn := range []string{"one", "two", "three"} {
+		session.Append(
+			&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: turn + strings.Repeat(" x", 1000)},
+			&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleAssistant, Content: "answer " + turn + strings.Repeat(" y", 1000)},
+		)
+	}
+	if err := db.Sessions.SaveSession(session); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	if tokens := domain.EstimateMessagesTokens(session.Messages); tokens <= 2048 {
+		t.Fatalf("expected the session to be larger than the Ollama context size, got %d tokens", tokens)
+	}
+
+	// As on the REST API, where the request sets no context length and the
+	// chatter has the default one of the setup
+	var sent []*chat.ChatCompletionMessage
+	chatter := &Chatter{db: db, vendor: recordingVendor(&sent), model: "claude-sonnet-4-5", modelContextLength: 2048}
+	if _, err := chatter.Send(context.Background(), longSessionRequest(), &domain.ChatOptions{}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if len(sent) != 7 {
+		t.Errorf("expected the whole history to be sent, got %d messages", len(sent))
+	}
+}
@@ -5,19 +5,33 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/danielmiessler/fabric/internal/chat"
 
 	"github.com/danielmiessler/fabric/internal/domain"
+	debuglog "github.com/danielmiessler/fabric/internal/log"
 	"github.com/danielmiessler/fabric/internal/plugins/ai"
 	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
 	"github.com/danielmiessler/fabric/internal/plugins/strategy"
 	"github.com/danielmiessler/fabric/internal/plugins/template"
+	"github.com/danielmiessler/fabric/internal/plugins/toolcall"
 )
 
 const NoSessionPatternUserMessages = "no session, pattern or user messages provided"
 
+// MaxToolRounds bounds how many times the model may call tools before it has
+// to produce a final answer.
+const MaxToolRounds = 10
+
+// MaxSchemaRepairs bounds how many times the model is asked to correct a
+// response that does not match the schema.
+const MaxSchemaRepairs = 1
+
+const schemaRepairPrompt = "Your response does not match the required JSON schema:\n%v\n\n" +
+	"Reply with only the corrected JSON document, without any explanation or code fence."
+
 type Chatter struct {
 	db *fsdb.Db
 
@@ -28,15 +42,48 @@ type Chatter struct {
 	modelContextLength int
 	vendor             ai.Vendor
 	strategy           string
+	tools              *toolcall.Registry
+	pricing            ai.Pricing
+
+	// Sink receives the events of each request; nil discards them
+	Sink OutputSink
+	// Fallbacks are tried in order when the vendor fails with a retryable error
+	Fallbacks []Fallback
+
+	contextWindow ContextWindowConfig
+	// getSummarizer returns the model that summarizes sessions, nil for the chatter's own
+	getSummarizer func() (Fallback, error)
 }
 
-// Send processes a chat request and applies file changes for create_coding_feature pattern
-func (o *Chatter) Send(request *domain.ChatRequest, opts *domain.ChatOptions) (session *fsdb.Session, err error) {
+// Fallback is a vendor and model to answer in place of the ones before it.
+type Fallback struct {
+	Vendor ai.Vendor
+	Model  string
+}
+
+// Send processes a chat request and applies file changes for create_coding_feature pattern.
+// Cancelling ctx stops the generation; a partially streamed response is kept in the session.
+// The events of the request are passed to the Sink, ending with either done or error.
+func (o *Chatter) Send(ctx context.Context, request *domain.ChatRequest, opts *domain.ChatOptions) (session *fsdb.Session, err error) {
+	defer func() {
+		if err != nil {
+			o.emit(domain.StreamUpdate{Type: domain.StreamTypeError, Err: err})
+		}
+	}()
+
 	// Use o.model (normalized) for NeedsRawMode check instead of opts.Model
 	// This ensures case-insensitive model names work correctly (e.g., "GPT-5" → "gpt-5")
 	if o.vendor.NeedsRawMode(o.model) {
 		opts.Raw = true
 	}
+	// The cached answer is the one being replaced
+	if request.Regenerate {
+		opts.NoCache = true
+	}
+	// Chats with the same session take turns, each one answering the session as the last one left it
+	if request.SessionName != "" {
+		defer o.db.Sessions.Lock(request.SessionName)()
+	}
 	if session, err = o.BuildSession(request, opts.Raw); err != nil {
 		return
 	}
@@ -53,58 +100,55 @@ func (o *Chatter) Send(request *domain.ChatRequest, opts *domain.ChatOptions) (s
 		return
 	}
 
-	// Always use the normalized model name from the Chatter
-	// This handles cases where user provides "GPT-5" but we've normalized it to "gpt-5"
-	opts.Model = o.model
-
+	// Only a context length the request asks for is the window of the model.
+	// The default one is the Ollama context size and says nothing about it.
+	window := o.contextWindow.Window(o.model, opts.ModelContextLength)
 	if opts.ModelContextLength == 0 {
 		opts.ModelContextLength = o.modelContextLength
 	}
 
-	message := ""
-
-	if o.Stream {
-		responseChan := make(chan string)
-		errChan := make(chan error, 1)
-		done := make(chan struct{})
-		printedStream := false
-
-		go func() {
-			defer close(done)
-			if streamErr := o.vendor.SendStream(session.GetVendorMessages(), opts, responseChan); streamErr != nil {
-				errChan <- streamErr
-			}
-		}()
+	// Long sessions give way, oldest turns first, before they overflow the model
+	if err = o.fitContextWindow(ctx, session, opts, window); err != nil {
+		return
+	}
 
-		for response := range responseChan {
-			message += response
-			if !opts.SuppressThink {
-				fmt.Print(response)
-				printedStream = true
-			}
+	// A schema given with the request wins over the one of the pattern
+	if opts.Schema == nil && request.PatternName != "" {
+		if opts.Schema, err = o.db.Patterns.GetSchema(request.PatternName); err != nil {
+			return
 		}
-
-		if printedStream && !opts.SuppressThink && !strings.HasSuffix(message, "\n") {
-			fmt.Println()
+	}
+	if request.PatternName != "" {
+		if err = o.applyPatternMetadata(request.PatternName, opts); err != nil {
+			return
 		}
+	}
 
-		// Wait for goroutine to finish
-		<-done
-
-		// Check for errors in errChan
-		select {
-		case streamErr := <-errChan:
-			if streamErr != nil {
-				err = streamErr
-				return
-			}
-		default:
-			// No errors, continue
-		}
-	} else {
-		if message, err = o.vendor.Send(context.Background(), session.GetVendorMessages(), opts); err != nil {
-			return
+	// The first candidate that answers wins; the others are only tried after a
+	// retryable error and as long as nothing has been streamed yet
+	candidates := append([]Fallback{{Vendor: o.vendor, Model: o.model}}, o.Fallbacks...)
+	var answered Fallback
+	var message string
+	var usage *domain.UsageMetadata
+	for i, candidate := range candidates {
+		answered = candidate
+		// Always use the normalized model name, e.g. "gpt-5" when the user asked for "GPT-5"
+		opts.Model = candidate.Model
+		message, usage, err = o.generate(ctx, session, opts, candidate.Vendor)
+		if err == nil || ctx.Err() != nil || message != "" || !ai.IsRetryable(err) || i == len(candidates)-1 {
+			break
 		}
+		next := candidates[i+1]
+		debuglog.Log("%s|%s failed: %v\nFalling back to %s|%s\n",
+			candidate.Vendor.GetName(), candidate.Model, err, next.Vendor.GetName(), next.Model)
+	}
+
+	if ctx.Err() != nil {
+		err = o.savePartialResponse(session, message, usage, answered, request, opts, ctx.Err())
+		return
+	}
+	if err != nil {
+		return
 	}
 
 	if opts.SuppressThink && !o.DryRun {
@@ -117,6 +161,25 @@ func (o *Chatter) Send(request *domain.ChatRequest, opts *domain.ChatOptions) (s
 		return
 	}
 
+	if o.validates(opts) {
+		if message, usage, err = o.enforceSchema(ctx, session, opts, answered, message, usage); err != nil {
+			// Keep the last answer so it can be inspected in the session
+			if message != "" {
+				o.appendAnswer(session, message, usage, answered, request, opts)
+				if session.Name != "" {
+					if saveErr := o.db.Sessions.SaveSession(session); saveErr != nil {
+						err = errors.Join(err, saveErr)
+					}
+				}
+			}
+			return
+		}
+		// Validated responses are not streamed, so they are passed on in one piece
+		if o.Stream {
+			o.emit(domain.StreamUpdate{Type: domain.StreamTypeContent, Content: message})
+		}
+	}
+
 	// Process file changes for create_coding_feature pattern
 	if request.PatternName == "create_coding_feature" {
 		summary, fileChanges, parseErr := domain.ParseFileChanges(message)
@@ -138,14 +201,277 @@ func (o *Chatter) Send(request *domain.ChatRequest, opts *domain.ChatOptions) (s
 		message = summary
 	}
 
-	session.Append(&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleAssistant, Content: message})
+	o.appendAnswer(session, message, usage, answered, request, opts)
 
 	if session.Name != "" {
-		err = o.db.Sessions.SaveSession(session)
+		if err = o.db.Sessions.SaveSession(session); err != nil {
+			return
+		}
+	}
+	o.emit(domain.StreamUpdate{Type: domain.StreamTypeDone, Content: message})
+	return
+}
+
+// applyPatternMetadata applies the temperature and format of the front matter
+// of the pattern, where the request leaves them to it.
+func (o *Chatter) applyPatternMetadata(patternName string, opts *domain.ChatOptions) (err error) {
+	var metadata *fsdb.PatternMetadata
+	if metadata, err = o.db.Patterns.GetMetadata(patternName); err != nil || metadata == nil {
+		return
+	}
+	if metadata.Temperature != nil && !opts.TemperatureSet {
+		opts.Temperature = *metadata.Temperature
+	}
+	// A JSON pattern without a schema only hints the format to the vendor, its
+	// answers are checked where a schema.json or --schema asks for it
+	if metadata.Format == fsdb.PatternFormatJSON && opts.Schema == nil {
+		opts.JSONFormat = true
 	}
 	return
 }
 
+// emit passes update to the sink, if there is one
+func (o *Chatter) emit(update domain.StreamUpdate) {
+	if o.Sink != nil {
+		o.Sink.Emit(update)
+	}
+}
+
+func (o *Chatter) emitImages(images []string) {
+	for _, image := range images {
+		o.emit(domain.StreamUpdate{Type: domain.StreamTypeImage, Content: image})
+	}
+}
+
+// validates reports whether the response is checked against a schema. Such
+// responses are not streamed, as an invalid one may still be replaced.
+func (o *Chatter) validates(opts *domain.ChatOptions) bool {
+	return opts.Schema != nil && !o.DryRun
+}
+
+// enforceSchema validates message against the schema of the request. A
+// response that does not match is sent back to vendor with the validation
+// error until it does or MaxSchemaRepairs is reached; the rejected responses
+// and repair requests stay in the session. The returned message is the bare
+// JSON document, or the last rejected response along with the error.
+func (o *Chatter) enforceSchema(ctx context.Context, session *fsdb.Session, opts *domain.ChatOptions, answered Fallback,
+	message string, usage *domain.UsageMetadata) (ret string, retUsage *domain.UsageMetadata, err error) {
+	retUsage = usage
+	for repair := 0; ; repair++ {
+		var invalid error
+		if ret, invalid = opts.Schema.Validate(message); invalid == nil {
+			return
+		}
+		ret = message
+		if repair == MaxSchemaRepairs {
+			err = fmt.Errorf("response does not match schema %s: %w", opts.Schema.Name, invalid)
+			return
+		}
+
+		debuglog.Debug(debuglog.Basic, "Response does not match schema %s, asking for a repair: %v\n", opts.Schema.Name, invalid)
+		session.Append(
+			&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleAssistant, Content: message},
+			&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: fmt.Sprintf(schemaRepairPrompt, invalid)},
+		)
+		var repairUsage *domain.UsageMetadata
+		message, repairUsage, err = o.generate(ctx, session, opts, answered.Vendor)
+		if repairUsage != nil {
+			if retUsage == nil {
+				retUsage = &domain.UsageMetadata{}
+			}
+			retUsage.Add(repairUsage)
+		}
+		if err != nil {
+			ret = message
+			return
+		}
+		if opts.SuppressThink {
+			message = domain.StripThinkBlocks(message, opts.ThinkStartTag, opts.ThinkEndTag)
+		}
+	}
+}
+
+// generate gets an answer to the session from vendor, streaming it when the
+// chatter streams. When ctx is cancelled it returns what was streamed so far.
+func (o *Chatter) generate(ctx context.Context, session *fsdb.Session, opts *domain.ChatOptions, vendor ai.Vendor) (
+	message string, usage *domain.UsageMetadata, err error) {
+	stream := o.Stream && !o.validates(opts)
+	if len(opts.Tools) > 0 {
+		if message, usage, err = o.runToolLoop(ctx, session, opts, vendor); err != nil {
+			return
+		}
+		// Tool rounds are not streamed, so the final answer is passed on in one piece
+		if stream {
+			o.emit(domain.StreamUpdate{Type: domain.StreamTypeContent, Content: message})
+		}
+	} else if stream {
+		responseChan := make(chan domain.StreamUpdate)
+		errChan := make(chan error, 1)
+		done := make(chan struct{})
+		var citations []domain.Citation
+		var reportedErr error
+
+		go func() {
+			defer close(done)
+			if streamErr := vendor.SendStream(ctx, session.GetVendorMessages(), opts, responseChan); streamErr != nil {
+				errChan <- streamErr
+			}
+		}()
+
+		for update := range responseChan {
+			switch update.Type {
+			case domain.StreamTypeContent:
+				message += update.Content
+				o.emit(update)
+			case domain.StreamTypeCitation:
+				// Vendors may cite a source more than once
+				if !slices.Contains(citations, *update.Citation) {
+					citations = append(citations, *update.Citation)
+					o.emit(update)
+				}
+			case domain.StreamTypeUsage:
+				// Vendors may report running totals, the last update wins
+				usage = update.Usage
+			case domain.StreamTypeError:
+				// Reported once the stream has ended, with the error the vendor returns
+				reportedErr = update.Err
+			default:
+				o.emit(update)
+			}
+		}
+
+		// Wait for goroutine to finish
+		<-done
+
+		// A cancelled stream keeps what was streamed, whatever error the vendor reports for it
+		if err = ctx.Err(); err == nil {
+			select {
+			case err = <-errChan:
+			default:
+				err = reportedErr
+			}
+		}
+		message += domain.FormatCitations(citations)
+	} else {
+		var response *domain.ChatResponse
+		if response, err = vendor.Send(ctx, session.GetVendorMessages(), opts); err != nil {
+			return
+		}
+		message = response.Content
+		usage = response.Usage
+		o.emitImages(response.Images)
+	}
+	return
+}
+
+// runToolLoop sends the session with the tools advertised, runs every tool the
+// model asks for and feeds the results back until the model answers without
+// calling a tool. Each tool call and result is appended to the session.
+func (o *Chatter) runToolLoop(ctx context.Context, session *fsdb.Session, opts *domain.ChatOptions, vendor ai.Vendor) (
+	message string, usage *domain.UsageMetadata, err error) {
+	if o.tools == nil {
+		err = errors.New("tools were requested but no tool registry is configured")
+		return
+	}
+
+	for round := 0; round < MaxToolRounds; round++ {
+		var response *domain.ChatResponse
+		if response, err = vendor.Send(ctx, session.GetVendorMessages(), opts); err != nil {
+			return
+		}
+		if response.Usage != nil {
+			if usage == nil {
+				usage = &domain.UsageMetadata{}
+			}
+			usage.Add(response.Usage)
+		}
+		o.emitImages(response.Images)
+		if len(response.ToolCalls) == 0 {
+			message = response.Content
+			return
+		}
+
+		toolCalls := &chat.ChatCompletionMessage{
+			Role:      chat.ChatMessageRoleAssistant,
+			Content:   response.Content,
+			ToolCalls: response.ToolCalls,
+		}
+		session.Append(toolCalls)
+		metadata := session.Metadata(toolCalls)
+		metadata.Vendor, metadata.Model, metadata.Usage = vendor.GetName(), opts.Model, response.Usage
+
+		for _, call := range response.ToolCalls {
+			o.emit(domain.StreamUpdate{Type: domain.StreamTypeToolCall, ToolCall: &call})
+			debuglog.Debug(debuglog.Basic, "Calling tool %s with %s\n", call.Function.Name, call.Function.Arguments)
+			result, callErr := o.tools.Call(ctx, call)
+			if callErr != nil {
+				// Let the model see the failure and decide how to continue
+				result = fmt.Sprintf("error: %v", callErr)
+			}
+			if result == "" {
+				result = "(no output)"
+			}
+			session.Append(&chat.ChatCompletionMessage{
+				Role:       chat.ChatMessageRoleTool,
+				Name:       call.Function.Name,
+				ToolCallID: call.ID,
+				Content:    result,
+			})
+		}
+	}
+
+	err = fmt.Errorf("no final answer after %d tool rounds", MaxToolRounds)
+	return
+}
+
+// savePartialResponse keeps what was streamed before the request was cancelled,
+// so an interrupted generation is not lost from the session.
+func (o *Chatter) savePartialResponse(session *fsdb.Session, message string, usage *domain.UsageMetadata,
+	answered Fallback, request *domain.ChatRequest, opts *domain.ChatOptions, cause error) (err error) {
+	err = fmt.Errorf("response interrupted: %w", cause)
+	if message == "" {
+		return
+	}
+
+	o.appendAnswer(session, message, usage, answered, request, opts)
+	if session.Name != "" {
+		if saveErr := o.db.Sessions.SaveSession(session); saveErr != nil {
+			err = errors.Join(err, saveErr)
+		}
+	}
+	return
+}
+
+// appendAnswer adds the answer to the session along with the vendor and model
+// that gave it, and records its usage. The metadata of the answer records the
+// request and options it was produced with.
+func (o *Chatter) appendAnswer(session *fsdb.Session, message string, usage *domain.UsageMetadata, answered Fallback,
+	request *domain.ChatRequest, opts *domain.ChatOptions) {
+	answer := &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleAssistant, Content: message}
+	session.Append(answer)
+	session.Vendor, session.Model = answered.Vendor.GetName(), answered.Model
+	o.recordUsage(session, usage, answered)
+
+	metadata := session.Metadata(answer)
+	metadata.Vendor, metadata.Model = session.Vendor, session.Model
+	metadata.Pattern, metadata.Context, metadata.Strategy = request.PatternName, request.ContextName, request.StrategyName
+	metadata.Options = fsdb.NewMessageOptions(opts)
+	metadata.Usage = usage
+}
+
+// recordUsage prices usage with the pricing table, unless the vendor already
+// reported a cost, and adds it to the session.
+func (o *Chatter) recordUsage(session *fsdb.Session, usage *domain.UsageMetadata, answered Fallback) {
+	if usage == nil {
+		return
+	}
+	if usage.Cost == 0 {
+		usage.Cost, _ = o.pricing.Cost(answered.Vendor.GetName(), answered.Model, usage)
+	}
+	session.AddUsage(usage)
+	o.emit(domain.StreamUpdate{Type: domain.StreamTypeUsage, Usage: usage})
+}
+
 func (o *Chatter) BuildSession(request *domain.ChatRequest, raw bool) (session *fsdb.Session, err error) {
 	if request.SessionName != "" {
 		var sess *fsdb.Session
@@ -158,6 +484,22 @@ func (o *Chatter) BuildSession(request *domain.ChatRequest, raw bool) (session *
 		session = &fsdb.Session{}
 	}
 
+	// A regenerated answer replaces the last one. It keeps the pattern, context
+	// and strategy of the request it answers, and with them its schema
+	if request.Regenerate {
+		if request.SessionName == "" {
+			return nil, errors.New("regenerating an answer needs a session")
+		}
+		if err = session.DropAnswer(); err != nil {
+			return nil, err
+		}
+		if metadata := session.Metadata(session.GetLastMessage()); metadata != nil && request.PatternName == "" {
+			request.PatternName, request.ContextName, request.StrategyName = metadata.Pattern, metadata.Context, metadata.Strategy
+		}
+		return
+	}
+	start := len(session.Messages)
+
 	if request.Meta != "" {
 		session.Append(&chat.ChatCompletionMessage{Role: domain.ChatMessageRoleMeta, Content: request.Meta})
 	}
@@ -206,6 +548,10 @@ func (o *Chatter) BuildSession(request *domain.ChatRequest, raw bool) (session *
 		}
 		patternContent = pattern.Pattern
 		inputUsed = true
+		// The strategy of the front matter is used when the request names none
+		if request.StrategyName == "" && pattern.Metadata != nil {
+			request.StrategyName = pattern.Metadata.Strategy
+		}
 	}
 
 	systemMessage := strings.TrimSpace(contextContent) + strings.TrimSpace(patternContent)
@@ -277,6 +623,13 @@ func (o *Chatter) BuildSession(request *domain.ChatRequest, raw bool) (session *
 		}
 	}
 
+	// The messages of the request record what they were built from
+	for _, message := range session.Messages[start:] {
+		if metadata := session.Metadata(message); metadata != nil {
+			metadata.Pattern, metadata.Context, metadata.Strategy = request.PatternName, request.ContextName, request.StrategyName
+		}
+	}
+
 	if session.IsEmpty() {
 		session = nil
 		err = errors.New(NoSessionPatternUserMessages)
@@ -4,21 +4,32 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/danielmiessler/fabric/internal/chat"
 	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/plugins/ai"
 	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+	"github.com/danielmiessler/fabric/internal/plugins/toolcall"
 )
 
 // mockVendor implements the ai.Vendor interface for testing
 type mockVendor struct {
+	name            string
 	sendStreamError error
 	streamChunks    []string
-	sendFunc        func(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions) (string, error)
+	streamUsage     *domain.UsageMetadata
+	streamFunc      func(context.Context, chan domain.StreamUpdate) error
+	sendFunc        func(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions) (*domain.ChatResponse, error)
 }
 
 func (m *mockVendor) GetName() string {
+	if m.name != "" {
+		return m.name
+	}
 	return "mock"
 }
 
@@ -45,23 +56,30 @@ func (m *mockVendor) ListModels() ([]string, error) {
 	return []string{"test-model"}, nil
 }
 
-func (m *mockVendor) SendStream(messages []*chat.ChatCompletionMessage, opts *domain.ChatOptions, responseChan chan string) error {
+func (m *mockVendor) SendStream(ctx context.Context, messages []*chat.ChatCompletionMessage, opts *domain.ChatOptions, responseChan chan domain.StreamUpdate) error {
+	if m.streamFunc != nil {
+		defer close(responseChan)
+		return m.streamFunc(ctx, responseChan)
+	}
 	// Send chunks if provided (for successful streaming test)
 	if m.streamChunks != nil {
 		for _, chunk := range m.streamChunks {
-			responseChan <- chunk
+			responseChan <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: chunk}
 		}
 	}
+	if m.streamUsage != nil {
+		responseChan <- domain.StreamUpdate{Type: domain.StreamTypeUsage, Usage: m.streamUsage}
+	}
 	// Close the channel like real vendors do
 	close(responseChan)
 	return m.sendStreamError
 }
 
-func (m *mockVendor) Send(ctx context.Context, messages []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (string, error) {
+func (m *mockVendor) Send(ctx context.Context, messages []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (*domain.ChatResponse, error) {
 	if m.sendFunc != nil {
 		return m.sendFunc(ctx, messages, opts)
 	}
-	return "test response", nil
+	return &domain.ChatResponse{Content: "test response"}, nil
 }
 
 func (m *mockVendor) NeedsRawMode(modelName string) bool {
@@ -96,11 +114,11 @@ func TestChatter_Send_SuppressThink(t *testing.T) {
 	}
 
 	// custom send function returning a message with think tags
-	mockVendor.sendFunc = func(ctx context.Context, msgs []*chat.ChatCompletionMessage, o *domain.ChatOptions) (string, error) {
-		return "<think>hidden</think> visible", nil
+	mockVendor.sendFunc = func(ctx context.Context, msgs []*chat.ChatCompletionMessage, o *domain.ChatOptions) (*domain.ChatResponse, error) {
+		return &domain.ChatResponse{Content: "<think>hidden</think> visible"}, nil
 	}
 
-	session, err := chatter.Send(request, opts)
+	session, err := chatter.Send(context.Background(), request, opts)
 	if err != nil {
 		t.Fatalf("Send returned error: %v", err)
 	}
@@ -146,7 +164,7 @@ func TestChatter_Send_StreamingErrorPropagation(t *testing.T) {
 	}
 
 	// Call Send and expect it to return the streaming error
-	session, err := chatter.Send(request, opts)
+	session, err := chatter.Send(context.Background(), request, opts)
 
 	// Verify that the error from SendStream is propagated
 	if err == nil {
@@ -200,7 +218,7 @@ func TestChatter_Send_StreamingSuccessfulAggregation(t *testing.T) {
 	}
 
 	// Call Send and expect successful aggregation
-	session, err := chatter.Send(request, opts)
+	session, err := chatter.Send(context.Background(), request, opts)
 
 	// Verify no error occurred
 	if err != nil {
@@ -228,3 +246,570 @@ func TestChatter_Send_StreamingSuccessfulAggregation(t *testing.T) {
 		t.Errorf("Expected aggregated message %q, got %q", expectedMessage, assistantMessage.Content)
 	}
 }
+
+func TestChatter_Send_CancelKeepsPartialResponse(t *testing.T) {
+	tempDir := t.TempDir()
+	db := fsdb.NewDb(tempDir)
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockVendor := &mockVendor{
+		streamFunc: func(ctx context.Context, responseChan chan domain.StreamUpdate) error {
+			responseChan <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: "partial"}
+			// Simulate Ctrl-C while the model is still generating
+			cancel()
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+	chatter := &Chatter{
+		db:     db,
+		Stream: true,
+		vendor: mockVendor,
+		model:  "test-model",
+	}
+	request := &domain.ChatRequest{
+		SessionName: "interrupted",
+		Message:     &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "hello"},
+	}
+
+	_, err := chatter.Send(ctx, request, &domain.ChatOptions{Model: "test-model"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	session, err := db.Sessions.Get("interrupted")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	last := session.GetLastMessage()
+	if last == nil || last.Role != chat.ChatMessageRoleAssistant || last.Content != "partial" {
+		t.Errorf("Expected the partial response to be saved, got %+v", last)
+	}
+}
+
+func TestChatter_Send_EmitsEvents(t *testing.T) {
+	db := fsdb.NewDb(t.TempDir())
+
+	citation := &domain.Citation{Title: "Example", URL: "https://example.com"}
+	mockVendor := &mockVendor{
+		streamFunc: func(ctx context.Context, responseChan chan domain.StreamUpdate) error {
+			responseChan <- domain.StreamUpdate{Type: domain.StreamTypeReasoning, Content: "pondering"}
+			responseChan <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: "answer"}
+			responseChan <- domain.StreamUpdate{Type: domain.StreamTypeCitation, Citation: citation}
+			responseChan <- domain.StreamUpdate{Type: domain.StreamTypeCitation, Citation: citation}
+			responseChan <- domain.StreamUpdate{Type: domain.StreamTypeUsage, Usage: &domain.UsageMetadata{InputTokens: 1, OutputTokens: 2}}
+			return nil
+		},
+	}
+	var events []domain.StreamUpdate
+	chatter := &Chatter{
+		db:     db,
+		Stream: true,
+		vendor: mockVendor,
+		model:  "test-model",
+		Sink:   OutputSinkFunc(func(update domain.StreamUpdate) { events = append(events, update) }),
+	}
+	request := &domain.ChatRequest{
+		Message: &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "hello"},
+	}
+
+	session, err := chatter.Send(context.Background(), request, &domain.ChatOptions{Model: "test-model"})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var types []domain.StreamType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	expected := []domain.StreamType{
+		domain.StreamTypeReasoning, domain.StreamTypeContent, domain.StreamTypeCitation,
+		domain.StreamTypeUsage, domain.StreamTypeDone,
+	}
+	if len(types) != len(expected) {
+		t.Fatalf("Expected events %v, got %v", expected, types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Fatalf("Expected events %v, got %v", expected, types)
+		}
+	}
+
+	want := "answer" + domain.FormatCitations([]domain.Citation{*citation})
+	if last := session.GetLastMessage(); last.Content != want {
+		t.Errorf("Expected the sources to be appended to the message, got %q", last.Content)
+	}
+	if done := events[len(events)-1]; done.Content != want {
+		t.Errorf("Expected done to carry the message, got %q", done.Content)
+	}
+}
+
+func TestChatter_Send_EmitsError(t *testing.T) {
+	db := fsdb.NewDb(t.TempDir())
+
+	mockVendor := &mockVendor{
+		streamFunc: func(ctx context.Context, responseChan chan domain.StreamUpdate) error {
+			responseChan <- domain.StreamUpdate{Type: domain.StreamTypeError, Err: errors.New("quota exceeded")}
+			return nil
+		},
+	}
+	var events []domain.StreamUpdate
+	chatter := &Chatter{
+		db:     db,
+		Stream: true,
+		vendor: mockVendor,
+		model:  "test-model",
+		Sink:   OutputSinkFunc(func(update domain.StreamUpdate) { events = append(events, update) }),
+	}
+	request := &domain.ChatRequest{
+		Message: &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "hello"},
+	}
+
+	_, err := chatter.Send(context.Background(), request, &domain.ChatOptions{Model: "test-model"})
+	if err == nil || err.Error() != "quota exceeded" {
+		t.Fatalf("Expected the reported error, got %v", err)
+	}
+	if len(events) != 1 || events[0].Type != domain.StreamTypeError || events[0].Err != err {
+		t.Errorf("Expected a single error event, got %+v", events)
+	}
+}
+
+func TestChatter_Send_Fallback(t *testing.T) {
+	tempDir := t.TempDir()
+	db := fsdb.NewDb(tempDir)
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+
+	rateLimited := &mockVendor{
+		name: "Primary",
+		sendFunc: func(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions) (*domain.ChatResponse, error) {
+			return nil, errors.New("unexpected status code: 429")
+		},
+	}
+	var fallbackModel string
+	fallback := &mockVendor{
+		name: "Fallback",
+		sendFunc: func(_ context.Context, _ []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (*domain.ChatResponse, error) {
+			fallbackModel = opts.Model
+			return &domain.ChatResponse{Content: "fallback answer"}, nil
+		},
+	}
+	chatter := &Chatter{
+		db:        db,
+		vendor:    rateLimited,
+		model:     "primary-model",
+		Fallbacks: []Fallback{{Vendor: fallback, Model: "fallback-model"}},
+	}
+	request := &domain.ChatRequest{
+		SessionName: "fallback",
+		Message:     &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "hello"},
+	}
+
+	if _, err := chatter.Send(context.Background(), request, &domain.ChatOptions{}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if fallbackModel != "fallback-model" {
+		t.Errorf("Expected the fallback to be asked with its own model, got %q", fallbackModel)
+	}
+
+	session, err := db.Sessions.Get("fallback")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	if session.Vendor != "Fallback" || session.Model != "fallback-model" {
+		t.Errorf("Expected the answering model to be recorded, got %s|%s", session.Vendor, session.Model)
+	}
+	if last := session.GetLastMessage(); last.Content != "fallback answer" {
+		t.Errorf("Expected the fallback answer, got %q", last.Content)
+	}
+}
+
+func TestChatter_Send_Regenerate(t *testing.T) {
+	db := fsdb.NewDb(t.TempDir())
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+
+	answers := []string{"first answer", "second answer"}
+	var sent []*chat.ChatCompletionMessage
+	var noCache bool
+	vendor := &mockVendor{
+		sendFunc: func(_ context.Context, messages []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (*domain.ChatResponse, error) {
+			sent, noCache = messages, opts.NoCache
+			answer := answers[0]
+			answers = answers[1:]
+			return &domain.ChatResponse{Content: answer}, nil
+		},
+	}
+	chatter := &Chatter{db: db, vendor: vendor, model: "test-model"}
+
+	request := &domain.ChatRequest{
+		SessionName: "regenerate",
+		Message:     &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "hello"},
+	}
+	if _, err := chatter.Send(context.Background(), request, &domain.ChatOptions{}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	session, err := chatter.Send(context.Background(), &domain.ChatRequest{SessionName: "regenerate", Regenerate: true}, &domain.ChatOptions{})
+	if err != nil {
+		t.Fatalf("Regenerate failed: %v", err)
+	}
+	if !noCache {
+		t.Error("Expected a regenerated answer to skip the response cache")
+	}
+	if len(sent) != 1 || sent[0].Content != "hello" {
+		t.Errorf("Expected only the user message to be sent again, got %v", sent)
+	}
+	if len(session.Messages) != 2 || session.GetLastMessage().Content != "second answer" {
+		t.Errorf("Expected the new answer in place of the old one, got %v", session.Messages)
+	}
+}
+
+func TestChatter_Send_NoFallbackOnPermanentError(t *testing.T) {
+	db := fsdb.NewDb(t.TempDir())
+
+	primary := &mockVendor{
+		sendFunc: func(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions) (*domain.ChatResponse, error) {
+			return nil, errors.New("invalid api key")
+		},
+	}
+	fallbackCalled := false
+	fallback := &mockVendor{
+		sendFunc: func(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions) (*domain.ChatResponse, error) {
+			fallbackCalled = true
+			return &domain.ChatResponse{Content: "fallback answer"}, nil
+		},
+	}
+	chatter := &Chatter{
+		db:        db,
+		vendor:    primary,
+		model:     "primary-model",
+		Fallbacks: []Fallback{{Vendor: fallback, Model: "fallback-model"}},
+	}
+	request := &domain.ChatRequest{
+		Message: &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "hello"},
+	}
+
+	if _, err := chatter.Send(context.Background(), request, &domain.ChatOptions{}); err == nil || err.Error() != "invalid api key" {
+		t.Fatalf("Expected the primary error, got %v", err)
+	}
+	if fallbackCalled {
+		t.Error("Expected no fallback after a permanent error")
+	}
+}
+
+func TestChatter_Send_RecordsUsage(t *testing.T) {
+	tempDir := t.TempDir()
+	db := fsdb.NewDb(tempDir)
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+
+	mockVendor := &mockVendor{
+		streamChunks: []string{"hi"},
+		streamUsage:  &domain.UsageMetadata{InputTokens: 1000, OutputTokens: 500, CachedTokens: 200},
+	}
+	chatter := &Chatter{
+		db:      db,
+		Stream:  true,
+		vendor:  mockVendor,
+		model:   "test-model",
+		pricing: ai.Pricing{"test-model": {Input: 2, CachedInput: 1, Output: 10}},
+	}
+	request := &domain.ChatRequest{
+		SessionName: "usage",
+		Message:     &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "hello"},
+	}
+
+	for range 2 {
+		if _, err := chatter.Send(context.Background(), request, &domain.ChatOptions{Model: "test-model"}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	session, err := db.Sessions.Get("usage")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	if session.Usage == nil {
+		t.Fatal("Expected usage to be saved with the session")
+	}
+	if session.Usage.InputTokens != 2000 || session.Usage.OutputTokens != 1000 {
+		t.Errorf("Expected usage totals over both requests, got %+v", session.Usage)
+	}
+	// (800*2 + 200*1 + 500*10) / 1M per request
+	if expected := 2 * 0.0068; session.Usage.Cost < expected-1e-9 || session.Usage.Cost > expected+1e-9 {
+		t.Errorf("Expected cost %v, got %v", expected, session.Usage.Cost)
+	}
+}
+
+func TestChatter_Send_ToolLoop(t *testing.T) {
+	tempDir := t.TempDir()
+	db := fsdb.NewDb(tempDir)
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+
+	registry := toolcall.NewRegistry()
+	var gotArguments string
+	err := registry.RegisterFunc("lookup", "Look something up", nil,
+		func(_ context.Context, arguments string) (string, error) {
+			gotArguments = arguments
+			return "42", nil
+		})
+	if err != nil {
+		t.Fatalf("RegisterFunc returned error: %v", err)
+	}
+
+	rounds := 0
+	mockVendor := &mockVendor{
+		sendFunc: func(_ context.Context, msgs []*chat.ChatCompletionMessage, o *domain.ChatOptions) (*domain.ChatResponse, error) {
+			rounds++
+			if len(o.Tools) != 1 || o.Tools[0].Function.Name != "lookup" {
+				t.Errorf("expected lookup tool to be advertised, got %+v", o.Tools)
+			}
+			last := msgs[len(msgs)-1]
+			if last.Role == chat.ChatMessageRoleTool {
+				return &domain.ChatResponse{Content: "The answer is " + last.Content}, nil
+			}
+			return &domain.ChatResponse{ToolCalls: []chat.ToolCall{{
+				ID:       "call_1",
+				Type:     chat.ToolTypeFunction,
+				Function: chat.FunctionCall{Name: "lookup", Arguments: `{"q":"answer"}`},
+			}}}, nil
+		},
+	}
+
+	chatter := &Chatter{db: db, vendor: mockVendor, model: "test-model", tools: registry}
+
+	tools, err := registry.Definitions()
+	if err != nil {
+		t.Fatalf("Definitions returned error: %v", err)
+	}
+	request := &domain.ChatRequest{
+		SessionName: "agent",
+		Message:     &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "what is the answer?"},
+	}
+	session, err := chatter.Send(context.Background(), request, &domain.ChatOptions{Model: "test-model", Tools: tools})
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	if rounds != 2 {
+		t.Errorf("expected 2 vendor round trips, got %d", rounds)
+	}
+	if gotArguments != `{"q":"answer"}` {
+		t.Errorf("unexpected tool arguments %q", gotArguments)
+	}
+
+	// user, assistant tool call, tool result, final answer
+	if len(session.Messages) != 4 {
+		t.Fatalf("expected 4 messages in session, got %d", len(session.Messages))
+	}
+	if len(session.Messages[1].ToolCalls) != 1 {
+		t.Errorf("expected assistant tool call to be recorded")
+	}
+	if session.Messages[2].Role != chat.ChatMessageRoleTool || session.Messages[2].ToolCallID != "call_1" {
+		t.Errorf("expected tool result for call_1, got %+v", session.Messages[2])
+	}
+	if session.GetLastMessage().Content != "The answer is 42" {
+		t.Errorf("unexpected final answer %q", session.GetLastMessage().Content)
+	}
+
+	saved, err := db.Sessions.Get("agent")
+	if err != nil {
+		t.Fatalf("could not load saved session: %v", err)
+	}
+	if len(saved.Messages) != 4 {
+		t.Errorf("expected the whole tool loop to be saved, got %d messages", len(saved.Messages))
+	}
+}
+
+func TestChatter_Send_ToolLoopGivesUp(t *testing.T) {
+	registry := toolcall.NewRegistry()
+	_ = registry.RegisterFunc("loop", "", nil, func(context.Context, string) (string, error) { return "", nil })
+
+	mockVendor := &mockVendor{
+		sendFunc: func(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions) (*domain.ChatResponse, error) {
+			return &domain.ChatResponse{ToolCalls: []chat.ToolCall{{ID: "x", Function: chat.FunctionCall{Name: "loop"}}}}, nil
+		},
+	}
+	chatter := &Chatter{db: fsdb.NewDb(t.TempDir()), vendor: mockVendor, model: "test-model", tools: registry}
+
+	tools, _ := registry.Definitions()
+	request := &domain.ChatRequest{Message: &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "go"}}
+	if _, err := chatter.Send(context.Background(), request, &domain.ChatOptions{Tools: tools}); err == nil {
+		t.Fatal("expected an error when the model never stops calling tools")
+	}
+}
+
+var personSchema = []byte(`{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`)
+
+func TestChatter_Send_SchemaRepair(t *testing.T) {
+	schema, err := domain.ParseSchema("person", personSchema)
+	if err != nil {
+		t.Fatalf("ParseSchema failed: %v", err)
+	}
+
+	var calls int
+	var repairRequest string
+	vendor := &mockVendor{
+		sendFunc: func(_ context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (*domain.ChatResponse, error) {
+			calls++
+			if opts.Schema != schema {
+				t.Errorf("Expected the schema to be passed to the vendor")
+			}
+			if calls == 1 {
+				return &domain.ChatResponse{Content: `{"age": 42}`}, nil
+			}
+			repairRequest = msgs[len(msgs)-1].Content
+			return &domain.ChatResponse{Content: "```json\n{\"name\": \"Ada\"}\n```"}, nil
+		},
+	}
+	var output []string
+	chatter := &Chatter{
+		db:     fsdb.NewDb(t.TempDir()),
+		vendor: vendor,
+		model:  "test-model",
+		Stream: true,
+		Sink: OutputSinkFunc(func(update domain.StreamUpdate) {
+			if update.Type == domain.StreamTypeContent {
+				output = append(output, update.Content)
+			}
+		}),
+	}
+	request := &domain.ChatRequest{
+		Message: &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "who?"},
+	}
+
+	session, err := chatter.Send(context.Background(), request, &domain.ChatOptions{Schema: schema})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if calls != 2 || !strings.Contains(repairRequest, "name") {
+		t.Errorf("Expected one repair request naming the missing property, got %d calls and %q", calls, repairRequest)
+	}
+	if last := session.GetLastMessage(); last.Content != `{"name": "Ada"}` {
+		t.Errorf("Expected the repaired JSON without code fence, got %q", last.Content)
+	}
+	if len(output) != 1 || output[0] != `{"name": "Ada"}` {
+		t.Errorf("Expected only the valid response to be passed on, got %q", output)
+	}
+}
+
+func TestChatter_Send_SchemaStillInvalid(t *testing.T) {
+	tempDir := t.TempDir()
+	db := fsdb.NewDb(tempDir)
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	// The schema of the pattern applies without --schema
+	if err := os.MkdirAll(filepath.Join(db.Patterns.Dir, "person"), 0755); err != nil {
+		t.Fatalf("failed to create pattern dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(db.Patterns.Dir, "person", "system.md"), []byte("Name a person"), 0644); err != nil {
+		t.Fatalf("failed to write pattern: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(db.Patterns.Dir, "person", fsdb.SchemaFile), personSchema, 0644); err != nil {
+		t.Fatalf("failed to write schema: %v", err)
+	}
+
+	var calls int
+	vendor := &mockVendor{
+		sendFunc: func(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions) (*domain.ChatResponse, error) {
+			calls++
+			return &domain.ChatResponse{Content: "Ada Lovelace"}, nil
+		},
+	}
+	chatter := &Chatter{db: db, vendor: vendor, model: "test-model"}
+	request := &domain.ChatRequest{
+		SessionName: "person",
+		PatternName: "person",
+		Message:     &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "who?"},
+	}
+
+	_, err := chatter.Send(context.Background(), request, &domain.ChatOptions{})
+	if err == nil || !strings.Contains(err.Error(), "does not match schema person") {
+		t.Fatalf("Expected a schema error, got %v", err)
+	}
+	if calls != 1+MaxSchemaRepairs {
+		t.Errorf("Expected %d calls, got %d", 1+MaxSchemaRepairs, calls)
+	}
+
+	session, err := db.Sessions.Get("person")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	if last := session.GetLastMessage(); last.Content != "Ada Lovelace" {
+		t.Errorf("Expected the invalid response to be kept in the session, got %q", last.Content)
+	}
+}
+
+func TestChatter_Send_PatternMetadata(t *testing.T) {
+	db := fsdb.NewDb(t.TempDir())
+	if err := os.MkdirAll(filepath.Join(db.Patterns.Dir, "extract"), 0755); err != nil {
+		t.Fatalf("failed to create pattern dir: %v", err)
+	}
+	pattern := "---\ntemperature: 0.2\nformat: json\nvariables:\n  - name: count\n    default: \"3\"\n---\nExtract {{count}} facts as JSON."
+	if err := os.WriteFile(filepath.Join(db.Patterns.Dir, "extract", "system.md"), []byte(pattern), 0644); err != nil {
+		t.Fatalf("failed to write pattern: %v", err)
+	}
+
+	var sent []*chat.ChatCompletionMessage
+	var sentOpts *domain.ChatOptions
+	calls, answer := 0, `{"facts": []}`
+	vendor := &mockVendor{
+		sendFunc: func(_ context.Context, messages []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (*domain.ChatResponse, error) {
+			sent, sentOpts = messages, opts
+			calls++
+			return &domain.ChatResponse{Content: answer}, nil
+		},
+	}
+	chatter := &Chatter{db: db, vendor: vendor, model: "test-model"}
+	request := &domain.ChatRequest{
+		PatternName: "extract",
+		Message:     &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "text"},
+	}
+
+	if _, err := chatter.Send(context.Background(), request, &domain.ChatOptions{Temperature: domain.DefaultTemperature}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if sentOpts.Temperature != 0.2 {
+		t.Errorf("Expected the temperature of the front matter, got %v", sentOpts.Temperature)
+	}
+	if !sentOpts.JSONFormat || sentOpts.Schema != nil {
+		t.Errorf("Expected a JSON pattern to hint the format without a schema, got %v and %+v", sentOpts.JSONFormat, sentOpts.Schema)
+	}
+	if len(sent) == 0 || sent[0].Content != "Extract 3 facts as JSON.\ntext" {
+		t.Errorf("Expected the pattern without its front matter and with the default, got %+v", sent)
+	}
+
+	// A temperature set on the request wins, even when it is the default one
+	for _, temperature := range []float64{1, domain.DefaultTemperature} {
+		request.Message = &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "text"}
+		if _, err := chatter.Send(context.Background(), request, &domain.ChatOptions{Temperature: temperature, TemperatureSet: true}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		if sentOpts.Temperature != temperature {
+			t.Errorf("Expected the temperature %v of the request, got %v", temperature, sentOpts.Temperature)
+		}
+	}
+
+	// Without a schema the answers of a JSON pattern are neither checked nor repaired
+	calls, answer = 0, "no JSON here"
+	request.Message = &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "text"}
+	session, err := chatter.Send(context.Background(), request, &domain.ChatOptions{})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if calls != 1 || session.GetLastMessage().Content != answer {
+		t.Errorf("Expected the answer as is after one call, got %q after %d", session.GetLastMessage().Content, calls)
+	}
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+// CompareResult is the answer of one model to a compared request.
+type CompareResult struct {
+	Vendor  string
+	Model   string
+	Content string
+	Latency time.Duration
+	Usage   *domain.UsageMetadata
+	Err     error
+}
+
+type compareResult struct {
+	index  int
+	result *CompareResult
+}
+
+// Compare builds the session of request once and sends it to every
+// candidate at the same time. The results are in the order of candidates;
+// a model that fails has its error in the result. The answers are not added
+// to the session, and Compare only fails when every model did.
+func (o *Chatter) Compare(ctx context.Context, request *domain.ChatRequest, opts *domain.ChatOptions,
+	candidates []Fallback) (results []*CompareResult, err error) {
+	if len(candidates) == 0 {
+		err = errors.New("no models to compare")
+		return
+	}
+
+	// All models get the same session, so it is raw if any of them needs it
+	for _, candidate := range candidates {
+		if candidate.Vendor.NeedsRawMode(candidate.Model) {
+			opts.Raw = true
+		}
+	}
+	var session *fsdb.Session
+	if session, err = o.BuildSession(request, opts.Raw); err != nil {
+		return
+	}
+	messages := session.GetVendorMessages()
+
+	var wg sync.WaitGroup
+	resultsChan := make(chan compareResult, len(candidates))
+	for i, candidate := range candidates {
+		wg.Add(1)
+		go o.compareCandidate(ctx, &wg, i, candidate, messages, *opts, resultsChan)
+	}
+
+	// Wait for all goroutines to finish
+	go func() {
+		wg.Wait()
+		close(resultsChan)
+	}()
+
+	// Collect results
+	results = make([]*CompareResult, len(candidates))
+	failed := 0
+	for result := range resultsChan {
+		results[result.index] = result.result
+		if result.result.Err != nil {
+			failed++
+		}
+	}
+	if failed == len(candidates) {
+		errs := make([]error, 0, len(results))
+		for _, result := range results {
+			errs = append(errs, result.Err)
+		}
+		err = errors.Join(errs...)
+	}
+	return
+}
+
+// compareCandidate sends messages to one candidate, with its own copy of the
+// options, and reports the answer on resultsChan.
+func (o *Chatter) compareCandidate(ctx context.Context, wg *sync.WaitGroup, index int, candidate Fallback,
+	messages []*chat.ChatCompletionMessage, opts domain.ChatOptions, resultsChan chan<- compareResult) {
+
+	defer wg.Done()
+
+	// Tools are run by the chatter loop, which shares the session, so they are left out
+	opts.Model = candidate.Model
+	opts.Tools = nil
+	result := &CompareResult{Vendor: candidate.Vendor.GetName(), Model: candidate.Model}
+
+	start := time.Now()
+	response, err := candidate.Vendor.Send(ctx, messages, &opts)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Err = err
+	} else {
+		result.Content = response.Content
+		if opts.SuppressThink && !o.DryRun {
+			result.Content = domain.StripThinkBlocks(result.Content, opts.ThinkStartTag, opts.ThinkEndTag)
+		}
+		if result.Usage = response.Usage; result.Usage != nil && result.Usage.Cost == 0 {
+			result.Usage.Cost, _ = o.pricing.Cost(result.Vendor, result.Model, result.Usage)
+		}
+	}
+	resultsChan <- compareResult{index: index, result: result}
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+func TestChatter_Compare(t *testing.T) {
+	db := fsdb.NewDb(t.TempDir())
+
+	// Both models block until the other one was called, so they must run at the same time
+	var started sync.WaitGroup
+	started.Add(2)
+	answering := func(content string) *mockVendor {
+		return &mockVendor{name: content, sendFunc: func(_ context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (*domain.ChatResponse, error) {
+			started.Done()
+			started.Wait()
+			return &domain.ChatResponse{
+				Content: msgs[len(msgs)-1].Content + " " + opts.Model,
+				Usage:   &domain.UsageMetadata{InputTokens: 3, OutputTokens: 5},
+			}, nil
+		}}
+	}
+	failing := &mockVendor{name: "failing", sendFunc: func(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions) (*domain.ChatResponse, error) {
+		return nil, errors.New("unavailable")
+	}}
+
+	chatter := &Chatter{db: db, vendor: &mockVendor{}, model: "test-model"}
+	request := &domain.ChatRequest{
+		SessionName: "kept",
+		Message:     &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "hello"},
+	}
+	candidates := []Fallback{
+		{Vendor: answering("first"), Model: "model-a"},
+		{Vendor: failing, Model: "model-b"},
+		{Vendor: answering("third"), Model: "model-c"},
+	}
+
+	results, err := chatter.Compare(context.Background(), request, &domain.ChatOptions{}, candidates)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected a result per model, got %d", len(results))
+	}
+	if results[0].Vendor != "first" || results[0].Content != "hello model-a" || results[2].Content != "hello model-c" {
+		t.Errorf("Expected the results in the order of the models, got %+v and %+v", results[0], results[2])
+	}
+	if results[1].Err == nil || results[1].Model != "model-b" {
+		t.Errorf("Expected the failing model to keep its error, got %+v", results[1])
+	}
+	if results[0].Usage == nil || results[0].Usage.OutputTokens != 5 {
+		t.Errorf("Expected the usage of each model, got %+v", results[0].Usage)
+	}
+
+	session, err := db.Sessions.Get("kept")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	if len(session.Messages) != 0 {
+		t.Errorf("Expected the answers not to be saved, got %d messages", len(session.Messages))
+	}
+}
+
+func TestChatter_Compare_AllFail(t *testing.T) {
+	chatter := &Chatter{db: fsdb.NewDb(t.TempDir()), vendor: &mockVendor{}, model: "test-model"}
+	request := &domain.ChatRequest{Message: &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "hello"}}
+	failing := &mockVendor{sendFunc: func(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions) (*domain.ChatResponse, error) {
+		return nil, errors.New("unavailable")
+	}}
+
+	results, err := chatter.Compare(context.Background(), request, &domain.ChatOptions{},
+		[]Fallback{{Vendor: failing, Model: "a"}, {Vendor: failing, Model: "b"}})
+	if err == nil {
+		t.Fatal("Expected an error when every model fails")
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected the failed results to be returned, got %d", len(results))
+	}
+
+	if _, err := chatter.Compare(context.Background(), request, &domain.ChatOptions{}, nil); err == nil {
+		t.Error("Expected an error without models")
+	}
+}
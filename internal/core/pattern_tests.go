@@ -0,0 +1,233 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/plugins"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+// The modes of a pattern test. Live tests ask a model, record tests also save
+// its answers as the golden files of the fixtures; recorded and mock tests run
+// offline on the golden files and the mock responses of the fixtures.
+const (
+	PatternTestLive     = "live"
+	PatternTestRecord   = "record"
+	PatternTestRecorded = "recorded"
+	PatternTestMock     = "mock"
+)
+
+// PatternTestModes lists the modes in the order they are documented.
+var PatternTestModes = []string{PatternTestLive, PatternTestRecord, PatternTestRecorded, PatternTestMock}
+
+const judgePrompt = "You grade the answer an AI gave to an input against a rubric.\n" +
+	"Reply with PASS or FAIL on the first line, then one sentence explaining why."
+
+// FixtureResult is the outcome of one fixture of a pattern test.
+type FixtureResult struct {
+	Fixture    *fsdb.PatternFixture
+	Output     string
+	Duration   time.Duration
+	Assertions []AssertionResult
+	// Err is set when the fixture could not be run
+	Err error
+	// Skipped fixtures have no answer in the mode of the test
+	Skipped bool
+}
+
+// Failed reports whether the fixture could not be run or an assertion failed.
+func (o *FixtureResult) Failed() bool {
+	if o.Err != nil {
+		return true
+	}
+	for _, assertion := range o.Assertions {
+		if assertion.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// AssertionResult is the outcome of one assertion; Err tells why it failed.
+type AssertionResult struct {
+	Assertion *fsdb.FixtureAssertion
+	Err       error
+	// Skipped rubrics had no model to judge them
+	Skipped bool
+}
+
+// PatternTester runs the test fixtures of patterns.
+type PatternTester struct {
+	db   *fsdb.Db
+	mode string
+	// getChatter returns the chatter of live and record tests
+	getChatter func() (*Chatter, error)
+
+	// Judge grades rubrics; in live and record tests it defaults to the tested model
+	Judge *Fallback
+	// Language is that of every request
+	Language string
+}
+
+// NewPatternTester returns a tester running fixtures in mode, asking the
+// chatters of getChatter in live and record tests.
+func NewPatternTester(db *fsdb.Db, mode string, getChatter func() (*Chatter, error)) (ret *PatternTester, err error) {
+	switch mode {
+	case "":
+		mode = PatternTestLive
+	case PatternTestLive, PatternTestRecord, PatternTestRecorded, PatternTestMock:
+	default:
+		return nil, fmt.Errorf("unknown test mode %s, use one of %s", mode, strings.Join(PatternTestModes, ", "))
+	}
+	return &PatternTester{db: db, mode: mode, getChatter: getChatter}, nil
+}
+
+// Run runs every fixture of pattern with opts and returns their results in
+// the order of the fixtures. It only fails when the fixtures cannot be read.
+func (o *PatternTester) Run(ctx context.Context, pattern string, opts *domain.ChatOptions) (results []*FixtureResult, err error) {
+	var fixtures []*fsdb.PatternFixture
+	if fixtures, err = o.db.Patterns.GetFixtures(pattern); err != nil {
+		return
+	}
+	for _, fixture := range fixtures {
+		results = append(results, o.runFixture(ctx, pattern, fixture, *opts))
+	}
+	return
+}
+
+func (o *PatternTester) runFixture(ctx context.Context, pattern string, fixture *fsdb.PatternFixture,
+	opts domain.ChatOptions) (ret *FixtureResult) {
+	ret = &FixtureResult{Fixture: fixture}
+
+	var chatter *Chatter
+	if chatter, ret.Skipped, ret.Err = o.chatter(fixture); ret.Skipped || ret.Err != nil {
+		return
+	}
+	request := &domain.ChatRequest{
+		PatternName:      pattern,
+		PatternVariables: fixture.Variables,
+		Message:          &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: fixture.Input},
+		Language:         o.Language,
+	}
+
+	start := time.Now()
+	session, err := chatter.Send(ctx, request, &opts)
+	ret.Duration = time.Since(start)
+	if err != nil {
+		ret.Err = err
+		return
+	}
+	ret.Output = session.GetLastMessage().Content
+	if o.mode == PatternTestRecord {
+		if err = os.WriteFile(fixture.GoldenFile(), []byte(ret.Output), 0644); err != nil {
+			ret.Err = fmt.Errorf("could not record the answer: %w", err)
+			return
+		}
+	}
+
+	judge := o.Judge
+	if judge == nil && (o.mode == PatternTestLive || o.mode == PatternTestRecord) {
+		judge = &Fallback{Vendor: chatter.vendor, Model: chatter.model}
+	}
+	for i := range fixture.Assertions {
+		assertion := &fixture.Assertions[i]
+		result := AssertionResult{Assertion: assertion}
+		if assertion.Rubric == "" {
+			result.Err = assertion.Check(ret.Output)
+		} else if judge == nil {
+			result.Skipped = true
+		} else {
+			result.Err = o.judge(ctx, judge, assertion.Rubric, fixture.Input, ret.Output, opts)
+		}
+		ret.Assertions = append(ret.Assertions, result)
+	}
+	return
+}
+
+// chatter returns the chatter answering fixture in the mode of the test, or
+// skipped when the fixture has no answer in this mode.
+func (o *PatternTester) chatter(fixture *fsdb.PatternFixture) (ret *Chatter, skipped bool, err error) {
+	var response string
+	switch o.mode {
+	case PatternTestMock:
+		if fixture.MockResponse == "" {
+			return nil, true, nil
+		}
+		response = fixture.MockResponse
+	case PatternTestRecorded:
+		var data []byte
+		if data, err = os.ReadFile(fixture.GoldenFile()); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, true, nil
+			}
+			return
+		}
+		response = string(data)
+	default:
+		ret, err = o.getChatter()
+		return
+	}
+	return &Chatter{db: o.db, vendor: newStaticVendor(o.mode, response), model: o.mode}, false, nil
+}
+
+// judge asks model whether output meets rubric.
+func (o *PatternTester) judge(ctx context.Context, model *Fallback, rubric, input, output string, opts domain.ChatOptions) error {
+	opts.Model = model.Model
+	opts.Temperature = 0
+	opts.Schema = nil
+	opts.Tools = nil
+	messages := []*chat.ChatCompletionMessage{
+		{Role: chat.ChatMessageRoleSystem, Content: judgePrompt},
+		{Role: chat.ChatMessageRoleUser, Content: fmt.Sprintf("RUBRIC:\n%s\n\nINPUT:\n%s\n\nANSWER:\n%s", rubric, input, output)},
+	}
+	response, err := model.Vendor.Send(ctx, messages, &opts)
+	if err != nil {
+		return fmt.Errorf("the judge failed: %w", err)
+	}
+	verdict, reason, _ := strings.Cut(strings.TrimSpace(response.Content), "\n")
+	verdict = strings.ToUpper(strings.TrimSpace(verdict))
+	switch {
+	case strings.HasPrefix(verdict, "PASS"):
+		return nil
+	case strings.HasPrefix(verdict, "FAIL"):
+		return fmt.Errorf("the judge failed %q: %s", rubric, strings.TrimSpace(reason))
+	default:
+		return fmt.Errorf("the judge answered neither PASS nor FAIL: %s", response.Content)
+	}
+}
+
+// staticVendor answers every request with the same response, standing in for
+// a model in offline tests.
+type staticVendor struct {
+	*plugins.PluginBase
+	response string
+}
+
+func newStaticVendor(name, response string) *staticVendor {
+	return &staticVendor{PluginBase: &plugins.PluginBase{Name: name}, response: response}
+}
+
+func (o *staticVendor) ListModels() ([]string, error) {
+	return []string{o.Name}, nil
+}
+
+func (o *staticVendor) Send(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions) (*domain.ChatResponse, error) {
+	return &domain.ChatResponse{Content: o.response}, nil
+}
+
+func (o *staticVendor) SendStream(_ context.Context, _ []*chat.ChatCompletionMessage, _ *domain.ChatOptions, channel chan domain.StreamUpdate) error {
+	defer close(channel)
+	channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: o.response}
+	return nil
+}
+
+func (o *staticVendor) NeedsRawMode(string) bool {
+	return false
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+func writePatternFixtures(t *testing.T, db *fsdb.Db, pattern string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(db.Patterns.Dir, pattern, fsdb.PatternTestsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("failed to create tests dir: %v", err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write fixture: %v", err)
+		}
+	}
+}
+
+func TestPatternTester_Mock(t *testing.T) {
+	db := newPipelineDb(t, "summarize")
+	writePatternFixtures(t, db, "summarize", map[string]string{
+		"good.yaml":    "input: text\nvariables: {lang: en}\nmock_response: '{\"summary\": \"short\"}'\nassertions:\n  - contains: short\n  - regex: '\"summary\"'\n  - json_schema: {type: object, required: [summary]}\n  - rubric: It is short\n",
+		"bad.yaml":     "input: text\nvariables: {lang: en}\nmock_response: a very long answer\nassertions:\n  - max_length: 5\n  - contains: short\n",
+		"missing.yaml": "input: text\nvariables: {lang: en}\nassertions:\n  - contains: short\n",
+		"broken.yaml":  "input: text\nmock_response: answer\nassertions:\n  - contains: answer\n",
+	})
+
+	tester, err := NewPatternTester(db, PatternTestMock, nil)
+	if err != nil {
+		t.Fatalf("NewPatternTester failed: %v", err)
+	}
+	results, err := tester.Run(context.Background(), "summarize", &domain.ChatOptions{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected a result per fixture, got %d", len(results))
+	}
+
+	bad, broken, good, missing := results[0], results[1], results[2], results[3]
+	if !bad.Failed() || bad.Assertions[0].Err == nil || bad.Assertions[1].Err == nil {
+		t.Errorf("Expected both assertions of bad to fail, got %+v", bad.Assertions)
+	}
+	if broken.Err == nil || !strings.Contains(broken.Err.Error(), "lang") {
+		t.Errorf("Expected broken to miss its variable, got %v", broken.Err)
+	}
+	if good.Failed() || good.Output != `{"summary": "short"}` || !good.Assertions[3].Skipped {
+		t.Errorf("Expected good to pass without judging its rubric, got %+v", good)
+	}
+	if !missing.Skipped || missing.Failed() {
+		t.Errorf("Expected missing to be skipped, got %+v", missing)
+	}
+}
+
+func TestPatternTester_RecordAndJudge(t *testing.T) {
+	db := newPipelineDb(t, "summarize")
+	writePatternFixtures(t, db, "summarize", map[string]string{
+		"first.yaml": "input: text\nvariables: {lang: en}\nassertions:\n  - rubric: It is a summary\n",
+	})
+
+	var judged []string
+	tester, err := NewPatternTester(db, PatternTestRecord, func() (*Chatter, error) {
+		vendor := &mockVendor{sendFunc: func(_ context.Context, msgs []*chat.ChatCompletionMessage, _ *domain.ChatOptions) (*domain.ChatResponse, error) {
+			if msgs[0].Content == judgePrompt {
+				judged = append(judged, msgs[1].Content)
+				return &domain.ChatResponse{Content: "FAIL\nIt repeats the input."}, nil
+			}
+			return &domain.ChatResponse{Content: "the summary"}, nil
+		}}
+		return &Chatter{db: db, vendor: vendor, model: "test-model"}, nil
+	})
+	if err != nil {
+		t.Fatalf("NewPatternTester failed: %v", err)
+	}
+	results, err := tester.Run(context.Background(), "summarize", &domain.ChatOptions{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if assertion := results[0].Assertions[0]; assertion.Err == nil || !strings.Contains(assertion.Err.Error(), "repeats the input") {
+		t.Errorf("Expected the judge to fail the rubric, got %v", assertion.Err)
+	}
+	if len(judged) != 1 || !strings.Contains(judged[0], "ANSWER:\nthe summary") {
+		t.Errorf("Expected the tested model to judge the answer, got %q", judged)
+	}
+
+	golden, err := os.ReadFile(results[0].Fixture.GoldenFile())
+	if err != nil || string(golden) != "the summary" {
+		t.Fatalf("Expected the answer to be recorded, got %q, %v", golden, err)
+	}
+	if tester, err = NewPatternTester(db, PatternTestRecorded, nil); err != nil {
+		t.Fatalf("NewPatternTester failed: %v", err)
+	}
+	if results, err = tester.Run(context.Background(), "summarize", &domain.ChatOptions{}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if results[0].Output != "the summary" || results[0].Failed() {
+		t.Errorf("Expected the recorded answer to be replayed, got %+v", results[0])
+	}
+
+	if _, err = NewPatternTester(db, "replay", nil); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
@@ -0,0 +1,276 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+	"gopkg.in/yaml.v3"
+)
+
+// Pipeline is a sequence of patterns, each run on the output of the one
+// before it. It is either given as a comma separated list of patterns or
+// read from a YAML file:
+//
+//	session: wisdom
+//	steps:
+//	  - pattern: extract_wisdom
+//	    model: Anthropic|claude-sonnet-4-5
+//	  - pattern: translate
+//	    variables: {lang_code: de}
+//	  - pattern: create_tags
+//	    when: {contains: "AI"}
+type Pipeline struct {
+	Name string `yaml:"name"`
+	// Session records every step when the request names no session
+	Session string         `yaml:"session"`
+	Steps   []PipelineStep `yaml:"steps"`
+}
+
+// PipelineStep runs a pattern with its own model, strategy and variables,
+// falling back to those of the request for anything it leaves out.
+type PipelineStep struct {
+	Pattern string `yaml:"pattern"`
+	Vendor  string `yaml:"vendor"`
+	// Model is a model name or Vendor|model
+	Model     string            `yaml:"model"`
+	Strategy  string            `yaml:"strategy"`
+	Variables map[string]string `yaml:"variables"`
+	// When skips the step unless its input matches; a skipped step passes its input on
+	When *StepCondition `yaml:"when"`
+}
+
+// StepCondition is met when the input of a step matches all of its fields.
+type StepCondition struct {
+	Contains    string `yaml:"contains"`
+	NotContains string `yaml:"not_contains"`
+	Matches     string `yaml:"matches"`
+
+	matches *regexp.Regexp
+}
+
+// Met reports whether input meets the condition.
+func (o *StepCondition) Met(input string) bool {
+	return (o.Contains == "" || strings.Contains(input, o.Contains)) &&
+		(o.NotContains == "" || !strings.Contains(input, o.NotContains)) &&
+		(o.matches == nil || o.matches.MatchString(input))
+}
+
+// ParseChain builds a pipeline from a comma separated list of patterns.
+func ParseChain(spec string) (ret *Pipeline, err error) {
+	ret = &Pipeline{}
+	for pattern := range strings.SplitSeq(spec, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			ret.Steps = append(ret.Steps, PipelineStep{Pattern: pattern})
+		}
+	}
+	err = ret.validate()
+	return
+}
+
+// LoadPipeline reads a pipeline from a YAML file.
+func LoadPipeline(path string) (ret *Pipeline, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		err = fmt.Errorf("could not read pipeline %s: %w", path, err)
+		return
+	}
+	ret = &Pipeline{}
+	if err = yaml.Unmarshal(data, ret); err == nil {
+		err = ret.validate()
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid pipeline %s: %w", path, err)
+	}
+	return
+}
+
+func (o *Pipeline) validate() (err error) {
+	if len(o.Steps) == 0 {
+		return errors.New("a pipeline needs at least one step")
+	}
+	for i := range o.Steps {
+		step := &o.Steps[i]
+		if step.Pattern == "" {
+			return fmt.Errorf("step %d has no pattern", i+1)
+		}
+		if step.When != nil && step.When.Matches != "" {
+			if step.When.matches, err = regexp.Compile(step.When.Matches); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+		}
+	}
+	return
+}
+
+// StepResult is the outcome of a pipeline step.
+type StepResult struct {
+	Pattern  string
+	Vendor   string
+	Model    string
+	Output   string
+	Duration time.Duration
+	Skipped  bool
+	Usage    *domain.UsageMetadata
+}
+
+// PipelineRunner runs pipelines in one process, passing the output of each
+// step to the next as its input.
+type PipelineRunner struct {
+	db         *fsdb.Db
+	getChatter func(step *PipelineStep) (*Chatter, error)
+
+	// Sink receives the events of the last step, the others run silently
+	Sink OutputSink
+	// Stream streams the last step
+	Stream bool
+	// OnStep is called after each step, including skipped ones
+	OnStep func(index int, result *StepResult)
+}
+
+// NewPipelineRunner returns a runner that asks getChatter for the chatter of
+// each step.
+func NewPipelineRunner(db *fsdb.Db, getChatter func(step *PipelineStep) (*Chatter, error)) *PipelineRunner {
+	return &PipelineRunner{db: db, getChatter: getChatter}
+}
+
+// Run runs the steps of pipeline on the message of request. The context,
+// language and variables of request apply to every step, its schema only to
+// the last. Steps do not see each other's conversation; when request or the
+// pipeline names a session, each step is recorded in it, headed by a meta
+// message naming the step.
+func (o *PipelineRunner) Run(ctx context.Context, pipeline *Pipeline, request *domain.ChatRequest, opts *domain.ChatOptions) (
+	results []*StepResult, err error) {
+	// Variables are checked before the first step calls a vendor, for every step
+	// but the conditional ones, which may never run
+	if !request.NoVariableReplacement {
+		for i := range pipeline.Steps {
+			step := &pipeline.Steps[i]
+			if step.When != nil {
+				continue
+			}
+			if err = o.db.Patterns.CheckVariables(step.Pattern, stepVariables(request, step)); err != nil {
+				err = fmt.Errorf("step %d (%s): %w", i+1, step.Pattern, err)
+				return
+			}
+		}
+	}
+
+	sessionName := request.SessionName
+	if sessionName == "" {
+		sessionName = pipeline.Session
+	}
+	var record *fsdb.Session
+	if sessionName != "" {
+		defer o.db.Sessions.Lock(sessionName)()
+		if record, err = o.db.Sessions.Get(sessionName); err != nil {
+			return
+		}
+		// Steps that ran are recorded even when a later one fails
+		defer func() {
+			if saveErr := o.db.Sessions.SaveSession(record); saveErr != nil {
+				err = errors.Join(err, saveErr)
+			}
+		}()
+	}
+
+	message := request.Message
+	if message == nil {
+		message = &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser}
+	}
+	for i := range pipeline.Steps {
+		step := &pipeline.Steps[i]
+		last := i == len(pipeline.Steps)-1
+		result := &StepResult{Pattern: step.Pattern}
+
+		if step.When != nil && !step.When.Met(message.Content) {
+			result.Skipped = true
+			result.Output = message.Content
+			results = append(results, result)
+			o.stepDone(i, result)
+			continue
+		}
+
+		var chatter *Chatter
+		if chatter, err = o.getChatter(step); err != nil {
+			err = fmt.Errorf("step %d (%s): %w", i+1, step.Pattern, err)
+			return
+		}
+		chatter.Stream = o.Stream && last
+		if last {
+			chatter.Sink = o.Sink
+		}
+
+		strategy := step.Strategy
+		if strategy == "" {
+			strategy = request.StrategyName
+		}
+		stepRequest := &domain.ChatRequest{
+			ContextName:           request.ContextName,
+			PatternName:           step.Pattern,
+			PatternVariables:      stepVariables(request, step),
+			Message:               message,
+			Language:              request.Language,
+			InputHasVars:          request.InputHasVars,
+			NoVariableReplacement: request.NoVariableReplacement,
+			StrategyName:          strategy,
+		}
+		stepOpts := *opts
+		if !last {
+			stepOpts.Schema = nil
+		}
+
+		start := time.Now()
+		var session *fsdb.Session
+		session, err = chatter.Send(ctx, stepRequest, &stepOpts)
+		result.Duration = time.Since(start)
+		if err != nil {
+			err = fmt.Errorf("step %d (%s): %w", i+1, step.Pattern, err)
+			return
+		}
+
+		result.Output = session.GetLastMessage().Content
+		result.Vendor, result.Model, result.Usage = session.Vendor, session.Model, session.Usage
+		results = append(results, result)
+		o.stepDone(i, result)
+
+		if record != nil {
+			record.Append(&chat.ChatCompletionMessage{
+				Role:    domain.ChatMessageRoleMeta,
+				Content: fmt.Sprintf("step %d: %s (%s|%s, %v)", i+1, step.Pattern, result.Vendor, result.Model, result.Duration.Round(time.Millisecond)),
+			})
+			for _, message := range session.Messages {
+				record.Append(message)
+				record.SetMetadata(message, session.Metadata(message))
+			}
+			record.Vendor, record.Model = session.Vendor, session.Model
+			record.AddUsage(session.Usage)
+		}
+		message = &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: result.Output}
+	}
+	return
+}
+
+// stepVariables merges the variables of step over those of request.
+func stepVariables(request *domain.ChatRequest, step *PipelineStep) (ret map[string]string) {
+	ret = maps.Clone(request.PatternVariables)
+	if ret == nil {
+		ret = map[string]string{}
+	}
+	maps.Copy(ret, step.Variables)
+	return
+}
+
+func (o *PipelineRunner) stepDone(index int, result *StepResult) {
+	if o.OnStep != nil {
+		o.OnStep(index, result)
+	}
+}
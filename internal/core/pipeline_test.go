@@ -0,0 +1,192 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+)
+
+// newPipelineDb creates a db with patterns that echo their name and variables
+func newPipelineDb(t *testing.T, patterns ...string) *fsdb.Db {
+	t.Helper()
+	db := fsdb.NewDb(t.TempDir())
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	for _, pattern := range patterns {
+		dir := filepath.Join(db.Patterns.Dir, pattern)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create pattern dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "system.md"), []byte(pattern+" {{lang}}:"), 0644); err != nil {
+			t.Fatalf("failed to write pattern: %v", err)
+		}
+	}
+	return db
+}
+
+// appendingVendor answers with its input followed by the pattern it was run with
+func appendingVendor() *mockVendor {
+	return &mockVendor{
+		sendFunc: func(_ context.Context, msgs []*chat.ChatCompletionMessage, _ *domain.ChatOptions) (*domain.ChatResponse, error) {
+			system := msgs[0].Content
+			name, input, _ := strings.Cut(system, ":")
+			return &domain.ChatResponse{Content: strings.TrimSpace(input) + " > " + name}, nil
+		},
+	}
+}
+
+func TestParseChain(t *testing.T) {
+	pipeline, err := ParseChain("extract_wisdom, summarize,,create_tags")
+	if err != nil {
+		t.Fatalf("ParseChain failed: %v", err)
+	}
+	var patterns []string
+	for _, step := range pipeline.Steps {
+		patterns = append(patterns, step.Pattern)
+	}
+	if strings.Join(patterns, ",") != "extract_wisdom,summarize,create_tags" {
+		t.Errorf("Unexpected steps %v", patterns)
+	}
+
+	if _, err := ParseChain(" , "); err == nil {
+		t.Error("Expected an error for a chain without patterns")
+	}
+}
+
+func TestLoadPipeline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pipeline.yaml")
+	content := `
+session: record
+steps:
+  - pattern: first
+    model: OpenAI|gpt-4o
+    variables: {lang: de}
+  - pattern: second
+    when: {matches: "^[a-z]+$"}
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write pipeline: %v", err)
+	}
+	pipeline, err := LoadPipeline(path)
+	if err != nil {
+		t.Fatalf("LoadPipeline failed: %v", err)
+	}
+	if pipeline.Session != "record" || len(pipeline.Steps) != 2 || pipeline.Steps[0].Variables["lang"] != "de" {
+		t.Errorf("Unexpected pipeline %+v", pipeline)
+	}
+	if when := pipeline.Steps[1].When; when.Met("Hello") || !when.Met("hello") {
+		t.Error("Expected the condition to use the regular expression")
+	}
+
+	if err := os.WriteFile(path, []byte("steps:\n  - when: {matches: \"(\"}\n"), 0644); err != nil {
+		t.Fatalf("failed to write pipeline: %v", err)
+	}
+	if _, err := LoadPipeline(path); err == nil {
+		t.Error("Expected an error for a step without a pattern")
+	}
+}
+
+func TestPipelineRunner_Run(t *testing.T) {
+	db := newPipelineDb(t, "first", "second", "third")
+	var chatterPatterns []string
+	runner := NewPipelineRunner(db, func(step *PipelineStep) (*Chatter, error) {
+		chatterPatterns = append(chatterPatterns, step.Pattern)
+		return &Chatter{db: db, vendor: appendingVendor(), model: "model-" + step.Pattern}, nil
+	})
+	var finished []int
+	runner.OnStep = func(index int, _ *StepResult) { finished = append(finished, index) }
+
+	pipeline := &Pipeline{Session: "record", Steps: []PipelineStep{
+		{Pattern: "first"},
+		{Pattern: "second", Variables: map[string]string{"lang": "de"}},
+		{Pattern: "skipped", When: &StepCondition{Contains: "missing"}},
+		{Pattern: "third"},
+	}}
+	request := &domain.ChatRequest{
+		PatternVariables: map[string]string{"lang": "en"},
+		Message:          &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "input"},
+	}
+
+	results, err := runner.Run(context.Background(), pipeline, request, &domain.ChatOptions{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if got := results[len(results)-1].Output; got != "input > first en > second de > third en" {
+		t.Errorf("Expected each step to run on the output of the one before, got %q", got)
+	}
+	if !results[2].Skipped || strings.Join(chatterPatterns, ",") != "first,second,third" {
+		t.Errorf("Expected the third step to be skipped without a chatter, got %v", chatterPatterns)
+	}
+	if len(finished) != 4 || results[1].Model != "model-second" {
+		t.Errorf("Expected every step to be reported with its model, got %v and %+v", finished, results[1])
+	}
+
+	session, err := db.Sessions.Get("record")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	var metas int
+	for _, message := range session.Messages {
+		if message.Role == domain.ChatMessageRoleMeta {
+			metas++
+		}
+	}
+	if metas != 3 || session.GetLastMessage().Content != results[3].Output {
+		t.Errorf("Expected the three steps that ran to be recorded, got %d steps", metas)
+	}
+}
+
+func TestPipelineRunner_StreamsLastStepOnly(t *testing.T) {
+	db := newPipelineDb(t, "first", "second")
+	runner := NewPipelineRunner(db, func(step *PipelineStep) (*Chatter, error) {
+		return &Chatter{db: db, vendor: &mockVendor{streamChunks: []string{step.Pattern}}, model: "test-model"}, nil
+	})
+	runner.Stream = true
+	var streamed []string
+	runner.Sink = OutputSinkFunc(func(update domain.StreamUpdate) {
+		if update.Type == domain.StreamTypeContent {
+			streamed = append(streamed, update.Content)
+		}
+	})
+
+	pipeline, _ := ParseChain("first,second")
+	request := &domain.ChatRequest{
+		PatternVariables: map[string]string{"lang": "en"},
+		Message:          &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "input"},
+	}
+	if _, err := runner.Run(context.Background(), pipeline, request, &domain.ChatOptions{}); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if strings.Join(streamed, "") != "second" {
+		t.Errorf("Expected only the last step to be streamed, got %q", streamed)
+	}
+}
+
+func TestPipelineRunner_ChecksVariablesFirst(t *testing.T) {
+	db := newPipelineDb(t, "first", "second")
+	var chatters int
+	runner := NewPipelineRunner(db, func(step *PipelineStep) (*Chatter, error) {
+		chatters++
+		return &Chatter{db: db, vendor: appendingVendor(), model: "test-model"}, nil
+	})
+
+	pipeline := &Pipeline{Steps: []PipelineStep{
+		{Pattern: "first", Variables: map[string]string{"lang": "en"}},
+		{Pattern: "second"},
+	}}
+	request := &domain.ChatRequest{Message: &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "input"}}
+	_, err := runner.Run(context.Background(), pipeline, request, &domain.ChatOptions{})
+	if err == nil || !strings.Contains(err.Error(), "step 2") || !strings.Contains(err.Error(), "lang") {
+		t.Errorf("Expected the missing variable of the second step, got %v", err)
+	}
+	if chatters != 0 {
+		t.Errorf("Expected no step to run, got %d", chatters)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/danielmiessler/fabric/internal/i18n"
 	debuglog "github.com/danielmiessler/fabric/internal/log"
@@ -31,6 +32,7 @@ import (
 	"github.com/danielmiessler/fabric/internal/plugins/ai"
 	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
 	"github.com/danielmiessler/fabric/internal/plugins/template"
+	"github.com/danielmiessler/fabric/internal/plugins/toolcall"
 	"github.com/danielmiessler/fabric/internal/tools"
 	"github.com/danielmiessler/fabric/internal/tools/custom_patterns"
 	"github.com/danielmiessler/fabric/internal/tools/jina"
@@ -81,6 +83,18 @@ func NewPluginRegistry(db *fsdb.Db) (ret *PluginRegistry, err error) {
 		Language:       lang.NewLanguage(),
 		Jina:           jina.NewClient(),
 		Strategies:     strategy.NewStrategiesManager(),
+		Tools:          toolcall.NewRegistry(),
+	}
+
+	// Tools declared in YAML; tools registered in code are added by callers
+	if toolsErr := ret.Tools.LoadDir(filepath.Join(db.Dir, "tools")); toolsErr != nil {
+		debuglog.Log("Failed to load some tools: %v\n", toolsErr)
+	}
+
+	var pricingErr error
+	if ret.Pricing, pricingErr = ai.LoadPricing(filepath.Join(db.Dir, "pricing.yaml")); pricingErr != nil {
+		debuglog.Log("Failed to load pricing, using default prices: %v\n", pricingErr)
+		ret.Pricing = ai.DefaultPricing
 	}
 
 	var homedir string
@@ -152,6 +166,19 @@ type PluginRegistry struct {
 	Jina               *jina.Client
 	TemplateExtensions *template.ExtensionManager
 	Strategies         *strategy.StrategiesManager
+	Tools              *toolcall.Registry
+	Pricing            ai.Pricing
+	// Retry configures retries and rate limits of the vendors of chatters
+	Retry ai.RetryConfig
+	// Cache answers repeated requests of chatters from disk, nil disables it
+	Cache *ai.ResponseCache
+	// ContextWindow keeps the sessions of chatters within the context window of their model
+	ContextWindow ContextWindowConfig
+	// PatternFallbacks are the fallback lists of patterns, by pattern name
+	PatternFallbacks map[string]string
+
+	limitersMu sync.Mutex
+	limiters   map[string]*ai.RateLimiter
 }
 
 func (o *PluginRegistry) SaveEnvFile() (err error) {
@@ -490,11 +517,75 @@ func (o *PluginRegistry) Configure() (err error) {
 	return
 }
 
+// GetFallbacks resolves a comma separated list of "Vendor|model" entries, or
+// plain model names, to chatter fallbacks in the same order.
+func (o *PluginRegistry) GetFallbacks(spec string) (ret []Fallback, err error) {
+	return o.GetVendorModels(spec, false)
+}
+
+// GetVendorModels resolves a comma separated list of "Vendor|model" entries,
+// or plain model names, to vendors and normalized model names in the same
+// order. With dryRun every entry is answered by the dry run vendor.
+func (o *PluginRegistry) GetVendorModels(spec string, dryRun bool) (ret []Fallback, err error) {
+	for entry := range strings.SplitSeq(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		vendorName, model := "", entry
+		if parts := strings.SplitN(entry, "|", 2); len(parts) == 2 {
+			vendorName, model = strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		}
+		var chatter *Chatter
+		if chatter, err = o.GetChatter(model, 0, vendorName, "", false, dryRun); err != nil {
+			err = fmt.Errorf("invalid model %s: %w", entry, err)
+			return
+		}
+		ret = append(ret, Fallback{Vendor: chatter.vendor, Model: chatter.model})
+	}
+	return
+}
+
+// PatternModel returns the vendor and model a pattern is run with when the
+// request chooses none: the "Vendor|model" or model of FABRIC_MODEL_<PATTERN>,
+// then the one of the front matter of the pattern. Both are empty when neither
+// chooses a model.
+func (o *PluginRegistry) PatternModel(pattern string) (vendor, model string) {
+	spec := os.Getenv("FABRIC_MODEL_" + strings.ToUpper(strings.ReplaceAll(pattern, "-", "_")))
+	if spec == "" {
+		if metadata, err := o.Db.Patterns.GetMetadata(pattern); err == nil && metadata != nil {
+			return metadata.PreferredModel()
+		}
+	}
+	if before, after, found := strings.Cut(spec, "|"); found {
+		return before, after
+	}
+	return "", spec
+}
+
+// PatternFallback returns the comma separated "Vendor|model" list a pattern
+// falls back to when the request gives none: the one of FABRIC_FALLBACK_<PATTERN>,
+// then the one of PatternFallbacks, then the fallback list of the front matter
+// of the pattern. It is empty when none of them gives a list.
+func (o *PluginRegistry) PatternFallback(pattern string) string {
+	if spec := os.Getenv("FABRIC_FALLBACK_" + strings.ToUpper(strings.ReplaceAll(pattern, "-", "_"))); spec != "" {
+		return spec
+	}
+	if spec := o.PatternFallbacks[pattern]; spec != "" {
+		return spec
+	}
+	if metadata, err := o.Db.Patterns.GetMetadata(pattern); err == nil && metadata != nil {
+		return strings.Join(metadata.Fallback, ",")
+	}
+	return ""
+}
+
 func (o *PluginRegistry) GetChatter(model string, modelContextLength int, vendorName string, strategy string, stream bool, dryRun bool) (ret *Chatter, err error) {
 	ret = &Chatter{
-		db:     o.Db,
-		Stream: stream,
-		DryRun: dryRun,
+		db:      o.Db,
+		Stream:  stream,
+		DryRun:  dryRun,
+		tools:   o.Tools,
+		pricing: o.Pricing,
 	}
 
 	defaultModel := o.Defaults.Model.Value
@@ -573,5 +664,48 @@ func (o *PluginRegistry) GetChatter(model string, modelContextLength int, vendor
 		return
 	}
 	ret.strategy = strategy
+	ret.contextWindow = o.ContextWindow
+	if spec := o.ContextWindow.SummaryModel; spec != "" {
+		// Resolved when a session is summarized, which most requests never need
+		ret.getSummarizer = func() (summarizer Fallback, err error) {
+			var models []Fallback
+			if models, err = o.GetVendorModels(spec, dryRun); err == nil && len(models) == 0 {
+				err = fmt.Errorf("invalid summary model %q", spec)
+			}
+			if err == nil {
+				summarizer = models[0]
+			}
+			return
+		}
+	}
+	if !dryRun {
+		ret.vendor = o.withRetries(ret.vendor)
+		// Cached answers skip the retries and rate limits
+		if o.Cache != nil {
+			ret.vendor = ai.NewCachingVendor(ret.vendor, o.Cache)
+		}
+	}
 	return
 }
+
+// withRetries wraps vendor with the retry policy and the rate limiter of its
+// name, which all chatters of the vendor share.
+func (o *PluginRegistry) withRetries(vendor ai.Vendor) ai.Vendor {
+	o.limitersMu.Lock()
+	defer o.limitersMu.Unlock()
+
+	name := strings.ToLower(vendor.GetName())
+	limiter, ok := o.limiters[name]
+	if !ok {
+		for vendorName, limit := range o.Retry.RateLimits {
+			if strings.EqualFold(vendorName, name) {
+				limiter = ai.NewRateLimiter(limit)
+			}
+		}
+		if o.limiters == nil {
+			o.limiters = map[string]*ai.RateLimiter{}
+		}
+		o.limiters[name] = limiter
+	}
+	return ai.NewRetryingVendor(vendor, o.Retry, limiter)
+}
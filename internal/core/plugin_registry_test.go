@@ -5,6 +5,7 @@ import (
 	"context"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -43,11 +44,11 @@ func (m *testVendor) Configure() error                      { return nil }
 func (m *testVendor) Setup() error                          { return nil }
 func (m *testVendor) SetupFillEnvFileContent(*bytes.Buffer) {}
 func (m *testVendor) ListModels() ([]string, error)         { return m.models, nil }
-func (m *testVendor) SendStream([]*chat.ChatCompletionMessage, *domain.ChatOptions, chan string) error {
+func (m *testVendor) SendStream(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions, chan domain.StreamUpdate) error {
 	return nil
 }
-func (m *testVendor) Send(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions) (string, error) {
-	return "", nil
+func (m *testVendor) Send(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions) (*domain.ChatResponse, error) {
+	return &domain.ChatResponse{}, nil
 }
 func (m *testVendor) NeedsRawMode(string) bool { return false }
 
@@ -96,3 +97,26 @@ func TestGetChatter_WarnsOnAmbiguousModel(t *testing.T) {
 		t.Fatalf("expected warning about multiple vendors, got %q", string(warning))
 	}
 }
+
+func TestPluginRegistry_PatternFallback(t *testing.T) {
+	db := newPipelineDb(t, "summarize", "translate", "plain")
+	if err := os.WriteFile(filepath.Join(db.Patterns.Dir, "translate", "system.md"),
+		[]byte("---\nfallback: [\"OpenAI|gpt-4o\", \"Ollama|llama3\"]\n---\nTranslate"), 0644); err != nil {
+		t.Fatalf("failed to write pattern: %v", err)
+	}
+	registry := &PluginRegistry{Db: db, PatternFallbacks: map[string]string{"summarize": "Anthropic|claude-sonnet-4-5"}}
+
+	if got := registry.PatternFallback("summarize"); got != "Anthropic|claude-sonnet-4-5" {
+		t.Errorf("expected the list of config.yaml, got %q", got)
+	}
+	if got := registry.PatternFallback("translate"); got != "OpenAI|gpt-4o,Ollama|llama3" {
+		t.Errorf("expected the list of the front matter, got %q", got)
+	}
+	if got := registry.PatternFallback("plain"); got != "" {
+		t.Errorf("expected no list, got %q", got)
+	}
+	t.Setenv("FABRIC_FALLBACK_SUMMARIZE", "Gemini|gemini-2.5-pro")
+	if got := registry.PatternFallback("summarize"); got != "Gemini|gemini-2.5-pro" {
+		t.Errorf("expected the environment to come first, got %q", got)
+	}
+}
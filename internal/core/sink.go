@@ -0,0 +1,109 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/danielmiessler/fabric/internal/domain"
+)
+
+// OutputSink receives the events of a chat request as they happen, so the
+// CLI, the REST server and library users can each render them their own way.
+type OutputSink interface {
+	Emit(update domain.StreamUpdate)
+}
+
+// OutputSinkFunc adapts an ordinary function to an OutputSink.
+type OutputSinkFunc func(update domain.StreamUpdate)
+
+func (f OutputSinkFunc) Emit(update domain.StreamUpdate) {
+	f(update)
+}
+
+// TextSink renders the events of a request as plain text, the way the CLI
+// prints them. Reasoning is wrapped in think tags and the sources a vendor
+// cited are listed after the answer. When think blocks are suppressed only
+// saved images are reported, as the answer is printed once it is complete.
+type TextSink struct {
+	w             io.Writer
+	suppressThink bool
+	thinkStartTag string
+	thinkEndTag   string
+
+	inReasoning bool
+	printed     bool
+	atLineStart bool
+	citations   []domain.Citation
+}
+
+func NewTextSink(w io.Writer, opts *domain.ChatOptions) *TextSink {
+	ret := &TextSink{
+		w:             w,
+		suppressThink: opts.SuppressThink,
+		thinkStartTag: opts.ThinkStartTag,
+		thinkEndTag:   opts.ThinkEndTag,
+	}
+	if ret.thinkStartTag == "" || ret.thinkEndTag == "" {
+		ret.thinkStartTag, ret.thinkEndTag = "<think>", "</think>"
+	}
+	return ret
+}
+
+func (o *TextSink) Emit(update domain.StreamUpdate) {
+	switch update.Type {
+	case domain.StreamTypeContent:
+		if o.suppressThink {
+			return
+		}
+		o.endReasoning()
+		o.write(update.Content)
+	case domain.StreamTypeReasoning:
+		if o.suppressThink {
+			return
+		}
+		if !o.inReasoning {
+			o.inReasoning = true
+			o.write(o.thinkStartTag + "\n")
+		}
+		o.write(update.Content)
+	case domain.StreamTypeCitation:
+		o.citations = append(o.citations, *update.Citation)
+	case domain.StreamTypeImage:
+		o.endLine()
+		fmt.Fprintf(o.w, "Image saved to: %s\n", update.Content)
+	case domain.StreamTypeDone:
+		if o.suppressThink {
+			return
+		}
+		o.endReasoning()
+		o.write(domain.FormatCitations(o.citations))
+		o.endLine()
+	case domain.StreamTypeError:
+		o.endLine()
+	}
+}
+
+func (o *TextSink) write(text string) {
+	if text == "" {
+		return
+	}
+	fmt.Fprint(o.w, text)
+	o.printed = true
+	o.atLineStart = strings.HasSuffix(text, "\n")
+}
+
+func (o *TextSink) endReasoning() {
+	if o.inReasoning {
+		o.inReasoning = false
+		o.endLine()
+		o.write(o.thinkEndTag + "\n\n")
+	}
+}
+
+// endLine finishes a partly printed line, so whatever follows starts on its own
+func (o *TextSink) endLine() {
+	if o.printed && !o.atLineStart {
+		o.write("\n")
+	}
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/domain"
+)
+
+func TestTextSink(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewTextSink(&out, &domain.ChatOptions{})
+
+	sink.Emit(domain.StreamUpdate{Type: domain.StreamTypeReasoning, Content: "hmm"})
+	sink.Emit(domain.StreamUpdate{Type: domain.StreamTypeContent, Content: "Hello"})
+	sink.Emit(domain.StreamUpdate{Type: domain.StreamTypeCitation, Citation: &domain.Citation{URL: "https://example.com"}})
+	sink.Emit(domain.StreamUpdate{Type: domain.StreamTypeUsage, Usage: &domain.UsageMetadata{InputTokens: 1}})
+	sink.Emit(domain.StreamUpdate{Type: domain.StreamTypeDone, Content: "Hello"})
+
+	expected := "<think>\nhmm\n</think>\n\nHello\n\n## Sources\n\n- https://example.com\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestTextSink_SuppressThink(t *testing.T) {
+	var out bytes.Buffer
+	sink := NewTextSink(&out, &domain.ChatOptions{SuppressThink: true})
+
+	sink.Emit(domain.StreamUpdate{Type: domain.StreamTypeReasoning, Content: "hmm"})
+	sink.Emit(domain.StreamUpdate{Type: domain.StreamTypeContent, Content: "Hello"})
+	sink.Emit(domain.StreamUpdate{Type: domain.StreamTypeImage, Content: "cat.png"})
+	sink.Emit(domain.StreamUpdate{Type: domain.StreamTypeDone, Content: "Hello"})
+
+	if expected := "Image saved to: cat.png\n"; out.String() != expected {
+		t.Errorf("Expected only the saved image, got %q", out.String())
+	}
+}
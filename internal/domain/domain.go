@@ -23,6 +23,9 @@ type ChatRequest struct {
 	InputHasVars          bool
 	NoVariableReplacement bool
 	StrategyName          string
+	// Regenerate sends the session again for a new answer to its last user
+	// message, in place of the answer it has
+	Regenerate bool
 }
 
 type ChatOptions struct {
@@ -51,6 +54,26 @@ type ChatOptions struct {
 	Voice               string
 	Notification        bool
 	NotificationCommand string
+	Tools               []chat.Tool
+	// Schema is the JSON Schema the response has to match, nil for free text
+	Schema *Schema
+	// JSONFormat asks the vendor for a JSON answer where there is no Schema;
+	// the answer is not checked
+	JSONFormat bool
+	// NoCache skips the response cache, so that the vendor is asked again
+	NoCache bool
+	// TemperatureSet tells that Temperature was asked for rather than left to
+	// its default, which the front matter of a pattern may change
+	TemperatureSet bool `json:"-"`
+}
+
+// ChatResponse is the result of a non-streaming vendor call. When ToolCalls is
+// not empty the model is asking for those tools to be run before it answers.
+type ChatResponse struct {
+	Content   string
+	ToolCalls []chat.ToolCall
+	Usage     *UsageMetadata
+	Images    []string // paths of images the vendor saved
 }
 
 // NormalizeMessages remove empty messages and ensure messages order user-assist-user
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// Schema is a JSON Schema the response of a request has to match.
+type Schema struct {
+	// Name identifies the schema to vendors that ask for one
+	Name string
+	// Definition is the schema document as vendors take it
+	Definition map[string]any
+
+	compiled *jsonschema.Schema
+}
+
+var schemaNameInvalidChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// LoadSchema reads a JSON Schema file, naming the schema after the file.
+func LoadSchema(path string) (ret *Schema, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		err = fmt.Errorf("could not read schema %s: %w", path, err)
+		return
+	}
+	if ret, err = ParseSchema(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), data); err != nil {
+		err = fmt.Errorf("invalid schema %s: %w", path, err)
+	}
+	return
+}
+
+// ParseSchema compiles a JSON Schema document. The name is reduced to the
+// characters vendors accept.
+func ParseSchema(name string, data []byte) (ret *Schema, err error) {
+	ret = &Schema{Name: schemaNameInvalidChars.ReplaceAllString(name, "_")}
+	if ret.Name == "" {
+		ret.Name = "response"
+	}
+	ret.Name = ret.Name[:min(len(ret.Name), 64)]
+
+	if err = json.Unmarshal(data, &ret.Definition); err != nil {
+		return nil, err
+	}
+	var doc any
+	if doc, err = jsonschema.UnmarshalJSON(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	compiler := jsonschema.NewCompiler()
+	if err = compiler.AddResource("schema.json", doc); err != nil {
+		return nil, err
+	}
+	if ret.compiled, err = compiler.Compile("schema.json"); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// Validate checks that text is a JSON document matching the schema. Models
+// often wrap JSON in a Markdown code fence, so a fence is removed first and
+// the bare document is returned.
+func (o *Schema) Validate(text string) (ret string, err error) {
+	ret = stripCodeFence(text)
+	var doc any
+	if doc, err = jsonschema.UnmarshalJSON(strings.NewReader(ret)); err != nil {
+		err = fmt.Errorf("not valid JSON: %w", err)
+		return
+	}
+	err = o.compiled.Validate(doc)
+	return
+}
+
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") || !strings.HasSuffix(text, "```") {
+		return text
+	}
+	// Drop the opening fence line, which may name the language
+	if i := strings.Index(text, "\n"); i >= 0 {
+		return strings.TrimSpace(strings.TrimSuffix(text[i+1:], "```"))
+	}
+	return text
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSchema_Validate(t *testing.T) {
+	schema, err := ParseSchema("my schema!", []byte(`{
+		"type": "object",
+		"properties": {"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2}},
+		"required": ["tags"]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseSchema failed: %v", err)
+	}
+	if schema.Name != "my_schema_" {
+		t.Errorf("Expected the name to be reduced to allowed characters, got %q", schema.Name)
+	}
+	if schema.Definition["type"] != "object" {
+		t.Errorf("Expected the definition to be kept, got %v", schema.Definition)
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		wantErr string
+	}{
+		{"valid", `{"tags": ["a"]}`, `{"tags": ["a"]}`, ""},
+		{"code fence", "```json\n{\"tags\": []}\n```", `{"tags": []}`, ""},
+		{"missing property", `{}`, "", "tags"},
+		{"too many items", `{"tags": ["a", "b", "c"]}`, "", "maxItems"},
+		{"not json", `Here you go: {"tags": []}`, "", "not valid JSON"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := schema.Validate(tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected an error mentioning %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "person.schema.json")
+	if err := os.WriteFile(path, []byte(`{"type": "string"}`), 0644); err != nil {
+		t.Fatalf("failed to write schema: %v", err)
+	}
+	schema, err := LoadSchema(path)
+	if err != nil {
+		t.Fatalf("LoadSchema failed: %v", err)
+	}
+	if schema.Name != "person_schema" {
+		t.Errorf("Expected the schema to be named after the file, got %q", schema.Name)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"type": 42}`), 0644); err != nil {
+		t.Fatalf("failed to write schema: %v", err)
+	}
+	if _, err := LoadSchema(path); err == nil {
+		t.Error("Expected an invalid schema to be rejected")
+	}
+}
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+)
+
+// StreamType identifies what a StreamUpdate carries.
+type StreamType string
+
+const (
+	StreamTypeContent   StreamType = "content"   // Content holds a text delta of the answer
+	StreamTypeReasoning StreamType = "reasoning" // Content holds a delta of the model's thinking
+	StreamTypeCitation  StreamType = "citation"  // Citation holds a source the answer is based on
+	StreamTypeToolCall  StreamType = "tool_call" // ToolCall holds a tool the model asked to run
+	StreamTypeUsage     StreamType = "usage"     // Usage holds the token counts of the request
+	StreamTypeImage     StreamType = "image"     // Content holds the path of a saved image
+	StreamTypeDone      StreamType = "done"      // Content holds the complete answer
+	StreamTypeError     StreamType = "error"     // Err holds what went wrong
+)
+
+// StreamUpdate is a single event of a chat request. Vendors send them on the
+// SendStream channel and the chatter passes them on to its output sink.
+type StreamUpdate struct {
+	Type     StreamType
+	Content  string
+	Usage    *UsageMetadata
+	Citation *Citation
+	ToolCall *chat.ToolCall
+	Err      error
+}
+
+// Citation is a source a vendor grounded its answer on.
+type Citation struct {
+	Title string `json:"title,omitempty"`
+	URL   string `json:"url"`
+}
+
+// FormatCitations renders citations as a Markdown sources section to append
+// to an answer. It returns an empty string when there are no citations.
+func FormatCitations(citations []Citation) string {
+	if len(citations) == 0 {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteString("\n\n## Sources\n\n")
+	for _, citation := range citations {
+		if citation.Title != "" {
+			builder.WriteString(fmt.Sprintf("- [%s](%s)\n", citation.Title, citation.URL))
+		} else {
+			builder.WriteString(fmt.Sprintf("- %s\n", citation.URL))
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"unicode/utf8"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+)
+
+// messageOverheadTokens is what a message costs beyond its content, for the
+// role and the separators vendors add around it.
+const messageOverheadTokens = 4
+
+// imageTokens is a rough cost of an attached image, which vendors price by size.
+const imageTokens = 1000
+
+// EstimateTokens estimates the number of tokens of text without a tokenizer:
+// about four characters per token for ASCII text, and one per character for
+// other scripts, which tokenizers split much finer.
+func EstimateTokens(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii+3)/4 + other
+}
+
+// EstimateMessageTokens estimates the tokens a message takes up in the context window.
+func EstimateMessageTokens(msg *chat.ChatCompletionMessage) (ret int) {
+	ret = messageOverheadTokens + EstimateTokens(msg.Content) + EstimateTokens(msg.ReasoningContent)
+	for _, part := range msg.MultiContent {
+		if part.Type == chat.ChatMessagePartTypeImageURL {
+			ret += imageTokens
+		} else {
+			ret += EstimateTokens(part.Text)
+		}
+	}
+	for _, call := range msg.ToolCalls {
+		ret += EstimateTokens(call.Function.Name) + EstimateTokens(call.Function.Arguments)
+	}
+	return
+}
+
+// EstimateMessagesTokens estimates the tokens of a conversation.
+func EstimateMessagesTokens(msgs []*chat.ChatCompletionMessage) (ret int) {
+	for _, msg := range msgs {
+		ret += EstimateMessageTokens(msg)
+	}
+	return
+}
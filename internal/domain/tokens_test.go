@@ -0,0 +1,33 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	if got := EstimateTokens(strings.Repeat("a", 400)); got != 100 {
+		t.Errorf("expected 100 tokens for 400 ASCII characters, got %d", got)
+	}
+	if got := EstimateTokens("日本語"); got != 3 {
+		t.Errorf("expected a token per CJK character, got %d", got)
+	}
+	if got := EstimateTokens(""); got != 0 {
+		t.Errorf("expected no tokens for empty text, got %d", got)
+	}
+}
+
+func TestEstimateMessageTokens(t *testing.T) {
+	msg := &chat.ChatCompletionMessage{
+		Role: chat.ChatMessageRoleUser,
+		MultiContent: []chat.ChatMessagePart{
+			{Type: chat.ChatMessagePartTypeText, Text: strings.Repeat("a", 40)},
+			{Type: chat.ChatMessagePartTypeImageURL, ImageURL: &chat.ChatMessageImageURL{URL: "data:image/png;base64,AAAA"}},
+		},
+	}
+	if got := EstimateMessageTokens(msg); got != messageOverheadTokens+10+imageTokens {
+		t.Errorf("unexpected estimate %d", got)
+	}
+}
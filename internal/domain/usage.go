@@ -0,0 +1,29 @@
+package domain
+
+// UsageMetadata holds the token counts reported by a vendor. InputTokens
+// includes CachedTokens and OutputTokens includes ReasoningTokens, so the
+// breakdowns never need to be added on top of the totals.
+type UsageMetadata struct {
+	InputTokens     int     `json:"input_tokens"`
+	OutputTokens    int     `json:"output_tokens"`
+	ReasoningTokens int     `json:"reasoning_tokens,omitempty"`
+	CachedTokens    int     `json:"cached_tokens,omitempty"`
+	Cost            float64 `json:"cost,omitempty"` // USD, set from the pricing table when the model is priced
+}
+
+// Add accumulates other into o. A nil other is ignored.
+func (o *UsageMetadata) Add(other *UsageMetadata) {
+	if other == nil {
+		return
+	}
+	o.InputTokens += other.InputTokens
+	o.OutputTokens += other.OutputTokens
+	o.ReasoningTokens += other.ReasoningTokens
+	o.CachedTokens += other.CachedTokens
+	o.Cost += other.Cost
+}
+
+// TotalTokens returns the number of input and output tokens.
+func (o *UsageMetadata) TotalTokens() int {
+	return o.InputTokens + o.OutputTokens
+}
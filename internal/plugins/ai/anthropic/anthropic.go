@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 
@@ -137,7 +136,8 @@ func (an *Client) Setup() (err error) {
 }
 
 func (an *Client) configure() (err error) {
-	opts := []option.RequestOption{}
+	// Retries are left to ai.RetryingVendor
+	opts := []option.RequestOption{option.WithMaxRetries(0)}
 
 	if an.ApiBaseURL.Value != "" {
 		opts = append(opts, option.WithBaseURL(an.ApiBaseURL.Value))
@@ -184,7 +184,7 @@ func parseThinking(level domain.ThinkingLevel) (anthropic.ThinkingConfigParamUni
 }
 
 func (an *Client) SendStream(
-	msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan string,
+	ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan domain.StreamUpdate,
 ) (err error) {
 	messages := an.toMessages(msgs)
 	if len(messages) == 0 {
@@ -193,8 +193,6 @@ func (an *Client) SendStream(
 		return
 	}
 
-	ctx := context.Background()
-
 	params := an.buildMessageParams(messages, opts)
 	betas := an.modelBetas[opts.Model]
 	var reqOpts []option.RequestOption
@@ -207,17 +205,43 @@ func (an *Client) SendStream(
 		stream = an.client.Messages.NewStreaming(ctx, params)
 	}
 
+	// Input tokens arrive with message_start, the final output count with message_delta
+	var usage anthropic.Usage
+	forced := forcesSchemaTool(opts)
 	for stream.Next() {
 		event := stream.Current()
 
+		switch event.Type {
+		case "message_start":
+			usage = event.Message.Usage
+		case "message_delta":
+			usage.OutputTokens = event.Usage.OutputTokens
+		}
+
 		// directly send any non-empty delta text
-		if event.Delta.Text != "" {
-			channel <- event.Delta.Text
+		switch {
+		case event.Delta.Text != "":
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: event.Delta.Text}
+		case event.Delta.Thinking != "":
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeReasoning, Content: event.Delta.Thinking}
+		case forced && event.Delta.PartialJSON != "":
+			// The input of the forced schema tool is the response
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: event.Delta.PartialJSON}
+		case event.Delta.Citation.Type == "web_search_result_location":
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeCitation, Citation: &domain.Citation{
+				Title: event.Delta.Citation.Title,
+				URL:   event.Delta.Citation.URL,
+			}}
 		}
 	}
 
-	if stream.Err() != nil {
-		fmt.Fprintf(os.Stderr, "Messages stream error: %v\n", stream.Err())
+	if ctx.Err() != nil {
+		// Cancelled by the caller, which keeps whatever was streamed so far
+		err = ctx.Err()
+	} else if stream.Err() != nil {
+		err = fmt.Errorf("messages stream error: %w", stream.Err())
+	} else if metadata := toUsageMetadata(usage); metadata != nil {
+		channel <- domain.StreamUpdate{Type: domain.StreamTypeUsage, Usage: metadata}
 	}
 	close(channel)
 	return
@@ -272,19 +296,29 @@ func (an *Client) buildMessageParams(msgs []anthropic.MessageParam, opts *domain
 		}
 	}
 
+	if len(opts.Tools) > 0 {
+		params.Tools = append(params.Tools, toToolParams(opts.Tools)...)
+	}
+
 	if t, ok := parseThinking(opts.Thinking); ok {
 		params.Thinking = t
 	}
 
+	if forcesSchemaTool(opts) {
+		params.Tools = append(params.Tools, toToolParams([]chat.Tool{schemaTool(opts.Schema)})...)
+		params.ToolChoice = anthropic.ToolChoiceParamOfTool(opts.Schema.Name)
+	}
+
 	return
 }
 
 func (an *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (
-	ret string, err error) {
+	ret *domain.ChatResponse, err error) {
 
+	ret = &domain.ChatResponse{}
 	messages := an.toMessages(msgs)
 	if len(messages) == 0 {
-		// No messages to send after normalization, return empty string and no error.
+		// No messages to send after normalization, return an empty response and no error.
 		return
 	}
 
@@ -341,7 +375,19 @@ func (an *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage,
 		resultBuilder.WriteString("\n\n")
 		resultBuilder.WriteString(strings.Join(citations, "\n"))
 	}
-	ret = resultBuilder.String()
+	ret.Content = resultBuilder.String()
+	ret.ToolCalls = extractToolCalls(message)
+	ret.Usage = toUsageMetadata(message.Usage)
+
+	// The input of the forced schema tool is the response
+	if forcesSchemaTool(opts) {
+		for _, call := range ret.ToolCalls {
+			if call.Function.Name == opts.Schema.Name {
+				ret.Content, ret.ToolCalls = call.Function.Arguments, nil
+				break
+			}
+		}
+	}
 
 	return
 }
@@ -359,8 +405,31 @@ func (an *Client) toMessages(msgs []*chat.ChatCompletionMessage) (ret []anthropi
 
 	isFirstUserMessage := true
 	lastRoleWasUser := false
+	lastWasToolResult := false
 
 	for _, msg := range msgs {
+		// Tool results go back as tool_result blocks in a user message;
+		// consecutive results share the same message.
+		if msg.Role == chat.ChatMessageRoleTool {
+			block := anthropic.NewToolResultBlock(msg.ToolCallID, msg.Content, false)
+			if lastWasToolResult {
+				last := &anthropicMessages[len(anthropicMessages)-1]
+				last.Content = append(last.Content, block)
+			} else {
+				anthropicMessages = append(anthropicMessages, anthropic.NewUserMessage(block))
+			}
+			lastRoleWasUser = true
+			lastWasToolResult = true
+			continue
+		}
+		lastWasToolResult = false
+
+		if msg.Role == chat.ChatMessageRoleAssistant && len(msg.ToolCalls) > 0 {
+			anthropicMessages = append(anthropicMessages, anthropic.NewAssistantMessage(toToolUseBlocks(msg)...))
+			lastRoleWasUser = false
+			continue
+		}
+
 		if strings.TrimSpace(msg.Content) == "" {
 			continue // Skip empty messages
 		}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/danielmiessler/fabric/internal/chat"
 	"github.com/danielmiessler/fabric/internal/domain"
 )
 
@@ -325,3 +326,89 @@ func TestBuildMessageParams_ExplicitTopP(t *testing.T) {
 		t.Errorf("Expected TopP %f, got %f", opts.TopP, params.TopP.Value)
 	}
 }
+
+func TestToMessages_ToolCallsAndResults(t *testing.T) {
+	client := NewClient()
+	msgs := []*chat.ChatCompletionMessage{
+		{Role: chat.ChatMessageRoleUser, Content: "Weather in Paris and Rome?"},
+		{Role: chat.ChatMessageRoleAssistant, ToolCalls: []chat.ToolCall{
+			{ID: "toolu_1", Function: chat.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+			{ID: "toolu_2", Function: chat.FunctionCall{Name: "get_weather", Arguments: `{"city":"Rome"}`}},
+		}},
+		{Role: chat.ChatMessageRoleTool, ToolCallID: "toolu_1", Content: "sunny"},
+		{Role: chat.ChatMessageRoleTool, ToolCallID: "toolu_2", Content: "rainy"},
+	}
+
+	result := client.toMessages(msgs)
+
+	if len(result) != 3 {
+		t.Fatalf("Expected 3 messages, got %d", len(result))
+	}
+	if len(result[1].Content) != 2 || result[1].Content[0].OfToolUse == nil {
+		t.Errorf("Expected assistant message with 2 tool_use blocks, got %+v", result[1].Content)
+	}
+	if result[2].Role != anthropic.MessageParamRoleUser || len(result[2].Content) != 2 {
+		t.Fatalf("Expected tool results merged into one user message, got %+v", result[2])
+	}
+	if got := result[2].Content[1].OfToolResult; got == nil || got.ToolUseID != "toolu_2" {
+		t.Errorf("Expected second tool_result for toolu_2, got %+v", got)
+	}
+}
+
+func TestToToolParams(t *testing.T) {
+	tools := []chat.Tool{{
+		Type: chat.ToolTypeFunction,
+		Function: &chat.FunctionDefinition{
+			Name:        "get_weather",
+			Description: "Current weather",
+			Parameters: map[string]any{
+				"type":                 "object",
+				"properties":           map[string]any{"city": map[string]any{"type": "string"}},
+				"required":             []any{"city"},
+				"additionalProperties": false,
+			},
+		},
+	}}
+
+	params := toToolParams(tools)
+
+	if len(params) != 1 || params[0].OfTool == nil {
+		t.Fatalf("Expected one custom tool, got %+v", params)
+	}
+	schema := params[0].OfTool.InputSchema
+	if len(schema.Required) != 1 || schema.Required[0] != "city" {
+		t.Errorf("Expected required [city], got %v", schema.Required)
+	}
+	if schema.ExtraFields["additionalProperties"] != false {
+		t.Errorf("Expected additionalProperties to be kept, got %v", schema.ExtraFields)
+	}
+}
+
+func TestBuildMessageParams_WithSchema(t *testing.T) {
+	client := NewClient()
+	schema, err := domain.ParseSchema("person", []byte(`{"type":"object","properties":{"name":{"type":"string"}},"required":["name"]}`))
+	if err != nil {
+		t.Fatalf("ParseSchema failed: %v", err)
+	}
+	opts := &domain.ChatOptions{Model: "claude-sonnet-4-5", TopP: domain.DefaultTopP, Schema: schema}
+	messages := []anthropic.MessageParam{
+		anthropic.NewUserMessage(anthropic.NewTextBlock("Hello")),
+	}
+
+	params := client.buildMessageParams(messages, opts)
+	if len(params.Tools) != 1 || params.Tools[0].OfTool == nil || params.Tools[0].OfTool.Name != "person" {
+		t.Fatalf("Expected the schema tool, got %+v", params.Tools)
+	}
+	if params.Tools[0].OfTool.InputSchema.Required[0] != "name" {
+		t.Errorf("Expected the schema as tool input, got %+v", params.Tools[0].OfTool.InputSchema)
+	}
+	if params.ToolChoice.OfTool == nil || params.ToolChoice.OfTool.Name != "person" {
+		t.Errorf("Expected the schema tool to be forced, got %+v", params.ToolChoice)
+	}
+
+	// Forcing a tool is not allowed with extended thinking
+	opts.Thinking = domain.ThinkingHigh
+	if params = client.buildMessageParams(messages, opts); params.ToolChoice.OfTool != nil {
+		t.Error("Expected no forced tool with thinking enabled")
+	}
+}
@@ -0,0 +1,112 @@
+package anthropic
+
+import (
+	"encoding/json"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+)
+
+// toToolParams converts function tools to Anthropic custom tool definitions.
+// Anthropic takes the JSON schema split into properties, required and any
+// remaining keywords, so the schema is taken apart here.
+func toToolParams(tools []chat.Tool) (ret []anthropic.ToolUnionParam) {
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		schema := anthropic.ToolInputSchemaParam{}
+		for key, value := range tool.Function.Parameters {
+			switch key {
+			case "type":
+			case "properties":
+				schema.Properties = value
+			case "required":
+				schema.Required = toStringSlice(value)
+			default:
+				if schema.ExtraFields == nil {
+					schema.ExtraFields = map[string]any{}
+				}
+				schema.ExtraFields[key] = value
+			}
+		}
+		param := anthropic.ToolParam{
+			Name:        tool.Function.Name,
+			InputSchema: schema,
+		}
+		if tool.Function.Description != "" {
+			param.Description = anthropic.String(tool.Function.Description)
+		}
+		ret = append(ret, anthropic.ToolUnionParam{OfTool: &param})
+	}
+	return
+}
+
+func toStringSlice(value any) (ret []string) {
+	switch v := value.(type) {
+	case []string:
+		ret = v
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				ret = append(ret, s)
+			}
+		}
+	}
+	return
+}
+
+// toToolUseBlocks converts an assistant message carrying tool calls to content blocks
+func toToolUseBlocks(msg *chat.ChatCompletionMessage) (ret []anthropic.ContentBlockParamUnion) {
+	if msg.Content != "" {
+		ret = append(ret, anthropic.NewTextBlock(msg.Content))
+	}
+	for _, call := range msg.ToolCalls {
+		arguments := call.Function.Arguments
+		if arguments == "" {
+			arguments = "{}"
+		}
+		ret = append(ret, anthropic.NewToolUseBlock(call.ID, json.RawMessage(arguments), call.Function.Name))
+	}
+	return
+}
+
+// extractToolCalls returns the tool_use blocks of a response as fabric tool calls
+func extractToolCalls(message *anthropic.Message) (ret []chat.ToolCall) {
+	for _, block := range message.Content {
+		if block.Type == "tool_use" {
+			ret = append(ret, chat.ToolCall{
+				ID:       block.ID,
+				Type:     chat.ToolTypeFunction,
+				Function: chat.FunctionCall{Name: block.Name, Arguments: string(block.Input)},
+			})
+		}
+	}
+	return
+}
+
+// forcesSchemaTool reports whether a structured response is requested by
+// forcing the model to call a tool that takes the schema as its input, as
+// Anthropic has no response format. That only works for object schemas, and
+// neither alongside other tools nor with extended thinking.
+func forcesSchemaTool(opts *domain.ChatOptions) bool {
+	if opts.Schema == nil || len(opts.Tools) > 0 || opts.Search {
+		return false
+	}
+	if _, thinking := parseThinking(opts.Thinking); thinking {
+		return false
+	}
+	return opts.Schema.Definition["type"] == "object"
+}
+
+func schemaTool(schema *domain.Schema) chat.Tool {
+	return chat.Tool{
+		Type: chat.ToolTypeFunction,
+		Function: &chat.FunctionDefinition{
+			Name:        schema.Name,
+			Description: "Respond with the result, which must match this schema.",
+			Parameters:  schema.Definition,
+		},
+	}
+}
@@ -0,0 +1,19 @@
+package anthropic
+
+import (
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/danielmiessler/fabric/internal/domain"
+)
+
+// toUsageMetadata converts Anthropic usage to fabric usage metadata. Anthropic
+// reports cache reads and writes apart from the uncached input tokens.
+func toUsageMetadata(usage anthropic.Usage) *domain.UsageMetadata {
+	if !usage.JSON.InputTokens.Valid() && usage.OutputTokens == 0 {
+		return nil
+	}
+	return &domain.UsageMetadata{
+		InputTokens:  int(usage.InputTokens + usage.CacheReadInputTokens + usage.CacheCreationInputTokens),
+		OutputTokens: int(usage.OutputTokens),
+		CachedTokens: int(usage.CacheReadInputTokens),
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/danielmiessler/fabric/internal/plugins/ai/openai"
 	openaiapi "github.com/openai/openai-go"
 	"github.com/openai/openai-go/azure"
+	"github.com/openai/openai-go/option"
 )
 
 func NewClient() (ret *Client) {
@@ -50,9 +51,11 @@ func (oi *Client) configure() error {
 		oi.ApiVersion.Value = apiVersion
 	}
 
+	// Retries are left to ai.RetryingVendor
 	client := openaiapi.NewClient(
 		azure.WithAPIKey(apiKey),
 		azure.WithEndpoint(baseURL, apiVersion),
+		option.WithMaxRetries(0),
 	)
 	oi.ApiClient = &client
 	return nil
@@ -109,7 +109,8 @@ func (c *BedrockClient) configure() error {
 	}
 
 	ctx := context.Background()
-	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(c.bedrockRegion.Value))
+	// Retries are left to ai.RetryingVendor
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(c.bedrockRegion.Value), config.WithRetryMaxAttempts(1))
 	if err != nil {
 		return fmt.Errorf("unable to load AWS Config with region %s: %w", c.bedrockRegion.Value, err)
 	}
@@ -154,7 +155,7 @@ func (c *BedrockClient) ListModels() ([]string, error) {
 }
 
 // SendStream sends the messages to the Bedrock ConverseStream API
-func (c *BedrockClient) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan string) (err error) {
+func (c *BedrockClient) SendStream(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan domain.StreamUpdate) (err error) {
 	// Ensure channel is closed on all exit paths to prevent goroutine leaks
 	defer func() {
 		if r := recover(); r != nil {
@@ -173,10 +174,11 @@ func (c *BedrockClient) SendStream(msgs []*chat.ChatCompletionMessage, opts *dom
 			TopP:        aws.Float32(float32(opts.TopP))},
 	}
 
-	response, err := c.runtimeClient.ConverseStream(context.Background(), &converseInput)
+	response, err := c.runtimeClient.ConverseStream(ctx, &converseInput)
 	if err != nil {
 		return fmt.Errorf("bedrock conversestream failed for model %s: %w", opts.Model, err)
 	}
+	defer response.GetStream().Close()
 
 	for event := range response.GetStream().Events() {
 		// Possible ConverseStream event types
@@ -186,57 +188,82 @@ func (c *BedrockClient) SendStream(msgs []*chat.ChatCompletionMessage, opts *dom
 		case *types.ConverseStreamOutputMemberContentBlockDelta:
 			text, ok := v.Value.Delta.(*types.ContentBlockDeltaMemberText)
 			if ok {
-				channel <- text.Value
+				channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: text.Value}
 			}
 
 		case *types.ConverseStreamOutputMemberMessageStop:
-			channel <- "\n"
-			return nil // Let defer handle the close
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: "\n"}
+
+		// The metadata event follows the message stop and carries the token usage
+		case *types.ConverseStreamOutputMemberMetadata:
+			if usage := fromTokenUsage(v.Value.Usage); usage != nil {
+				channel <- domain.StreamUpdate{Type: domain.StreamTypeUsage, Usage: usage}
+			}
 
 		// Unused Events
 		case *types.ConverseStreamOutputMemberMessageStart,
 			*types.ConverseStreamOutputMemberContentBlockStart,
-			*types.ConverseStreamOutputMemberContentBlockStop,
-			*types.ConverseStreamOutputMemberMetadata:
+			*types.ConverseStreamOutputMemberContentBlockStop:
 
 		default:
 			return fmt.Errorf("unknown stream event type: %T", v)
 		}
 	}
 
-	return nil
+	return response.GetStream().Err()
 }
 
 // Send sends the messages the Bedrock Converse API
-func (c *BedrockClient) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret string, err error) {
+func (c *BedrockClient) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret *domain.ChatResponse, err error) {
 
 	messages := c.toMessages(msgs)
 
 	var converseInput = bedrockruntime.ConverseInput{
-		ModelId:  aws.String(opts.Model),
-		Messages: messages,
+		ModelId:    aws.String(opts.Model),
+		Messages:   messages,
+		ToolConfig: toToolConfiguration(opts.Tools),
 	}
 	response, err := c.runtimeClient.Converse(ctx, &converseInput)
 	if err != nil {
-		return "", fmt.Errorf("bedrock converse failed for model %s: %w", opts.Model, err)
+		return nil, fmt.Errorf("bedrock converse failed for model %s: %w", opts.Model, err)
 	}
 
 	responseText, ok := response.Output.(*types.ConverseOutputMemberMessage)
 	if !ok {
-		return "", fmt.Errorf("unexpected response type: %T", response.Output)
+		return nil, fmt.Errorf("unexpected response type: %T", response.Output)
 	}
 
 	if len(responseText.Value.Content) == 0 {
-		return "", fmt.Errorf("empty response content")
+		return nil, fmt.Errorf("empty response content")
 	}
 
-	responseContentBlock := responseText.Value.Content[0]
-	text, ok := responseContentBlock.(*types.ContentBlockMemberText)
-	if !ok {
-		return "", fmt.Errorf("unexpected content block type: %T", responseContentBlock)
+	ret = &domain.ChatResponse{Usage: fromTokenUsage(response.Usage)}
+	for _, responseContentBlock := range responseText.Value.Content {
+		switch block := responseContentBlock.(type) {
+		case *types.ContentBlockMemberText:
+			ret.Content += block.Value
+		case *types.ContentBlockMemberToolUse:
+			ret.ToolCalls = append(ret.ToolCalls, fromToolUseBlock(block.Value))
+		default:
+			return nil, fmt.Errorf("unexpected content block type: %T", responseContentBlock)
+		}
 	}
 
-	return text.Value, nil
+	return ret, nil
+}
+
+// fromTokenUsage converts Bedrock token usage to fabric usage metadata.
+// Bedrock reports cache reads and writes apart from the input tokens.
+func fromTokenUsage(usage *types.TokenUsage) *domain.UsageMetadata {
+	if usage == nil {
+		return nil
+	}
+	cacheRead := int(aws.ToInt32(usage.CacheReadInputTokens))
+	return &domain.UsageMetadata{
+		InputTokens:  int(aws.ToInt32(usage.InputTokens)) + cacheRead + int(aws.ToInt32(usage.CacheWriteInputTokens)),
+		OutputTokens: int(aws.ToInt32(usage.OutputTokens)),
+		CachedTokens: cacheRead,
+	}
 }
 
 // NeedsRawMode indicates whether the model requires raw mode processing.
@@ -251,6 +278,22 @@ func (c *BedrockClient) NeedsRawMode(modelName string) bool {
 // pattern content and user input.
 func (c *BedrockClient) toMessages(inputMessages []*chat.ChatCompletionMessage) (messages []types.Message) {
 	for _, msg := range inputMessages {
+		// Tool results are sent back as tool result blocks in a user message;
+		// consecutive results share the same message.
+		if msg.Role == chat.ChatMessageRoleTool {
+			if n := len(messages); n > 0 && messages[n-1].Role == types.ConversationRoleUser {
+				if _, isResult := messages[n-1].Content[0].(*types.ContentBlockMemberToolResult); isResult {
+					messages[n-1].Content = append(messages[n-1].Content, toToolResultBlock(msg))
+					continue
+				}
+			}
+			messages = append(messages, types.Message{
+				Role:    types.ConversationRoleUser,
+				Content: []types.ContentBlock{toToolResultBlock(msg)},
+			})
+			continue
+		}
+
 		roles := map[string]types.ConversationRole{
 			chat.ChatMessageRoleUser:      types.ConversationRoleUser,
 			chat.ChatMessageRoleAssistant: types.ConversationRoleAssistant,
@@ -262,9 +305,15 @@ func (c *BedrockClient) toMessages(inputMessages []*chat.ChatCompletionMessage)
 			continue
 		}
 
+		var content []types.ContentBlock
+		if msg.Content != "" || len(msg.ToolCalls) == 0 {
+			content = append(content, &types.ContentBlockMemberText{Value: msg.Content})
+		}
+		content = append(content, toToolUseBlocks(msg.ToolCalls)...)
+
 		message := types.Message{
 			Role:    role,
-			Content: []types.ContentBlock{&types.ContentBlockMemberText{Value: msg.Content}},
+			Content: content,
 		}
 		messages = append(messages, message)
 
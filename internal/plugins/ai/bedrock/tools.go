@@ -0,0 +1,72 @@
+package bedrock
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/document"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+)
+
+// toToolConfiguration converts function tools to a Converse tool configuration
+func toToolConfiguration(tools []chat.Tool) *types.ToolConfiguration {
+	var specs []types.Tool
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		spec := types.ToolSpecification{
+			Name:        aws.String(tool.Function.Name),
+			InputSchema: &types.ToolInputSchemaMemberJson{Value: document.NewLazyDocument(tool.Function.Parameters)},
+		}
+		if tool.Function.Description != "" {
+			spec.Description = aws.String(tool.Function.Description)
+		}
+		specs = append(specs, &types.ToolMemberToolSpec{Value: spec})
+	}
+	if len(specs) == 0 {
+		return nil
+	}
+	return &types.ToolConfiguration{Tools: specs}
+}
+
+// toToolUseBlocks converts the tool calls of an assistant message to tool use content blocks
+func toToolUseBlocks(calls []chat.ToolCall) (ret []types.ContentBlock) {
+	for _, call := range calls {
+		args := map[string]any{}
+		if call.Function.Arguments != "" {
+			_ = json.Unmarshal([]byte(call.Function.Arguments), &args)
+		}
+		ret = append(ret, &types.ContentBlockMemberToolUse{Value: types.ToolUseBlock{
+			ToolUseId: aws.String(call.ID),
+			Name:      aws.String(call.Function.Name),
+			Input:     document.NewLazyDocument(args),
+		}})
+	}
+	return
+}
+
+// toToolResultBlock converts a tool result message to a tool result content block
+func toToolResultBlock(msg *chat.ChatCompletionMessage) types.ContentBlock {
+	return &types.ContentBlockMemberToolResult{Value: types.ToolResultBlock{
+		ToolUseId: aws.String(msg.ToolCallID),
+		Content:   []types.ToolResultContentBlock{&types.ToolResultContentBlockMemberText{Value: msg.Content}},
+	}}
+}
+
+// fromToolUseBlock converts a tool use content block returned by the model to a fabric tool call
+func fromToolUseBlock(block types.ToolUseBlock) chat.ToolCall {
+	arguments := "{}"
+	if block.Input != nil {
+		if data, err := block.Input.MarshalSmithyDocument(); err == nil {
+			arguments = string(data)
+		}
+	}
+	return chat.ToolCall{
+		ID:       aws.ToString(block.ToolUseId),
+		Type:     chat.ToolTypeFunction,
+		Function: chat.FunctionCall{Name: aws.ToString(block.Name), Arguments: arguments},
+	}
+}
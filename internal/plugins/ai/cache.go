@@ -0,0 +1,481 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+	debuglog "github.com/danielmiessler/fabric/internal/log"
+)
+
+// CacheConfig configures the response cache. It is read from the cache
+// section of config.yaml:
+//
+//	cache:
+//	  enabled: true
+//	  ttl: 24h
+//	  max_size_mb: 100
+//	  max_entries: 1000
+type CacheConfig struct {
+	// Enabled turns the cache on, it is off by default
+	Enabled bool `yaml:"enabled"`
+	// TTL is how long a response is served from the cache
+	TTL time.Duration `yaml:"ttl"`
+	// MaxSizeMB and MaxEntries bound the cache, the oldest responses are removed first.
+	// A MaxEntries of 0 sets no limit on the number of responses.
+	MaxSizeMB  int64 `yaml:"max_size_mb"`
+	MaxEntries int   `yaml:"max_entries"`
+}
+
+// DefaultCacheConfig is used for every setting config.yaml leaves out.
+var DefaultCacheConfig = CacheConfig{
+	TTL:       7 * 24 * time.Hour,
+	MaxSizeMB: 100,
+}
+
+// WithDefaults returns the config with unset values taken from DefaultCacheConfig.
+func (o CacheConfig) WithDefaults() CacheConfig {
+	if o.TTL <= 0 {
+		o.TTL = DefaultCacheConfig.TTL
+	}
+	if o.MaxSizeMB <= 0 {
+		o.MaxSizeMB = DefaultCacheConfig.MaxSizeMB
+	}
+	if o.MaxEntries < 0 {
+		o.MaxEntries = DefaultCacheConfig.MaxEntries
+	}
+	return o
+}
+
+const cacheStatsFile = "stats.json"
+
+// cacheFlushInterval is how often the counters are added to stats.json while
+// the cache is in use, so that a server that is killed loses little of them.
+const cacheFlushInterval = time.Minute
+
+// ResponseCache stores vendor responses on disk, one JSON file per request
+// fingerprint, along with hit and miss counters. The counters are kept in
+// memory and added to stats.json by Flush.
+type ResponseCache struct {
+	dir    string
+	config CacheConfig
+	mu     sync.Mutex
+
+	hits, misses, savedInputTokens, savedOutputTokens atomic.Int64
+	// flushed is when the counters were last added to stats.json, in Unix nanoseconds
+	flushed atomic.Int64
+}
+
+func NewResponseCache(dir string, config CacheConfig) (ret *ResponseCache) {
+	ret = &ResponseCache{dir: dir, config: config.WithDefaults()}
+	ret.flushed.Store(time.Now().UnixNano())
+	return
+}
+
+// Dir returns the directory of the cache.
+func (o *ResponseCache) Dir() string {
+	return o.dir
+}
+
+// Config returns the config of the cache, with defaults applied.
+func (o *ResponseCache) Config() CacheConfig {
+	return o.config
+}
+
+// cacheEntry is a cached response. Content is the answer without the
+// citations, which are kept apart so a stream can be replayed as it was sent.
+type cacheEntry struct {
+	Vendor    string                `json:"vendor"`
+	Model     string                `json:"model"`
+	Content   string                `json:"content"`
+	Citations []domain.Citation     `json:"citations,omitempty"`
+	Usage     *domain.UsageMetadata `json:"usage,omitempty"`
+}
+
+// CacheStats reports the contents of the cache and how often it was used.
+type CacheStats struct {
+	Entries int   `json:"-"`
+	Expired int   `json:"-"`
+	Bytes   int64 `json:"-"`
+
+	Hits              int `json:"hits"`
+	Misses            int `json:"misses"`
+	SavedInputTokens  int `json:"saved_input_tokens"`
+	SavedOutputTokens int `json:"saved_output_tokens"`
+}
+
+// cacheKey holds everything that makes two requests get the same response.
+type cacheKey struct {
+	Vendor             string                        `json:"vendor"`
+	Model              string                        `json:"model"`
+	Messages           []*chat.ChatCompletionMessage `json:"messages"`
+	Temperature        float64                       `json:"temperature"`
+	TopP               float64                       `json:"top_p"`
+	PresencePenalty    float64                       `json:"presence_penalty"`
+	FrequencyPenalty   float64                       `json:"frequency_penalty"`
+	Raw                bool                          `json:"raw"`
+	Seed               int                           `json:"seed"`
+	Thinking           domain.ThinkingLevel          `json:"thinking"`
+	ModelContextLength int                           `json:"model_context_length"`
+	MaxTokens          int                           `json:"max_tokens"`
+	Search             bool                          `json:"search"`
+	SearchLocation     string                        `json:"search_location"`
+	Schema             map[string]any                `json:"schema,omitempty"`
+	JSONFormat         bool                          `json:"json_format,omitempty"`
+}
+
+// Key returns the fingerprint of a request. Messages are normalized so that
+// line endings and surrounding whitespace do not change it.
+func (o *ResponseCache) Key(vendor string, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret string, err error) {
+	key := cacheKey{
+		Vendor:             strings.ToLower(vendor),
+		Model:              opts.Model,
+		Temperature:        opts.Temperature,
+		TopP:               opts.TopP,
+		PresencePenalty:    opts.PresencePenalty,
+		FrequencyPenalty:   opts.FrequencyPenalty,
+		Raw:                opts.Raw,
+		Seed:               opts.Seed,
+		Thinking:           opts.Thinking,
+		ModelContextLength: opts.ModelContextLength,
+		MaxTokens:          opts.MaxTokens,
+		Search:             opts.Search,
+		SearchLocation:     opts.SearchLocation,
+		JSONFormat:         opts.JSONFormat,
+	}
+	if opts.Schema != nil {
+		key.Schema = opts.Schema.Definition
+	}
+	for _, msg := range msgs {
+		normalized := *msg
+		normalized.Content = normalizeCacheText(msg.Content)
+		if msg.MultiContent != nil {
+			normalized.MultiContent = slices.Clone(msg.MultiContent)
+			for i := range normalized.MultiContent {
+				normalized.MultiContent[i].Text = normalizeCacheText(normalized.MultiContent[i].Text)
+			}
+		}
+		key.Messages = append(key.Messages, &normalized)
+	}
+
+	var data []byte
+	if data, err = json.Marshal(key); err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	ret = hex.EncodeToString(sum[:])
+	return
+}
+
+func normalizeCacheText(text string) string {
+	return strings.TrimSpace(strings.ReplaceAll(text, "\r\n", "\n"))
+}
+
+// get returns the response cached for key unless it is missing or expired,
+// and counts the hit or miss.
+func (o *ResponseCache) get(key string) (ret *cacheEntry, ok bool) {
+	if ret, ok = o.read(key); !ok {
+		o.misses.Add(1)
+	} else {
+		o.hits.Add(1)
+		if ret.Usage != nil {
+			o.savedInputTokens.Add(int64(ret.Usage.InputTokens))
+			o.savedOutputTokens.Add(int64(ret.Usage.OutputTokens))
+		}
+	}
+	if time.Since(time.Unix(0, o.flushed.Load())) >= cacheFlushInterval {
+		// The counters only serve the report, so failing to write them does not fail the request
+		if err := o.Flush(); err != nil {
+			debuglog.Debug(debuglog.Basic, "Could not update the cache counters: %v\n", err)
+		}
+	}
+	return
+}
+
+func (o *ResponseCache) read(key string) (ret *cacheEntry, ok bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	path := o.entryPath(key)
+	if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) < o.config.TTL {
+		if data, readErr := os.ReadFile(path); readErr == nil {
+			ret = &cacheEntry{}
+			ok = json.Unmarshal(data, ret) == nil
+		}
+	}
+	return
+}
+
+// put stores entry for key and removes expired responses and, beyond the
+// limits of the cache, the oldest ones.
+func (o *ResponseCache) put(key string, entry *cacheEntry) (err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err = os.MkdirAll(o.dir, 0755); err != nil {
+		return
+	}
+	var data []byte
+	if data, err = json.Marshal(entry); err != nil {
+		return
+	}
+	if err = writeFileAtomic(o.entryPath(key), data); err != nil {
+		return
+	}
+	return o.prune()
+}
+
+func (o *ResponseCache) prune() (err error) {
+	entries, err := o.entries()
+	if err != nil {
+		return
+	}
+	// Oldest first
+	slices.SortFunc(entries, func(a, b os.FileInfo) int { return a.ModTime().Compare(b.ModTime()) })
+
+	var size int64
+	for _, entry := range entries {
+		size += entry.Size()
+	}
+	count := len(entries)
+	maxSize := o.config.MaxSizeMB * 1024 * 1024
+	for _, entry := range entries {
+		expired := time.Since(entry.ModTime()) >= o.config.TTL
+		if !expired && size <= maxSize && (o.config.MaxEntries == 0 || count <= o.config.MaxEntries) {
+			continue
+		}
+		if removeErr := os.Remove(filepath.Join(o.dir, entry.Name())); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			return removeErr
+		}
+		size -= entry.Size()
+		count--
+	}
+	return
+}
+
+// Stats returns what the cache holds and the counters of its use.
+func (o *ResponseCache) Stats() (ret CacheStats, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	ret = o.readCounters()
+	ret.Hits += int(o.hits.Load())
+	ret.Misses += int(o.misses.Load())
+	ret.SavedInputTokens += int(o.savedInputTokens.Load())
+	ret.SavedOutputTokens += int(o.savedOutputTokens.Load())
+	var entries []os.FileInfo
+	if entries, err = o.entries(); err != nil {
+		return
+	}
+	for _, entry := range entries {
+		ret.Entries++
+		ret.Bytes += entry.Size()
+		if time.Since(entry.ModTime()) >= o.config.TTL {
+			ret.Expired++
+		}
+	}
+	return
+}
+
+// entries returns the cached responses, which is none when the cache was never written.
+func (o *ResponseCache) entries() (ret []os.FileInfo, err error) {
+	dirEntries, err := os.ReadDir(o.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || dirEntry.Name() == cacheStatsFile || filepath.Ext(dirEntry.Name()) != ".json" {
+			continue
+		}
+		var info os.FileInfo
+		if info, err = dirEntry.Info(); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				err = nil
+				continue
+			}
+			return
+		}
+		ret = append(ret, info)
+	}
+	return
+}
+
+func (o *ResponseCache) entryPath(key string) string {
+	return filepath.Join(o.dir, key+".json")
+}
+
+func (o *ResponseCache) readCounters() (ret CacheStats) {
+	if data, err := os.ReadFile(filepath.Join(o.dir, cacheStatsFile)); err == nil {
+		_ = json.Unmarshal(data, &ret)
+	}
+	return
+}
+
+// Flush adds the counters of this process to stats.json. It is called when
+// fabric is done and every cacheFlushInterval while the cache is in use.
+func (o *ResponseCache) Flush() (err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.flushed.Store(time.Now().UnixNano())
+	hits, misses := o.hits.Swap(0), o.misses.Swap(0)
+	savedInputTokens, savedOutputTokens := o.savedInputTokens.Swap(0), o.savedOutputTokens.Swap(0)
+	if hits == 0 && misses == 0 {
+		return
+	}
+
+	stats := o.readCounters()
+	stats.Hits += int(hits)
+	stats.Misses += int(misses)
+	stats.SavedInputTokens += int(savedInputTokens)
+	stats.SavedOutputTokens += int(savedOutputTokens)
+	var data []byte
+	if data, err = json.Marshal(stats); err == nil {
+		if err = os.MkdirAll(o.dir, 0755); err == nil {
+			err = writeFileAtomic(filepath.Join(o.dir, cacheStatsFile), data)
+		}
+	}
+	if err != nil {
+		// Kept for the next flush
+		o.hits.Add(hits)
+		o.misses.Add(misses)
+		o.savedInputTokens.Add(savedInputTokens)
+		o.savedOutputTokens.Add(savedOutputTokens)
+	}
+	return
+}
+
+// writeFileAtomic writes through a temporary file, so concurrent readers
+// never see a partial file.
+func writeFileAtomic(path string, data []byte) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// CachingVendor wraps a vendor so that a request it has answered before is
+// answered from the cache. Requests with tools, image or audio output are
+// never cached, as their results are more than the text of the answer. A
+// cached answer reports no usage, since nothing was spent on it.
+type CachingVendor struct {
+	Vendor
+	cache *ResponseCache
+}
+
+func NewCachingVendor(vendor Vendor, cache *ResponseCache) *CachingVendor {
+	return &CachingVendor{Vendor: vendor, cache: cache}
+}
+
+func (o *CachingVendor) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (
+	ret *domain.ChatResponse, err error) {
+	key := o.key(msgs, opts)
+	if key == "" {
+		return o.Vendor.Send(ctx, msgs, opts)
+	}
+	if entry, ok := o.cache.get(key); ok {
+		debuglog.Debug(debuglog.Basic, "Answering %s|%s from the cache\n", o.GetName(), opts.Model)
+		ret = &domain.ChatResponse{Content: entry.Content + domain.FormatCitations(entry.Citations)}
+		return
+	}
+
+	if ret, err = o.Vendor.Send(ctx, msgs, opts); err != nil {
+		return
+	}
+	if ret.Content != "" && len(ret.ToolCalls) == 0 && len(ret.Images) == 0 {
+		o.store(key, &cacheEntry{Content: ret.Content, Usage: ret.Usage}, opts)
+	}
+	return
+}
+
+// SendStream replays a cached answer as a stream. Otherwise the stream of the
+// vendor is passed on and cached once it has completed without error.
+func (o *CachingVendor) SendStream(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions,
+	channel chan domain.StreamUpdate) (err error) {
+	key := o.key(msgs, opts)
+	if key == "" {
+		return o.Vendor.SendStream(ctx, msgs, opts, channel)
+	}
+	defer close(channel)
+
+	if entry, ok := o.cache.get(key); ok {
+		debuglog.Debug(debuglog.Basic, "Answering %s|%s from the cache\n", o.GetName(), opts.Model)
+		for line := range strings.SplitAfterSeq(entry.Content, "\n") {
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: line}
+		}
+		for _, citation := range entry.Citations {
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeCitation, Citation: &citation}
+		}
+		return
+	}
+
+	updates := make(chan domain.StreamUpdate)
+	done := make(chan error, 1)
+	go func() {
+		done <- o.Vendor.SendStream(ctx, msgs, opts, updates)
+	}()
+
+	entry := &cacheEntry{}
+	failed := false
+	for update := range updates {
+		switch update.Type {
+		case domain.StreamTypeContent:
+			entry.Content += update.Content
+		case domain.StreamTypeCitation:
+			entry.Citations = append(entry.Citations, *update.Citation)
+		case domain.StreamTypeUsage:
+			entry.Usage = update.Usage
+		case domain.StreamTypeError:
+			failed = true
+		}
+		channel <- update
+	}
+	if err = <-done; err == nil && !failed && ctx.Err() == nil && entry.Content != "" {
+		o.store(key, entry, opts)
+	}
+	return
+}
+
+// key returns the fingerprint of a cacheable request and "" for others.
+func (o *CachingVendor) key(msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) string {
+	if opts.NoCache || len(opts.Tools) > 0 || opts.ImageFile != "" || opts.AudioOutput {
+		return ""
+	}
+	key, err := o.cache.Key(o.GetName(), msgs, opts)
+	if err != nil {
+		debuglog.Debug(debuglog.Basic, "Not caching the request: %v\n", err)
+		return ""
+	}
+	return key
+}
+
+// store caches entry. A cache that cannot be written does not fail the request.
+func (o *CachingVendor) store(key string, entry *cacheEntry, opts *domain.ChatOptions) {
+	entry.Vendor, entry.Model = o.GetName(), opts.Model
+	if err := o.cache.put(key, entry); err != nil {
+		debuglog.Log("Could not cache the response: %v\n", err)
+	}
+}
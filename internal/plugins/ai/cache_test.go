@@ -0,0 +1,202 @@
+package ai
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+)
+
+// countingVendor answers every call with a new numbered answer
+type countingVendor struct {
+	stubVendor
+	calls int
+}
+
+func (v *countingVendor) answer() string {
+	v.calls++
+	return strings.Repeat("answer ", v.calls) + "\nsecond line"
+}
+
+func (v *countingVendor) Send(context.Context, []*chat.ChatCompletionMessage, *domain.ChatOptions) (*domain.ChatResponse, error) {
+	return &domain.ChatResponse{Content: v.answer(), Usage: &domain.UsageMetadata{InputTokens: 10, OutputTokens: 4}}, nil
+}
+
+func (v *countingVendor) SendStream(_ context.Context, _ []*chat.ChatCompletionMessage, _ *domain.ChatOptions, channel chan domain.StreamUpdate) error {
+	defer close(channel)
+	channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: v.answer()}
+	channel <- domain.StreamUpdate{Type: domain.StreamTypeCitation, Citation: &domain.Citation{URL: "https://example.com"}}
+	channel <- domain.StreamUpdate{Type: domain.StreamTypeUsage, Usage: &domain.UsageMetadata{InputTokens: 10, OutputTokens: 4}}
+	return nil
+}
+
+func userMessage(content string) []*chat.ChatCompletionMessage {
+	return []*chat.ChatCompletionMessage{{Role: chat.ChatMessageRoleUser, Content: content}}
+}
+
+func collectStream(t *testing.T, vendor Vendor, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (content string, citations int, usage *domain.UsageMetadata) {
+	t.Helper()
+	channel := make(chan domain.StreamUpdate)
+	done := make(chan error, 1)
+	go func() { done <- vendor.SendStream(context.Background(), msgs, opts, channel) }()
+	for update := range channel {
+		switch update.Type {
+		case domain.StreamTypeContent:
+			content += update.Content
+		case domain.StreamTypeCitation:
+			citations++
+		case domain.StreamTypeUsage:
+			usage = update.Usage
+		}
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("SendStream failed: %v", err)
+	}
+	return
+}
+
+func TestCachingVendor_Send(t *testing.T) {
+	cache := NewResponseCache(t.TempDir(), CacheConfig{Enabled: true})
+	inner := &countingVendor{stubVendor: stubVendor{name: "OpenAI"}}
+	vendor := NewCachingVendor(inner, cache)
+	opts := &domain.ChatOptions{Model: "gpt-4o", Temperature: 0.7}
+
+	first, err := vendor.Send(context.Background(), userMessage("hello"), opts)
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	second, err := vendor.Send(context.Background(), userMessage("hello \r\n"), opts)
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if inner.calls != 1 || second.Content != first.Content {
+		t.Errorf("Expected the second request to be answered from the cache, got %d calls", inner.calls)
+	}
+	if second.Usage != nil {
+		t.Errorf("Expected no usage for a cached answer, got %+v", second.Usage)
+	}
+
+	// Any option that changes the answer misses the cache
+	if _, err = vendor.Send(context.Background(), userMessage("hello"), &domain.ChatOptions{Model: "gpt-4o", Temperature: 0.2}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	// Tools are never cached
+	withTools := &domain.ChatOptions{Model: "gpt-4o", Temperature: 0.7, Tools: []chat.Tool{{Type: chat.ToolTypeFunction}}}
+	for range 2 {
+		if _, err = vendor.Send(context.Background(), userMessage("hello"), withTools); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	if inner.calls != 4 {
+		t.Errorf("Expected changed options and tools to reach the vendor, got %d calls", inner.calls)
+	}
+
+	stats, err := cache.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Entries != 2 || stats.Hits != 1 || stats.Misses != 2 || stats.SavedInputTokens != 10 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestCachingVendor_SendStream(t *testing.T) {
+	cache := NewResponseCache(t.TempDir(), CacheConfig{Enabled: true})
+	inner := &countingVendor{stubVendor: stubVendor{name: "OpenAI"}}
+	vendor := NewCachingVendor(inner, cache)
+	opts := &domain.ChatOptions{Model: "gpt-4o"}
+
+	content, citations, usage := collectStream(t, vendor, userMessage("hello"), opts)
+	if citations != 1 || usage == nil {
+		t.Fatalf("Expected the stream to be passed on, got %d citations and usage %v", citations, usage)
+	}
+	replayed, replayedCitations, replayedUsage := collectStream(t, vendor, userMessage("hello"), opts)
+	if inner.calls != 1 || replayed != content || replayedCitations != 1 || replayedUsage != nil {
+		t.Errorf("Expected the stream to be replayed from the cache, got %q with %d calls", replayed, inner.calls)
+	}
+
+	// A streamed answer also answers a request that is not streamed
+	response, err := vendor.Send(context.Background(), userMessage("hello"), opts)
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if !strings.HasPrefix(response.Content, content) || !strings.Contains(response.Content, "https://example.com") {
+		t.Errorf("Expected the cached answer with its citations, got %q", response.Content)
+	}
+}
+
+func TestResponseCache_Flush(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewResponseCache(dir, CacheConfig{Enabled: true})
+	if err := cache.put("a", &cacheEntry{Content: "a", Usage: &domain.UsageMetadata{InputTokens: 3}}); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	cache.get("a")
+	cache.get("b")
+	if _, err := os.Stat(filepath.Join(dir, cacheStatsFile)); !os.IsNotExist(err) {
+		t.Fatalf("Expected lookups not to write the counters, got %v", err)
+	}
+	if stats, err := cache.Stats(); err != nil || stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected the counters of this process, got %+v, %v", stats, err)
+	}
+
+	// Flushes of several processes add up
+	for range 2 {
+		if err := cache.Flush(); err != nil {
+			t.Fatalf("Flush failed: %v", err)
+		}
+	}
+	other := NewResponseCache(dir, CacheConfig{Enabled: true})
+	other.get("a")
+	if err := other.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	stats, err := NewResponseCache(dir, CacheConfig{Enabled: true}).Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Hits != 2 || stats.Misses != 1 || stats.SavedInputTokens != 6 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestResponseCache_Limits(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewResponseCache(dir, CacheConfig{Enabled: true, TTL: time.Hour, MaxEntries: 2})
+	for i, key := range []string{"a", "b", "c"} {
+		if err := cache.put(key, &cacheEntry{Content: key}); err != nil {
+			t.Fatalf("put failed: %v", err)
+		}
+		// Give the entries distinct ages
+		age := time.Now().Add(-time.Duration(3-i) * time.Minute)
+		if err := os.Chtimes(filepath.Join(dir, key+".json"), age, age); err != nil {
+			t.Fatalf("Chtimes failed: %v", err)
+		}
+	}
+	if _, ok := cache.get("a"); ok {
+		t.Error("Expected the oldest entry to be removed beyond the entry limit")
+	}
+	if _, ok := cache.get("c"); !ok {
+		t.Error("Expected the newest entry to be kept")
+	}
+
+	expired := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, "b.json"), expired, expired); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	if _, ok := cache.get("b"); ok {
+		t.Error("Expected an expired entry not to be served")
+	}
+	stats, err := cache.Stats()
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	if stats.Entries != 2 || stats.Expired != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
@@ -90,6 +90,20 @@ func (c *Client) formatOptions(opts *domain.ChatOptions) string {
 	if opts.Thinking != "" {
 		builder.WriteString(fmt.Sprintf("Thinking: %s\n", string(opts.Thinking)))
 	}
+	if len(opts.Tools) > 0 {
+		names := make([]string, 0, len(opts.Tools))
+		for _, tool := range opts.Tools {
+			if tool.Function != nil {
+				names = append(names, tool.Function.Name)
+			}
+		}
+		builder.WriteString(fmt.Sprintf("Tools: %s\n", strings.Join(names, ", ")))
+	}
+	if opts.Schema != nil {
+		builder.WriteString(fmt.Sprintf("Schema: %s\n", opts.Schema.Name))
+	} else if opts.JSONFormat {
+		builder.WriteString("Format: json\n")
+	}
 	if opts.SuppressThink {
 		builder.WriteString("SuppressThink: enabled\n")
 		builder.WriteString(fmt.Sprintf("Thinking Start Tag: %s\n", opts.ThinkStartTag))
@@ -108,19 +122,19 @@ func (c *Client) constructRequest(msgs []*chat.ChatCompletionMessage, opts *doma
 	return builder.String()
 }
 
-func (c *Client) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan string) error {
+func (c *Client) SendStream(_ context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan domain.StreamUpdate) error {
 	defer close(channel)
 	request := c.constructRequest(msgs, opts)
-	channel <- request
-	channel <- "\n"
-	channel <- DryRunResponse
+	channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: request}
+	channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: "\n"}
+	channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: DryRunResponse}
 	return nil
 }
 
-func (c *Client) Send(_ context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (string, error) {
+func (c *Client) Send(_ context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (*domain.ChatResponse, error) {
 	request := c.constructRequest(msgs, opts)
 
-	return request + "\n" + DryRunResponse, nil
+	return &domain.ChatResponse{Content: request + "\n" + DryRunResponse}, nil
 }
 
 func (c *Client) Setup() error {
@@ -0,0 +1,162 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	ollamaapi "github.com/ollama/ollama/api"
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+// HTTPError is returned by vendors that call their API over plain HTTP when
+// the response status is not a success.
+type HTTPError struct {
+	StatusCode int
+	Header     http.Header
+	Body       string
+}
+
+// NewHTTPError reads the start of the response body into an HTTPError.
+func NewHTTPError(resp *http.Response) *HTTPError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return &HTTPError{StatusCode: resp.StatusCode, Header: resp.Header, Body: strings.TrimSpace(string(body))}
+}
+
+func (o *HTTPError) Error() string {
+	if o.Body == "" {
+		return fmt.Sprintf("unexpected status code: %d", o.StatusCode)
+	}
+	return fmt.Sprintf("unexpected status code: %d: %s", o.StatusCode, o.Body)
+}
+
+// statusCodePattern finds the status code in errors of vendors that only
+// report it as text, like "unexpected status code: 429".
+var statusCodePattern = regexp.MustCompile(`status code:? \(?(\d{3})\)?`)
+
+// StatusCode returns the HTTP status code of a failed vendor call, or 0 when
+// err does not carry one.
+func StatusCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode
+	}
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) {
+		return openaiErr.StatusCode
+	}
+	var anthropicErr *anthropic.Error
+	if errors.As(err, &anthropicErr) {
+		return anthropicErr.StatusCode
+	}
+	var geminiErr genai.APIError
+	if errors.As(err, &geminiErr) {
+		return geminiErr.Code
+	}
+	var ollamaErr ollamaapi.StatusError
+	if errors.As(err, &ollamaErr) {
+		return ollamaErr.StatusCode
+	}
+	// AWS SDK response errors
+	var responseErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &responseErr) {
+		return responseErr.HTTPStatusCode()
+	}
+
+	if match := statusCodePattern.FindStringSubmatch(err.Error()); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		return code
+	}
+	return 0
+}
+
+// IsRetryable reports whether a failed vendor call may succeed when tried
+// again or with another vendor: rate limits, server errors and timeouts.
+// Cancellation by the caller is never retryable.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	code := StatusCode(err)
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+}
+
+// RetryAfter returns how long the vendor asked to wait before the next call,
+// from the Retry-After headers or, for Gemini, the RetryInfo error detail.
+// It returns 0 when the vendor did not say.
+func RetryAfter(err error) time.Duration {
+	var header http.Header
+	var httpErr *HTTPError
+	var openaiErr *openai.Error
+	var anthropicErr *anthropic.Error
+	var geminiErr genai.APIError
+	switch {
+	case errors.As(err, &httpErr):
+		header = httpErr.Header
+	case errors.As(err, &openaiErr):
+		if openaiErr.Response != nil {
+			header = openaiErr.Response.Header
+		}
+	case errors.As(err, &anthropicErr):
+		if anthropicErr.Response != nil {
+			header = anthropicErr.Response.Header
+		}
+	case errors.As(err, &geminiErr):
+		for _, detail := range geminiErr.Details {
+			if kind, _ := detail["@type"].(string); strings.HasSuffix(kind, "RetryInfo") {
+				if delay, ok := detail["retryDelay"].(string); ok {
+					ret, _ := time.ParseDuration(delay)
+					return ret
+				}
+			}
+		}
+	}
+	return parseRetryAfter(header)
+}
+
+// parseRetryAfter reads the retry-after-ms header some vendors send and the
+// standard Retry-After header in seconds or as an HTTP date.
+func parseRetryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+	if value := header.Get("Retry-After-Ms"); value != "" {
+		if ms, err := strconv.ParseFloat(value, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
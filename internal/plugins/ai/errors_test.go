@@ -0,0 +1,34 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/openai/openai-go"
+	"google.golang.org/genai"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"rate limit", &openai.Error{StatusCode: 429}, true},
+		{"wrapped server error", fmt.Errorf("send: %w", genai.APIError{Code: 503}), true},
+		{"bad request", &openai.Error{StatusCode: 400}, false},
+		{"status in text", errors.New("unexpected status code: 502"), true},
+		{"status in parentheses", errors.New("unexpected status code (429) and cannot read response"), true},
+		{"timeout", context.DeadlineExceeded, true},
+		{"cancelled", fmt.Errorf("stream: %w", context.Canceled), false},
+		{"other", errors.New("invalid api key"), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("%s: IsRetryable(%v) = %v, want %v", tt.name, tt.err, got, tt.want)
+		}
+	}
+}
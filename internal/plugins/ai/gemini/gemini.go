@@ -89,7 +89,7 @@ func (o *Client) ListModels() (ret []string, err error) {
 	return
 }
 
-func (o *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret string, err error) {
+func (o *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret *domain.ChatResponse, err error) {
 	// Check if this is a TTS model request
 	if o.isTTSModel(opts.Model) {
 		if !opts.AudioOutput {
@@ -98,7 +98,12 @@ func (o *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, o
 		}
 
 		// Handle TTS generation
-		return o.generateTTSAudio(ctx, msgs, opts)
+		var audio string
+		if audio, err = o.generateTTSAudio(ctx, msgs, opts); err != nil {
+			return
+		}
+		ret = &domain.ChatResponse{Content: audio}
+		return
 	}
 
 	// Regular text generation
@@ -115,22 +120,25 @@ func (o *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, o
 
 	cfg, err := o.buildGenerateContentConfig(opts)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// Generate content with optional tools
 	response, err := client.Models.GenerateContent(ctx, o.buildModelNameFull(opts.Model), contents, cfg)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Extract text from response
-	ret = o.extractTextFromResponse(response)
+	// Extract text and requested function calls from response
+	ret = &domain.ChatResponse{
+		Content:   o.extractTextFromResponse(response),
+		ToolCalls: extractToolCalls(response),
+		Usage:     toUsageMetadata(response.UsageMetadata),
+	}
 	return
 }
 
-func (o *Client) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan string) (err error) {
-	ctx := context.Background()
+func (o *Client) SendStream(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan domain.StreamUpdate) (err error) {
 	defer close(channel)
 
 	var client *genai.Client
@@ -152,21 +160,60 @@ func (o *Client) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.Cha
 	// Generate streaming content with optional tools
 	stream := client.Models.GenerateContentStream(ctx, o.buildModelNameFull(opts.Model), contents, cfg)
 
+	// Every chunk carries the usage so far, so only the last one is reported
+	var usage *genai.GenerateContentResponseUsageMetadata
 	for response, err := range stream {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil {
-			channel <- fmt.Sprintf("Error: %v\n", err)
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeError, Err: err}
 			return err
 		}
 
-		text := o.extractTextFromResponse(response)
-		if text != "" {
-			channel <- text
+		for _, candidate := range response.Candidates {
+			if candidate == nil || candidate.Content == nil {
+				continue
+			}
+			for _, part := range candidate.Content.Parts {
+				if part == nil || part.Text == "" {
+					continue
+				}
+				if part.Thought {
+					channel <- domain.StreamUpdate{Type: domain.StreamTypeReasoning, Content: part.Text}
+				} else {
+					channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: part.Text}
+				}
+			}
+		}
+		for _, citation := range o.extractWebSources(response) {
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeCitation, Citation: &citation}
+		}
+		if response.UsageMetadata != nil {
+			usage = response.UsageMetadata
 		}
 	}
 
+	if metadata := toUsageMetadata(usage); metadata != nil {
+		channel <- domain.StreamUpdate{Type: domain.StreamTypeUsage, Usage: metadata}
+	}
 	return
 }
 
+// toUsageMetadata converts Gemini usage to fabric usage metadata. Gemini
+// counts thought tokens apart from the candidate tokens.
+func toUsageMetadata(usage *genai.GenerateContentResponseUsageMetadata) *domain.UsageMetadata {
+	if usage == nil {
+		return nil
+	}
+	return &domain.UsageMetadata{
+		InputTokens:     int(usage.PromptTokenCount + usage.ToolUsePromptTokenCount),
+		OutputTokens:    int(usage.CandidatesTokenCount + usage.ThoughtsTokenCount),
+		ReasoningTokens: int(usage.ThoughtsTokenCount),
+		CachedTokens:    int(usage.CachedContentTokenCount),
+	}
+}
+
 func (o *Client) NeedsRawMode(modelName string) bool {
 	return false
 }
@@ -221,10 +268,23 @@ func (o *Client) buildGenerateContentConfig(opts *domain.ChatOptions) (*genai.Ge
 		}
 	}
 
+	if tool := toFunctionDeclarations(opts.Tools); tool != nil {
+		cfg.Tools = append(cfg.Tools, tool)
+	}
+
 	if tc, ok := parseThinkingConfig(opts.Thinking); ok {
 		cfg.ThinkingConfig = tc
 	}
 
+	// responseJsonSchema takes the schema as is, where responseSchema only
+	// supports an OpenAPI subset of it
+	if opts.Schema != nil {
+		cfg.ResponseMIMEType = "application/json"
+		cfg.ResponseJsonSchema = opts.Schema.Definition
+	} else if opts.JSONFormat {
+		cfg.ResponseMIMEType = "application/json"
+	}
+
 	return cfg, nil
 }
 
@@ -441,6 +501,16 @@ func (o *Client) convertMessages(msgs []*chat.ChatCompletionMessage) []*genai.Co
 	var contents []*genai.Content
 
 	for _, msg := range msgs {
+		// Function responses for the same turn are sent together in one content
+		if msg.Role == chat.ChatMessageRoleTool {
+			if n := len(contents); n > 0 && len(contents[n-1].Parts) > 0 && contents[n-1].Parts[0].FunctionResponse != nil {
+				contents[n-1].Parts = append(contents[n-1].Parts, toFunctionResponsePart(msg))
+			} else {
+				contents = append(contents, &genai.Content{Role: "user", Parts: []*genai.Part{toFunctionResponsePart(msg)}})
+			}
+			continue
+		}
+
 		content := &genai.Content{Parts: []*genai.Part{}}
 
 		switch msg.Role {
@@ -471,6 +541,8 @@ func (o *Client) convertMessages(msgs []*chat.ChatCompletionMessage) []*genai.Co
 			}
 		}
 
+		content.Parts = append(content.Parts, toFunctionCallParts(msg.ToolCalls)...)
+
 		contents = append(contents, content)
 	}
 
@@ -507,13 +579,21 @@ func (o *Client) extractTextParts(response *genai.GenerateContentResponse) strin
 	return builder.String()
 }
 
-func (o *Client) extractCitations(response *genai.GenerateContentResponse) []string {
+func (o *Client) extractCitations(response *genai.GenerateContentResponse) (ret []string) {
+	for _, source := range o.extractWebSources(response) {
+		ret = append(ret, fmt.Sprintf(citationFormat, source.Title, source.URL))
+	}
+	return
+}
+
+// extractWebSources returns the distinct web sources a response was grounded on
+func (o *Client) extractWebSources(response *genai.GenerateContentResponse) []domain.Citation {
 	if response == nil || len(response.Candidates) == 0 {
 		return nil
 	}
 
 	citationMap := make(map[string]bool)
-	var citations []string
+	var citations []domain.Citation
 	for _, candidate := range response.Candidates {
 		if candidate == nil || candidate.GroundingMetadata == nil {
 			continue
@@ -538,8 +618,7 @@ func (o *Client) extractCitations(response *genai.GenerateContentResponse) []str
 			key := keyBuilder.String()
 			if !citationMap[key] {
 				citationMap[key] = true
-				citationText := fmt.Sprintf(citationFormat, title, uri)
-				citations = append(citations, citationText)
+				citations = append(citations, domain.Citation{Title: title, URL: uri})
 			}
 		}
 	}
@@ -0,0 +1,68 @@
+package gemini
+
+import (
+	"encoding/json"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"google.golang.org/genai"
+)
+
+// toFunctionDeclarations converts function tools to a Gemini tool with function declarations
+func toFunctionDeclarations(tools []chat.Tool) *genai.Tool {
+	var declarations []*genai.FunctionDeclaration
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		declarations = append(declarations, &genai.FunctionDeclaration{
+			Name:                 tool.Function.Name,
+			Description:          tool.Function.Description,
+			ParametersJsonSchema: tool.Function.Parameters,
+		})
+	}
+	if len(declarations) == 0 {
+		return nil
+	}
+	return &genai.Tool{FunctionDeclarations: declarations}
+}
+
+// toFunctionCallParts converts the tool calls of an assistant message to function call parts
+func toFunctionCallParts(calls []chat.ToolCall) (ret []*genai.Part) {
+	for _, call := range calls {
+		args := map[string]any{}
+		if call.Function.Arguments != "" {
+			_ = json.Unmarshal([]byte(call.Function.Arguments), &args)
+		}
+		ret = append(ret, &genai.Part{FunctionCall: &genai.FunctionCall{
+			ID:   call.ID,
+			Name: call.Function.Name,
+			Args: args,
+		}})
+	}
+	return
+}
+
+// toFunctionResponsePart converts a tool result message to a function response part
+func toFunctionResponsePart(msg *chat.ChatCompletionMessage) *genai.Part {
+	return &genai.Part{FunctionResponse: &genai.FunctionResponse{
+		ID:       msg.ToolCallID,
+		Name:     msg.Name,
+		Response: map[string]any{"output": msg.Content},
+	}}
+}
+
+// extractToolCalls returns the function calls requested in a response
+func extractToolCalls(response *genai.GenerateContentResponse) (ret []chat.ToolCall) {
+	if response == nil {
+		return
+	}
+	for _, call := range response.FunctionCalls() {
+		arguments, _ := json.Marshal(call.Args)
+		ret = append(ret, chat.ToolCall{
+			ID:       call.ID,
+			Type:     chat.ToolTypeFunction,
+			Function: chat.FunctionCall{Name: call.Name, Arguments: string(arguments)},
+		})
+	}
+	return
+}
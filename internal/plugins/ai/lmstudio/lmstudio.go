@@ -13,6 +13,7 @@ import (
 
 	"github.com/danielmiessler/fabric/internal/domain"
 	"github.com/danielmiessler/fabric/internal/plugins"
+	"github.com/danielmiessler/fabric/internal/plugins/ai"
 )
 
 // NewClient creates a new LM Studio client with default configuration.
@@ -66,7 +67,7 @@ func (c *Client) ListModels() ([]string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, ai.NewHTTPError(resp)
 	}
 
 	var result struct {
@@ -87,13 +88,18 @@ func (c *Client) ListModels() ([]string, error) {
 	return models, nil
 }
 
-func (c *Client) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan string) (err error) {
+func (c *Client) SendStream(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan domain.StreamUpdate) (err error) {
+	defer close(channel)
+
 	url := fmt.Sprintf("%s/chat/completions", c.ApiUrl.Value)
 
 	payload := map[string]any{
 		"messages": msgs,
 		"model":    opts.Model,
 		"stream":   true, // Enable streaming
+		"stream_options": map[string]any{
+			"include_usage": true,
+		},
 	}
 
 	var jsonPayload []byte
@@ -103,7 +109,7 @@ func (c *Client) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.Cha
 	}
 
 	var req *http.Request
-	if req, err = http.NewRequest("POST", url, bytes.NewBuffer(jsonPayload)); err != nil {
+	if req, err = http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload)); err != nil {
 		err = fmt.Errorf("failed to create request: %w", err)
 		return
 	}
@@ -118,12 +124,10 @@ func (c *Client) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.Cha
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		err = ai.NewHTTPError(resp)
 		return
 	}
 
-	defer close(channel)
-
 	reader := bufio.NewReader(resp.Body)
 	for {
 		var line []byte
@@ -153,6 +157,10 @@ func (c *Client) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.Cha
 			continue
 		}
 
+		if usage := parseUsage(result); usage != nil {
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeUsage, Usage: usage}
+		}
+
 		var choices []any
 		var ok bool
 		if choices, ok = result["choices"].([]any); !ok || len(choices) == 0 {
@@ -166,14 +174,14 @@ func (c *Client) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.Cha
 
 		var content string
 		if content, _ = delta["content"].(string); content != "" {
-			channel <- content
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: content}
 		}
 	}
 
 	return
 }
 
-func (c *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (content string, err error) {
+func (c *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret *domain.ChatResponse, err error) {
 	url := fmt.Sprintf("%s/chat/completions", c.ApiUrl.Value)
 
 	payload := map[string]any{
@@ -204,7 +212,7 @@ func (c *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, o
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		err = ai.NewHTTPError(resp)
 		return
 	}
 
@@ -227,14 +235,30 @@ func (c *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, o
 		return
 	}
 
+	var content string
 	if content, ok = message["content"].(string); !ok {
 		err = fmt.Errorf("invalid response format: missing or non-string content in message")
 		return
 	}
 
+	ret = &domain.ChatResponse{Content: content, Usage: parseUsage(result)}
 	return
 }
 
+// parseUsage reads the OpenAI style usage object of a response, if present
+func parseUsage(result map[string]any) *domain.UsageMetadata {
+	usage, ok := result["usage"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	promptTokens, _ := usage["prompt_tokens"].(float64)
+	completionTokens, _ := usage["completion_tokens"].(float64)
+	return &domain.UsageMetadata{
+		InputTokens:  int(promptTokens),
+		OutputTokens: int(completionTokens),
+	}
+}
+
 func (c *Client) Complete(ctx context.Context, prompt string, opts *domain.ChatOptions) (text string, err error) {
 	url := fmt.Sprintf("%s/completions", c.ApiUrl.Value)
 
@@ -266,7 +290,7 @@ func (c *Client) Complete(ctx context.Context, prompt string, opts *domain.ChatO
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		err = ai.NewHTTPError(resp)
 		return
 	}
 
@@ -322,7 +346,7 @@ func (c *Client) GetEmbeddings(ctx context.Context, input string, opts *domain.C
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		err = fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		err = ai.NewHTTPError(resp)
 		return
 	}
 
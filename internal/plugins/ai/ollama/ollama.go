@@ -3,6 +3,7 @@ package ollama
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
@@ -106,8 +107,8 @@ func (o *Client) ListModels() (ret []string, err error) {
 	return
 }
 
-func (o *Client) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan string) (err error) {
-	ctx := context.Background()
+func (o *Client) SendStream(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan domain.StreamUpdate) (err error) {
+	defer close(channel)
 
 	var req ollamaapi.ChatRequest
 	if req, err = o.createChatRequest(ctx, msgs, opts); err != nil {
@@ -115,19 +116,22 @@ func (o *Client) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.Cha
 	}
 
 	respFunc := func(resp ollamaapi.ChatResponse) (streamErr error) {
-		channel <- resp.Message.Content
-		return
-	}
-
-	if err = o.client.Chat(ctx, &req, respFunc); err != nil {
+		if resp.Message.Thinking != "" {
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeReasoning, Content: resp.Message.Thinking}
+		}
+		channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: resp.Message.Content}
+		// The eval counts are only set on the final response
+		if resp.Done {
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeUsage, Usage: toUsageMetadata(resp.Metrics)}
+		}
 		return
 	}
 
-	close(channel)
+	err = o.client.Chat(ctx, &req, respFunc)
 	return
 }
 
-func (o *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret string, err error) {
+func (o *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret *domain.ChatResponse, err error) {
 	bf := false
 
 	var req ollamaapi.ChatRequest
@@ -136,8 +140,11 @@ func (o *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, o
 	}
 	req.Stream = &bf
 
+	ret = &domain.ChatResponse{}
 	respFunc := func(resp ollamaapi.ChatResponse) (streamErr error) {
-		ret = resp.Message.Content
+		ret.Content = resp.Message.Content
+		ret.ToolCalls = fromOllamaToolCalls(resp.Message.ToolCalls)
+		ret.Usage = toUsageMetadata(resp.Metrics)
 		return
 	}
 
@@ -147,6 +154,14 @@ func (o *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, o
 	return
 }
 
+// toUsageMetadata converts the Ollama eval counts to fabric usage metadata
+func toUsageMetadata(metrics ollamaapi.Metrics) *domain.UsageMetadata {
+	return &domain.UsageMetadata{
+		InputTokens:  metrics.PromptEvalCount,
+		OutputTokens: metrics.EvalCount,
+	}
+}
+
 func (o *Client) createChatRequest(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret ollamaapi.ChatRequest, err error) {
 	messages := make([]ollamaapi.Message, len(msgs))
 	for i, message := range msgs {
@@ -171,12 +186,35 @@ func (o *Client) createChatRequest(ctx context.Context, msgs []*chat.ChatComplet
 		Messages: messages,
 		Options:  options,
 	}
+
+	if len(opts.Tools) > 0 {
+		if ret.Tools, err = toOllamaTools(opts.Tools); err != nil {
+			return
+		}
+	}
+
+	// Ollama constrains the output to a JSON schema given as the format
+	if opts.Schema != nil {
+		if ret.Format, err = json.Marshal(opts.Schema.Definition); err != nil {
+			return
+		}
+	} else if opts.JSONFormat {
+		ret.Format = json.RawMessage(`"json"`)
+	}
 	return
 }
 
 func (o *Client) convertMessage(ctx context.Context, message *chat.ChatCompletionMessage) (ret ollamaapi.Message, err error) {
 	ret = ollamaapi.Message{Role: message.Role, Content: message.Content}
 
+	if message.Role == chat.ChatMessageRoleTool {
+		ret.ToolName = message.Name
+		ret.ToolCallID = message.ToolCallID
+	}
+	if len(message.ToolCalls) > 0 {
+		ret.ToolCalls = toOllamaToolCalls(message.ToolCalls)
+	}
+
 	if len(message.MultiContent) == 0 {
 		return
 	}
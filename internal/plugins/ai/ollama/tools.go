@@ -0,0 +1,63 @@
+package ollama
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	ollamaapi "github.com/ollama/ollama/api"
+)
+
+// toOllamaTools converts function tools to Ollama tool definitions. The JSON
+// schema is round-tripped through JSON because Ollama models it with typed structs.
+func toOllamaTools(tools []chat.Tool) (ret ollamaapi.Tools, err error) {
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		var data []byte
+		if data, err = json.Marshal(tool); err != nil {
+			return
+		}
+		var converted ollamaapi.Tool
+		if err = json.Unmarshal(data, &converted); err != nil {
+			err = fmt.Errorf("tool %s: unsupported parameters schema: %w", tool.Function.Name, err)
+			return
+		}
+		ret = append(ret, converted)
+	}
+	return
+}
+
+func toOllamaToolCalls(calls []chat.ToolCall) (ret []ollamaapi.ToolCall) {
+	for i, call := range calls {
+		args := ollamaapi.ToolCallFunctionArguments{}
+		if call.Function.Arguments != "" {
+			_ = json.Unmarshal([]byte(call.Function.Arguments), &args)
+		}
+		ret = append(ret, ollamaapi.ToolCall{
+			ID: call.ID,
+			Function: ollamaapi.ToolCallFunction{
+				Index:     i,
+				Name:      call.Function.Name,
+				Arguments: args,
+			},
+		})
+	}
+	return
+}
+
+func fromOllamaToolCalls(calls []ollamaapi.ToolCall) (ret []chat.ToolCall) {
+	for i, call := range calls {
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i)
+		}
+		ret = append(ret, chat.ToolCall{
+			ID:       id,
+			Type:     chat.ToolTypeFunction,
+			Function: chat.FunctionCall{Name: call.Function.Name, Arguments: call.Function.Arguments.String()},
+		})
+	}
+	return
+}
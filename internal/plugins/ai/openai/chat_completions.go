@@ -15,35 +15,42 @@ import (
 )
 
 // sendChatCompletions sends a request using the Chat Completions API
-func (o *Client) sendChatCompletions(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret string, err error) {
+func (o *Client) sendChatCompletions(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret *domain.ChatResponse, err error) {
 	req := o.buildChatCompletionParams(msgs, opts)
 
 	var resp *openai.ChatCompletion
 	if resp, err = o.ApiClient.Chat.Completions.New(ctx, req); err != nil {
 		return
 	}
+	ret = &domain.ChatResponse{Usage: fromCompletionUsage(resp.Usage)}
 	if len(resp.Choices) > 0 {
-		ret = resp.Choices[0].Message.Content
+		ret.Content = resp.Choices[0].Message.Content
+		ret.ToolCalls = fromChatCompletionToolCalls(resp.Choices[0].Message.ToolCalls)
 	}
 	return
 }
 
 // sendStreamChatCompletions sends a streaming request using the Chat Completions API
 func (o *Client) sendStreamChatCompletions(
-	msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan string,
+	ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan domain.StreamUpdate,
 ) (err error) {
 	defer close(channel)
 
 	req := o.buildChatCompletionParams(msgs, opts)
-	stream := o.ApiClient.Chat.Completions.NewStreaming(context.Background(), req)
+	// Ask for a final chunk carrying the token usage of the request
+	req.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+	stream := o.ApiClient.Chat.Completions.NewStreaming(ctx, req)
 	for stream.Next() {
 		chunk := stream.Current()
 		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
-			channel <- chunk.Choices[0].Delta.Content
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: chunk.Choices[0].Delta.Content}
+		}
+		if usage := fromCompletionUsage(chunk.Usage); usage != nil {
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeUsage, Usage: usage}
 		}
 	}
 	if stream.Err() == nil {
-		channel <- "\n"
+		channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: "\n"}
 	}
 	return stream.Err()
 }
@@ -67,6 +74,23 @@ func (o *Client) buildChatCompletionParams(
 		Messages: messages,
 	}
 
+	if len(opts.Tools) > 0 {
+		ret.Tools = toChatCompletionTools(opts.Tools)
+	}
+
+	if opts.Schema != nil {
+		ret.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{
+					Name:   opts.Schema.Name,
+					Schema: opts.Schema.Definition,
+				},
+			},
+		}
+	} else if opts.JSONFormat {
+		ret.ResponseFormat = openai.ChatCompletionNewParamsResponseFormatUnion{OfJSONObject: &shared.ResponseFormatJSONObjectParam{}}
+	}
+
 	if !opts.Raw {
 		ret.Temperature = openai.Float(opts.Temperature)
 		if opts.TopP != 0 {
@@ -114,7 +138,12 @@ func (o *Client) convertChatMessage(msg chat.ChatCompletionMessage) openai.ChatC
 		}
 		return openai.UserMessage(result.Content)
 	case chat.ChatMessageRoleAssistant:
+		if len(msg.ToolCalls) > 0 {
+			return toAssistantToolCallMessage(msg)
+		}
 		return openai.AssistantMessage(result.Content)
+	case chat.ChatMessageRoleTool:
+		return openai.ToolMessage(result.Content, msg.ToolCallID)
 	default:
 		return openai.UserMessage(result.Content)
 	}
@@ -76,7 +76,8 @@ func (o *Client) SetResponsesAPIEnabled(enabled bool) {
 }
 
 func (o *Client) configure() (ret error) {
-	opts := []option.RequestOption{option.WithAPIKey(o.ApiKey.Value)}
+	// Retries are left to ai.RetryingVendor
+	opts := []option.RequestOption{option.WithAPIKey(o.ApiKey.Value), option.WithMaxRetries(0)}
 	if o.ApiBaseURL.Value != "" {
 		opts = append(opts, option.WithBaseURL(o.ApiBaseURL.Value))
 	}
@@ -108,42 +109,59 @@ func (o *Client) ListModels() (ret []string, err error) {
 }
 
 func (o *Client) SendStream(
-	msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan string,
+	ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan domain.StreamUpdate,
 ) (err error) {
 	// Use Responses API for OpenAI, Chat Completions API for other providers
 	if o.supportsResponsesAPI() {
-		return o.sendStreamResponses(msgs, opts, channel)
+		return o.sendStreamResponses(ctx, msgs, opts, channel)
 	}
-	return o.sendStreamChatCompletions(msgs, opts, channel)
+	return o.sendStreamChatCompletions(ctx, msgs, opts, channel)
 }
 
 func (o *Client) sendStreamResponses(
-	msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan string,
+	ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan domain.StreamUpdate,
 ) (err error) {
 	defer close(channel)
 
 	req := o.buildResponseParams(msgs, opts)
-	stream := o.ApiClient.Responses.NewStreaming(context.Background(), req)
+	stream := o.ApiClient.Responses.NewStreaming(ctx, req)
 	for stream.Next() {
 		event := stream.Current()
 		switch event.Type {
 		case string(constant.ResponseOutputTextDelta("").Default()):
-			channel <- event.AsResponseOutputTextDelta().Delta
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: event.AsResponseOutputTextDelta().Delta}
+		case string(constant.ResponseReasoningSummaryTextDelta("").Default()):
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeReasoning, Content: event.AsResponseReasoningSummaryTextDelta().Delta}
 		case string(constant.ResponseOutputTextDone("").Default()):
 			// The Responses API sends the full text again in the
 			// final "done" event. Since we've already streamed all
 			// delta chunks above, sending it would duplicate the
 			// output. Ignore it here to prevent doubled results.
 			continue
+		case string(constant.ResponseCompleted("").Default()):
+			resp := event.AsResponseCompleted().Response
+			for _, citation := range extractCitations(&resp) {
+				channel <- domain.StreamUpdate{Type: domain.StreamTypeCitation, Citation: &citation}
+			}
+			var image string
+			if image, err = o.extractAndSaveImages(&resp, opts); err != nil {
+				return
+			}
+			if image != "" {
+				channel <- domain.StreamUpdate{Type: domain.StreamTypeImage, Content: image}
+			}
+			if usage := fromResponseUsage(resp.Usage); usage != nil {
+				channel <- domain.StreamUpdate{Type: domain.StreamTypeUsage, Usage: usage}
+			}
 		}
 	}
 	if stream.Err() == nil {
-		channel <- "\n"
+		channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: "\n"}
 	}
 	return stream.Err()
 }
 
-func (o *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret string, err error) {
+func (o *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret *domain.ChatResponse, err error) {
 	// Use Responses API for OpenAI, Chat Completions API for other providers
 	if o.supportsResponsesAPI() {
 		return o.sendResponses(ctx, msgs, opts)
@@ -151,10 +169,10 @@ func (o *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, o
 	return o.sendChatCompletions(ctx, msgs, opts)
 }
 
-func (o *Client) sendResponses(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret string, err error) {
+func (o *Client) sendResponses(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (ret *domain.ChatResponse, err error) {
 	// Validate model supports image generation if image file is specified
 	if opts.ImageFile != "" && !supportsImageGeneration(opts.Model) {
-		return "", fmt.Errorf("model '%s' does not support image generation. Supported models: %s", opts.Model, strings.Join(ImageGenerationSupportedModels, ", "))
+		return nil, fmt.Errorf("model '%s' does not support image generation. Supported models: %s", opts.Model, strings.Join(ImageGenerationSupportedModels, ", "))
 	}
 
 	req := o.buildResponseParams(msgs, opts)
@@ -165,11 +183,19 @@ func (o *Client) sendResponses(ctx context.Context, msgs []*chat.ChatCompletionM
 	}
 
 	// Extract and save images if requested
-	if err = o.extractAndSaveImages(resp, opts); err != nil {
+	var image string
+	if image, err = o.extractAndSaveImages(resp, opts); err != nil {
 		return
 	}
 
-	ret = o.extractText(resp)
+	ret = &domain.ChatResponse{
+		Content:   o.extractText(resp),
+		ToolCalls: extractToolCalls(resp),
+		Usage:     fromResponseUsage(resp.Usage),
+	}
+	if image != "" {
+		ret.Images = []string{image}
+	}
 	return
 }
 
@@ -217,13 +243,17 @@ func (o *Client) buildResponseParams(
 	inputMsgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions,
 ) (ret responses.ResponseNewParams) {
 
-	items := make([]responses.ResponseInputItemUnionParam, len(inputMsgs))
-	for i, msgPtr := range inputMsgs {
+	items := make([]responses.ResponseInputItemUnionParam, 0, len(inputMsgs))
+	for _, msgPtr := range inputMsgs {
 		msg := *msgPtr
 		if strings.Contains(opts.Model, "deepseek") && len(inputMsgs) == 1 && msg.Role == chat.ChatMessageRoleSystem {
 			msg.Role = chat.ChatMessageRoleUser
 		}
-		items[i] = convertMessage(msg)
+		if toolItems, ok := convertToolMessages(msg); ok {
+			items = append(items, toolItems...)
+			continue
+		}
+		items = append(items, convertMessage(msg))
 	}
 
 	ret = responses.ResponseNewParams{
@@ -254,6 +284,9 @@ func (o *Client) buildResponseParams(
 	// Add image generation tool if needed
 	tools = o.addImageGenerationTool(opts, tools)
 
+	// Add function tools registered by the caller
+	tools = append(tools, toResponsesTools(opts.Tools)...)
+
 	if len(tools) > 0 {
 		ret.Tools = tools
 	}
@@ -262,6 +295,21 @@ func (o *Client) buildResponseParams(
 		ret.Reasoning = shared.ReasoningParam{Effort: eff}
 	}
 
+	if opts.Schema != nil {
+		ret.Text = responses.ResponseTextConfigParam{
+			Format: responses.ResponseFormatTextConfigUnionParam{
+				OfJSONSchema: &responses.ResponseFormatTextJSONSchemaConfigParam{
+					Name:   opts.Schema.Name,
+					Schema: opts.Schema.Definition,
+				},
+			},
+		}
+	} else if opts.JSONFormat {
+		ret.Text = responses.ResponseTextConfigParam{
+			Format: responses.ResponseFormatTextConfigUnionParam{OfJSONObject: &shared.ResponseFormatJSONObjectParam{}},
+		}
+	}
+
 	if !opts.Raw {
 		ret.Temperature = openai.Float(opts.Temperature)
 		if opts.TopP != 0 {
@@ -315,28 +363,11 @@ func convertMessage(msg chat.ChatCompletionMessage) responses.ResponseInputItemU
 
 func (o *Client) extractText(resp *responses.Response) (ret string) {
 	var textParts []string
-	var citations []string
-	citationMap := make(map[string]bool) // To avoid duplicate citations
-
 	for _, item := range resp.Output {
 		if item.Type == "message" {
 			for _, c := range item.Content {
 				if c.Type == "output_text" {
-					outputText := c.AsOutputText()
-					textParts = append(textParts, outputText.Text)
-
-					// Extract citations from annotations
-					for _, annotation := range outputText.Annotations {
-						if annotation.Type == "url_citation" {
-							urlCitation := annotation.AsURLCitation()
-							citationKey := urlCitation.URL + "|" + urlCitation.Title
-							if !citationMap[citationKey] {
-								citationMap[citationKey] = true
-								citationText := fmt.Sprintf("- [%s](%s)", urlCitation.Title, urlCitation.URL)
-								citations = append(citations, citationText)
-							}
-						}
-					}
+					textParts = append(textParts, c.AsOutputText().Text)
 				}
 			}
 			break
@@ -346,9 +377,36 @@ func (o *Client) extractText(resp *responses.Response) (ret string) {
 	ret = strings.Join(textParts, "")
 
 	// Append citations if any were found
-	if len(citations) > 0 {
-		ret += "\n\n## Sources\n\n" + strings.Join(citations, "\n")
+	if citations := extractCitations(resp); len(citations) > 0 {
+		ret += strings.TrimSuffix(domain.FormatCitations(citations), "\n")
 	}
 
 	return
 }
+
+// extractCitations returns the distinct url_citation annotations of the first output message
+func extractCitations(resp *responses.Response) (ret []domain.Citation) {
+	seen := make(map[string]bool)
+	for _, item := range resp.Output {
+		if item.Type != "message" {
+			continue
+		}
+		for _, c := range item.Content {
+			if c.Type != "output_text" {
+				continue
+			}
+			for _, annotation := range c.AsOutputText().Annotations {
+				if annotation.Type == "url_citation" {
+					urlCitation := annotation.AsURLCitation()
+					key := urlCitation.URL + "|" + urlCitation.Title
+					if !seen[key] {
+						seen[key] = true
+						ret = append(ret, domain.Citation{Title: urlCitation.Title, URL: urlCitation.URL})
+					}
+				}
+			}
+		}
+		break
+	}
+	return
+}
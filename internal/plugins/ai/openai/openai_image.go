@@ -102,10 +102,11 @@ func (o *Client) shouldUseImageGeneration(opts *domain.ChatOptions) bool {
 	return opts.ImageFile != ""
 }
 
-// extractAndSaveImages extracts generated images from the response and saves them
-func (o *Client) extractAndSaveImages(resp *responses.Response, opts *domain.ChatOptions) error {
+// extractAndSaveImages extracts generated images from the response and saves
+// them, returning the path of the saved image or "" when there was none
+func (o *Client) extractAndSaveImages(resp *responses.Response, opts *domain.ChatOptions) (string, error) {
 	if opts.ImageFile == "" {
-		return nil // No image file specified, skip saving
+		return "", nil // No image file specified, skip saving
 	}
 
 	// Extract image data from response
@@ -116,27 +117,26 @@ func (o *Client) extractAndSaveImages(resp *responses.Response, opts *domain.Cha
 				// Decode base64 image data
 				imageData, err := base64.StdEncoding.DecodeString(imageCall.Result)
 				if err != nil {
-					return fmt.Errorf("failed to decode image data: %w", err)
+					return "", fmt.Errorf("failed to decode image data: %w", err)
 				}
 
 				// Ensure directory exists
 				dir := filepath.Dir(opts.ImageFile)
 				if dir != "." {
 					if err := os.MkdirAll(dir, 0755); err != nil {
-						return fmt.Errorf("failed to create directory %s: %w", dir, err)
+						return "", fmt.Errorf("failed to create directory %s: %w", dir, err)
 					}
 				}
 
 				// Save image to file
 				if err := os.WriteFile(opts.ImageFile, imageData, 0644); err != nil {
-					return fmt.Errorf("failed to save image to %s: %w", opts.ImageFile, err)
+					return "", fmt.Errorf("failed to save image to %s: %w", opts.ImageFile, err)
 				}
 
-				fmt.Printf("Image saved to: %s\n", opts.ImageFile)
-				return nil
+				return opts.ImageFile, nil
 			}
 		}
 	}
 
-	return nil
+	return "", nil
 }
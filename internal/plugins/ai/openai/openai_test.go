@@ -175,3 +175,35 @@ func TestCitationFormatting(t *testing.T) {
 	citationCount := strings.Count(result, "- [")
 	assert.Equal(t, 2, citationCount, "Expected 2 unique citations")
 }
+
+func TestBuildParamsWithSchema(t *testing.T) {
+	schema, err := domain.ParseSchema("person", []byte(`{"type":"object","properties":{"name":{"type":"string"}}}`))
+	assert.NoError(t, err)
+	msgs := []*chat.ChatCompletionMessage{{Role: chat.ChatMessageRoleUser, Content: "who?"}}
+	opts := &domain.ChatOptions{Model: "gpt-4o", Schema: schema}
+
+	var client = NewClient()
+	request := client.buildResponseParams(msgs, opts)
+	if assert.NotNil(t, request.Text.Format.OfJSONSchema) {
+		assert.Equal(t, "person", request.Text.Format.OfJSONSchema.Name)
+		assert.Equal(t, schema.Definition, request.Text.Format.OfJSONSchema.Schema)
+	}
+
+	params := client.buildChatCompletionParams(msgs, opts)
+	if assert.NotNil(t, params.ResponseFormat.OfJSONSchema) {
+		assert.Equal(t, "person", params.ResponseFormat.OfJSONSchema.JSONSchema.Name)
+	}
+}
+
+func TestBuildParamsWithJSONFormat(t *testing.T) {
+	msgs := []*chat.ChatCompletionMessage{{Role: chat.ChatMessageRoleUser, Content: "who?"}}
+	opts := &domain.ChatOptions{Model: "gpt-4o", JSONFormat: true}
+
+	var client = NewClient()
+	request := client.buildResponseParams(msgs, opts)
+	assert.NotNil(t, request.Text.Format.OfJSONObject)
+	assert.Nil(t, request.Text.Format.OfJSONSchema)
+
+	params := client.buildChatCompletionParams(msgs, opts)
+	assert.NotNil(t, params.ResponseFormat.OfJSONObject)
+}
@@ -0,0 +1,111 @@
+package openai
+
+// This file maps fabric tool definitions, tool calls and tool results to the
+// Responses and Chat Completions APIs.
+
+import (
+	"github.com/danielmiessler/fabric/internal/chat"
+	openai "github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+	"github.com/openai/openai-go/shared"
+)
+
+// toResponsesTools converts function tools to Responses API tool params
+func toResponsesTools(tools []chat.Tool) (ret []responses.ToolUnionParam) {
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		param := responses.ToolParamOfFunction(tool.Function.Name, tool.Function.Parameters, tool.Function.Strict)
+		if tool.Function.Description != "" {
+			param.OfFunction.Description = openai.String(tool.Function.Description)
+		}
+		ret = append(ret, param)
+	}
+	return
+}
+
+// toChatCompletionTools converts function tools to Chat Completions API tool params
+func toChatCompletionTools(tools []chat.Tool) (ret []openai.ChatCompletionToolParam) {
+	for _, tool := range tools {
+		if tool.Function == nil {
+			continue
+		}
+		function := shared.FunctionDefinitionParam{
+			Name:       tool.Function.Name,
+			Parameters: shared.FunctionParameters(tool.Function.Parameters),
+		}
+		if tool.Function.Description != "" {
+			function.Description = openai.String(tool.Function.Description)
+		}
+		if tool.Function.Strict {
+			function.Strict = openai.Bool(true)
+		}
+		ret = append(ret, openai.ChatCompletionToolParam{Function: function})
+	}
+	return
+}
+
+// convertToolMessages converts assistant tool calls and tool results to
+// Responses API input items. ok is false for any other message.
+func convertToolMessages(msg chat.ChatCompletionMessage) (ret []responses.ResponseInputItemUnionParam, ok bool) {
+	switch {
+	case msg.Role == chat.ChatMessageRoleTool:
+		ret = append(ret, responses.ResponseInputItemParamOfFunctionCallOutput(msg.ToolCallID, msg.Content))
+	case msg.Role == chat.ChatMessageRoleAssistant && len(msg.ToolCalls) > 0:
+		if msg.Content != "" {
+			ret = append(ret, responses.ResponseInputItemParamOfMessage(msg.Content, responses.EasyInputMessageRoleAssistant))
+		}
+		for _, call := range msg.ToolCalls {
+			ret = append(ret, responses.ResponseInputItemParamOfFunctionCall(call.Function.Arguments, call.ID, call.Function.Name))
+		}
+	default:
+		return nil, false
+	}
+	return ret, true
+}
+
+// extractToolCalls returns the function calls requested in a Responses API response
+func extractToolCalls(resp *responses.Response) (ret []chat.ToolCall) {
+	for _, item := range resp.Output {
+		if item.Type == "function_call" {
+			ret = append(ret, chat.ToolCall{
+				ID:       item.CallID,
+				Type:     chat.ToolTypeFunction,
+				Function: chat.FunctionCall{Name: item.Name, Arguments: item.Arguments},
+			})
+		}
+	}
+	return
+}
+
+// toAssistantToolCallMessage converts an assistant message carrying tool calls
+// to a Chat Completions message param
+func toAssistantToolCallMessage(msg chat.ChatCompletionMessage) openai.ChatCompletionMessageParamUnion {
+	assistant := openai.ChatCompletionAssistantMessageParam{}
+	if msg.Content != "" {
+		assistant.Content.OfString = openai.String(msg.Content)
+	}
+	for _, call := range msg.ToolCalls {
+		assistant.ToolCalls = append(assistant.ToolCalls, openai.ChatCompletionMessageToolCallParam{
+			ID: call.ID,
+			Function: openai.ChatCompletionMessageToolCallFunctionParam{
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			},
+		})
+	}
+	return openai.ChatCompletionMessageParamUnion{OfAssistant: &assistant}
+}
+
+// fromChatCompletionToolCalls converts Chat Completions tool calls to fabric tool calls
+func fromChatCompletionToolCalls(calls []openai.ChatCompletionMessageToolCall) (ret []chat.ToolCall) {
+	for _, call := range calls {
+		ret = append(ret, chat.ToolCall{
+			ID:       call.ID,
+			Type:     chat.ToolTypeFunction,
+			Function: chat.FunctionCall{Name: call.Function.Name, Arguments: call.Function.Arguments},
+		})
+	}
+	return
+}
@@ -0,0 +1,68 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTools = []chat.Tool{{
+	Type: chat.ToolTypeFunction,
+	Function: &chat.FunctionDefinition{
+		Name:        "get_weather",
+		Description: "Current weather for a city",
+		Parameters: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"city": map[string]any{"type": "string"}},
+		},
+	},
+}}
+
+var testToolConversation = []*chat.ChatCompletionMessage{
+	{Role: chat.ChatMessageRoleUser, Content: "Weather in Paris?"},
+	{Role: chat.ChatMessageRoleAssistant, ToolCalls: []chat.ToolCall{{
+		ID:       "call_1",
+		Type:     chat.ToolTypeFunction,
+		Function: chat.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+	}}},
+	{Role: chat.ChatMessageRoleTool, ToolCallID: "call_1", Name: "get_weather", Content: "sunny"},
+}
+
+func TestBuildResponseParams_WithFunctionTools(t *testing.T) {
+	client := NewClient()
+	opts := &domain.ChatOptions{Model: "gpt-4o", Tools: testTools}
+
+	params := client.buildResponseParams(testToolConversation, opts)
+
+	require.Len(t, params.Tools, 1)
+	require.NotNil(t, params.Tools[0].OfFunction)
+	assert.Equal(t, "get_weather", params.Tools[0].OfFunction.Name)
+
+	items := params.Input.OfInputItemList
+	require.Len(t, items, 3)
+	require.NotNil(t, items[1].OfFunctionCall)
+	assert.Equal(t, "call_1", items[1].OfFunctionCall.CallID)
+	assert.Equal(t, `{"city":"Paris"}`, items[1].OfFunctionCall.Arguments)
+	require.NotNil(t, items[2].OfFunctionCallOutput)
+	assert.Equal(t, "call_1", items[2].OfFunctionCallOutput.CallID)
+}
+
+func TestBuildChatCompletionParams_WithFunctionTools(t *testing.T) {
+	client := NewClient()
+	opts := &domain.ChatOptions{Model: "gpt-4o", Tools: testTools}
+
+	params := client.buildChatCompletionParams(testToolConversation, opts)
+
+	require.Len(t, params.Tools, 1)
+	assert.Equal(t, "get_weather", params.Tools[0].Function.Name)
+
+	require.Len(t, params.Messages, 3)
+	require.NotNil(t, params.Messages[1].OfAssistant)
+	require.Len(t, params.Messages[1].OfAssistant.ToolCalls, 1)
+	assert.Equal(t, "call_1", params.Messages[1].OfAssistant.ToolCalls[0].ID)
+	require.NotNil(t, params.Messages[2].OfTool)
+	assert.Equal(t, "call_1", params.Messages[2].OfTool.ToolCallID)
+}
@@ -0,0 +1,33 @@
+package openai
+
+import (
+	"github.com/danielmiessler/fabric/internal/domain"
+	openai "github.com/openai/openai-go"
+	"github.com/openai/openai-go/responses"
+)
+
+// fromResponseUsage converts Responses API usage to fabric usage metadata
+func fromResponseUsage(usage responses.ResponseUsage) *domain.UsageMetadata {
+	if !usage.JSON.InputTokens.Valid() {
+		return nil
+	}
+	return &domain.UsageMetadata{
+		InputTokens:     int(usage.InputTokens),
+		OutputTokens:    int(usage.OutputTokens),
+		ReasoningTokens: int(usage.OutputTokensDetails.ReasoningTokens),
+		CachedTokens:    int(usage.InputTokensDetails.CachedTokens),
+	}
+}
+
+// fromCompletionUsage converts Chat Completions API usage to fabric usage metadata
+func fromCompletionUsage(usage openai.CompletionUsage) *domain.UsageMetadata {
+	if !usage.JSON.PromptTokens.Valid() {
+		return nil
+	}
+	return &domain.UsageMetadata{
+		InputTokens:     int(usage.PromptTokens),
+		OutputTokens:    int(usage.CompletionTokens),
+		ReasoningTokens: int(usage.CompletionTokensDetails.ReasoningTokens),
+		CachedTokens:    int(usage.PromptTokensDetails.CachedTokens),
+	}
+}
@@ -63,10 +63,10 @@ func (c *Client) ListModels() ([]string, error) {
 	return models, nil
 }
 
-func (c *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (string, error) {
+func (c *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (*domain.ChatResponse, error) {
 	if c.client == nil {
 		if err := c.Configure(); err != nil {
-			return "", fmt.Errorf("failed to configure Perplexity client: %w", err)
+			return nil, fmt.Errorf("failed to configure Perplexity client: %w", err)
 		}
 	}
 
@@ -103,9 +103,9 @@ func (c *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, o
 	request := perplexity.NewCompletionRequest(requestOptions...)
 
 	// Corrected: Use SendCompletionRequest method from perplexity-go library
-	resp, err := c.client.SendCompletionRequest(request) // Pass request directly
+	resp, err := c.client.SendCompletionRequestWithContext(ctx, request)
 	if err != nil {
-		return "", fmt.Errorf("perplexity API request failed: %w", err) // Corrected capitalization
+		return nil, fmt.Errorf("perplexity API request failed: %w", err) // Corrected capitalization
 	}
 
 	var content strings.Builder
@@ -120,10 +120,10 @@ func (c *Client) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, o
 		}
 	}
 
-	return content.String(), nil
+	return &domain.ChatResponse{Content: content.String(), Usage: toUsageMetadata(resp.Usage)}, nil
 }
 
-func (c *Client) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan string) error {
+func (c *Client) SendStream(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions, channel chan domain.StreamUpdate) error {
 	if c.client == nil {
 		if err := c.Configure(); err != nil {
 			close(channel) // Ensure channel is closed on error
@@ -170,7 +170,7 @@ func (c *Client) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.Cha
 	wg.Add(1)
 
 	go func() {
-		err := c.client.SendSSEHTTPRequest(&wg, request, responseChan)
+		err := c.client.SendSSEHTTPRequestWithContext(ctx, &wg, request, responseChan)
 		if err != nil {
 			// Log error, can't send to string channel directly.
 			// Consider a mechanism to propagate this error if needed.
@@ -196,26 +196,36 @@ func (c *Client) SendStream(msgs []*chat.ChatCompletionMessage, opts *domain.Cha
 					content = resp.Choices[0].Message.Content
 				}
 				if content != "" {
-					channel <- content
+					channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: content}
 				}
 			}
 		}
 
-		// Send citations at the end if available
+		// Send citations and usage at the end if available
 		if lastResponse != nil {
-			citations := lastResponse.GetCitations()
-			if len(citations) > 0 {
-				channel <- "\n\n# CITATIONS\n\n"
-				for i, citation := range citations {
-					channel <- fmt.Sprintf("- [%d] %s\n", i+1, citation)
-				}
+			for _, citation := range lastResponse.GetCitations() {
+				channel <- domain.StreamUpdate{Type: domain.StreamTypeCitation, Citation: &domain.Citation{URL: citation}}
 			}
+			channel <- domain.StreamUpdate{Type: domain.StreamTypeUsage, Usage: toUsageMetadata(lastResponse.Usage)}
 		}
 	}()
 
 	return nil
 }
 
+// toUsageMetadata converts Perplexity usage to fabric usage metadata. Perplexity
+// reports the request cost itself, which then takes precedence over the pricing table.
+func toUsageMetadata(usage perplexity.Usage) *domain.UsageMetadata {
+	ret := &domain.UsageMetadata{
+		InputTokens:  usage.PromptTokens,
+		OutputTokens: usage.CompletionTokens,
+	}
+	if usage.Cost != nil && usage.Cost.TotalCost != nil {
+		ret.Cost = *usage.Cost.TotalCost
+	}
+	return ret
+}
+
 func (c *Client) NeedsRawMode(modelName string) bool {
 	return true
 }
@@ -0,0 +1,113 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/danielmiessler/fabric/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// ModelPrice is the price of a model in USD per million tokens. CachedInput
+// falls back to Input when not set.
+type ModelPrice struct {
+	Input       float64 `yaml:"input"`
+	CachedInput float64 `yaml:"cached_input"`
+	Output      float64 `yaml:"output"`
+}
+
+// Pricing maps model names to prices. A key matches the model of the same
+// name and, failing that, any model it is the longest prefix of, so dated
+// releases like "gpt-4o-2024-08-06" are priced as "gpt-4o". Keys of the form
+// "Vendor|model" only apply to that vendor.
+type Pricing map[string]ModelPrice
+
+// DefaultPricing holds list prices of common models. Override or extend it in
+// ~/.config/fabric/pricing.yaml.
+var DefaultPricing = Pricing{
+	"gpt-4o":                {Input: 2.50, CachedInput: 1.25, Output: 10.00},
+	"gpt-4o-mini":           {Input: 0.15, CachedInput: 0.075, Output: 0.60},
+	"gpt-4.1":               {Input: 2.00, CachedInput: 0.50, Output: 8.00},
+	"gpt-4.1-mini":          {Input: 0.40, CachedInput: 0.10, Output: 1.60},
+	"gpt-4.1-nano":          {Input: 0.10, CachedInput: 0.025, Output: 0.40},
+	"gpt-5":                 {Input: 1.25, CachedInput: 0.125, Output: 10.00},
+	"gpt-5-mini":            {Input: 0.25, CachedInput: 0.025, Output: 2.00},
+	"gpt-5-nano":            {Input: 0.05, CachedInput: 0.005, Output: 0.40},
+	"o3":                    {Input: 2.00, CachedInput: 0.50, Output: 8.00},
+	"o3-mini":               {Input: 1.10, CachedInput: 0.55, Output: 4.40},
+	"o4-mini":               {Input: 1.10, CachedInput: 0.275, Output: 4.40},
+	"claude-opus-4":         {Input: 15.00, CachedInput: 1.50, Output: 75.00},
+	"claude-opus-4-5":       {Input: 5.00, CachedInput: 0.50, Output: 25.00},
+	"claude-sonnet-4":       {Input: 3.00, CachedInput: 0.30, Output: 15.00},
+	"claude-3-7-sonnet":     {Input: 3.00, CachedInput: 0.30, Output: 15.00},
+	"claude-haiku-4-5":      {Input: 1.00, CachedInput: 0.10, Output: 5.00},
+	"claude-3-5-haiku":      {Input: 0.80, CachedInput: 0.08, Output: 4.00},
+	"gemini-2.5-pro":        {Input: 1.25, CachedInput: 0.31, Output: 10.00},
+	"gemini-2.5-flash":      {Input: 0.30, CachedInput: 0.075, Output: 2.50},
+	"gemini-2.5-flash-lite": {Input: 0.10, CachedInput: 0.025, Output: 0.40},
+	"gemini-2.0-flash":      {Input: 0.10, CachedInput: 0.025, Output: 0.40},
+}
+
+// LoadPricing returns the default pricing overlaid with the prices in the YAML
+// file at path. A missing file is not an error.
+func LoadPricing(path string) (ret Pricing, err error) {
+	ret = make(Pricing, len(DefaultPricing))
+	for model, price := range DefaultPricing {
+		ret[model] = price
+	}
+
+	var content []byte
+	if content, err = os.ReadFile(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return
+	}
+
+	var custom Pricing
+	if err = yaml.Unmarshal(content, &custom); err != nil {
+		err = fmt.Errorf("could not parse pricing file %s: %w", path, err)
+		return
+	}
+	for model, price := range custom {
+		ret[strings.ToLower(model)] = price
+	}
+	return
+}
+
+// Lookup returns the price of model for vendor.
+func (o Pricing) Lookup(vendor, model string) (ret ModelPrice, ok bool) {
+	model = strings.ToLower(model)
+	if ret, ok = o[strings.ToLower(vendor)+"|"+model]; ok {
+		return
+	}
+	if ret, ok = o[model]; ok {
+		return
+	}
+
+	longest := 0
+	for key, price := range o {
+		if len(key) > longest && !strings.Contains(key, "|") && strings.HasPrefix(model, key) {
+			ret, ok, longest = price, true, len(key)
+		}
+	}
+	return
+}
+
+// Cost returns the price in USD of usage, and false when the model is not priced.
+func (o Pricing) Cost(vendor, model string, usage *domain.UsageMetadata) (ret float64, ok bool) {
+	var price ModelPrice
+	if price, ok = o.Lookup(vendor, model); !ok || usage == nil {
+		return
+	}
+	cachedPrice := price.CachedInput
+	if cachedPrice == 0 {
+		cachedPrice = price.Input
+	}
+	ret = (float64(usage.InputTokens-usage.CachedTokens)*price.Input +
+		float64(usage.CachedTokens)*cachedPrice +
+		float64(usage.OutputTokens)*price.Output) / 1_000_000
+	return
+}
@@ -0,0 +1,78 @@
+package ai
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/domain"
+)
+
+func TestPricingLookup(t *testing.T) {
+	pricing := Pricing{
+		"gpt-4o":             {Input: 2.5, Output: 10},
+		"gpt-4o-mini":        {Input: 0.15, Output: 0.6},
+		"bedrock|my-profile": {Input: 1, Output: 1},
+	}
+
+	tests := []struct {
+		vendor, model string
+		wantInput     float64
+		wantOk        bool
+	}{
+		{"OpenAI", "gpt-4o", 2.5, true},
+		{"OpenAI", "GPT-4o-2024-08-06", 2.5, true},
+		{"OpenAI", "gpt-4o-mini-2024-07-18", 0.15, true},
+		{"Bedrock", "my-profile", 1, true},
+		{"OpenAI", "my-profile", 0, false},
+		{"Ollama", "llama3", 0, false},
+	}
+	for _, tt := range tests {
+		price, ok := pricing.Lookup(tt.vendor, tt.model)
+		if ok != tt.wantOk || price.Input != tt.wantInput {
+			t.Errorf("Lookup(%q, %q) = %v, %v; want input %v, %v", tt.vendor, tt.model, price, ok, tt.wantInput, tt.wantOk)
+		}
+	}
+}
+
+func TestPricingCost(t *testing.T) {
+	pricing := Pricing{"model": {Input: 3, Output: 15}}
+	usage := &domain.UsageMetadata{InputTokens: 10000, CachedTokens: 4000, OutputTokens: 2000}
+
+	// Cached tokens fall back to the input price when no cached price is set
+	cost, ok := pricing.Cost("Vendor", "model", usage)
+	if !ok || math.Abs(cost-0.06) > 1e-9 {
+		t.Errorf("Cost() = %v, %v; want 0.06, true", cost, ok)
+	}
+
+	if _, ok = pricing.Cost("Vendor", "unknown", usage); ok {
+		t.Errorf("Cost() of an unpriced model should not be ok")
+	}
+}
+
+func TestLoadPricing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pricing.yaml")
+	content := "gpt-4o:\n  input: 5\n  output: 20\nMy-Model:\n  input: 1\n  output: 2\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write pricing file: %v", err)
+	}
+
+	pricing, err := LoadPricing(path)
+	if err != nil {
+		t.Fatalf("LoadPricing() error = %v", err)
+	}
+	if pricing["gpt-4o"].Input != 5 {
+		t.Errorf("expected gpt-4o to be overridden, got %v", pricing["gpt-4o"])
+	}
+	if _, ok := pricing.Lookup("", "my-model"); !ok {
+		t.Errorf("expected custom model to be added")
+	}
+	if DefaultPricing["gpt-4o"].Input == 5 {
+		t.Errorf("LoadPricing must not modify DefaultPricing")
+	}
+
+	if pricing, err = LoadPricing(filepath.Join(t.TempDir(), "missing.yaml")); err != nil || len(pricing) != len(DefaultPricing) {
+		t.Errorf("LoadPricing() of a missing file = %d prices, %v; want defaults", len(pricing), err)
+	}
+}
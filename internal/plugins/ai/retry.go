@@ -0,0 +1,241 @@
+package ai
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+	debuglog "github.com/danielmiessler/fabric/internal/log"
+)
+
+// RetryConfig configures how vendor calls are retried and rate limited. It is
+// read from the retry section of config.yaml:
+//
+//	retry:
+//	  max_attempts: 4
+//	  initial_backoff: 2s
+//	  rate_limits:
+//	    OpenAI: {requests_per_minute: 60, burst: 5}
+type RetryConfig struct {
+	// MaxAttempts is the number of calls made before giving up, 1 disables retries
+	MaxAttempts int `yaml:"max_attempts"`
+	// InitialBackoff is the wait before the first retry, it grows by Multiplier
+	// with every further retry up to MaxBackoff
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Multiplier     float64       `yaml:"multiplier"`
+	// Jitter randomizes each wait by up to this fraction in either direction
+	Jitter float64 `yaml:"jitter"`
+	// RateLimits maps vendor names to client-side request rate limits
+	RateLimits map[string]RateLimit `yaml:"rate_limits"`
+}
+
+// RateLimit allows RequestsPerMinute calls on average, with bursts of up to
+// Burst calls.
+type RateLimit struct {
+	RequestsPerMinute float64 `yaml:"requests_per_minute"`
+	Burst             int     `yaml:"burst"`
+}
+
+// DefaultRetryConfig is used for every setting config.yaml leaves out.
+var DefaultRetryConfig = RetryConfig{
+	MaxAttempts:    3,
+	InitialBackoff: time.Second,
+	MaxBackoff:     30 * time.Second,
+	Multiplier:     2,
+	Jitter:         0.2,
+}
+
+// WithDefaults returns the config with unset values taken from DefaultRetryConfig.
+func (o RetryConfig) WithDefaults() RetryConfig {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = DefaultRetryConfig.MaxAttempts
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = DefaultRetryConfig.InitialBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = DefaultRetryConfig.MaxBackoff
+	}
+	if o.Multiplier < 1 {
+		o.Multiplier = DefaultRetryConfig.Multiplier
+	}
+	if o.Jitter < 0 || o.Jitter > 1 {
+		o.Jitter = DefaultRetryConfig.Jitter
+	}
+	return o
+}
+
+// Backoff returns the wait before the given retry, counting from 1.
+func (o RetryConfig) Backoff(retry int) time.Duration {
+	backoff := float64(o.InitialBackoff) * math.Pow(o.Multiplier, float64(retry-1))
+	backoff = math.Min(backoff, float64(o.MaxBackoff))
+	backoff += backoff * o.Jitter * (2*rand.Float64() - 1)
+	return time.Duration(backoff)
+}
+
+// RateLimiter is a token bucket shared by all calls to a vendor.
+type RateLimiter struct {
+	mu       sync.Mutex
+	rate     float64 // tokens added per second
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+// NewRateLimiter returns a limiter for limit, or nil when limit sets no rate.
+// A nil limiter never waits.
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	if limit.RequestsPerMinute <= 0 {
+		return nil
+	}
+	capacity := float64(max(limit.Burst, 1))
+	return &RateLimiter{
+		rate:     limit.RequestsPerMinute / 60,
+		capacity: capacity,
+		tokens:   capacity,
+		last:     time.Now(),
+	}
+}
+
+// Wait blocks until a call may be made or ctx is done.
+func (o *RateLimiter) Wait(ctx context.Context) error {
+	if o == nil {
+		return nil
+	}
+	for {
+		o.mu.Lock()
+		now := time.Now()
+		o.tokens = math.Min(o.capacity, o.tokens+now.Sub(o.last).Seconds()*o.rate)
+		o.last = now
+		if o.tokens >= 1 {
+			o.tokens--
+			o.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - o.tokens) / o.rate * float64(time.Second))
+		o.mu.Unlock()
+
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// RetryingVendor wraps a vendor so that calls failing with a retryable error
+// are tried again after a backoff, and calls are spaced out by a rate limiter.
+// A wait the vendor asks for with Retry-After is honoured, and when it is
+// longer than MaxBackoff the error is returned instead so that a fallback can
+// take over.
+type RetryingVendor struct {
+	Vendor
+	config  RetryConfig
+	limiter *RateLimiter
+}
+
+func NewRetryingVendor(vendor Vendor, config RetryConfig, limiter *RateLimiter) *RetryingVendor {
+	return &RetryingVendor{Vendor: vendor, config: config.WithDefaults(), limiter: limiter}
+}
+
+func (o *RetryingVendor) Send(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions) (
+	ret *domain.ChatResponse, err error) {
+	for attempt := 1; ; attempt++ {
+		if err = o.limiter.Wait(ctx); err != nil {
+			return
+		}
+		if ret, err = o.Vendor.Send(ctx, msgs, opts); err == nil {
+			return
+		}
+		delay, retry := o.retryDelay(ctx, err, attempt)
+		if !retry {
+			return
+		}
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			return
+		}
+	}
+}
+
+// SendStream retries as long as the vendor has not streamed anything yet, so
+// the caller never sees part of a failed attempt.
+func (o *RetryingVendor) SendStream(ctx context.Context, msgs []*chat.ChatCompletionMessage, opts *domain.ChatOptions,
+	channel chan domain.StreamUpdate) (err error) {
+	defer close(channel)
+
+	for attempt := 1; ; attempt++ {
+		if err = o.limiter.Wait(ctx); err != nil {
+			return
+		}
+
+		updates := make(chan domain.StreamUpdate)
+		done := make(chan error, 1)
+		go func() {
+			done <- o.Vendor.SendStream(ctx, msgs, opts, updates)
+		}()
+
+		// Errors reported before anything was streamed are held back until it
+		// is clear there will be no retry
+		forwarded := false
+		var errorUpdates []domain.StreamUpdate
+		for update := range updates {
+			if update.Type == domain.StreamTypeError && !forwarded {
+				errorUpdates = append(errorUpdates, update)
+				continue
+			}
+			forwarded = true
+			channel <- update
+		}
+		if err = <-done; err == nil && len(errorUpdates) > 0 {
+			err = errorUpdates[0].Err
+		}
+		if err == nil {
+			return
+		}
+
+		delay, retry := o.retryDelay(ctx, err, attempt)
+		if forwarded || !retry {
+			for _, update := range errorUpdates {
+				channel <- update
+			}
+			return
+		}
+		if sleepErr := sleep(ctx, delay); sleepErr != nil {
+			return
+		}
+	}
+}
+
+// retryDelay returns how long to wait before retrying a call that failed with
+// err, and false when it should not be retried.
+func (o *RetryingVendor) retryDelay(ctx context.Context, err error, attempt int) (delay time.Duration, retry bool) {
+	if attempt >= o.config.MaxAttempts || ctx.Err() != nil || !IsRetryable(err) {
+		return
+	}
+	if delay = RetryAfter(err); delay > 0 {
+		if delay > o.config.MaxBackoff {
+			debuglog.Debug(debuglog.Basic, "%s asked to retry after %v, giving up: %v\n", o.GetName(), delay, err)
+			return
+		}
+	} else {
+		delay = o.config.Backoff(attempt)
+	}
+	debuglog.Debug(debuglog.Basic, "%s call failed (attempt %d of %d), retrying in %v: %v\n",
+		o.GetName(), attempt, o.config.MaxAttempts, delay.Round(time.Millisecond), err)
+	retry = true
+	return
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
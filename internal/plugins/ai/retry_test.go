@@ -0,0 +1,215 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+)
+
+// httpVendor calls a test server the way the plain HTTP vendors do
+type httpVendor struct {
+	stubVendor
+	url string
+}
+
+func (v *httpVendor) call(ctx context.Context) (content string, err error) {
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodPost, v.url, nil); err != nil {
+		return
+	}
+	var resp *http.Response
+	if resp, err = http.DefaultClient.Do(req); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err = NewHTTPError(resp)
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	content = string(body)
+	return
+}
+
+func (v *httpVendor) Send(ctx context.Context, _ []*chat.ChatCompletionMessage, _ *domain.ChatOptions) (*domain.ChatResponse, error) {
+	content, err := v.call(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.ChatResponse{Content: content}, nil
+}
+
+func (v *httpVendor) SendStream(ctx context.Context, _ []*chat.ChatCompletionMessage, _ *domain.ChatOptions, channel chan domain.StreamUpdate) error {
+	defer close(channel)
+	content, err := v.call(ctx)
+	if err != nil {
+		return err
+	}
+	channel <- domain.StreamUpdate{Type: domain.StreamTypeContent, Content: content}
+	return nil
+}
+
+// newFlakyServer fails the first failures calls with status, then answers "ok"
+func newFlakyServer(t *testing.T, failures int32, status int, header http.Header) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte("try again later"))
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+var fastRetries = RetryConfig{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Second}
+
+func TestRetryingVendor_RetriesServerErrors(t *testing.T) {
+	server, calls := newFlakyServer(t, 2, http.StatusServiceUnavailable, nil)
+	vendor := NewRetryingVendor(&httpVendor{url: server.URL}, fastRetries, nil)
+
+	resp, err := vendor.Send(context.Background(), nil, &domain.ChatOptions{})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if resp.Content != "ok" || calls.Load() != 3 {
+		t.Errorf("Expected success on the third call, got %q after %d calls", resp.Content, calls.Load())
+	}
+}
+
+func TestRetryingVendor_GivesUpAfterMaxAttempts(t *testing.T) {
+	server, calls := newFlakyServer(t, 5, http.StatusTooManyRequests, nil)
+	vendor := NewRetryingVendor(&httpVendor{url: server.URL}, fastRetries, nil)
+
+	_, err := vendor.Send(context.Background(), nil, &domain.ChatOptions{})
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("Expected the 429 error, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 calls, got %d", calls.Load())
+	}
+}
+
+func TestRetryingVendor_DoesNotRetryClientErrors(t *testing.T) {
+	server, calls := newFlakyServer(t, 1, http.StatusBadRequest, nil)
+	vendor := NewRetryingVendor(&httpVendor{url: server.URL}, fastRetries, nil)
+
+	if _, err := vendor.Send(context.Background(), nil, &domain.ChatOptions{}); err == nil {
+		t.Fatal("Expected the 400 error")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected a single call, got %d", calls.Load())
+	}
+}
+
+func TestRetryingVendor_HonoursRetryAfter(t *testing.T) {
+	server, calls := newFlakyServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After-Ms": {"100"}})
+	vendor := NewRetryingVendor(&httpVendor{url: server.URL}, fastRetries, nil)
+
+	start := time.Now()
+	if _, err := vendor.Send(context.Background(), nil, &domain.ChatOptions{}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected to wait the requested 100ms, waited %v", elapsed)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls.Load())
+	}
+}
+
+func TestRetryingVendor_RetryAfterBeyondMaxBackoff(t *testing.T) {
+	server, calls := newFlakyServer(t, 1, http.StatusTooManyRequests, http.Header{"Retry-After": {"3600"}})
+	vendor := NewRetryingVendor(&httpVendor{url: server.URL}, fastRetries, nil)
+
+	if _, err := vendor.Send(context.Background(), nil, &domain.ChatOptions{}); err == nil {
+		t.Fatal("Expected the 429 error instead of waiting an hour")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected a single call, got %d", calls.Load())
+	}
+}
+
+func TestRetryingVendor_SendStream(t *testing.T) {
+	server, calls := newFlakyServer(t, 1, http.StatusBadGateway, nil)
+	vendor := NewRetryingVendor(&httpVendor{url: server.URL}, fastRetries, nil)
+
+	channel := make(chan domain.StreamUpdate)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- vendor.SendStream(context.Background(), nil, &domain.ChatOptions{}, channel)
+	}()
+
+	var content string
+	for update := range channel {
+		content += update.Content
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("SendStream failed: %v", err)
+	}
+	if content != "ok" || calls.Load() != 2 {
+		t.Errorf("Expected only the successful attempt to be streamed, got %q after %d calls", content, calls.Load())
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	// 10 calls per second, no burst
+	limiter := NewRateLimiter(RateLimit{RequestsPerMinute: 600, Burst: 1})
+
+	start := time.Now()
+	for range 3 {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("Expected 3 calls to take about 200ms, took %v", elapsed)
+	}
+
+	if NewRateLimiter(RateLimit{}) != nil {
+		t.Error("Expected no limiter without a rate")
+	}
+}
+
+func TestRetryConfig_Backoff(t *testing.T) {
+	config := RetryConfig{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2}
+	for retry, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second} {
+		if got := config.Backoff(retry); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", retry, got, want)
+		}
+	}
+
+	config.Jitter = 0.5
+	for range 100 {
+		if got := config.Backoff(1); got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Fatalf("Backoff with jitter out of range: %v", got)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter(http.Header{"Retry-After": {"2"}}); got != 2*time.Second {
+		t.Errorf("Expected 2s, got %v", got)
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(http.Header{"Retry-After": {date}}); got < 50*time.Second || got > time.Minute {
+		t.Errorf("Expected about a minute, got %v", got)
+	}
+	if got := parseRetryAfter(http.Header{}); got != 0 {
+		t.Errorf("Expected 0 without a header, got %v", got)
+	}
+}
@@ -1,8 +1,11 @@
 package db
 
-type Storage[T any] interface {
+import "time"
+
+// Store keeps the content of named items, such as files in a directory or
+// rows of a database table.
+type Store interface {
 	Configure() (err error)
-	Get(name string) (ret *T, err error)
 	GetNames() (ret []string, err error)
 	Delete(name string) (err error)
 	Exists(name string) (ret bool)
@@ -10,4 +13,13 @@ type Storage[T any] interface {
 	Save(name string, content []byte) (err error)
 	Load(name string) (ret []byte, err error)
 	ListNames(shellCompleteList bool) (err error)
+	// Stat returns when an item was saved last and the size of its content
+	Stat(name string) (modTime time.Time, size int64, err error)
+	// Touch marks an item as saved now, leaving its content as it is
+	Touch(name string) (err error)
+}
+
+type Storage[T any] interface {
+	Store
+	Get(name string) (ret *T, err error)
 }
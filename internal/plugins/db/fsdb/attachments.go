@@ -0,0 +1,236 @@
+package fsdb
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+	debuglog "github.com/danielmiessler/fabric/internal/log"
+	"github.com/danielmiessler/fabric/internal/plugins/db"
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// attachmentScheme starts the image URLs that refer to an attachment of the
+// attachment store, followed by its id.
+const attachmentScheme = "fabric-attachment:"
+
+// attachmentIdPattern matches the ids of attachments, the SHA-256 of their content.
+var attachmentIdPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// orphanAttachmentAge is how long an attachment that no session refers to is
+// kept, as the session that is about to refer to it may still be saving.
+const orphanAttachmentAge = time.Hour
+
+// AttachmentsEntity keeps the attachments of sessions once each, named by the
+// id of their content. Sessions hold references to them instead of data URLs.
+type AttachmentsEntity struct {
+	db.Store
+}
+
+// Put stores the content, or touches it when it is stored already, and returns the reference to it.
+func (o *AttachmentsEntity) Put(content []byte) (ret string, err error) {
+	var id string
+	if id, err = (&domain.Attachment{Content: content}).GetId(); err != nil {
+		return
+	}
+	// An attachment put again is touched, so that it is not collected as an
+	// orphan before the session that refers to it is saved.
+	if o.Touch(id) != nil {
+		if err = o.Save(id, content); err != nil {
+			return
+		}
+	}
+	return attachmentScheme + id, nil
+}
+
+// DataURL returns the data URL of the attachment that reference refers to. A
+// reference to anything but an id, such as a path, refers to no attachment.
+func (o *AttachmentsEntity) DataURL(reference string) (ret string, err error) {
+	id, ok := attachmentId(reference)
+	if !ok {
+		return "", fmt.Errorf("could not load attachment: %q is not the id of an attachment", id)
+	}
+	var content []byte
+	if content, err = o.Load(id); err != nil {
+		return "", fmt.Errorf("could not load attachment: %w", err)
+	}
+	return fmt.Sprintf("data:%s;base64,%s", mimetype.Detect(content).String(), base64.StdEncoding.EncodeToString(content)), nil
+}
+
+func isAttachmentReference(url string) bool {
+	return strings.HasPrefix(url, attachmentScheme)
+}
+
+// attachmentId returns the id reference refers to and whether it is the id of an attachment.
+func attachmentId(reference string) (ret string, ok bool) {
+	ret = strings.TrimPrefix(reference, attachmentScheme)
+	return ret, attachmentIdPattern.MatchString(ret)
+}
+
+// dehydrate returns message with its base64 data URLs stored as attachments
+// and replaced by references. The message itself is left as it is.
+func (o *AttachmentsEntity) dehydrate(message *chat.ChatCompletionMessage) (ret *chat.ChatCompletionMessage, err error) {
+	return mapImageURLs(message, func(url string) (string, error) {
+		header, data, ok := strings.Cut(url, ",")
+		if !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") || !ok {
+			return url, nil
+		}
+		content, decodeErr := base64.StdEncoding.DecodeString(data)
+		if decodeErr != nil {
+			return url, nil
+		}
+		return o.Put(content)
+	})
+}
+
+// hydrate returns message with its references replaced by data URLs.
+func (o *AttachmentsEntity) hydrate(message *chat.ChatCompletionMessage) (ret *chat.ChatCompletionMessage, err error) {
+	return mapImageURLs(message, func(url string) (string, error) {
+		if !isAttachmentReference(url) {
+			return url, nil
+		}
+		return o.DataURL(url)
+	})
+}
+
+// mapImageURLs returns a copy of message with its image URLs changed by change,
+// or message itself when change keeps all of them.
+func mapImageURLs(message *chat.ChatCompletionMessage, change func(url string) (string, error)) (ret *chat.ChatCompletionMessage, err error) {
+	ret = message
+	for i, part := range message.MultiContent {
+		if part.Type != chat.ChatMessagePartTypeImageURL || part.ImageURL == nil {
+			continue
+		}
+		var url string
+		if url, err = change(part.ImageURL.URL); err != nil {
+			return message, err
+		}
+		if url == part.ImageURL.URL {
+			continue
+		}
+		if ret == message {
+			copied := *message
+			copied.MultiContent = append([]chat.ChatMessagePart(nil), message.MultiContent...)
+			ret = &copied
+		}
+		imageURL := *part.ImageURL
+		imageURL.URL = url
+		ret.MultiContent[i].ImageURL = &imageURL
+	}
+	return
+}
+
+// references returns the ids of the attachments message refers to.
+func references(message *chat.ChatCompletionMessage) (ret []string) {
+	for _, part := range message.MultiContent {
+		if part.Type != chat.ChatMessagePartTypeImageURL || part.ImageURL == nil || !isAttachmentReference(part.ImageURL.URL) {
+			continue
+		}
+		if id, ok := attachmentId(part.ImageURL.URL); ok {
+			ret = append(ret, id)
+		}
+	}
+	return
+}
+
+// hydrated returns the session with the attachments of its messages in place,
+// or the session itself when it refers to none.
+func (o *Session) hydrated() (ret *Session, err error) {
+	ret = o
+	if o.attachments == nil {
+		return
+	}
+	for i, message := range o.Messages {
+		var hydrated *chat.ChatCompletionMessage
+		if hydrated, err = o.attachments.hydrate(message); err != nil {
+			return o, err
+		}
+		if hydrated == message {
+			continue
+		}
+		if ret == o {
+			copied := *o
+			copied.Messages = append([]*chat.ChatCompletionMessage(nil), o.Messages...)
+			copied.metadata = make(map[*chat.ChatCompletionMessage]*MessageMetadata, len(o.metadata))
+			for message, metadata := range o.metadata {
+				copied.metadata[message] = metadata
+			}
+			copied.vendorMessages = nil
+			ret = &copied
+		}
+		ret.Messages[i] = hydrated
+		ret.metadata[hydrated] = o.metadata[message]
+	}
+	return
+}
+
+// vendorMessage returns message as it is sent to the vendor, with its attachments in place.
+func (o *Session) vendorMessage(message *chat.ChatCompletionMessage) *chat.ChatCompletionMessage {
+	if o.attachments == nil {
+		return message
+	}
+	hydrated, err := o.attachments.hydrate(message)
+	if err != nil {
+		debuglog.Log("Leaving out an attachment of session %s: %v\n", o.Name, err)
+		return dropAttachmentReferences(message)
+	}
+	return hydrated
+}
+
+// dropAttachmentReferences returns message without the attachments that could not be loaded.
+func dropAttachmentReferences(message *chat.ChatCompletionMessage) *chat.ChatCompletionMessage {
+	copied := *message
+	copied.MultiContent = nil
+	for _, part := range message.MultiContent {
+		if part.Type == chat.ChatMessagePartTypeImageURL && part.ImageURL != nil && isAttachmentReference(part.ImageURL.URL) {
+			continue
+		}
+		copied.MultiContent = append(copied.MultiContent, part)
+	}
+	return &copied
+}
+
+// collectAttachments removes the attachments no session refers to. It leaves
+// all of them when a session cannot be read, since its references are unknown.
+func (o *SessionsEntity) collectAttachments(now time.Time) (err error) {
+	if o.Attachments == nil {
+		return
+	}
+	var names []string
+	if names, err = o.GetNames(); err != nil {
+		return
+	}
+	referenced := map[string]bool{}
+	for _, name := range names {
+		session := &Session{}
+		if err = o.loadSession(name, session); err != nil {
+			return fmt.Errorf("could not read the attachments of session %s: %w", name, err)
+		}
+		for _, message := range session.Messages {
+			for _, id := range references(message) {
+				referenced[id] = true
+			}
+		}
+	}
+
+	var ids []string
+	if ids, err = o.Attachments.GetNames(); err != nil {
+		return
+	}
+	for _, id := range ids {
+		if referenced[id] {
+			continue
+		}
+		if modTime, _, statErr := o.Attachments.Stat(id); statErr != nil || now.Sub(modTime) < orphanAttachmentAge {
+			continue
+		}
+		if err = o.Attachments.Delete(id); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,132 @@
+package fsdb
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+)
+
+func newImageMessage(dataURL string) *chat.ChatCompletionMessage {
+	return &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, MultiContent: []chat.ChatMessagePart{
+		{Type: chat.ChatMessagePartTypeText, Text: "Describe this"},
+		{Type: chat.ChatMessagePartTypeImageURL, ImageURL: &chat.ChatMessageImageURL{URL: dataURL}},
+	}}
+}
+
+func TestSessions_Attachments(t *testing.T) {
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 32)...)
+	dataURL := "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+
+	attachments := &AttachmentsEntity{&StorageEntity{Dir: t.TempDir()}}
+	sessions := &SessionsEntity{Store: &StorageEntity{Dir: t.TempDir(), FileExtension: ".json"}, Attachments: attachments}
+	if err := sessions.Configure(); err != nil {
+		t.Fatalf("failed to configure sessions: %v", err)
+	}
+
+	for _, name := range []string{"first", "second"} {
+		session := &Session{Name: name}
+		message := newImageMessage(dataURL)
+		session.Append(message)
+		if err := sessions.SaveSession(session); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		if message.MultiContent[1].ImageURL.URL != dataURL {
+			t.Errorf("expected saving to leave the message in memory as it is")
+		}
+	}
+
+	ids, err := attachments.GetNames()
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("expected the attachment to be stored once, got %v, %v", ids, err)
+	}
+	content, err := sessions.Load("first")
+	if err != nil || strings.Contains(string(content), "base64") || !strings.Contains(string(content), attachmentScheme+ids[0]) {
+		t.Errorf("expected the session to refer to the attachment, got %s, %v", content, err)
+	}
+
+	session, err := sessions.Get("first")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	if url := session.Messages[0].MultiContent[1].ImageURL.URL; url != attachmentScheme+ids[0] {
+		t.Errorf("expected the loaded session to keep the reference, got %s", url)
+	}
+	if url := session.GetVendorMessages()[0].MultiContent[1].ImageURL.URL; url != dataURL {
+		t.Errorf("expected the vendor message to embed the attachment, got %s", url)
+	}
+	if markdown, err := session.Export(SessionFormatMarkdown); err != nil || !strings.Contains(string(markdown), dataURL) {
+		t.Errorf("expected the export to embed the attachment, got %s, %v", markdown, err)
+	}
+
+	// Attachments that no session refers to are removed once they are old enough
+	now := time.Now()
+	if err = sessions.Delete("first"); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+	if err = sessions.collectAttachments(now); err != nil || !attachments.Exists(ids[0]) {
+		t.Errorf("expected the attachment of the remaining session to be kept, got %v", err)
+	}
+	if err = sessions.Delete("second"); err != nil {
+		t.Fatalf("failed to delete session: %v", err)
+	}
+	if err = sessions.collectAttachments(now); err != nil || !attachments.Exists(ids[0]) {
+		t.Errorf("expected a recent orphan to be kept, got %v", err)
+	}
+	old := now.Add(-2 * orphanAttachmentAge)
+	if err = os.Chtimes(attachments.Store.(*StorageEntity).BuildFilePathByName(ids[0]), old, old); err != nil {
+		t.Fatalf("failed to age attachment: %v", err)
+	}
+
+	// An old orphan put again is kept for the session about to refer to it
+	if _, err = attachments.Put(png); err != nil {
+		t.Fatalf("failed to put attachment: %v", err)
+	}
+	if err = sessions.collectAttachments(time.Now()); err != nil || !attachments.Exists(ids[0]) {
+		t.Errorf("expected the attachment put again to be kept, got %v", err)
+	}
+	if err = os.Chtimes(attachments.Store.(*StorageEntity).BuildFilePathByName(ids[0]), old, old); err != nil {
+		t.Fatalf("failed to age attachment: %v", err)
+	}
+	if err = sessions.collectAttachments(now); err != nil || attachments.Exists(ids[0]) {
+		t.Errorf("expected an old orphan to be removed, got %v", err)
+	}
+}
+
+func TestSession_VendorMessagesWithoutAttachment(t *testing.T) {
+	attachments := &AttachmentsEntity{&StorageEntity{Dir: t.TempDir()}}
+	session := &Session{Name: "missing", attachments: attachments}
+	session.Append(newImageMessage(attachmentScheme + "gone"))
+	messages := session.GetVendorMessages()
+	if len(messages) != 1 || len(messages[0].MultiContent) != 1 || messages[0].MultiContent[0].Text != "Describe this" {
+		t.Errorf("expected the missing attachment to be left out, got %+v", messages)
+	}
+}
+
+func TestAttachments_PathReference(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("SECRET=1"), 0600); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	attachments := &AttachmentsEntity{&StorageEntity{Dir: filepath.Join(dir, "attachments")}}
+	reference := attachmentScheme + "../.env"
+
+	if url, err := attachments.DataURL(reference); err == nil {
+		t.Errorf("expected a path not to be loaded, got %s", url)
+	}
+	session := &Session{Name: "imported", attachments: attachments}
+	session.Append(newImageMessage(reference))
+	if messages := session.GetVendorMessages(); len(messages[0].MultiContent) != 1 {
+		t.Errorf("expected the reference to a path to be left out, got %+v", messages[0].MultiContent)
+	}
+	if _, err := session.Export(SessionFormatMarkdown); err == nil {
+		t.Error("expected the export to fail on the reference to a path")
+	}
+	if ids := references(session.Messages[0]); len(ids) != 0 {
+		t.Errorf("expected no attachment ids, got %v", ids)
+	}
+}
@@ -0,0 +1,414 @@
+package fsdb
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	debuglog "github.com/danielmiessler/fabric/internal/log"
+	"github.com/danielmiessler/fabric/internal/plugins/template"
+	"github.com/danielmiessler/fabric/internal/tools/converter"
+	"github.com/danielmiessler/fabric/internal/util"
+	"gopkg.in/yaml.v3"
+)
+
+// ContextDefinitionSuffix ends the names of the contexts that are definitions,
+// assembled from their sources whenever they are used.
+const ContextDefinitionSuffix = ".context.yaml"
+
+const (
+	defaultContextMaxFileKB      = 256
+	defaultContextMaxSizeKB      = 1024
+	defaultContextRefreshTimeout = time.Minute
+)
+
+// ContextDefinition assembles a context from files, globs, directory trees and URLs:
+//
+//	description: Project docs
+//	dir: ~/src/project
+//	refresh: make docs
+//	max_size_kb: 512
+//	sources:
+//	  - file: README.md
+//	  - glob: docs/**/*.md
+//	  - dir: api
+//	    ignore: ["*_test.go", "testdata/"]
+//	    gitignore: true
+//	  - url: https://example.com/guide
+type ContextDefinition struct {
+	Description string `yaml:"description"`
+	// Dir is where relative paths and the refresh command start from, the current directory by default
+	Dir string `yaml:"dir"`
+	// Refresh is run with "sh -c" in Dir before the sources are read, when it is asked for
+	Refresh        string `yaml:"refresh"`
+	RefreshTimeout string `yaml:"refresh_timeout"`
+	// MaxFileKB leaves out larger files found by globs and directories, and fails for larger files and URLs
+	MaxFileKB int64 `yaml:"max_file_kb"`
+	// MaxSizeKB is the size of the assembled context, which fails when it is larger
+	MaxSizeKB int64           `yaml:"max_size_kb"`
+	Sources   []ContextSource `yaml:"sources"`
+}
+
+// ContextSource is one source of a context definition. Exactly one of File,
+// Glob, Dir and URL is set.
+type ContextSource struct {
+	File string `yaml:"file"`
+	// Glob matches files like the shell does, with ** for any number of directories
+	Glob string `yaml:"glob"`
+	// Dir takes all files below the directory, but hidden ones and those matched by Ignore
+	Dir string `yaml:"dir"`
+	// Ignore holds patterns in the style of .gitignore, without negation
+	Ignore []string `yaml:"ignore"`
+	// Gitignore adds the patterns of the .gitignore file at the top of Dir
+	Gitignore bool `yaml:"gitignore"`
+	// URL is fetched, and HTML pages are reduced to their readable text
+	URL string `yaml:"url"`
+}
+
+// IsContextDefinition tells whether the context of that name is a definition.
+func IsContextDefinition(name string) bool {
+	return strings.HasSuffix(name, ContextDefinitionSuffix)
+}
+
+// ParseContextDefinition reads a context definition and checks its sources.
+func ParseContextDefinition(content []byte) (ret *ContextDefinition, err error) {
+	ret = &ContextDefinition{}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(ret); err != nil {
+		return nil, fmt.Errorf("invalid context definition: %w", err)
+	}
+	if ret.MaxFileKB < 0 || ret.MaxSizeKB < 0 {
+		return nil, errors.New("the size limits of a context definition cannot be negative")
+	}
+	if len(ret.Sources) == 0 {
+		return nil, errors.New("a context definition needs sources")
+	}
+	for i, source := range ret.Sources {
+		set := 0
+		for _, value := range []string{source.File, source.Glob, source.Dir, source.URL} {
+			if value != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("source %d of the context definition needs exactly one of file, glob, dir and url", i+1)
+		}
+		if source.Dir == "" && (len(source.Ignore) > 0 || source.Gitignore) {
+			return nil, fmt.Errorf("source %d of the context definition can only ignore files of a dir", i+1)
+		}
+	}
+	return
+}
+
+// Assemble returns the content of the sources, each part headed by where it
+// comes from. With refresh, the refresh command is run first.
+func (o *ContextDefinition) Assemble(refresh bool) (ret string, err error) {
+	var base string
+	if o.Dir == "" {
+		base, err = os.Getwd()
+	} else {
+		base, err = util.GetAbsolutePath(o.Dir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not resolve the directory of the context: %w", err)
+	}
+	if refresh {
+		if err = o.refresh(base); err != nil {
+			return
+		}
+	}
+
+	maxFile, maxSize := o.MaxFileKB*1024, o.MaxSizeKB*1024
+	if maxFile == 0 {
+		maxFile = defaultContextMaxFileKB * 1024
+	}
+	if maxSize == 0 {
+		maxSize = defaultContextMaxSizeKB * 1024
+	}
+
+	var builder strings.Builder
+	added := map[string]bool{}
+	add := func(label, content string) error {
+		builder.WriteString("==> " + label + " <==\n")
+		builder.WriteString(strings.TrimRight(content, "\n") + "\n\n")
+		if int64(builder.Len()) > maxSize {
+			return fmt.Errorf("the context is larger than its max_size_kb of %d", maxSize/1024)
+		}
+		return nil
+	}
+	addFiles := func(files []string) error {
+		for _, file := range files {
+			if added[file] {
+				continue
+			}
+			added[file] = true
+			content, skip := readContextFile(file, maxFile)
+			if skip != nil {
+				debuglog.Log("Leaving %s out of the context: %v\n", file, skip)
+				continue
+			}
+			if err := add(contextLabel(base, file), content); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, source := range o.Sources {
+		switch {
+		case source.File != "":
+			var file string
+			if file, err = resolveContextPath(base, source.File); err != nil {
+				return
+			}
+			var content string
+			if content, err = readContextFile(file, maxFile); err != nil {
+				return "", fmt.Errorf("could not read %s: %w", source.File, err)
+			}
+			added[file] = true
+			err = add(contextLabel(base, file), content)
+		case source.Glob != "":
+			var files []string
+			if files, err = globContextFiles(base, source.Glob); err == nil {
+				err = addFiles(files)
+			}
+		case source.Dir != "":
+			var files []string
+			if files, err = source.dirFiles(base); err == nil {
+				err = addFiles(files)
+			}
+		case source.URL != "":
+			var content string
+			if content, err = fetchContextURL(source.URL, maxFile); err == nil {
+				err = add(source.URL, content)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+	return strings.TrimRight(builder.String(), "\n") + "\n", nil
+}
+
+func (o *ContextDefinition) refresh(base string) (err error) {
+	if o.Refresh == "" {
+		return
+	}
+	timeout := defaultContextRefreshTimeout
+	if o.RefreshTimeout != "" {
+		if timeout, err = time.ParseDuration(o.RefreshTimeout); err != nil {
+			return fmt.Errorf("invalid refresh_timeout %q: %w", o.RefreshTimeout, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", o.Refresh)
+	cmd.Dir = base
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("the refresh command of the context timed out after %v", timeout)
+		}
+		return fmt.Errorf("the refresh command of the context failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return
+}
+
+func resolveContextPath(base, name string) (string, error) {
+	if strings.HasPrefix(name, "~") || filepath.IsAbs(name) {
+		return util.GetAbsolutePath(name)
+	}
+	return filepath.Join(base, name), nil
+}
+
+// contextLabel names a file relative to the directory of the context when it is below it.
+func contextLabel(base, file string) string {
+	if rel, err := filepath.Rel(base, file); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return file
+}
+
+// readContextFile returns the content of a text file that is at most maxSize bytes long.
+func readContextFile(file string, maxSize int64) (ret string, err error) {
+	var info os.FileInfo
+	if info, err = os.Stat(file); err != nil {
+		return
+	}
+	if info.Size() > maxSize {
+		return "", fmt.Errorf("it is larger than max_file_kb of %d", maxSize/1024)
+	}
+	var content []byte
+	if content, err = os.ReadFile(file); err != nil {
+		return
+	}
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		return "", errors.New("it is not a text file")
+	}
+	return string(content), nil
+}
+
+func fetchContextURL(url string, maxSize int64) (ret string, err error) {
+	if ret, err = (&template.FetchPlugin{}).Apply("get", url); err != nil {
+		return
+	}
+	if start := strings.ToLower(strings.TrimSpace(ret[:min(len(ret), 512)])); strings.HasPrefix(start, "<!doctype html") || strings.Contains(start, "<html") {
+		if ret, err = converter.HtmlReadability(ret); err != nil {
+			return "", fmt.Errorf("could not read %s: %w", url, err)
+		}
+	}
+	if int64(len(ret)) > maxSize {
+		return "", fmt.Errorf("%s is larger than max_file_kb of %d", url, maxSize/1024)
+	}
+	return
+}
+
+// globContextFiles returns the files that match pattern, in lexical order.
+func globContextFiles(base, pattern string) (ret []string, err error) {
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	// The segments without wildcards lead to the directory the search starts in
+	static := 0
+	for static < len(segments)-1 && !strings.ContainsAny(segments[static], "*?[") {
+		static++
+	}
+	root := strings.Join(segments[:static], "/")
+	if root == "" && strings.HasPrefix(pattern, "/") {
+		root = "/"
+	}
+	if root, err = resolveContextPath(base, filepath.FromSlash(root)); err != nil {
+		return
+	}
+	segments = segments[static:]
+
+	err = filepath.WalkDir(root, func(file string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if file == root || entry.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, file)
+		if matchPathSegments(segments, strings.Split(filepath.ToSlash(rel), "/")) {
+			ret = append(ret, file)
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return
+}
+
+// matchPathSegments matches the segments of a path against those of a pattern,
+// where ** stands for any number of segments. Like in the shell, hidden names
+// are only matched by segments that start with a dot.
+func matchPathSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(name); i++ {
+			if matchPathSegments(pattern[1:], name[i:]) {
+				return true
+			}
+			if i < len(name) && strings.HasPrefix(name[i], ".") {
+				break
+			}
+		}
+		return false
+	}
+	if len(name) == 0 || (strings.HasPrefix(name[0], ".") && !strings.HasPrefix(pattern[0], ".")) {
+		return false
+	}
+	matched, _ := path.Match(pattern[0], name[0])
+	return matched && matchPathSegments(pattern[1:], name[1:])
+}
+
+// ignoreRule is a pattern of the .gitignore style. Patterns without a slash
+// match names at any depth, the others the path from the top of the directory.
+type ignoreRule struct {
+	segments []string
+	anchored bool
+	dirOnly  bool
+}
+
+func parseIgnoreRules(lines []string) (ret []ignoreRule) {
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		rule := ignoreRule{dirOnly: strings.HasSuffix(line, "/")}
+		line = strings.Trim(line, "/")
+		rule.anchored = strings.Contains(line, "/")
+		rule.segments = strings.Split(line, "/")
+		ret = append(ret, rule)
+	}
+	return
+}
+
+func (o ignoreRule) matches(rel string, isDir bool) bool {
+	if o.dirOnly && !isDir {
+		return false
+	}
+	names := strings.Split(rel, "/")
+	if !o.anchored {
+		matched, _ := path.Match(o.segments[0], names[len(names)-1])
+		return matched
+	}
+	return matchPathSegments(o.segments, names)
+}
+
+// dirFiles returns the files below the directory of the source, in lexical order.
+func (o *ContextSource) dirFiles(base string) (ret []string, err error) {
+	var root string
+	if root, err = resolveContextPath(base, o.Dir); err != nil {
+		return
+	}
+	lines := o.Ignore
+	if o.Gitignore {
+		if content, readErr := os.ReadFile(filepath.Join(root, ".gitignore")); readErr == nil {
+			lines = append(append([]string(nil), lines...), strings.Split(string(content), "\n")...)
+		}
+	}
+	rules := parseIgnoreRules(lines)
+
+	err = filepath.WalkDir(root, func(file string, entry fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if file == root {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, file)
+		rel = filepath.ToSlash(rel)
+		ignored := strings.HasPrefix(entry.Name(), ".")
+		for _, rule := range rules {
+			ignored = ignored || rule.matches(rel, entry.IsDir())
+		}
+		switch {
+		case ignored && entry.IsDir():
+			return filepath.SkipDir
+		case !ignored && entry.Type().IsRegular():
+			ret = append(ret, file)
+		}
+		return nil
+	})
+	if err != nil {
+		err = fmt.Errorf("could not read %s: %w", o.Dir, err)
+	}
+	return
+}
@@ -0,0 +1,149 @@
+package fsdb
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeContextFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write file: %v", err)
+		}
+	}
+}
+
+func TestContexts_GetDefinition(t *testing.T) {
+	project := t.TempDir()
+	writeContextFiles(t, project, map[string]string{
+		"README.md":            "# Project",
+		"docs/intro.md":        "intro",
+		"docs/guide/deploy.md": "deploy",
+		"docs/.drafts/wip.md":  "wip",
+		"docs/logo.md":         "\x00binary",
+		"api/server.go":        "package api",
+		"api/server_test.go":   "package api_test",
+		"api/testdata/case.go": "package testdata",
+		"api/gen/types.go":     "package gen",
+		"api/.gitignore":       "gen/\n",
+		"api/huge.go":          strings.Repeat("x", 2048),
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, "<!DOCTYPE html><html><head><title>Guide</title></head><body><article><p>Read the guide before you deploy anything to production.</p></article></body></html>")
+	}))
+	defer server.Close()
+
+	contexts := &ContextsEntity{Store: &StorageEntity{Dir: t.TempDir()}, Refresh: true}
+	definition := fmt.Sprintf(`description: Project docs
+dir: %s
+refresh: echo refreshed > CHANGES.md
+max_file_kb: 1
+sources:
+  - file: CHANGES.md
+  - file: README.md
+  - glob: docs/**/*.md
+  - dir: api
+    ignore: ["*_test.go", "testdata/"]
+    gitignore: true
+  - url: %s
+`, project, server.URL)
+	if err := contexts.Save("project"+ContextDefinitionSuffix, []byte(definition)); err != nil {
+		t.Fatalf("failed to save context definition: %v", err)
+	}
+
+	context, err := contexts.Get("project")
+	if err != nil {
+		t.Fatalf("failed to assemble context: %v", err)
+	}
+	for _, want := range []string{"==> CHANGES.md <==\nrefreshed", "==> README.md <==\n# Project", "==> docs/guide/deploy.md <==",
+		"==> docs/intro.md <==", "==> api/server.go <==", "==> " + server.URL + " <==", "Read the guide"} {
+		if !strings.Contains(context.Content, want) {
+			t.Errorf("expected the context to contain %q:\n%s", want, context.Content)
+		}
+	}
+	for _, unwanted := range []string{"wip", "binary", "server_test.go", "testdata", "gen/types.go", "huge.go"} {
+		if strings.Contains(context.Content, unwanted) {
+			t.Errorf("expected the context to leave out %q:\n%s", unwanted, context.Content)
+		}
+	}
+	if strings.Index(context.Content, "docs/guide/deploy.md") > strings.Index(context.Content, "docs/intro.md") {
+		t.Errorf("expected the files of a glob in lexical order:\n%s", context.Content)
+	}
+
+	// The refresh command only runs when it is asked for
+	if err = os.Remove(filepath.Join(project, "CHANGES.md")); err != nil {
+		t.Fatalf("failed to remove file: %v", err)
+	}
+	contexts.Refresh = false
+	if _, err = contexts.Get("project"); err == nil || !strings.Contains(err.Error(), "CHANGES.md") {
+		t.Errorf("expected the context to be assembled without refreshing, got %v", err)
+	}
+}
+
+func TestContextDefinition_Limits(t *testing.T) {
+	project := t.TempDir()
+	writeContextFiles(t, project, map[string]string{"a.md": strings.Repeat("a", 700), "b.md": strings.Repeat("b", 700)})
+
+	definition, err := ParseContextDefinition([]byte("dir: " + project + "\nmax_size_kb: 1\nsources:\n  - glob: '*.md'\n"))
+	if err != nil {
+		t.Fatalf("failed to parse context definition: %v", err)
+	}
+	if _, err = definition.Assemble(false); err == nil || !strings.Contains(err.Error(), "max_size_kb") {
+		t.Errorf("expected the context to be over its size, got %v", err)
+	}
+
+	definition.MaxFileKB, definition.MaxSizeKB = 0, 0
+	definition.Sources = []ContextSource{{File: "missing.md"}}
+	if _, err = definition.Assemble(false); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+
+	definition.Sources, definition.Refresh = []ContextSource{{File: "a.md"}}, "exit 3"
+	if _, err = definition.Assemble(true); err == nil || !strings.Contains(err.Error(), "refresh") {
+		t.Errorf("expected the refresh command to fail, got %v", err)
+	}
+	if _, err = definition.Assemble(false); err != nil {
+		t.Errorf("expected the refresh command not to run, got %v", err)
+	}
+
+	for _, invalid := range []string{
+		"sources: []",
+		"sources:\n  - file: a.md\n    glob: '*.md'",
+		"sources:\n  - file: a.md\n    ignore: [b.md]",
+		"max_file_kb: -1\nsources:\n  - file: a.md",
+		"source:\n  - file: a.md",
+	} {
+		if _, err = ParseContextDefinition([]byte(invalid)); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestMatchPathSegments(t *testing.T) {
+	for _, test := range []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"**/*.md", "a.md", true},
+		{"**/*.md", "x/y/a.md", true},
+		{"docs/*.md", "docs/x/a.md", false},
+		{"**/*.md", ".x/a.md", false},
+		{".x/*.md", ".x/a.md", true},
+		{"a/**/b", "a/b", true},
+	} {
+		if got := matchPathSegments(strings.Split(test.pattern, "/"), strings.Split(test.name, "/")); got != test.want {
+			t.Errorf("matching %s against %s: expected %v, got %v", test.name, test.pattern, test.want, got)
+		}
+	}
+}
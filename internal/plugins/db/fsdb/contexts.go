@@ -1,18 +1,41 @@
 package fsdb
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/danielmiessler/fabric/internal/plugins/db"
+)
 
 type ContextsEntity struct {
-	*StorageEntity
+	db.Store
+	// Refresh runs the refresh command of the definitions that are assembled.
+	// It is off unless asked for, so that using a context runs no command.
+	Refresh bool
 }
 
-// Get Load a context from file
+// Get Load a context from file. A name without a context falls back to the
+// definition of that name, which is assembled from its sources.
 func (o *ContextsEntity) Get(name string) (ret *Context, err error) {
+	if !o.Exists(name) && o.Exists(name+ContextDefinitionSuffix) {
+		name += ContextDefinitionSuffix
+	}
 	var content []byte
 	if content, err = o.Load(name); err != nil {
 		return
 	}
 
+	if IsContextDefinition(name) {
+		var definition *ContextDefinition
+		if definition, err = ParseContextDefinition(content); err != nil {
+			return
+		}
+		var assembled string
+		if assembled, err = definition.Assemble(o.Refresh); err != nil {
+			return nil, fmt.Errorf("could not assemble context %s: %w", name, err)
+		}
+		content = []byte(assembled)
+	}
+
 	ret = &Context{Name: name, Content: string(content)}
 	return
 }
@@ -9,7 +9,7 @@ import (
 func TestContexts_GetContext(t *testing.T) {
 	dir := t.TempDir()
 	contexts := &ContextsEntity{
-		StorageEntity: &StorageEntity{Dir: dir},
+		Store: &StorageEntity{Dir: dir},
 	}
 	contextName := "testContext"
 	contextPath := filepath.Join(dir, contextName)
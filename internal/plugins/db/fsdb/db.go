@@ -7,6 +7,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/danielmiessler/fabric/internal/plugins/db"
+	"github.com/danielmiessler/fabric/internal/plugins/db/sqlitedb"
 	"github.com/joho/godotenv"
 )
 
@@ -24,14 +26,23 @@ func NewDb(dir string) (db *Db) {
 	}
 
 	db.Sessions = &SessionsEntity{
-		&StorageEntity{Label: "Sessions", Dir: db.FilePath("sessions"), FileExtension: ".json"}}
+		Store:       &EncryptedStore{Store: db.sessionFiles()},
+		Attachments: &AttachmentsEntity{&EncryptedStore{Store: db.attachmentFiles()}},
+	}
+
+	db.Contexts = &ContextsEntity{Store: &EncryptedStore{Store: db.contextFiles()}}
 
-	db.Contexts = &ContextsEntity{
-		&StorageEntity{Label: "Contexts", Dir: db.FilePath("contexts")}}
+	db.SQLite = sqlitedb.NewDb(db.FilePath("fabric.db"))
 
 	return
 }
 
+// Storage backends of the sessions and contexts, chosen with STORAGE_BACKEND in the .env file
+const (
+	StorageBackendFiles  = "files"
+	StorageBackendSQLite = "sqlite"
+)
+
 type Db struct {
 	Dir string
 
@@ -39,6 +50,9 @@ type Db struct {
 	Sessions *SessionsEntity
 	Contexts *ContextsEntity
 
+	// SQLite keeps the sessions and contexts with the sqlite storage backend
+	SQLite *sqlitedb.Db
+
 	EnvFilePath string
 }
 
@@ -63,6 +77,24 @@ func (o *Db) Configure() (err error) {
 		o.Patterns.CustomPatternsDir = customPatternsDir
 	}
 
+	var sessions, attachments, contexts db.Store = o.sessionFiles(), o.attachmentFiles(), o.contextFiles()
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", StorageBackendFiles:
+	case StorageBackendSQLite:
+		sessions = o.SQLite.NewStorageEntity("Sessions", "session")
+		attachments = o.SQLite.NewStorageEntity("Attachments", "attachment")
+		contexts = o.SQLite.NewStorageEntity("Contexts", "context")
+	default:
+		return fmt.Errorf("unknown STORAGE_BACKEND %q, use %s or %s", backend, StorageBackendFiles, StorageBackendSQLite)
+	}
+	var key []byte
+	if key, err = LoadEncryptionKey(); err != nil {
+		return
+	}
+	o.Sessions.Store = &EncryptedStore{Store: sessions, Key: key}
+	o.Sessions.Attachments.Store = &EncryptedStore{Store: attachments, Key: key}
+	o.Contexts.Store = &EncryptedStore{Store: contexts, Key: key}
+
 	if err = o.Patterns.Configure(); err != nil {
 		return
 	}
@@ -78,6 +110,11 @@ func (o *Db) Configure() (err error) {
 	return
 }
 
+// Encrypted tells whether sessions and contexts are saved encrypted.
+func (o *Db) Encrypted() bool {
+	return isEncrypted(o.Sessions.Store)
+}
+
 func (o *Db) LoadEnvFile() (err error) {
 	if err = godotenv.Load(o.EnvFilePath); err != nil {
 		err = fmt.Errorf("error loading .env file: %s", err)
@@ -96,6 +133,64 @@ func (o *Db) SaveEnv(content string) (err error) {
 	return
 }
 
+// MigrateToSQLite copies the session, attachment and context files into the SQLite
+// database, in one transaction. Nothing is copied when one of them is in the
+// database already. The files are left as they are.
+func (o *Db) MigrateToSQLite() (sessions, contexts int, err error) {
+	var items []sqlitedb.Item
+	var attachments int
+	for _, source := range []struct {
+		files *StorageEntity
+		kind  string
+		count *int
+	}{
+		{o.sessionFiles(), "session", &sessions},
+		{o.attachmentFiles(), "attachment", &attachments},
+		{o.contextFiles(), "context", &contexts},
+	} {
+		if !dirExists(source.files.Dir) {
+			continue
+		}
+		var names []string
+		if names, err = source.files.GetNames(); err != nil {
+			return 0, 0, err
+		}
+		for _, name := range names {
+			item := sqlitedb.Item{Kind: source.kind, Name: name}
+			if item.Content, err = source.files.Load(name); err != nil {
+				return 0, 0, err
+			}
+			if item.ModTime, _, err = source.files.Stat(name); err != nil {
+				return 0, 0, err
+			}
+			items = append(items, item)
+			*source.count++
+		}
+	}
+	if err = o.SQLite.Import(items); err != nil {
+		return 0, 0, fmt.Errorf("could not migrate to %s: %w", o.SQLite.Path, err)
+	}
+	return
+}
+
+// Sessions and contexts may hold confidential material, so only the user can read their files
+func (o *Db) sessionFiles() *StorageEntity {
+	return &StorageEntity{Label: "Sessions", Dir: o.FilePath("sessions"), FileExtension: ".json", FileMode: 0600}
+}
+
+func (o *Db) attachmentFiles() *StorageEntity {
+	return &StorageEntity{Label: "Attachments", Dir: o.FilePath("attachments"), FileMode: 0600}
+}
+
+func (o *Db) contextFiles() *StorageEntity {
+	return &StorageEntity{Label: "Contexts", Dir: o.FilePath("contexts"), FileMode: 0600}
+}
+
+func dirExists(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
+
 func (o *Db) FilePath(fileName string) (ret string) {
 	return filepath.Join(o.Dir, fileName)
 }
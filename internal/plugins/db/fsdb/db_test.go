@@ -1,8 +1,11 @@
 package fsdb
 
 import (
+	"encoding/base64"
 	"os"
 	"testing"
+
+	"github.com/danielmiessler/fabric/internal/chat"
 )
 
 func TestDb_Configure(t *testing.T) {
@@ -25,6 +28,17 @@ func TestDb_Configure(t *testing.T) {
 	if err != nil {
 		t.Fatalf("db is not configured, but shall be after save: %v", err)
 	}
+	if db.Encrypted() {
+		t.Error("db is encrypted without a key")
+	}
+
+	t.Setenv("ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(make([]byte, EncryptionKeySize)))
+	if err = db.Configure(); err != nil {
+		t.Fatalf("db is not configured with a key: %v", err)
+	}
+	if !db.Encrypted() {
+		t.Error("db is not encrypted with a key")
+	}
 }
 
 func TestDb_LoadEnvFile(t *testing.T) {
@@ -53,3 +67,36 @@ func TestDb_SaveEnv(t *testing.T) {
 		t.Errorf("expected .env file to be saved")
 	}
 }
+
+func TestDb_MigrateToSQLite(t *testing.T) {
+	db := NewDb(t.TempDir())
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create the sessions: %v", err)
+	}
+	if err := db.Sessions.SaveSession(&Session{Name: "chat", Messages: []*chat.ChatCompletionMessage{{Role: chat.ChatMessageRoleUser, Content: "hi"}}}); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	defer db.SQLite.Close()
+
+	sessions, contexts, err := db.MigrateToSQLite()
+	if err != nil || sessions != 1 || contexts != 0 {
+		t.Fatalf("unexpected migration %d sessions, %d contexts: %v", sessions, contexts, err)
+	}
+	if _, _, err = db.MigrateToSQLite(); err == nil {
+		t.Errorf("expected a second migration to fail")
+	}
+
+	if err = db.SaveEnv("STORAGE_BACKEND=sqlite\n"); err != nil {
+		t.Fatalf("failed to save .env: %v", err)
+	}
+	// .env does not override the environment
+	t.Setenv("STORAGE_BACKEND", "")
+	os.Unsetenv("STORAGE_BACKEND")
+	if err = db.Configure(); err != nil {
+		t.Fatalf("failed to configure: %v", err)
+	}
+	session, err := db.Sessions.Get("chat")
+	if err != nil || len(session.Messages) != 1 {
+		t.Errorf("expected the session from the database, got %+v, %v", session, err)
+	}
+}
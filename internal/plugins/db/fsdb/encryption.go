@@ -0,0 +1,373 @@
+package fsdb
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/danielmiessler/fabric/internal/plugins/db"
+	"github.com/danielmiessler/fabric/internal/plugins/db/sqlitedb"
+	"github.com/danielmiessler/fabric/internal/util"
+	"golang.org/x/crypto/scrypt"
+)
+
+// EncryptionKeySize is the size of the AES-256 keys of EncryptedStore.
+const EncryptionKeySize = 32
+
+// encryptedPrefix starts encrypted items, followed by the nonce and the AES-GCM sealed content.
+var encryptedPrefix = []byte("fabric-encrypted:v1\n")
+
+// errNoEncryptionKey explains how to give the key of encrypted items.
+var errNoEncryptionKey = errors.New("it is encrypted; set ENCRYPTION_KEY or ENCRYPTION_KEY_FILE in the .env file")
+
+// ErrNoEncryptionPassphrase is returned for key files made from a passphrase
+// when it is not given.
+var ErrNoEncryptionPassphrase = errors.New("the key file is derived from a passphrase; set ENCRYPTION_PASSPHRASE in the .env file")
+
+// passphraseKeyPrefix starts key files made from a passphrase, which hold the
+// scrypt parameters and salt to derive the key again and a check of the key:
+//
+//	scrypt:<N>:<r>:<p>:<salt>:<check>
+//
+// with the salt and the check in base64.
+const passphraseKeyPrefix = "scrypt:"
+
+// The scrypt parameters of new key files.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// EncryptedStore encrypts the items of a store with AES-GCM. Without a key it
+// saves items as they are and fails to load the encrypted ones, and with one
+// it still loads the items saved before encryption was turned on.
+type EncryptedStore struct {
+	db.Store
+	Key []byte
+}
+
+func (o *EncryptedStore) Save(name string, content []byte) (err error) {
+	if o.Key != nil {
+		if content, err = encrypt(o.Key, content); err != nil {
+			return fmt.Errorf("could not encrypt %s: %v", name, err)
+		}
+	}
+	return o.Store.Save(name, content)
+}
+
+func (o *EncryptedStore) Load(name string) (ret []byte, err error) {
+	if ret, err = o.Store.Load(name); err != nil || !bytes.HasPrefix(ret, encryptedPrefix) {
+		return
+	}
+	if o.Key == nil {
+		return nil, fmt.Errorf("could not load %s: %w", name, errNoEncryptionKey)
+	}
+	if ret, err = decrypt(o.Key, ret); err != nil {
+		return nil, fmt.Errorf("could not decrypt %s, it was encrypted with another key: %v", name, err)
+	}
+	return
+}
+
+// Encrypted tells whether the items are saved encrypted.
+func (o *EncryptedStore) Encrypted() bool {
+	return o.Key != nil
+}
+
+func encrypt(key, content []byte) (ret []byte, err error) {
+	var aead cipher.AEAD
+	if aead, err = newAEAD(key); err != nil {
+		return
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+	ret = append(append(bytes.Clone(encryptedPrefix), nonce...), aead.Seal(nil, nonce, content, nil)...)
+	return
+}
+
+func decrypt(key, content []byte) (ret []byte, err error) {
+	var aead cipher.AEAD
+	if aead, err = newAEAD(key); err != nil {
+		return
+	}
+	sealed := content[len(encryptedPrefix):]
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("the content is truncated")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+}
+
+func newAEAD(key []byte) (ret cipher.AEAD, err error) {
+	var block cipher.Block
+	if block, err = aes.NewCipher(key); err != nil {
+		return
+	}
+	return cipher.NewGCM(block)
+}
+
+// LoadEncryptionKey returns the key of ENCRYPTION_KEY, or else the one in the
+// file of ENCRYPTION_KEY_FILE, derived with ENCRYPTION_PASSPHRASE when the file
+// was made from a passphrase. Without either it returns no key and no error.
+func LoadEncryptionKey() (ret []byte, err error) {
+	if value := os.Getenv("ENCRYPTION_KEY"); value != "" {
+		if ret, err = ParseEncryptionKey(value); err != nil {
+			err = fmt.Errorf("invalid ENCRYPTION_KEY: %w", err)
+		}
+		return
+	}
+	if path := os.Getenv("ENCRYPTION_KEY_FILE"); path != "" {
+		if ret, err = ReadEncryptionKeyFile(path, os.Getenv("ENCRYPTION_PASSPHRASE")); err != nil {
+			err = fmt.Errorf("invalid ENCRYPTION_KEY_FILE: %w", err)
+		}
+	}
+	return
+}
+
+// ReadEncryptionKeyFile reads the key in the file at path, which may start
+// with ~. The file holds either a base64 key or, when it was made by
+// CreateEncryptionKeyFile, what it takes to derive the key from passphrase.
+func ReadEncryptionKeyFile(path string, passphrase string) (ret []byte, err error) {
+	if path, err = util.GetAbsolutePath(path); err != nil {
+		return
+	}
+	var content []byte
+	if content, err = os.ReadFile(path); err != nil {
+		return
+	}
+	if value := strings.TrimSpace(string(content)); strings.HasPrefix(value, passphraseKeyPrefix) {
+		ret, err = derivePassphraseKey(value, passphrase)
+	} else {
+		ret, err = ParseEncryptionKey(value)
+	}
+	if err != nil {
+		err = fmt.Errorf("%s: %w", path, err)
+	}
+	return
+}
+
+// derivePassphraseKey derives the key of a passphrase key file from passphrase.
+func derivePassphraseKey(value string, passphrase string) (ret []byte, err error) {
+	if passphrase == "" {
+		return nil, ErrNoEncryptionPassphrase
+	}
+	fields := strings.Split(strings.TrimPrefix(value, passphraseKeyPrefix), ":")
+	if len(fields) != 5 {
+		return nil, errors.New("the passphrase key file is malformed")
+	}
+	var n, r, p int
+	var salt, check []byte
+	if _, err = fmt.Sscanf(strings.Join(fields[:3], " "), "%d %d %d", &n, &r, &p); err == nil {
+		if salt, err = base64.StdEncoding.DecodeString(fields[3]); err == nil {
+			check, err = base64.StdEncoding.DecodeString(fields[4])
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("the passphrase key file is malformed: %v", err)
+	}
+	if ret, err = scrypt.Key([]byte(passphrase), salt, n, r, p, EncryptionKeySize); err != nil {
+		return
+	}
+	if !bytes.Equal(keyCheck(ret), check) {
+		return nil, errors.New("the passphrase is not the one the key file was made with")
+	}
+	return
+}
+
+// keyCheck tells a key derived from the right passphrase from others, without
+// giving the key away.
+func keyCheck(key []byte) []byte {
+	sum := sha256.Sum256(append([]byte("fabric-key-check\n"), key...))
+	return sum[:8]
+}
+
+// ParseEncryptionKey decodes a base64 key, as `openssl rand -base64 32` prints one.
+func ParseEncryptionKey(value string) (ret []byte, err error) {
+	if ret, err = base64.StdEncoding.DecodeString(strings.TrimSpace(value)); err != nil {
+		return nil, errors.New("the key is not base64")
+	}
+	if len(ret) != EncryptionKeySize {
+		return nil, fmt.Errorf("the key has %d bytes instead of %d", len(ret), EncryptionKeySize)
+	}
+	return
+}
+
+// CreateEncryptionKeyFile writes a new file at path, that only the user can
+// read, holding the random salt and the parameters that derive a key from the
+// passphrase with scrypt. The key is derived again whenever the file is read,
+// so that it takes the passphrase as well as the file.
+func CreateEncryptionKeyFile(path string, passphrase string) (err error) {
+	if strings.TrimSpace(passphrase) == "" {
+		return errors.New("the passphrase is empty")
+	}
+	salt := make([]byte, 16)
+	if _, err = rand.Read(salt); err != nil {
+		return
+	}
+	var key []byte
+	if key, err = scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, EncryptionKeySize); err != nil {
+		return
+	}
+	if path, err = util.GetAbsolutePath(path); err != nil {
+		return
+	}
+	var file *os.File
+	if file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600); err != nil {
+		return fmt.Errorf("could not create %s: %w", path, err)
+	}
+	if _, err = fmt.Fprintf(file, "%s%d:%d:%d:%s:%s\n", passphraseKeyPrefix, scryptN, scryptR, scryptP,
+		base64.StdEncoding.EncodeToString(salt), base64.StdEncoding.EncodeToString(keyCheck(key))); err != nil {
+		file.Close()
+		return
+	}
+	return file.Close()
+}
+
+// Rekey saves every session, attachment and context again with key, or without
+// encryption when key is nil. It holds the lock of every session while it runs.
+//
+// All items are loaded and encrypted before any is changed, so that an item
+// that cannot be decrypted stops the change early. The new contents then take
+// the place of the old ones together: in one transaction with the sqlite
+// backend, and by renaming files written next to the old ones with the files
+// backend. When that fails part way, the items changed already are restored,
+// and the error names those that could not be.
+func (o *Db) Rekey(key []byte) (sessions, contexts int, err error) {
+	var sessionNames []string
+	if sessionNames, err = o.Sessions.GetNames(); err != nil {
+		return
+	}
+	slices.Sort(sessionNames)
+	for _, name := range sessionNames {
+		defer o.Sessions.Lock(name)()
+	}
+
+	stores := []*db.Store{&o.Sessions.Store, &o.Contexts.Store}
+	if o.Sessions.Attachments != nil {
+		stores = append(stores, &o.Sessions.Attachments.Store)
+	}
+	var items []rekeyItem
+	counts := make([]int, len(stores))
+	nextStores := make([]*EncryptedStore, len(stores))
+	for i, store := range stores {
+		current, ok := (*store).(*EncryptedStore)
+		if !ok {
+			current = &EncryptedStore{Store: *store}
+		}
+		var names []string
+		if names, err = current.GetNames(); err != nil {
+			return
+		}
+		for _, name := range names {
+			item := rekeyItem{store: current.Store, name: name}
+			if item.old, err = current.Store.Load(name); err != nil {
+				return
+			}
+			if item.new, err = current.Load(name); err != nil {
+				return
+			}
+			if key != nil {
+				if item.new, err = encrypt(key, item.new); err != nil {
+					return 0, 0, fmt.Errorf("could not encrypt %s: %v", name, err)
+				}
+			}
+			items = append(items, item)
+		}
+		counts[i] = len(names)
+		nextStores[i] = &EncryptedStore{Store: current.Store, Key: key}
+	}
+
+	if err = replaceItems(items); err != nil {
+		return
+	}
+	for i, store := range stores {
+		*store = nextStores[i]
+	}
+	return counts[0], counts[1], nil
+}
+
+// rekeyItem is an item of Rekey, with its content in the store before and after.
+type rekeyItem struct {
+	store    db.Store
+	name     string
+	old, new []byte
+}
+
+// replaceItems saves the new content of the items together, see Rekey.
+func replaceItems(items []rekeyItem) (err error) {
+	var staged []string
+	defer func() {
+		for _, temp := range staged {
+			os.Remove(temp)
+		}
+	}()
+
+	// Nothing is changed until every new content is written
+	transactions := map[*sqlitedb.Db][]sqlitedb.Item{}
+	var files, others []rekeyItem
+	for _, item := range items {
+		switch store := item.store.(type) {
+		case *StorageEntity:
+			var temp string
+			if temp, err = store.writeTemp(item.name, item.new); err != nil {
+				return fmt.Errorf("could not rekey %s, nothing was changed: %v", item.name, err)
+			}
+			staged = append(staged, temp)
+			files = append(files, item)
+		case *sqlitedb.StorageEntity:
+			transactions[store.Db] = append(transactions[store.Db], sqlitedb.Item{Kind: store.Kind, Name: item.name, Content: item.new})
+		default:
+			others = append(others, item)
+		}
+	}
+
+	var done []rekeyItem
+	for sqliteDb, sqliteItems := range transactions {
+		if err = sqliteDb.Replace(sqliteItems); err != nil {
+			return restoreItems(done, err)
+		}
+		for _, item := range items {
+			if store, ok := item.store.(*sqlitedb.StorageEntity); ok && store.Db == sqliteDb {
+				done = append(done, item)
+			}
+		}
+	}
+	for i, item := range files {
+		if err = os.Rename(staged[i], item.store.(*StorageEntity).BuildFilePathByName(item.name)); err != nil {
+			return restoreItems(done, fmt.Errorf("could not save %s: %v", item.name, err))
+		}
+		done = append(done, item)
+	}
+	for _, item := range others {
+		if err = item.store.Save(item.name, item.new); err != nil {
+			return restoreItems(done, err)
+		}
+		done = append(done, item)
+	}
+	return
+}
+
+// restoreItems saves the old content of the items Rekey changed before it
+// failed with err, and returns err with the items that kept the new one.
+func restoreItems(done []rekeyItem, err error) error {
+	var left []string
+	for _, item := range done {
+		if restoreErr := item.store.Save(item.name, item.old); restoreErr != nil {
+			left = append(left, item.name)
+		}
+	}
+	if len(left) > 0 {
+		return fmt.Errorf("could not rekey: %w; these items have the new key and the others the old one: %s",
+			err, strings.Join(left, ", "))
+	}
+	return fmt.Errorf("could not rekey, every item has the old key: %w", err)
+}
@@ -0,0 +1,160 @@
+package fsdb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+)
+
+func TestEncryptedStore(t *testing.T) {
+	files := &StorageEntity{Dir: t.TempDir(), FileMode: 0600}
+	key := bytes.Repeat([]byte{7}, EncryptionKeySize)
+	store := &EncryptedStore{Store: files, Key: key}
+
+	if err := files.Save("plain", []byte("saved before encryption")); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	if err := store.Save("secret", []byte("customer data")); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+	raw, _ := os.ReadFile(files.BuildFilePathByName("secret"))
+	if bytes.Contains(raw, []byte("customer data")) {
+		t.Errorf("expected the file to be encrypted, got %q", raw)
+	}
+	if info, err := os.Stat(files.BuildFilePathByName("secret")); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the file to be private, got %v, %v", info.Mode(), err)
+	}
+
+	for name, want := range map[string]string{"secret": "customer data", "plain": "saved before encryption"} {
+		if content, err := store.Load(name); err != nil || string(content) != want {
+			t.Errorf("expected %q for %s, got %q, %v", want, name, content, err)
+		}
+	}
+
+	if _, err := (&EncryptedStore{Store: files}).Load("secret"); !errors.Is(err, errNoEncryptionKey) {
+		t.Errorf("expected an error about the missing key, got %v", err)
+	}
+	other := &EncryptedStore{Store: files, Key: bytes.Repeat([]byte{8}, EncryptionKeySize)}
+	if _, err := other.Load("secret"); err == nil {
+		t.Errorf("expected an error with another key")
+	}
+}
+
+func TestDb_Rekey(t *testing.T) {
+	db := NewDb(t.TempDir())
+	if err := db.Contexts.Configure(); err != nil {
+		t.Fatalf("failed to create the contexts: %v", err)
+	}
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create the sessions: %v", err)
+	}
+	if err := db.Contexts.Save("ops", []byte("on call notes")); err != nil {
+		t.Fatalf("failed to save context: %v", err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "fabric.key")
+	if err := CreateEncryptionKeyFile(keyFile, "correct horse battery staple"); err != nil {
+		t.Fatalf("failed to create the key file: %v", err)
+	}
+	if err := CreateEncryptionKeyFile(keyFile, "again"); err == nil {
+		t.Errorf("expected an existing key file to be kept")
+	}
+	if _, err := ReadEncryptionKeyFile(keyFile, ""); !errors.Is(err, ErrNoEncryptionPassphrase) {
+		t.Errorf("expected the key file to need its passphrase, got %v", err)
+	}
+	if _, err := ReadEncryptionKeyFile(keyFile, "wrong"); err == nil {
+		t.Errorf("expected an error for another passphrase")
+	}
+	key, err := ReadEncryptionKeyFile(keyFile, "correct horse battery staple")
+	if err != nil {
+		t.Fatalf("failed to read the key file: %v", err)
+	}
+	if again, _ := ReadEncryptionKeyFile(keyFile, "correct horse battery staple"); !bytes.Equal(again, key) {
+		t.Errorf("expected the passphrase to derive the same key again")
+	}
+	if content, _ := os.ReadFile(keyFile); bytes.Contains(content, []byte(base64.StdEncoding.EncodeToString(key))) {
+		t.Errorf("expected the key file to hold no key, got %s", content)
+	}
+
+	if _, contexts, err := db.Rekey(key); err != nil || contexts != 1 {
+		t.Fatalf("failed to encrypt %d contexts: %v", contexts, err)
+	}
+	if context, err := db.Contexts.Get("ops"); err != nil || context.Content != "on call notes" {
+		t.Errorf("expected the context to be readable with the new key, got %+v, %v", context, err)
+	}
+	if _, err = (&EncryptedStore{Store: db.contextFiles()}).Load("ops"); err == nil {
+		t.Errorf("expected the context to be encrypted")
+	}
+
+	if _, _, err = db.Rekey(nil); err != nil {
+		t.Fatalf("failed to decrypt: %v", err)
+	}
+	if content, err := db.contextFiles().Load("ops"); err != nil || string(content) != "on call notes" {
+		t.Errorf("expected the context in plaintext, got %q, %v", content, err)
+	}
+}
+
+// failingStore fails to save the item named fail.
+type failingStore struct {
+	*StorageEntity
+}
+
+func (o failingStore) Save(name string, content []byte) error {
+	if name == "fail" {
+		return errors.New("disk full")
+	}
+	return o.StorageEntity.Save(name, content)
+}
+
+func TestDb_RekeyFailure(t *testing.T) {
+	db := NewDb(t.TempDir())
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create the sessions: %v", err)
+	}
+	if err := db.Contexts.Configure(); err != nil {
+		t.Fatalf("failed to create the contexts: %v", err)
+	}
+	oldKey := bytes.Repeat([]byte{7}, EncryptionKeySize)
+	db.Sessions.Store = &EncryptedStore{Store: db.sessionFiles(), Key: oldKey}
+	db.Contexts.Store = &EncryptedStore{Store: failingStore{db.contextFiles()}, Key: oldKey}
+	if err := db.Contexts.Save("ops", []byte("notes")); err != nil {
+		t.Fatalf("failed to save context: %v", err)
+	}
+	if err := db.contextFiles().Save("fail", []byte("saved before encryption")); err != nil {
+		t.Fatalf("failed to save context: %v", err)
+	}
+	session := &Session{Name: "chat"}
+	session.Append(&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "hi"})
+	if err := db.Sessions.SaveSession(session); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	// The sessions are renamed into place, then the context fails and they are restored
+	if _, _, err := db.Rekey(bytes.Repeat([]byte{8}, EncryptionKeySize)); err == nil || !strings.Contains(err.Error(), "every item has the old key") {
+		t.Fatalf("expected the rekey to fail and be undone, got %v", err)
+	}
+	if loaded, err := db.Sessions.Get("chat"); err != nil || len(loaded.Messages) != 1 {
+		t.Errorf("expected the session to keep the old key, got %+v, %v", loaded, err)
+	}
+	if content, err := db.Contexts.Load("ops"); err != nil || string(content) != "notes" {
+		t.Errorf("expected the context to keep the old key, got %q, %v", content, err)
+	}
+	if entries, _ := os.ReadDir(db.FilePath("sessions")); len(entries) != 1 {
+		t.Errorf("expected the temporary files to be removed, got %v", entries)
+	}
+}
+
+func TestParseEncryptionKey(t *testing.T) {
+	if _, err := ParseEncryptionKey("not base64!"); err == nil {
+		t.Errorf("expected an error for a key that is not base64")
+	}
+	if _, err := ParseEncryptionKey("c2hvcnQ="); err == nil {
+		t.Errorf("expected an error for a short key")
+	}
+}
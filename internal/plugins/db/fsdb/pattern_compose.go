@@ -0,0 +1,206 @@
+package fsdb
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var includePattern = regexp.MustCompile(`\{\{include:([^{}]*)\}\}`)
+
+// compose resolves the base pattern of pattern and its includes. chain holds
+// the patterns being composed, so that a pattern including itself is caught.
+func (o *PatternsEntity) compose(pattern *Pattern, chain []string) (err error) {
+	if pattern.Metadata != nil && pattern.Metadata.Extends != "" {
+		var base *Pattern
+		if base, err = o.loadComposed(pattern.Metadata.Extends, chain); err != nil {
+			return fmt.Errorf("pattern %s extends %s: %w", pattern.Name, pattern.Metadata.Extends, err)
+		}
+		pattern.Pattern = extendSections(base.Pattern, pattern.Pattern)
+		pattern.Metadata = pattern.Metadata.extend(base.Metadata)
+		pattern.Description = pattern.Metadata.Description
+	}
+
+	pattern.Pattern = includePattern.ReplaceAllStringFunc(pattern.Pattern, func(match string) string {
+		if err != nil {
+			return match
+		}
+		name, section, hasSection := strings.Cut(includePattern.FindStringSubmatch(match)[1], "#")
+		var included *Pattern
+		if included, err = o.loadComposed(name, chain); err != nil {
+			err = fmt.Errorf("pattern %s includes %s: %w", pattern.Name, name, err)
+			return match
+		}
+		content := included.Pattern
+		if hasSection {
+			var found bool
+			if content, found = findSection(content, section); !found {
+				err = fmt.Errorf("pattern %s includes %s: no section %q", pattern.Name, name, section)
+				return match
+			}
+		}
+		return strings.TrimRight(content, "\n")
+	})
+	return
+}
+
+// loadComposed loads the pattern name for another one to extend or include.
+func (o *PatternsEntity) loadComposed(name string, chain []string) (ret *Pattern, err error) {
+	if slices.Contains(chain, name) {
+		return nil, fmt.Errorf("cycle %s -> %s", strings.Join(chain, " -> "), name)
+	}
+	if ret, err = o.readPattern(name); err != nil {
+		return
+	}
+	err = o.compose(ret, append(slices.Clone(chain), name))
+	return
+}
+
+// extend fills what the metadata leaves out from that of the pattern it
+// extends. Variables of the base are kept unless they are declared again.
+func (o *PatternMetadata) extend(base *PatternMetadata) *PatternMetadata {
+	if base == nil {
+		return o
+	}
+	ret := *o
+	if ret.Description == "" {
+		ret.Description = base.Description
+	}
+	if ret.Tags == nil {
+		ret.Tags = base.Tags
+	}
+	if ret.Vendor == "" && ret.Model == "" {
+		ret.Vendor, ret.Model = base.Vendor, base.Model
+	}
+	if ret.Fallback == nil {
+		ret.Fallback = base.Fallback
+	}
+	if ret.Temperature == nil {
+		ret.Temperature = base.Temperature
+	}
+	if ret.Strategy == "" {
+		ret.Strategy = base.Strategy
+	}
+	if ret.Format == "" {
+		ret.Format = base.Format
+	}
+	ret.Variables = nil
+	for _, variable := range base.Variables {
+		if !slices.ContainsFunc(o.Variables, func(own PatternVariable) bool { return own.Name == variable.Name }) {
+			ret.Variables = append(ret.Variables, variable)
+		}
+	}
+	ret.Variables = append(ret.Variables, o.Variables...)
+	return &ret
+}
+
+// markdownSection is a heading of a Markdown text with the lines that follow
+// it, up to the next heading of the same or a higher level.
+type markdownSection struct {
+	Title      string
+	Level      int
+	Start, End int
+}
+
+// markdownSections returns the sections of content in order, leaving out the
+// lines of fenced code blocks.
+func markdownSections(content string) (ret []markdownSection) {
+	var fenced bool
+	for offset := 0; offset < len(content); {
+		line, _, _ := strings.Cut(content[offset:], "\n")
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+		} else if level, title := markdownHeading(line); !fenced && level > 0 {
+			ret = append(ret, markdownSection{Title: title, Level: level, Start: offset})
+		}
+		offset += len(line) + 1
+	}
+	for i := range ret {
+		ret[i].End = len(content)
+		for _, next := range ret[i+1:] {
+			if next.Level <= ret[i].Level {
+				ret[i].End = next.Start
+				break
+			}
+		}
+	}
+	return
+}
+
+// markdownHeading returns the level and title of an ATX heading, level 0 for
+// other lines.
+func markdownHeading(line string) (level int, title string) {
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ' && line[level] != '\t' && line[level] != '\r') {
+		return 0, ""
+	}
+	return level, strings.TrimSpace(line[level:])
+}
+
+// findSection returns the first section of content titled title, compared
+// without case, along with its heading.
+func findSection(content, title string) (string, bool) {
+	for _, section := range markdownSections(content) {
+		if strings.EqualFold(section.Title, strings.TrimSpace(title)) {
+			return content[section.Start:section.End], true
+		}
+	}
+	return "", false
+}
+
+// extendSections overrides the sections of base with those of the same title
+// in content, split at its highest headings. Sections base lacks are added at
+// its end, and text before the first heading of content replaces that of base.
+func extendSections(base, content string) string {
+	sections := markdownSections(content)
+	preamble := content
+	if len(sections) > 0 {
+		preamble = content[:sections[0].Start]
+	}
+	ret := base
+	if strings.TrimSpace(preamble) != "" {
+		if !strings.HasSuffix(preamble, "\n") {
+			preamble += "\n"
+		}
+		ret = preamble + ret[preambleEnd(ret):]
+	}
+	if len(sections) == 0 {
+		return ret
+	}
+
+	top := slices.MinFunc(sections, func(a, b markdownSection) int { return a.Level - b.Level }).Level
+	for _, section := range sections {
+		if section.Level != top {
+			continue
+		}
+		text := content[section.Start:section.End]
+		if !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		index := slices.IndexFunc(markdownSections(ret), func(own markdownSection) bool {
+			return strings.EqualFold(own.Title, section.Title)
+		})
+		if index < 0 {
+			if ret != "" && !strings.HasSuffix(ret, "\n") {
+				ret += "\n"
+			}
+			ret += text
+			continue
+		}
+		replaced := markdownSections(ret)[index]
+		ret = ret[:replaced.Start] + text + ret[replaced.End:]
+	}
+	return ret
+}
+
+// preambleEnd returns where the first heading of content starts.
+func preambleEnd(content string) int {
+	if sections := markdownSections(content); len(sections) > 0 {
+		return sections[0].Start
+	}
+	return len(content)
+}
@@ -0,0 +1,93 @@
+package fsdb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const styleGuide = `---
+description: House style
+variables:
+  - name: tone
+    default: friendly
+format: markdown
+---
+# IDENTITY
+
+You are a writer.
+
+# STEPS
+
+- Read the input.
+
+## Details
+
+- Take notes.
+
+# OUTPUT INSTRUCTIONS
+
+- Write in a {{tone}} tone.
+- Use Markdown.
+`
+
+func TestPatternsEntity_Include(t *testing.T) {
+	entity, cleanup := setupTestPatternsEntity(t)
+	defer cleanup()
+	createTestPattern(t, entity, "style_guide", styleGuide)
+	createTestPattern(t, entity, "summarize", "# IDENTITY\n\nYou summarize.\n\n{{include:style_guide#output instructions}}\n\n# INPUT\n")
+
+	pattern, err := entity.GetApplyVariables("summarize", map[string]string{"tone": "dry"}, "text")
+	require.NoError(t, err)
+	assert.Equal(t, "# IDENTITY\n\nYou summarize.\n\n# OUTPUT INSTRUCTIONS\n\n- Write in a dry tone.\n- Use Markdown.\n\n# INPUT\ntext", pattern.Pattern)
+
+	createTestPattern(t, entity, "whole", "{{include:style_guide}}")
+	pattern, err = entity.GetWithoutVariables("whole", "")
+	require.NoError(t, err)
+	assert.Contains(t, pattern.Pattern, "## Details")
+	assert.NotContains(t, pattern.Pattern, "description:")
+
+	createTestPattern(t, entity, "missing_section", "{{include:style_guide#EXAMPLES}}")
+	_, err = entity.GetWithoutVariables("missing_section", "")
+	assert.ErrorContains(t, err, `no section "EXAMPLES"`)
+
+	createTestPattern(t, entity, "a", "{{include:b}}")
+	createTestPattern(t, entity, "b", "{{include:a#IDENTITY}}")
+	_, err = entity.GetWithoutVariables("a", "")
+	assert.ErrorContains(t, err, "cycle a -> b -> a")
+}
+
+func TestPatternsEntity_Extends(t *testing.T) {
+	entity, cleanup := setupTestPatternsEntity(t)
+	defer cleanup()
+	createTestPattern(t, entity, "style_guide", styleGuide)
+	createTestPattern(t, entity, "team_style", "---\nextends: style_guide\ndescription: Team style\nvariables:\n  - name: team\n    required: true\n---\n# STEPS\n\n- Ask the {{team}} team.\n\n# EXAMPLES\n\n- One.\n")
+
+	pattern, err := entity.GetApplyVariables("team_style", map[string]string{"team": "docs"}, "text")
+	require.NoError(t, err)
+	assert.Equal(t, "# IDENTITY\n\nYou are a writer.\n\n# STEPS\n\n- Ask the docs team.\n\n# OUTPUT INSTRUCTIONS\n\n- Write in a friendly tone.\n- Use Markdown.\n# EXAMPLES\n\n- One.\ntext", pattern.Pattern)
+	assert.Equal(t, "Team style", pattern.Description)
+	assert.Equal(t, PatternFormatMarkdown, pattern.Metadata.Format)
+
+	variables, err := entity.GetVariables("team_style")
+	require.NoError(t, err)
+	assert.Equal(t, []PatternVariable{{Name: "tone", Default: "friendly"}, {Name: "team", Required: true}}, variables)
+
+	createTestPattern(t, entity, "loop", "---\nextends: loop\n---\n# IDENTITY\n")
+	_, err = entity.GetWithoutVariables("loop", "")
+	assert.ErrorContains(t, err, "cycle loop -> loop")
+}
+
+func TestExtendSections(t *testing.T) {
+	base := "Intro\n# A\na\n## A1\na1\n# B\nb\n"
+	assert.Equal(t, "Intro\n# A\nnew a\n# B\nb\n", extendSections(base, "# A\nnew a\n"))
+	assert.Equal(t, "Intro\n# A\na\n## A1\nnew a1\n# B\nb\n", extendSections(base, "## A1\nnew a1\n"))
+	assert.Equal(t, "Hello\n# A\na\n## A1\na1\n# B\nb\n", extendSections(base, "Hello"))
+	assert.Equal(t, "Intro\n# A\na\n## A1\na1\n# B\nb\n# C\nc\n", extendSections(base, "# C\nc"))
+
+	sections := markdownSections("# A\n```\n# not a heading\n```\n#B\n## C\n")
+	require.Len(t, sections, 2)
+	assert.Equal(t, "C", sections[1].Title)
+	assert.Equal(t, 2, sections[1].Level)
+}
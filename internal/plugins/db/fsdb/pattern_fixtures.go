@@ -0,0 +1,204 @@
+package fsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/danielmiessler/fabric/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// PatternTestsDir is the directory of a pattern holding its test fixtures.
+const PatternTestsDir = "tests"
+
+// GoldenSuffix ends the name of the file that records the answer to a fixture,
+// next to it.
+const GoldenSuffix = ".golden"
+
+// PatternFixture is a test of a pattern, read from tests/<name>.yaml in its
+// directory:
+//
+//	description: Summarize a short article
+//	input: |
+//	  The article ...
+//	variables:
+//	  lang_code: en
+//	mock_response: "# SUMMARY\n..."
+//	assertions:
+//	  - contains: "# SUMMARY"
+//	  - regex: "(?m)^- "
+//	  - max_length: 2000
+//	  - json_schema: {type: object, required: [summary]}
+//	  - rubric: The summary names the main idea of the article
+type PatternFixture struct {
+	// Name is the name of the fixture file without .yaml
+	Name        string            `yaml:"-"`
+	Description string            `yaml:"description"`
+	Input       string            `yaml:"input"`
+	Variables   map[string]string `yaml:"variables"`
+	// MockResponse is the answer of the mock vendor
+	MockResponse string             `yaml:"mock_response"`
+	Assertions   []FixtureAssertion `yaml:"assertions"`
+
+	file string
+}
+
+// FixtureAssertion checks one thing about the answer to a fixture; exactly
+// one of its fields is set.
+type FixtureAssertion struct {
+	Contains string `yaml:"contains"`
+	Regex    string `yaml:"regex"`
+	// JSONSchema is a JSON Schema written in YAML
+	JSONSchema map[string]any `yaml:"json_schema"`
+	// MaxLength is the most characters the answer may have
+	MaxLength int `yaml:"max_length"`
+	// Rubric is judged by a model
+	Rubric string `yaml:"rubric"`
+
+	regex  *regexp.Regexp
+	schema *domain.Schema
+}
+
+// File returns the path the fixture was read from.
+func (o *PatternFixture) File() string {
+	return o.file
+}
+
+// GoldenFile returns the path of the file recording the answer to the fixture.
+func (o *PatternFixture) GoldenFile() string {
+	return strings.TrimSuffix(o.file, filepath.Ext(o.file)) + GoldenSuffix
+}
+
+// LoadPatternFixture reads and validates a fixture file.
+func LoadPatternFixture(path string) (ret *PatternFixture, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return
+	}
+	ret = &PatternFixture{Name: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), file: path}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err = decoder.Decode(ret); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	if err = ret.validate(); err != nil {
+		return nil, fmt.Errorf("invalid fixture %s: %w", path, err)
+	}
+	return
+}
+
+func (o *PatternFixture) validate() (err error) {
+	if len(o.Assertions) == 0 {
+		return errors.New("it has no assertions")
+	}
+	for i := range o.Assertions {
+		if err = o.Assertions[i].compile(o.Name); err != nil {
+			return fmt.Errorf("assertion %d: %w", i+1, err)
+		}
+	}
+	return
+}
+
+func (o *FixtureAssertion) compile(name string) (err error) {
+	set := 0
+	for _, isSet := range []bool{o.Contains != "", o.Regex != "", o.JSONSchema != nil, o.MaxLength != 0, o.Rubric != ""} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("it needs exactly one of contains, regex, json_schema, max_length and rubric")
+	}
+	switch {
+	case o.Regex != "":
+		o.regex, err = regexp.Compile(o.Regex)
+	case o.JSONSchema != nil:
+		var data []byte
+		if data, err = json.Marshal(o.JSONSchema); err == nil {
+			o.schema, err = domain.ParseSchema(name, data)
+		}
+	case o.MaxLength < 0:
+		err = fmt.Errorf("max_length %d is negative", o.MaxLength)
+	}
+	return
+}
+
+// Kind names the field of the assertion that is set.
+func (o *FixtureAssertion) Kind() string {
+	switch {
+	case o.Contains != "":
+		return "contains"
+	case o.Regex != "":
+		return "regex"
+	case o.JSONSchema != nil:
+		return "json_schema"
+	case o.MaxLength != 0:
+		return "max_length"
+	default:
+		return "rubric"
+	}
+}
+
+// Check tells whether output passes the assertion. Rubrics need a model and
+// are left to the caller.
+func (o *FixtureAssertion) Check(output string) (err error) {
+	switch {
+	case o.Contains != "":
+		if !strings.Contains(output, o.Contains) {
+			err = fmt.Errorf("the answer does not contain %q", o.Contains)
+		}
+	case o.regex != nil:
+		if !o.regex.MatchString(output) {
+			err = fmt.Errorf("the answer does not match %s", o.Regex)
+		}
+	case o.schema != nil:
+		if _, invalid := o.schema.Validate(output); invalid != nil {
+			err = fmt.Errorf("the answer does not match the JSON schema: %w", invalid)
+		}
+	case o.MaxLength != 0:
+		if length := len([]rune(output)); length > o.MaxLength {
+			err = fmt.Errorf("the answer has %d characters, more than %d", length, o.MaxLength)
+		}
+	}
+	return
+}
+
+// PatternDir returns the directory of a pattern, the custom one when it
+// replaces the built-in one.
+func (o *PatternsEntity) PatternDir(name string) string {
+	if o.CustomPatternsDir != "" {
+		customDir := filepath.Join(o.CustomPatternsDir, name)
+		if _, err := os.Stat(filepath.Join(customDir, o.SystemPatternFile)); err == nil {
+			return customDir
+		}
+	}
+	return filepath.Join(o.Dir, name)
+}
+
+// GetFixtures returns the test fixtures of a pattern in the order of their names.
+func (o *PatternsEntity) GetFixtures(name string) (ret []*PatternFixture, err error) {
+	dir := filepath.Join(o.PatternDir(name), PatternTestsDir)
+	var files []string
+	if files, err = filepath.Glob(filepath.Join(dir, "*.yaml")); err != nil {
+		return
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("pattern %s has no test fixtures in %s", name, dir)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		var fixture *PatternFixture
+		if fixture, err = LoadPatternFixture(file); err != nil {
+			return nil, err
+		}
+		ret = append(ret, fixture)
+	}
+	return
+}
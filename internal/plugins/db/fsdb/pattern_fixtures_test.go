@@ -0,0 +1,50 @@
+package fsdb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatternsEntity_GetFixtures(t *testing.T) {
+	entity, cleanup := setupTestPatternsEntity(t)
+	defer cleanup()
+	createTestPattern(t, entity, "summarize", "Summarize")
+
+	_, err := entity.GetFixtures("summarize")
+	assert.ErrorContains(t, err, "has no test fixtures")
+
+	dir := filepath.Join(entity.Dir, "summarize", PatternTestsDir)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "short.yaml"),
+		[]byte("input: text\nassertions:\n  - contains: SUMMARY\n  - max_length: 10\n  - json_schema: {type: object}\n"), 0644))
+
+	fixtures, err := entity.GetFixtures("summarize")
+	require.NoError(t, err)
+	require.Len(t, fixtures, 1)
+	assert.Equal(t, "short", fixtures[0].Name)
+	assert.Equal(t, filepath.Join(dir, "short"+GoldenSuffix), fixtures[0].GoldenFile())
+
+	assertions := fixtures[0].Assertions
+	assert.NoError(t, assertions[0].Check("# SUMMARY"))
+	assert.Error(t, assertions[0].Check("# IDEAS"))
+	assert.NoError(t, assertions[1].Check("ten chars!"))
+	assert.ErrorContains(t, assertions[1].Check("eleven chars"), "12 characters")
+	assert.NoError(t, assertions[2].Check("```json\n{}\n```"))
+	assert.Error(t, assertions[2].Check("[]"))
+
+	for _, invalid := range []string{
+		"input: text\n",
+		"input: text\nassertions:\n  - contains: a\n    regex: b\n",
+		"input: text\nassertions:\n  - regex: '('\n",
+		"input: text\nassertions:\n  - max_length: -1\n",
+		"input: text\nexpect: a\nassertions:\n  - contains: a\n",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "short.yaml"), []byte(invalid), 0644))
+		_, err = entity.GetFixtures("summarize")
+		assert.Error(t, err, invalid)
+	}
+}
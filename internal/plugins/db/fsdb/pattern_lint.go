@@ -0,0 +1,179 @@
+package fsdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/danielmiessler/fabric/internal/plugins/template"
+)
+
+// The severities of lint issues. Errors make a pattern fail when it runs,
+// warnings point at what is probably a mistake.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintIssue is a problem found in the system file of a pattern. Line and
+// Column are counted from 1; issues of the whole file have no line.
+type LintIssue struct {
+	Pattern  string
+	File     string
+	Line     int
+	Column   int
+	Severity string
+	Message  string
+}
+
+// String formats the issue as file:line:column: severity: message.
+func (o LintIssue) String() string {
+	position := o.File
+	if o.Line > 0 {
+		position = fmt.Sprintf("%s:%d:%d", o.File, o.Line, o.Column)
+	}
+	return fmt.Sprintf("%s: %s: %s", position, o.Severity, o.Message)
+}
+
+// PatternLinter finds the mistakes of patterns that would otherwise only show
+// when they run.
+type PatternLinter struct {
+	patterns *PatternsEntity
+
+	// CheckExtension tells whether an extension call can run; nil leaves them unchecked
+	CheckExtension func(name, operation string) error
+	// Shadowed also reports the custom patterns that replace built-in ones
+	Shadowed bool
+}
+
+func NewPatternLinter(patterns *PatternsEntity) *PatternLinter {
+	return &PatternLinter{patterns: patterns}
+}
+
+// Lint checks the patterns of names, all of them when names is empty, and
+// returns the issues in the order of the patterns and of their lines.
+func (o *PatternLinter) Lint(names []string) (ret []LintIssue, err error) {
+	if len(names) == 0 {
+		if names, err = o.patterns.GetNames(); err != nil {
+			return
+		}
+	}
+	for _, name := range names {
+		var issues []LintIssue
+		if issues, err = o.lintPattern(name); err != nil {
+			return
+		}
+		slices.SortStableFunc(issues, func(a, b LintIssue) int { return a.Line - b.Line })
+		ret = append(ret, issues...)
+	}
+	return
+}
+
+func (o *PatternLinter) lintPattern(name string) (ret []LintIssue, err error) {
+	file := filepath.Join(o.patterns.PatternDir(name), o.patterns.SystemPatternFile)
+	var data []byte
+	if data, err = os.ReadFile(file); err != nil {
+		return nil, fmt.Errorf("pattern %s: %w", name, err)
+	}
+	report := func(line, column int, severity, format string, args ...any) {
+		ret = append(ret, LintIssue{Pattern: name, File: file, Line: line, Column: column, Severity: severity,
+			Message: fmt.Sprintf(format, args...)})
+	}
+
+	content := string(data)
+	metadata, body, parseErr := ParseFrontMatter(content)
+	if parseErr != nil {
+		report(1, 1, LintError, "%v", parseErr)
+		return
+	}
+	header := content[:len(content)-len(body)]
+	offset := strings.Count(header, "\n")
+
+	if o.Shadowed && o.patterns.CustomPatternsDir != "" && strings.HasPrefix(file, o.patterns.CustomPatternsDir) {
+		if _, statErr := os.Stat(filepath.Join(o.patterns.Dir, name, o.patterns.SystemPatternFile)); statErr == nil {
+			report(0, 0, LintWarning, "custom pattern replaces the built-in pattern %s", name)
+		}
+	}
+
+	if metadata != nil && metadata.Extends != "" {
+		if base, baseErr := o.patterns.loadComposed(metadata.Extends, []string{name}); baseErr != nil {
+			report(headerLine(header, "extends:"), 1, LintError, "extends %s: %v", metadata.Extends, baseErr)
+		} else {
+			metadata = metadata.extend(base.Metadata)
+		}
+	}
+	declared := map[string]bool{}
+	if metadata != nil {
+		for _, variable := range metadata.Variables {
+			declared[variable.Name] = true
+		}
+	}
+
+	for _, token := range template.Tokenize(body) {
+		line, column := token.Line+offset, token.Column
+		switch token.Kind {
+		case template.TokenVariable:
+			if !template.IsVariableName(token.Name) {
+				report(line, column, LintError, "{{%s}} is not a variable name, it fails unless given with -v", token.Raw)
+			} else if !token.HasDefault && !declared[token.Name] {
+				report(line, column, LintWarning, "variable %s is not declared and has no default, it has to be given with -v", token.Name)
+			}
+		case template.TokenPlugin:
+			if token.Malformed {
+				report(line, column, LintError, "malformed plugin call {{%s}}, use {{plugin:namespace:operation:value}}", token.Raw)
+			} else if !template.IsPluginNamespace(token.Name) {
+				report(line, column, LintError, "unknown plugin namespace %s, use one of %s", token.Name,
+					strings.Join(template.PluginNamespaces(), ", "))
+			}
+		case template.TokenExtension:
+			if token.Malformed {
+				report(line, column, LintError, "malformed extension call {{%s}}, use {{ext:name:operation:value}}", token.Raw)
+			} else if o.CheckExtension != nil {
+				if extErr := o.CheckExtension(token.Name, token.Operation); extErr != nil {
+					report(line, column, LintError, "%v", extErr)
+				}
+			}
+		case template.TokenInclude:
+			if token.Malformed {
+				report(line, column, LintError, "malformed include {{%s}}, use {{include:pattern}} or {{include:pattern#section}}", token.Raw)
+			} else if included, includeErr := o.patterns.loadComposed(token.Name, []string{name}); includeErr != nil {
+				report(line, column, LintError, "include %s: %v", token.Name, includeErr)
+			} else if _, found := findSection(included.Pattern, token.Value); token.Value != "" && !found {
+				report(line, column, LintError, "include %s: no section %q", token.Name, token.Value)
+			}
+		}
+	}
+
+	// The pattern as it runs, with its base and includes, is checked as a whole
+	composed, composeErr := o.patterns.loadPattern(name)
+	if composeErr != nil {
+		return
+	}
+	tokens := template.Tokenize(composed.Pattern)
+	if !slices.ContainsFunc(tokens, func(token template.Token) bool { return token.Kind == template.TokenInput }) {
+		report(0, 0, LintWarning, "no {{input}}, the input is appended at the end")
+	}
+	if composed.Metadata != nil {
+		for _, variable := range composed.Metadata.Variables {
+			if !slices.ContainsFunc(tokens, func(token template.Token) bool {
+				return token.Kind == template.TokenVariable && token.Name == variable.Name
+			}) {
+				report(headerLine(header, variable.Name), 1, LintWarning, "variable %s is declared but not used", variable.Name)
+			}
+		}
+	}
+	return
+}
+
+// headerLine returns the line of the front matter mentioning text, the first
+// one when none does.
+func headerLine(header, text string) int {
+	for i, line := range strings.Split(header, "\n") {
+		if i > 0 && strings.Contains(line, text) {
+			return i + 1
+		}
+	}
+	return 1
+}
@@ -0,0 +1,86 @@
+package fsdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lintMessages(issues []LintIssue) (ret []string) {
+	for _, issue := range issues {
+		ret = append(ret, issue.String())
+	}
+	return
+}
+
+func TestPatternLinter_Lint(t *testing.T) {
+	entity, cleanup := setupTestPatternsEntity(t)
+	defer cleanup()
+	createTestPattern(t, entity, "style_guide", styleGuide)
+	createTestPattern(t, entity, "broken", "---\n"+
+		"description: Broken\n"+
+		"---\n"+
+		"\n"+
+		"\n"+
+		"Write in a {{lang}} way, {{tone|short}}.\n"+
+		"{{plugin:math:add:1}} {{plugin:text}}\n"+
+		"{{ext:word:count:x}} {{include:style_guide#EXAMPLES}} {{include:missing}}\n"+
+		"{{not a name}}\n")
+	createTestPattern(t, entity, "clean", "{{include:style_guide#STEPS}}\n\n{{input}}")
+	createTestPattern(t, entity, "quiet", "---\nvariables:\n  - name: audience\n  - name: unused\n---\nWrite for {{audience}}.\n")
+
+	linter := NewPatternLinter(entity)
+	linter.CheckExtension = func(name, operation string) error {
+		return errors.New("extension " + name + " is not registered")
+	}
+	issues, err := linter.Lint([]string{"broken", "clean", "quiet"})
+	require.NoError(t, err)
+
+	file := filepath.Join(entity.Dir, "broken", "system.md")
+	messages := lintMessages(issues)
+	require.Len(t, messages, 9, messages)
+	assert.Equal(t, file+":6:12: warning: variable lang is not declared and has no default, it has to be given with -v", messages[0])
+	assert.Contains(t, messages[1], ":7:1: error: unknown plugin namespace math")
+	assert.Contains(t, messages[2], ":7:23: error: malformed plugin call")
+	assert.Contains(t, messages[3], ":8:1: error: extension word is not registered")
+	assert.Contains(t, messages[4], `:8:22: error: include style_guide: no section "EXAMPLES"`)
+	assert.Contains(t, messages[5], "error: include missing:")
+	assert.Contains(t, messages[6], ":9:1: error: {{not a name}} is not a variable name")
+
+	quiet := filepath.Join(entity.Dir, "quiet", "system.md")
+	assert.Equal(t, quiet+": warning: no {{input}}, the input is appended at the end", messages[7])
+	assert.Equal(t, quiet+":4:1: warning: variable unused is declared but not used", messages[8])
+}
+
+func TestPatternLinter_FrontMatterAndShadowing(t *testing.T) {
+	entity, cleanup := setupTestPatternsEntity(t)
+	defer cleanup()
+	entity.CustomPatternsDir = t.TempDir()
+	createTestPattern(t, entity, "summarize", "Summarize {{input}}")
+	createTestPattern(t, entity, "bad_header", "---\nvariables: [\n---\n{{input}}")
+
+	customDir := filepath.Join(entity.CustomPatternsDir, "summarize")
+	require.NoError(t, os.MkdirAll(customDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(customDir, "system.md"), []byte("My summary of {{input}}"), 0644))
+
+	linter := NewPatternLinter(entity)
+	issues, err := linter.Lint(nil)
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, LintError, issues[0].Severity)
+	assert.Equal(t, 1, issues[0].Line)
+
+	linter.Shadowed = true
+	issues, err = linter.Lint([]string{"summarize"})
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, LintWarning, issues[0].Severity)
+	assert.Contains(t, issues[0].Message, "replaces the built-in pattern summarize")
+
+	_, err = linter.Lint([]string{"nonexistent"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,208 @@
+package fsdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// The output formats a pattern can declare. A JSON pattern asks the vendor for
+// JSON; its answers are only checked when it has a schema.
+const (
+	PatternFormatMarkdown = "markdown"
+	PatternFormatText     = "text"
+	PatternFormatJSON     = "json"
+)
+
+const frontMatterDelimiter = "---"
+
+// PatternMetadata is the optional YAML front matter of a system file, between
+// two lines of three dashes at its very top:
+//
+//	---
+//	description: Extract the main ideas of a text
+//	tags: [analysis, writing]
+//	variables:
+//	  - name: lang_code
+//	    description: Language of the answer
+//	    default: en
+//	  - name: audience
+//	    required: true
+//	model: Anthropic|claude-sonnet-4-5
+//	temperature: 0.3
+//	strategy: cot
+//	format: markdown
+//	extends: style_guide
+//	---
+type PatternMetadata struct {
+	Description string            `yaml:"description" json:"description,omitempty"`
+	Tags        []string          `yaml:"tags" json:"tags,omitempty"`
+	Variables   []PatternVariable `yaml:"variables" json:"variables,omitempty"`
+	// Vendor and Model are used when neither --model nor FABRIC_MODEL_<PATTERN> choose one.
+	// Model may also be given as "Vendor|model".
+	Vendor string `yaml:"vendor" json:"vendor,omitempty"`
+	Model  string `yaml:"model" json:"model,omitempty"`
+	// Fallback lists the "Vendor|model" entries tried in order when the model fails
+	Fallback []string `yaml:"fallback" json:"fallback,omitempty"`
+	// Temperature is used unless the request sets another one than the default
+	Temperature *float64 `yaml:"temperature" json:"temperature,omitempty"`
+	// Strategy is used when the request names none
+	Strategy string `yaml:"strategy" json:"strategy,omitempty"`
+	// Format is markdown, text or json
+	Format string `yaml:"format" json:"format,omitempty"`
+	// Extends names a base pattern whose sections the system file overrides by
+	// title; the base fills in the fields of the front matter left out
+	Extends string `yaml:"extends" json:"extends,omitempty"`
+}
+
+// The types of pattern variables. Variables without a type are strings.
+const (
+	VariableTypeString = "string"
+	VariableTypeInt    = "int"
+	VariableTypeBool   = "bool"
+	VariableTypeEnum   = "enum"
+)
+
+var variableTypes = []string{VariableTypeString, VariableTypeInt, VariableTypeBool, VariableTypeEnum}
+
+// PatternVariable is a variable a pattern takes with -v.
+type PatternVariable struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	// Type is string, int, bool or enum
+	Type string `yaml:"type" json:"type,omitempty"`
+	// Values are the values an enum takes
+	Values []string `yaml:"values" json:"values,omitempty"`
+	// Required variables have to be given, the others fall back to Default
+	Required bool   `yaml:"required" json:"required,omitempty"`
+	Default  string `yaml:"default" json:"default,omitempty"`
+}
+
+// Check tells whether value is one of the variable's type.
+func (o *PatternVariable) Check(value string) (err error) {
+	switch o.Type {
+	case "", VariableTypeString:
+	case VariableTypeInt:
+		if _, err = strconv.Atoi(value); err != nil {
+			err = fmt.Errorf("variable %s takes an integer, not %q", o.Name, value)
+		}
+	case VariableTypeBool:
+		if _, err = strconv.ParseBool(value); err != nil {
+			err = fmt.Errorf("variable %s takes true or false, not %q", o.Name, value)
+		}
+	case VariableTypeEnum:
+		if !slices.Contains(o.Values, value) {
+			err = fmt.Errorf("variable %s takes one of %s, not %q", o.Name, strings.Join(o.Values, ", "), value)
+		}
+	}
+	return
+}
+
+// PreferredModel returns the vendor and model the pattern asks for, if any.
+func (o *PatternMetadata) PreferredModel() (vendor, model string) {
+	if before, after, found := strings.Cut(o.Model, "|"); found {
+		return before, after
+	}
+	return o.Vendor, o.Model
+}
+
+// Validate checks the fields that take a limited set of values.
+func (o *PatternMetadata) Validate() error {
+	if o.Temperature != nil && (*o.Temperature < 0 || *o.Temperature > 2) {
+		return fmt.Errorf("temperature %v is not between 0 and 2", *o.Temperature)
+	}
+	if o.Format != "" && !slices.Contains([]string{PatternFormatMarkdown, PatternFormatText, PatternFormatJSON}, o.Format) {
+		return fmt.Errorf("format %q is not one of markdown, text and json", o.Format)
+	}
+	seen := map[string]bool{}
+	for _, variable := range o.Variables {
+		if variable.Name == "" {
+			return errors.New("a variable needs a name")
+		}
+		if seen[variable.Name] {
+			return fmt.Errorf("variable %s is declared twice", variable.Name)
+		}
+		seen[variable.Name] = true
+		if variable.Required && variable.Default != "" {
+			return fmt.Errorf("variable %s is required and cannot have a default", variable.Name)
+		}
+		if variable.Type != "" && !slices.Contains(variableTypes, variable.Type) {
+			return fmt.Errorf("variable %s has the unknown type %s", variable.Name, variable.Type)
+		}
+		if (variable.Type == VariableTypeEnum) != (len(variable.Values) > 0) {
+			return fmt.Errorf("variable %s needs values when it is an enum, and only then", variable.Name)
+		}
+		if variable.Default != "" {
+			if err := variable.Check(variable.Default); err != nil {
+				return fmt.Errorf("invalid default: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveVariables checks the given variables against their declarations and
+// returns them with the defaults of the declared variables that are not given.
+func (o *PatternMetadata) resolveVariables(variables map[string]string) (ret map[string]string, err error) {
+	ret = make(map[string]string, len(variables)+len(o.Variables))
+	for name, value := range variables {
+		ret[name] = value
+	}
+	for _, variable := range o.Variables {
+		if value, ok := ret[variable.Name]; ok {
+			if err = variable.Check(value); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if variable.Required {
+			return nil, fmt.Errorf("missing required variable %s, set it with -v=%s:value", variable.Name, variable.Name)
+		}
+		ret[variable.Name] = variable.Default
+	}
+	return
+}
+
+// ParseFrontMatter splits the front matter off the content of a system file.
+// Content without front matter is returned as it is, with no metadata.
+func ParseFrontMatter(content string) (metadata *PatternMetadata, body string, err error) {
+	rest, ok := cutLine(content, frontMatterDelimiter)
+	if !ok {
+		return nil, content, nil
+	}
+	var header strings.Builder
+	for rest != "" {
+		line, next, _ := strings.Cut(rest, "\n")
+		if strings.TrimRight(line, "\r") == frontMatterDelimiter {
+			metadata = &PatternMetadata{}
+			decoder := yaml.NewDecoder(bytes.NewReader([]byte(header.String())))
+			decoder.KnownFields(true)
+			// Front matter without fields decodes to io.EOF
+			if err = decoder.Decode(metadata); err != nil && err != io.EOF {
+				return nil, content, fmt.Errorf("invalid front matter: %w", err)
+			}
+			if err = metadata.Validate(); err != nil {
+				return nil, content, fmt.Errorf("invalid front matter: %w", err)
+			}
+			return metadata, next, nil
+		}
+		header.WriteString(line + "\n")
+		rest = next
+	}
+	return nil, content, errors.New("invalid front matter: it is not closed by a line of ---")
+}
+
+// cutLine returns what follows the first line of content when that line is line.
+func cutLine(content, line string) (rest string, ok bool) {
+	first, rest, found := strings.Cut(content, "\n")
+	if !found || strings.TrimRight(first, "\r") != line {
+		return content, false
+	}
+	return rest, true
+}
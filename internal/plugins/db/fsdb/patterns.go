@@ -7,11 +7,16 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/danielmiessler/fabric/internal/domain"
 	"github.com/danielmiessler/fabric/internal/i18n"
 	"github.com/danielmiessler/fabric/internal/plugins/template"
 	"github.com/danielmiessler/fabric/internal/util"
 )
 
+// SchemaFile is the JSON Schema a pattern's response has to match, stored
+// next to its system file.
+const SchemaFile = "schema.json"
+
 type PatternsEntity struct {
 	*StorageEntity
 	SystemPatternFile      string
@@ -24,6 +29,29 @@ type Pattern struct {
 	Name        string
 	Description string
 	Pattern     string
+	// Metadata is the front matter of the system file, nil when it has none
+	Metadata *PatternMetadata `json:",omitempty"`
+}
+
+// newPattern splits the front matter off the content of a system file.
+func newPattern(name, content string) (ret *Pattern, err error) {
+	ret = &Pattern{Name: name}
+	if ret.Metadata, ret.Pattern, err = ParseFrontMatter(content); err != nil {
+		return nil, fmt.Errorf("pattern %s: %w", name, err)
+	}
+	if ret.Metadata != nil {
+		ret.Description = ret.Metadata.Description
+	}
+	return
+}
+
+// GetMetadata returns the front matter of a pattern, nil when it has none.
+func (o *PatternsEntity) GetMetadata(source string) (ret *PatternMetadata, err error) {
+	var pattern *Pattern
+	if pattern, err = o.loadPattern(source); err != nil {
+		return
+	}
+	return pattern.Metadata, nil
 }
 
 // GetApplyVariables main entry point for getting patterns from any source
@@ -50,7 +78,17 @@ func (o *PatternsEntity) GetWithoutVariables(source, input string) (pattern *Pat
 	return
 }
 
+// loadPattern reads a pattern and resolves the pattern it extends and those
+// it includes.
 func (o *PatternsEntity) loadPattern(source string) (pattern *Pattern, err error) {
+	if pattern, err = o.readPattern(source); err != nil {
+		return
+	}
+	err = o.compose(pattern, []string{source})
+	return
+}
+
+func (o *PatternsEntity) readPattern(source string) (pattern *Pattern, err error) {
 	// Determine if this is a file path
 	isFilePath := strings.HasPrefix(source, "\\") ||
 		strings.HasPrefix(source, "/") ||
@@ -95,6 +133,11 @@ func (o *PatternsEntity) applyVariables(
 
 	o.ensureInput(pattern)
 
+	// Variables are checked before the template runs any plugin or extension
+	if variables, err = o.resolveVariables(pattern, variables); err != nil {
+		return
+	}
+
 	// Temporarily replace {{input}} with a sentinel token to protect it
 	// from recursive variable resolution
 	withSentinel := strings.ReplaceAll(pattern.Pattern, "{{input}}", template.InputSentinel)
@@ -112,17 +155,68 @@ func (o *PatternsEntity) applyVariables(
 	return
 }
 
+// resolveVariables checks variables against what the pattern declares and
+// uses, and returns them with the defaults of the declared ones.
+func (o *PatternsEntity) resolveVariables(pattern *Pattern, variables map[string]string) (ret map[string]string, err error) {
+	ret = variables
+	if pattern.Metadata != nil {
+		if ret, err = pattern.Metadata.resolveVariables(variables); err != nil {
+			return nil, fmt.Errorf("pattern %s: %w", pattern.Name, err)
+		}
+	}
+	for _, token := range template.Tokenize(pattern.Pattern) {
+		if token.Kind != template.TokenVariable || token.HasDefault {
+			continue
+		}
+		if _, ok := ret[token.Name]; !ok {
+			return nil, fmt.Errorf("pattern %s: missing required variable: %s", pattern.Name, token.Name)
+		}
+	}
+	return
+}
+
+// CheckVariables tells whether the pattern can be applied with variables,
+// without applying it.
+func (o *PatternsEntity) CheckVariables(source string, variables map[string]string) (err error) {
+	var pattern *Pattern
+	if pattern, err = o.loadPattern(source); err != nil {
+		return
+	}
+	_, err = o.resolveVariables(pattern, variables)
+	return
+}
+
+// GetVariables returns the variables a pattern takes: those its front matter
+// declares, then the others its template uses, which are strings.
+func (o *PatternsEntity) GetVariables(source string) (ret []PatternVariable, err error) {
+	var pattern *Pattern
+	if pattern, err = o.loadPattern(source); err != nil {
+		return
+	}
+	seen := map[string]bool{}
+	if pattern.Metadata != nil {
+		for _, variable := range pattern.Metadata.Variables {
+			ret = append(ret, variable)
+			seen[variable.Name] = true
+		}
+	}
+	for _, token := range template.Tokenize(pattern.Pattern) {
+		if token.Kind != template.TokenVariable || seen[token.Name] {
+			continue
+		}
+		seen[token.Name] = true
+		ret = append(ret, PatternVariable{Name: token.Name, Required: !token.HasDefault, Default: token.Default})
+	}
+	return
+}
+
 // retrieves a pattern from the database by name
 func (o *PatternsEntity) getFromDB(name string) (ret *Pattern, err error) {
 	// First check custom patterns directory if it exists
 	if o.CustomPatternsDir != "" {
 		customPatternPath := filepath.Join(o.CustomPatternsDir, name, o.SystemPatternFile)
 		if pattern, customErr := os.ReadFile(customPatternPath); customErr == nil {
-			ret = &Pattern{
-				Name:    name,
-				Pattern: string(pattern),
-			}
-			return ret, nil
+			return newPattern(name, string(pattern))
 		}
 	}
 
@@ -143,10 +237,37 @@ func (o *PatternsEntity) getFromDB(name string) (ret *Pattern, err error) {
 		return nil, fmt.Errorf(i18n.T("pattern_not_found_list_available"), name)
 	}
 
-	patternStr := string(pattern)
-	ret = &Pattern{
-		Name:    name,
-		Pattern: patternStr,
+	return newPattern(name, string(pattern))
+}
+
+// GetSchema returns the JSON Schema stored next to the system file of a
+// pattern, or nil when the pattern has none. For patterns loaded from a file
+// the schema is looked up in the directory of that file.
+func (o *PatternsEntity) GetSchema(source string) (ret *domain.Schema, err error) {
+	var dir string
+	if strings.HasPrefix(source, "\\") || strings.HasPrefix(source, "/") ||
+		strings.HasPrefix(source, "~") || strings.HasPrefix(source, ".") {
+		var absPath string
+		if absPath, err = util.GetAbsolutePath(source); err != nil {
+			return nil, fmt.Errorf("could not resolve file path: %v", err)
+		}
+		dir = filepath.Dir(absPath)
+	} else {
+		// A custom pattern replaces the built-in one along with its schema
+		dir = o.PatternDir(source)
+	}
+
+	schemaPath := filepath.Join(dir, SchemaFile)
+	var data []byte
+	if data, err = os.ReadFile(schemaPath); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+	// The schema is named after the pattern
+	if ret, err = domain.ParseSchema(filepath.Base(dir), data); err != nil {
+		err = fmt.Errorf("invalid schema %s: %w", schemaPath, err)
 	}
 	return
 }
@@ -185,11 +306,7 @@ func (o *PatternsEntity) getFromFile(pathStr string) (pattern *Pattern, err erro
 		err = fmt.Errorf("could not read pattern file %s: %v", pathStr, err)
 		return
 	}
-	pattern = &Pattern{
-		Name:    pathStr,
-		Pattern: string(content),
-	}
-	return
+	return newPattern(pathStr, string(content))
 }
 
 // GetNames overrides StorageEntity.GetNames to include custom patterns directory
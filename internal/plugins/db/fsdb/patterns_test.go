@@ -298,3 +298,89 @@ func TestPatternsEntity_CustomPatternsEmpty(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, "Main pattern content", pattern.Pattern)
 }
+
+func TestParseFrontMatter(t *testing.T) {
+	metadata, body, err := ParseFrontMatter("---\ndescription: Summarize\ntags: [writing]\nmodel: Anthropic|claude-sonnet-4-5\n---\n# IDENTITY\n")
+	require.NoError(t, err)
+	assert.Equal(t, "# IDENTITY\n", body)
+	assert.Equal(t, "Summarize", metadata.Description)
+	assert.Equal(t, []string{"writing"}, metadata.Tags)
+	vendor, model := metadata.PreferredModel()
+	assert.Equal(t, "Anthropic", vendor)
+	assert.Equal(t, "claude-sonnet-4-5", model)
+
+	metadata, body, err = ParseFrontMatter("# IDENTITY\n---\n")
+	require.NoError(t, err)
+	assert.Nil(t, metadata)
+	assert.Equal(t, "# IDENTITY\n---\n", body)
+
+	for _, invalid := range []string{
+		"---\ndescription: never closed\n",
+		"---\nunknown: field\n---\n",
+		"---\nformat: xml\n---\n",
+		"---\ntemperature: 3\n---\n",
+		"---\nvariables:\n  - name: a\n  - name: a\n---\n",
+		"---\nvariables:\n  - name: a\n    required: true\n    default: b\n---\n",
+		"---\nvariables:\n  - name: a\n    type: float\n---\n",
+		"---\nvariables:\n  - name: a\n    type: enum\n---\n",
+		"---\nvariables:\n  - name: a\n    values: [b]\n---\n",
+		"---\nvariables:\n  - name: a\n    type: int\n    default: many\n---\n",
+	} {
+		_, _, err = ParseFrontMatter(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestPatternFrontMatterVariables(t *testing.T) {
+	entity, cleanup := setupTestPatternsEntity(t)
+	defer cleanup()
+	createTestPattern(t, entity, "translate", "---\ndescription: Translate a text\nvariables:\n  - name: lang\n    default: de\n  - name: tone\n    required: true\n---\nTranslate to {{lang}} in a {{tone}} tone.\n{{input}}")
+
+	pattern, err := entity.GetApplyVariables("translate", map[string]string{"tone": "formal"}, "Hello")
+	require.NoError(t, err)
+	assert.Equal(t, "Translate a text", pattern.Description)
+	assert.Equal(t, "Translate to de in a formal tone.\nHello", pattern.Pattern)
+
+	_, err = entity.GetApplyVariables("translate", map[string]string{"lang": "fr"}, "Hello")
+	assert.ErrorContains(t, err, "missing required variable tone")
+
+	metadata, err := entity.GetMetadata("translate")
+	require.NoError(t, err)
+	assert.Len(t, metadata.Variables, 2)
+}
+
+func TestPatternVariableTypes(t *testing.T) {
+	entity, cleanup := setupTestPatternsEntity(t)
+	defer cleanup()
+	createTestPattern(t, entity, "summarize", "---\nvariables:\n  - name: words\n    type: int\n    default: \"50\"\n  - name: style\n    type: enum\n    values: [short, long]\n    required: true\n  - name: bullets\n    type: bool\n---\nSummarize in {{words}} {{style}} words as {{format|prose}} for {{audience}}.\n{{input}}")
+
+	variables, err := entity.GetVariables("summarize")
+	require.NoError(t, err)
+	assert.Equal(t, []PatternVariable{
+		{Name: "words", Type: VariableTypeInt, Default: "50"},
+		{Name: "style", Type: VariableTypeEnum, Values: []string{"short", "long"}, Required: true},
+		{Name: "bullets", Type: VariableTypeBool},
+		{Name: "format", Default: "prose"},
+		{Name: "audience", Required: true},
+	}, variables)
+
+	valid := map[string]string{"style": "short", "audience": "kids"}
+	require.NoError(t, entity.CheckVariables("summarize", valid))
+	pattern, err := entity.GetApplyVariables("summarize", valid, "text")
+	require.NoError(t, err)
+	assert.Equal(t, "Summarize in 50 short words as prose for kids.\ntext", pattern.Pattern)
+
+	for _, test := range []struct {
+		variables map[string]string
+		err       string
+	}{
+		{map[string]string{"style": "short", "audience": "kids", "words": "ten"}, "takes an integer"},
+		{map[string]string{"style": "medium", "audience": "kids"}, "takes one of short, long"},
+		{map[string]string{"style": "short", "audience": "kids", "bullets": "maybe"}, "takes true or false"},
+		{map[string]string{"style": "short"}, "missing required variable: audience"},
+	} {
+		assert.ErrorContains(t, entity.CheckVariables("summarize", test.variables), test.err)
+		_, err = entity.GetApplyVariables("summarize", test.variables, "text")
+		assert.ErrorContains(t, err, test.err)
+	}
+}
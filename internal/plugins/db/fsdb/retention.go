@@ -0,0 +1,153 @@
+package fsdb
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// SessionRetention bounds the sessions that are kept, in the sessions section of config.yaml:
+//
+//	sessions:
+//	  max_age: 720h
+//	  max_count: 100
+//	  max_size_mb: 50
+//	  gc_on_startup: true
+//
+// The limits apply to the sessions that are not pinned, and each one left at 0
+// sets no limit. The oldest sessions are removed first.
+type SessionRetention struct {
+	// MaxAge is how long a session is kept after it was saved last
+	MaxAge time.Duration `yaml:"max_age"`
+	// MaxCount is the number of sessions kept
+	MaxCount int `yaml:"max_count"`
+	// MaxSizeMB is the size of the sessions kept, in total
+	MaxSizeMB int64 `yaml:"max_size_mb"`
+	// GCOnStartup applies the limits whenever fabric starts
+	GCOnStartup bool `yaml:"gc_on_startup"`
+}
+
+// IsSet tells whether any limit is set.
+func (o SessionRetention) IsSet() bool {
+	return o.MaxAge > 0 || o.MaxCount > 0 || o.MaxSizeMB > 0
+}
+
+// Validate checks the limits.
+func (o SessionRetention) Validate() error {
+	if o.MaxAge < 0 || o.MaxCount < 0 || o.MaxSizeMB < 0 {
+		return errors.New("the limits of the sessions section cannot be negative")
+	}
+	return nil
+}
+
+// SessionInfo describes a session without its messages.
+type SessionInfo struct {
+	Name     string
+	Messages int
+	Size     int64
+	ModTime  time.Time
+	Pinned   bool
+	// Err is why the session could not be read. Such sessions are never removed,
+	// since it is not known whether they are pinned.
+	Err error
+}
+
+// Infos describes all sessions, the most recently saved first. It only reads
+// them, so that listing the sessions leaves when they were saved last as it was.
+func (o *SessionsEntity) Infos() (ret []SessionInfo, err error) {
+	var names []string
+	if names, err = o.GetNames(); err != nil {
+		return
+	}
+	for _, name := range names {
+		info := SessionInfo{Name: name}
+		if info.ModTime, info.Size, info.Err = o.Stat(name); info.Err == nil {
+			session := &Session{}
+			if info.Err = o.loadSession(name, session); info.Err == nil {
+				info.Messages, info.Pinned = len(session.Messages), session.Pinned
+			}
+		}
+		ret = append(ret, info)
+	}
+	slices.SortStableFunc(ret, func(a, b SessionInfo) int {
+		return b.ModTime.Compare(a.ModTime)
+	})
+	return
+}
+
+// Collect removes the sessions beyond the limits of retention, as of now, and
+// returns them. With dryRun it only returns what it would remove. The sessions
+// named in keep are in use, so like pinned ones they are neither removed nor counted.
+func (o *SessionsEntity) Collect(retention SessionRetention, now time.Time, dryRun bool, keep ...string) (ret []SessionInfo, err error) {
+	if err = retention.Validate(); err != nil {
+		return
+	}
+	var infos []SessionInfo
+	if infos, err = o.Infos(); err != nil {
+		return
+	}
+
+	var count int
+	var size int64
+	for _, info := range infos {
+		if info.Pinned || info.Err != nil || slices.Contains(keep, info.Name) {
+			continue
+		}
+		count++
+		size += info.Size
+		if (retention.MaxAge > 0 && now.Sub(info.ModTime) > retention.MaxAge) ||
+			(retention.MaxCount > 0 && count > retention.MaxCount) ||
+			(retention.MaxSizeMB > 0 && size > retention.MaxSizeMB*1024*1024) {
+			ret = append(ret, info)
+		}
+	}
+
+	if dryRun {
+		return
+	}
+	candidates := ret
+	ret = nil
+	for _, info := range candidates {
+		var removed bool
+		if removed, err = o.removeUnpinned(info); err != nil {
+			return
+		}
+		if removed {
+			ret = append(ret, info)
+		}
+	}
+	err = o.collectAttachments(now)
+	return
+}
+
+// removeUnpinned deletes the session unless it was saved or pinned since it was listed.
+func (o *SessionsEntity) removeUnpinned(info SessionInfo) (removed bool, err error) {
+	defer o.Lock(info.Name)()
+	var modTime time.Time
+	if modTime, _, err = o.Stat(info.Name); err != nil || !modTime.Equal(info.ModTime) {
+		return
+	}
+	session := &Session{}
+	if err = o.loadSession(info.Name, session); err != nil || session.Pinned {
+		return
+	}
+	if err = o.Delete(info.Name); err == nil {
+		removed = true
+	}
+	return
+}
+
+// Pin exempts the session from the session retention, or makes it subject to it again.
+func (o *SessionsEntity) Pin(name string, pinned bool) (err error) {
+	defer o.Lock(name)()
+	if !o.Exists(name) {
+		return fmt.Errorf("session %s does not exist", name)
+	}
+	session := &Session{}
+	if err = o.loadSession(name, session); err != nil {
+		return
+	}
+	session.Pinned = pinned
+	return o.SaveSession(session)
+}
@@ -0,0 +1,122 @@
+package fsdb
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+)
+
+func TestSessions_Collect(t *testing.T) {
+	files := &StorageEntity{Dir: t.TempDir(), FileExtension: ".json"}
+	sessions := &SessionsEntity{Store: files}
+	now := time.Now()
+	for i, name := range []string{"new", "recent", "old", "pinned", "ancient"} {
+		session := &Session{Name: name, Pinned: name == "pinned"}
+		session.Append(&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: name})
+		if err := sessions.SaveSession(session); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		modTime := now.Add(-time.Duration(i) * 24 * time.Hour)
+		if err := os.Chtimes(files.BuildFilePathByName(name), modTime, modTime); err != nil {
+			t.Fatalf("failed to age session: %v", err)
+		}
+	}
+
+	infos, err := sessions.Infos()
+	if err != nil || len(infos) != 5 || infos[0].Name != "new" || infos[0].Messages != 1 || !infos[3].Pinned {
+		t.Fatalf("unexpected infos %+v, %v", infos, err)
+	}
+
+	// Pinned sessions are neither removed nor counted
+	removed, err := sessions.Collect(SessionRetention{MaxCount: 2}, now, true)
+	if err != nil || len(removed) != 2 || removed[0].Name != "old" || removed[1].Name != "ancient" {
+		t.Errorf("expected the oldest unpinned sessions beyond the count, got %+v, %v", removed, err)
+	}
+	if !sessions.Exists("old") {
+		t.Errorf("expected a dry run to keep the sessions")
+	}
+
+	if removed, err = sessions.Collect(SessionRetention{MaxAge: 36 * time.Hour}, now, false); err != nil || len(removed) != 2 {
+		t.Fatalf("expected the sessions older than the age to be removed, got %+v, %v", removed, err)
+	}
+	if sessions.Exists("old") || sessions.Exists("ancient") || !sessions.Exists("pinned") || !sessions.Exists("recent") {
+		t.Errorf("unexpected sessions left")
+	}
+
+	if err = sessions.Pin("pinned", false); err != nil {
+		t.Fatalf("failed to unpin: %v", err)
+	}
+	if removed, err = sessions.Collect(SessionRetention{MaxCount: 1}, now, false); err != nil || len(removed) != 2 {
+		t.Errorf("expected the unpinned session to be subject to the count, got %+v, %v", removed, err)
+	}
+
+	if _, err = sessions.Collect(SessionRetention{MaxCount: -1}, now, false); err == nil {
+		t.Errorf("expected an error for a negative limit")
+	}
+}
+
+func TestSessions_CollectInUse(t *testing.T) {
+	files := &StorageEntity{Dir: t.TempDir(), FileExtension: ".json"}
+	sessions := &SessionsEntity{Store: files}
+	now := time.Now()
+	for _, name := range []string{"current", "saved"} {
+		session := &Session{Name: name}
+		session.Append(&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: name})
+		if err := sessions.SaveSession(session); err != nil {
+			t.Fatalf("failed to save session: %v", err)
+		}
+		modTime := now.Add(-10 * 24 * time.Hour)
+		if err := os.Chtimes(files.BuildFilePathByName(name), modTime, modTime); err != nil {
+			t.Fatalf("failed to age session: %v", err)
+		}
+	}
+
+	// The sessions to keep are neither removed nor counted
+	retention := SessionRetention{MaxAge: 24 * time.Hour}
+	removed, err := sessions.Collect(retention, now, true, "current")
+	if err != nil || len(removed) != 1 || removed[0].Name != "saved" {
+		t.Errorf("expected only the session not in use, got %+v, %v", removed, err)
+	}
+
+	// A session saved after it was listed is kept
+	infos, err := sessions.Infos()
+	if err != nil {
+		t.Fatalf("failed to list sessions: %v", err)
+	}
+	if err = os.Chtimes(files.BuildFilePathByName("saved"), now, now); err != nil {
+		t.Fatalf("failed to touch session: %v", err)
+	}
+	for _, info := range infos {
+		if info.Name != "saved" {
+			continue
+		}
+		if ok, removeErr := sessions.removeUnpinned(info); removeErr != nil || ok || !sessions.Exists("saved") {
+			t.Errorf("expected the session saved since it was listed to be kept, got %v, %v", ok, removeErr)
+		}
+	}
+}
+
+func TestSessions_CollectLegacy(t *testing.T) {
+	files := &StorageEntity{Dir: t.TempDir(), FileExtension: ".json"}
+	sessions := &SessionsEntity{Store: files}
+	now := time.Now()
+	if err := sessions.Save("legacy", []byte(`{"messages":[{"role":"user","content":"hi"}],"model":"gpt-4o"}`)); err != nil {
+		t.Fatalf("failed to save v1 session: %v", err)
+	}
+	modTime := now.Add(-10 * 24 * time.Hour)
+	if err := os.Chtimes(files.BuildFilePathByName("legacy"), modTime, modTime); err != nil {
+		t.Fatalf("failed to age session: %v", err)
+	}
+
+	// Listing the sessions reads them without saving them again
+	retention := SessionRetention{MaxAge: 24 * time.Hour}
+	removed, err := sessions.Collect(retention, now, true)
+	if err != nil || len(removed) != 1 || !removed[0].ModTime.Equal(modTime) {
+		t.Fatalf("expected the old session in the dry run, got %+v, %v", removed, err)
+	}
+	if removed, err = sessions.Collect(retention, now, false); err != nil || len(removed) != 1 || sessions.Exists("legacy") {
+		t.Errorf("expected the old session to be removed after the dry run, got %+v, %v", removed, err)
+	}
+}
@@ -0,0 +1,389 @@
+package fsdb
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/plugins/db"
+	"github.com/danielmiessler/fabric/internal/plugins/db/sqlitedb"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Kinds of documents in the search index
+const (
+	SearchKindSession = "session"
+	SearchKindContext = "context"
+	SearchKindPattern = "pattern"
+)
+
+// ErrInvalidSearchQuery is wrapped by the errors of queries that are empty or
+// not in the FTS syntax; other errors of a search are those of the index.
+var ErrInvalidSearchQuery = errors.New("invalid search query")
+
+// SearchResult is a match of a search, with a snippet of the text around it.
+type SearchResult struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Message is the number of the matching session message, counted from 1
+	Message int     `json:"message,omitempty"`
+	Role    string  `json:"role,omitempty"`
+	Snippet string  `json:"snippet"`
+	Score   float64 `json:"score"`
+}
+
+// SearchIndex is a full-text index of the sessions, contexts and patterns in
+// an SQLite database next to them. Before each search it reindexes the items
+// that changed since the last one, in a transaction holding the write lock of
+// the database; searches that find nothing changed only read it.
+//
+// The index uses FTS4, which go-sqlite3 builds in by default; FTS5 needs the
+// sqlite_fts5 build tag. Results are ranked with BM25 over matchinfo.
+type SearchIndex struct {
+	Path string
+	db   *Db
+}
+
+// NewSearchIndex returns the search index of db, stored in search.db.
+func NewSearchIndex(db *Db) *SearchIndex {
+	return &SearchIndex{Path: db.FilePath("search.db"), db: db}
+}
+
+// Search returns the best matches of query, at most limit of them. The query
+// uses the FTS syntax: words, "phrases", prefix* and AND, OR, NOT.
+func (o *SearchIndex) Search(query string, limit int) (ret []SearchResult, err error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("%w: the query is empty", ErrInvalidSearchQuery)
+	}
+
+	var conn *sql.DB
+	if conn, err = o.open(); err != nil {
+		return
+	}
+	defer conn.Close()
+	if err = o.update(conn); err != nil {
+		return nil, fmt.Errorf("could not update the search index: %w", err)
+	}
+
+	var rows *sql.Rows
+	if rows, err = conn.Query(`SELECT kind, name, position, role,
+			snippet(docs, '[', ']', '…', 4, 16), matchinfo(docs, 'pcnalx')
+		FROM docs WHERE docs MATCH ?`, query); err != nil {
+		return nil, queryError(query, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var result SearchResult
+		var info []byte
+		if err = rows.Scan(&result.Kind, &result.Name, &result.Message, &result.Role, &result.Snippet, &info); err != nil {
+			return
+		}
+		result.Score = bm25(info, docsContentColumn)
+		ret = append(ret, result)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, queryError(query, err)
+	}
+
+	slices.SortStableFunc(ret, func(a, b SearchResult) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	if limit > 0 && len(ret) > limit {
+		ret = ret[:limit]
+	}
+	return
+}
+
+// queryError tells a query SQLite cannot parse, which it reports as a generic
+// error, from a failure of the index.
+func queryError(query string, err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrError {
+		return fmt.Errorf("%w %q: %v", ErrInvalidSearchQuery, query, err)
+	}
+	return fmt.Errorf("could not search: %w", err)
+}
+
+// docsContentColumn is the only indexed column of docs, the others describe where the text is from.
+const docsContentColumn = 4
+
+// openIndex serializes the opening of search indexes. Switching a new database
+// to WAL fails at once, without waiting, when another connection is doing the same.
+var openIndex sync.Mutex
+
+func (o *SearchIndex) open() (conn *sql.DB, err error) {
+	openIndex.Lock()
+	defer openIndex.Unlock()
+	if conn, err = sql.Open("sqlite3", sqlitedb.DataSource(o.Path)); err != nil {
+		return nil, fmt.Errorf("could not open the search index: %w", err)
+	}
+	// The tables are created in a transaction, which waits for the write lock
+	// of another search creating them
+	var tx *sql.Tx
+	if tx, err = conn.Begin(); err == nil {
+		for _, statement := range []string{
+			`CREATE TABLE IF NOT EXISTS files (
+				path TEXT PRIMARY KEY,
+				mod_time INTEGER NOT NULL,
+				size INTEGER NOT NULL
+			)`,
+			`CREATE VIRTUAL TABLE IF NOT EXISTS docs USING fts4(
+				path, kind, name, position, role, content,
+				notindexed=path, notindexed=kind, notindexed=name, notindexed=position, notindexed=role,
+				tokenize=unicode61
+			)`,
+		} {
+			if _, err = tx.Exec(statement); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not open the search index: %w", err)
+	}
+	return
+}
+
+// searchFile is an item to index and what it holds. Sessions and contexts are
+// in their store, patterns are files.
+type searchFile struct {
+	path, kind, name string
+	store            db.Store
+}
+
+func (o searchFile) stat() (modTime time.Time, size int64, err error) {
+	if o.store != nil {
+		return o.store.Stat(o.name)
+	}
+	var info os.FileInfo
+	if info, err = os.Stat(o.path); err == nil {
+		modTime, size = info.ModTime(), info.Size()
+	}
+	return
+}
+
+func (o searchFile) load() (ret []byte, err error) {
+	if o.store != nil {
+		return o.store.Load(o.name)
+	}
+	return os.ReadFile(o.path)
+}
+
+// update reindexes the items that were added or changed since the last update
+// and drops the ones that are gone. It only takes the write lock when there is
+// something to do, and then looks again, since another search may have done it
+// while it waited.
+func (o *SearchIndex) update(conn *sql.DB) (err error) {
+	var changed []searchChange
+	var deleted []string
+	if changed, deleted, err = o.changes(conn); err != nil || len(changed)+len(deleted) == 0 {
+		return
+	}
+
+	var tx *sql.Tx
+	if tx, err = conn.Begin(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	if changed, deleted, err = o.changes(tx); err != nil {
+		return
+	}
+
+	for _, change := range changed {
+		if err = o.index(tx, change.file); err != nil {
+			return
+		}
+		if _, err = tx.Exec(`INSERT OR REPLACE INTO files (path, mod_time, size) VALUES (?, ?, ?)`,
+			change.file.path, change.state[0], change.state[1]); err != nil {
+			return
+		}
+	}
+	for _, path := range deleted {
+		if _, err = tx.Exec(`DELETE FROM docs WHERE path = ?`, path); err != nil {
+			return
+		}
+		if _, err = tx.Exec(`DELETE FROM files WHERE path = ?`, path); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// searchChange is an item to reindex, with its modification time and size.
+type searchChange struct {
+	file  searchFile
+	state [2]int64
+}
+
+// searchQuerier is what *sql.DB and *sql.Tx have in common.
+type searchQuerier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// changes compares the items there are with those in the index, and returns
+// the ones added or changed since and the paths of the ones that are gone.
+func (o *SearchIndex) changes(q searchQuerier) (changed []searchChange, deleted []string, err error) {
+	indexed := map[string][2]int64{}
+	var rows *sql.Rows
+	if rows, err = q.Query(`SELECT path, mod_time, size FROM files`); err != nil {
+		return
+	}
+	for rows.Next() {
+		var path string
+		var modTime, size int64
+		if err = rows.Scan(&path, &modTime, &size); err != nil {
+			rows.Close()
+			return
+		}
+		indexed[path] = [2]int64{modTime, size}
+	}
+	rows.Close()
+
+	for _, file := range o.files() {
+		modTime, size, statErr := file.stat()
+		if statErr != nil {
+			continue
+		}
+		state := [2]int64{modTime.UnixNano(), size}
+		previous, known := indexed[file.path]
+		delete(indexed, file.path)
+		if !known || previous != state {
+			changed = append(changed, searchChange{file, state})
+		}
+	}
+	// What is left was deleted since the last update
+	for path := range indexed {
+		deleted = append(deleted, path)
+	}
+	return
+}
+
+// files lists the sessions, contexts and patterns there are to index. The
+// sessions and contexts are called by their kind and name, which stays the
+// same whichever storage backend keeps them.
+//
+// Encrypted sessions and contexts are left out, the index would hold them in plaintext.
+func (o *SearchIndex) files() (ret []searchFile) {
+	if names, err := o.db.Sessions.GetNames(); err == nil && !isEncrypted(o.db.Sessions.Store) {
+		for _, name := range names {
+			ret = append(ret, searchFile{SearchKindSession + "/" + name, SearchKindSession, name, o.db.Sessions})
+		}
+	}
+	if names, err := o.db.Contexts.GetNames(); err == nil && !isEncrypted(o.db.Contexts.Store) {
+		for _, name := range names {
+			ret = append(ret, searchFile{SearchKindContext + "/" + name, SearchKindContext, name, o.db.Contexts})
+		}
+	}
+	if names, err := o.db.Patterns.GetNames(); err == nil {
+		for _, name := range names {
+			// Custom patterns take the place of the ones with the same name
+			path := filepath.Join(o.db.Patterns.Dir, name, o.db.Patterns.SystemPatternFile)
+			if o.db.Patterns.CustomPatternsDir != "" {
+				custom := filepath.Join(o.db.Patterns.CustomPatternsDir, name, o.db.Patterns.SystemPatternFile)
+				if _, err = os.Stat(custom); err == nil {
+					path = custom
+				}
+			}
+			ret = append(ret, searchFile{path, SearchKindPattern, name, nil})
+		}
+	}
+	return
+}
+
+// index replaces the documents of file. Every message of a session is a
+// document of its own, so that a match points to it.
+func (o *SearchIndex) index(tx *sql.Tx, file searchFile) (err error) {
+	if _, err = tx.Exec(`DELETE FROM docs WHERE path = ?`, file.path); err != nil {
+		return
+	}
+	insert := func(position int, role, content string) (err error) {
+		if strings.TrimSpace(content) != "" {
+			_, err = tx.Exec(`INSERT INTO docs (path, kind, name, position, role, content) VALUES (?, ?, ?, ?, ?, ?)`,
+				file.path, file.kind, file.name, position, role, content)
+		}
+		return
+	}
+
+	if file.kind != SearchKindSession {
+		var content []byte
+		if content, err = file.load(); err != nil {
+			// An item that cannot be read should not stop the search of the others
+			return nil
+		}
+		return insert(0, "", string(content))
+	}
+
+	session := &Session{}
+	if err = o.db.Sessions.loadSession(file.name, session); err != nil {
+		// Nor should a broken session
+		return nil
+	}
+	for i, message := range session.Messages {
+		text, _ := messageParts(message)
+		if err = insert(i+1, message.Role, text); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func isEncrypted(store db.Store) bool {
+	encrypted, ok := store.(*EncryptedStore)
+	return ok && encrypted.Encrypted()
+}
+
+// bm25 ranks a match from the matchinfo 'pcnalx' of a row, for one column.
+func bm25(info []byte, column int) (ret float64) {
+	const k1, b = 1.2, 0.75
+	values := make([]uint32, len(info)/4)
+	for i := range values {
+		values[i] = binary.NativeEndian.Uint32(info[i*4:])
+	}
+	if len(values) < 3 {
+		return
+	}
+	phrases, columns, rows := int(values[0]), int(values[1]), float64(values[2])
+	if len(values) < 3+2*columns+3*phrases*columns {
+		return
+	}
+	average := float64(values[3+column])
+	length := float64(values[3+columns+column])
+	hits := values[3+2*columns:]
+	for phrase := range phrases {
+		offset := 3 * (phrase*columns + column)
+		frequency, documents := float64(hits[offset]), float64(hits[offset+2])
+		if frequency == 0 {
+			continue
+		}
+		idf := math.Log(1 + (rows-documents+0.5)/(documents+0.5))
+		ret += idf * frequency * (k1 + 1) / (frequency + k1*(1-b+b*length/max(average, 1)))
+	}
+	return
+}
@@ -0,0 +1,103 @@
+package fsdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+)
+
+func TestSearchIndex_Search(t *testing.T) {
+	db := NewDb(t.TempDir())
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create the sessions: %v", err)
+	}
+	if err := db.Contexts.Configure(); err != nil {
+		t.Fatalf("failed to create the contexts: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(db.Patterns.Dir, "summarize"), 0755); err != nil {
+		t.Fatalf("failed to create the pattern: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(db.Patterns.Dir, "summarize", "system.md"), []byte("Summarize the kubernetes deployment in bullet points."), 0644); err != nil {
+		t.Fatalf("failed to write pattern: %v", err)
+	}
+	if err := db.Contexts.Save("ops", []byte("We run kubernetes clusters on bare metal.")); err != nil {
+		t.Fatalf("failed to save context: %v", err)
+	}
+	session := &Session{Name: "chat"}
+	session.Append(
+		&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "How do I scale a deployment?"},
+		&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleAssistant, Content: "Use kubectl scale, kubernetes does the rest. Kubernetes kubernetes."},
+	)
+	if err := db.Sessions.SaveSession(session); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	index := NewSearchIndex(db)
+	results, err := index.Search("kubernetes", 10)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected a match in the session, context and pattern, got %+v", results)
+	}
+	if first := results[0]; first.Kind != SearchKindSession || first.Name != "chat" || first.Message != 2 || first.Role != chat.ChatMessageRoleAssistant {
+		t.Errorf("expected the session message with the most hits first, got %+v", first)
+	}
+
+	// Changed and deleted files are picked up by the next search
+	if err = db.Contexts.Delete("ops"); err != nil {
+		t.Fatalf("failed to delete context: %v", err)
+	}
+	session.Append(&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "And with helm?"})
+	if err = db.Sessions.SaveSession(session); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	if results, err = index.Search("kubernetes", 10); err != nil || len(results) != 2 {
+		t.Errorf("expected the deleted context to be gone, got %+v, %v", results, err)
+	}
+	if results, err = index.Search("helm", 10); err != nil || len(results) != 1 || results[0].Message != 3 {
+		t.Errorf("expected the new message to be found, got %+v, %v", results, err)
+	}
+
+	if _, err = index.Search("\"unbalanced", 10); !errors.Is(err, ErrInvalidSearchQuery) {
+		t.Errorf("expected an invalid query, got %v", err)
+	}
+	if _, err = index.Search(" ", 10); !errors.Is(err, ErrInvalidSearchQuery) {
+		t.Errorf("expected an empty query to be invalid, got %v", err)
+	}
+}
+
+func TestSearchIndex_Concurrent(t *testing.T) {
+	db := NewDb(t.TempDir())
+	if err := db.Contexts.Configure(); err != nil {
+		t.Fatalf("failed to create the contexts: %v", err)
+	}
+	for i := range 20 {
+		if err := db.Contexts.Save(fmt.Sprintf("ctx%d", i), []byte("kubernetes notes")); err != nil {
+			t.Fatalf("failed to save context: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each search opens the index of its own, as concurrent processes would
+			if _, err := NewSearchIndex(db).Search("kubernetes", 5); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent search failed: %v", err)
+	}
+}
@@ -1,21 +1,46 @@
 package fsdb
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/danielmiessler/fabric/internal/chat"
 	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/plugins/db"
 )
 
 type SessionsEntity struct {
-	*StorageEntity
+	db.Store
+	// Attachments keeps the attachments of the sessions, which are kept inline without it
+	Attachments *AttachmentsEntity
+
+	locks sessionLocks
+}
+
+// Configure prepares the sessions and their attachments.
+func (o *SessionsEntity) Configure() (err error) {
+	if err = o.Store.Configure(); err != nil || o.Attachments == nil {
+		return
+	}
+	return o.Attachments.Configure()
+}
+
+// Lock keeps the other chats of this process from changing the session until
+// unlock is called, so that chats with the same session take turns instead of
+// overwriting each other's messages.
+func (o *SessionsEntity) Lock(name string) (unlock func()) {
+	return o.locks.lock(name)
 }
 
 func (o *SessionsEntity) Get(name string) (session *Session, err error) {
-	session = &Session{Name: name}
+	session = &Session{Name: name, attachments: o.Attachments}
 
 	if o.Exists(name) {
-		err = o.LoadAsJson(name, &session.Messages)
+		err = o.loadSession(name, session)
 	} else {
 		fmt.Printf("Creating new session: %s\n", name)
 	}
@@ -25,29 +50,348 @@ func (o *SessionsEntity) Get(name string) (session *Session, err error) {
 func (o *SessionsEntity) PrintSession(name string) (err error) {
 	if o.Exists(name) {
 		var session Session
-		if err = o.LoadAsJson(name, &session.Messages); err == nil {
+		if err = o.loadSession(name, &session); err == nil {
 			fmt.Println(session.String())
 		}
 	}
 	return
 }
 
+// SessionFormatVersion is the version of the session file layout written by SaveSession.
+//
+//   - 0: a plain array of messages
+//   - 1: an object with the messages, usage totals and the model that answered last
+//   - 2: version 1 with metadata attached to each message
+//   - 3: version 2 with the attachments in the attachment store, referred to by their id
+const SessionFormatVersion = 3
+
+// SaveSession stores a session in the current session file layout.
 func (o *SessionsEntity) SaveSession(session *Session) (err error) {
-	return o.SaveAsJson(session.Name, session.Messages)
+	file := sessionFile{
+		Version: SessionFormatVersion,
+		Usage:   session.Usage,
+		Vendor:  session.Vendor,
+		Model:   session.Model,
+		Pinned:  session.Pinned,
+	}
+	for _, message := range session.Messages {
+		stored := message
+		if o.Attachments != nil {
+			if stored, err = o.Attachments.dehydrate(message); err != nil {
+				return fmt.Errorf("could not store the attachments of %s: %w", session.Name, err)
+			}
+		}
+		file.Messages = append(file.Messages, sessionEntry{Message: stored, Meta: session.Metadata(message)})
+	}
+	var content []byte
+	if content, err = json.Marshal(file); err != nil {
+		return fmt.Errorf("could not marshal %s: %s", session.Name, err)
+	}
+	return o.Save(session.Name, content)
+}
+
+// loadSession reads every session file layout. Sessions in an older layout
+// are migrated in memory only; they are written in the current one by the
+// next SaveSession, so that reading a session never changes it.
+func (o *SessionsEntity) loadSession(name string, session *Session) (err error) {
+	var content []byte
+	if content, err = o.Load(name); err != nil {
+		return
+	}
+	session.Name, session.attachments = name, o.Attachments
+	version := 0
+	if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &session.Messages)
+	} else {
+		var file sessionFile
+		if err = json.Unmarshal(trimmed, &file); err == nil {
+			version = max(file.Version, 1)
+			session.Usage, session.Vendor, session.Model, session.Pinned = file.Usage, file.Vendor, file.Model, file.Pinned
+			if version == 1 {
+				err = json.Unmarshal(file.Messages1, &session.Messages)
+			}
+			for _, entry := range file.Messages {
+				session.Messages = append(session.Messages, entry.Message)
+				session.SetMetadata(entry.Message, entry.Meta)
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("could not unmarshal %s: %s", name, err)
+	}
+	if version > SessionFormatVersion {
+		return fmt.Errorf("session %s has format version %d, which needs a newer version of fabric", name, version)
+	}
+
+	if version < 2 {
+		migrateSession(session)
+	}
+	return
+}
+
+// migrateSession attaches what older layouts knew to the messages: only the
+// model that answered last, which gave the last assistant message.
+func migrateSession(session *Session) {
+	if session.Model == "" {
+		return
+	}
+	for i := len(session.Messages) - 1; i >= 0; i-- {
+		if message := session.Messages[i]; message.Role == chat.ChatMessageRoleAssistant {
+			session.SetMetadata(message, &MessageMetadata{Vendor: session.Vendor, Model: session.Model})
+			return
+		}
+	}
+}
+
+// sessionLocks are the locks of the sessions in use, which go away with their last user.
+type sessionLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sessionLock
+}
+
+type sessionLock struct {
+	sync.Mutex
+	users int
+}
+
+func (o *sessionLocks) lock(name string) (unlock func()) {
+	o.mu.Lock()
+	if o.locks == nil {
+		o.locks = map[string]*sessionLock{}
+	}
+	lock := o.locks[name]
+	if lock == nil {
+		lock = &sessionLock{}
+		o.locks[name] = lock
+	}
+	lock.users++
+	o.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		o.mu.Lock()
+		if lock.users--; lock.users == 0 {
+			delete(o.locks, name)
+		}
+		o.mu.Unlock()
+	}
+}
+
+// sessionFile is the on-disk layout of a session.
+type sessionFile struct {
+	Version  int            `json:"version,omitempty"`
+	Messages []sessionEntry `json:"-"`
+	// Messages1 holds the bare messages of version 1
+	Messages1 json.RawMessage       `json:"-"`
+	Usage     *domain.UsageMetadata `json:"usage,omitempty"`
+	Vendor    string                `json:"vendor,omitempty"`
+	Model     string                `json:"model,omitempty"`
+	Pinned    bool                  `json:"pinned,omitempty"`
+}
+
+func (o sessionFile) MarshalJSON() ([]byte, error) {
+	type file sessionFile
+	return json.Marshal(struct {
+		file
+		Messages []sessionEntry `json:"messages"`
+	}{file(o), o.Messages})
+}
+
+func (o *sessionFile) UnmarshalJSON(data []byte) (err error) {
+	type file sessionFile
+	var raw struct {
+		file
+		Messages json.RawMessage `json:"messages"`
+	}
+	if err = json.Unmarshal(data, &raw); err != nil {
+		return
+	}
+	*o = sessionFile(raw.file)
+	if o.Version < 2 {
+		o.Messages1 = raw.Messages
+	} else if len(raw.Messages) > 0 {
+		err = json.Unmarshal(raw.Messages, &o.Messages)
+	}
+	return
+}
+
+// sessionEntry is a message with its metadata.
+type sessionEntry struct {
+	Message *chat.ChatCompletionMessage `json:"message"`
+	Meta    *MessageMetadata            `json:"meta,omitempty"`
+}
+
+// MessageMetadata records when and how a message of a session was produced.
+type MessageMetadata struct {
+	Time time.Time `json:"time,omitzero"`
+	// Edited is when the content of the message was last changed
+	Edited   time.Time `json:"edited,omitzero"`
+	Vendor   string    `json:"vendor,omitempty"`
+	Model    string    `json:"model,omitempty"`
+	Pattern  string    `json:"pattern,omitempty"`
+	Context  string    `json:"context,omitempty"`
+	Strategy string    `json:"strategy,omitempty"`
+	// Options are the options of the request that produced an answer
+	Options *MessageOptions       `json:"options,omitempty"`
+	Usage   *domain.UsageMetadata `json:"usage,omitempty"`
+}
+
+// MessageOptions are the chat options that shape an answer.
+type MessageOptions struct {
+	Temperature      float64              `json:"temperature"`
+	TopP             float64              `json:"top_p"`
+	PresencePenalty  float64              `json:"presence_penalty,omitempty"`
+	FrequencyPenalty float64              `json:"frequency_penalty,omitempty"`
+	Seed             int                  `json:"seed,omitempty"`
+	Thinking         domain.ThinkingLevel `json:"thinking,omitempty"`
+	MaxTokens        int                  `json:"max_tokens,omitempty"`
+	Raw              bool                 `json:"raw,omitempty"`
+	Search           bool                 `json:"search,omitempty"`
+	Schema           string               `json:"schema,omitempty"`
+}
+
+// NewMessageOptions takes the options to record from opts.
+func NewMessageOptions(opts *domain.ChatOptions) (ret *MessageOptions) {
+	ret = &MessageOptions{
+		Temperature:      opts.Temperature,
+		TopP:             opts.TopP,
+		PresencePenalty:  opts.PresencePenalty,
+		FrequencyPenalty: opts.FrequencyPenalty,
+		Seed:             opts.Seed,
+		Thinking:         opts.Thinking,
+		MaxTokens:        opts.MaxTokens,
+		Raw:              opts.Raw,
+		Search:           opts.Search,
+	}
+	if opts.Schema != nil {
+		ret.Schema = opts.Schema.Name
+	}
+	return
+}
+
+// String describes the metadata on one line.
+func (o *MessageMetadata) String() string {
+	var parts []string
+	if !o.Time.IsZero() {
+		parts = append(parts, o.Time.Local().Format(time.DateTime))
+	}
+	if !o.Edited.IsZero() {
+		parts = append(parts, "edited "+o.Edited.Local().Format(time.DateTime))
+	}
+	if o.Model != "" {
+		parts = append(parts, o.Vendor+"|"+o.Model)
+	}
+	if o.Pattern != "" {
+		parts = append(parts, "pattern "+o.Pattern)
+	}
+	if o.Context != "" {
+		parts = append(parts, "context "+o.Context)
+	}
+	if o.Strategy != "" {
+		parts = append(parts, "strategy "+o.Strategy)
+	}
+	if o.Options != nil {
+		parts = append(parts, fmt.Sprintf("temperature %v", o.Options.Temperature))
+		if o.Options.Seed != 0 {
+			parts = append(parts, fmt.Sprintf("seed %d", o.Options.Seed))
+		}
+		if o.Options.Thinking != "" {
+			parts = append(parts, fmt.Sprintf("thinking %s", o.Options.Thinking))
+		}
+		if o.Options.Schema != "" {
+			parts = append(parts, "schema "+o.Options.Schema)
+		}
+	}
+	if o.Usage != nil {
+		parts = append(parts, fmt.Sprintf("%d/%d tokens", o.Usage.InputTokens, o.Usage.OutputTokens))
+		if o.Usage.Cost > 0 {
+			parts = append(parts, fmt.Sprintf("$%.4f", o.Usage.Cost))
+		}
+	}
+	return strings.Join(parts, ", ")
 }
 
 type Session struct {
 	Name     string
 	Messages []*chat.ChatCompletionMessage
+	// Usage is the token usage and cost accumulated over all requests of the session
+	Usage *domain.UsageMetadata `json:",omitempty"`
+	// LastUsage is the usage of the most recent request, it is not persisted
+	LastUsage *domain.UsageMetadata `json:"-"`
+	// Vendor and Model answered the most recent request, which may be a fallback
+	Vendor string `json:",omitempty"`
+	Model  string `json:",omitempty"`
+	// Pinned sessions are never removed by the session retention
+	Pinned bool `json:",omitempty"`
 
 	vendorMessages []*chat.ChatCompletionMessage
+	// excluded messages are kept in the session but not sent to the vendor
+	excluded map[*chat.ChatCompletionMessage]bool
+	metadata map[*chat.ChatCompletionMessage]*MessageMetadata
+	// attachments holds the attachments the messages refer to
+	attachments *AttachmentsEntity
+}
+
+// MarshalJSON adds the metadata of the messages, in the order of the messages.
+func (o *Session) MarshalJSON() ([]byte, error) {
+	type session Session
+	metadata := make([]*MessageMetadata, len(o.Messages))
+	for i, message := range o.Messages {
+		metadata[i] = o.Metadata(message)
+	}
+	return json.Marshal(struct {
+		*session
+		Metadata []*MessageMetadata
+	}{(*session)(o), metadata})
+}
+
+// Metadata returns the metadata of message, nil when there is none.
+func (o *Session) Metadata(message *chat.ChatCompletionMessage) *MessageMetadata {
+	return o.metadata[message]
+}
+
+// SetMetadata attaches metadata to message, or removes it for nil.
+func (o *Session) SetMetadata(message *chat.ChatCompletionMessage, metadata *MessageMetadata) {
+	if metadata == nil {
+		delete(o.metadata, message)
+		return
+	}
+	if o.metadata == nil {
+		o.metadata = map[*chat.ChatCompletionMessage]*MessageMetadata{}
+	}
+	o.metadata[message] = metadata
+}
+
+// stamp attaches the current time to messages that have no metadata yet.
+func (o *Session) stamp(messages []*chat.ChatCompletionMessage) {
+	now := time.Now()
+	for _, message := range messages {
+		if o.Metadata(message) == nil {
+			o.SetMetadata(message, &MessageMetadata{Time: now})
+		}
+	}
+}
+
+// AddUsage records the usage of a request and adds it to the session totals.
+func (o *Session) AddUsage(usage *domain.UsageMetadata) {
+	if usage == nil {
+		return
+	}
+	o.LastUsage = usage
+	if o.Usage == nil {
+		o.Usage = &domain.UsageMetadata{}
+	}
+	o.Usage.Add(usage)
 }
 
 func (o *Session) IsEmpty() bool {
 	return len(o.Messages) == 0
 }
 
+// Append adds messages to the session, stamped with the current time.
 func (o *Session) Append(messages ...*chat.ChatCompletionMessage) {
+	o.stamp(messages)
 	if o.vendorMessages != nil {
 		for _, message := range messages {
 			o.Messages = append(o.Messages, message)
@@ -68,9 +412,147 @@ func (o *Session) GetVendorMessages() (ret []*chat.ChatCompletionMessage) {
 	return
 }
 
+// Exclude leaves messages out of what is sent to the vendor, while keeping
+// them in the stored session.
+func (o *Session) Exclude(messages ...*chat.ChatCompletionMessage) {
+	if o.excluded == nil {
+		o.excluded = map[*chat.ChatCompletionMessage]bool{}
+	}
+	for _, message := range messages {
+		o.excluded[message] = true
+	}
+	o.vendorMessages = nil
+}
+
+// Replace removes messages from the session and puts with in place of the
+// first of them.
+func (o *Session) Replace(messages []*chat.ChatCompletionMessage, with ...*chat.ChatCompletionMessage) {
+	if len(messages) == 0 {
+		return
+	}
+	replaced := make(map[*chat.ChatCompletionMessage]bool, len(messages))
+	for _, message := range messages {
+		replaced[message] = true
+		o.SetMetadata(message, nil)
+	}
+	o.stamp(with)
+	kept := make([]*chat.ChatCompletionMessage, 0, len(o.Messages)-len(messages)+len(with))
+	inserted := false
+	for _, message := range o.Messages {
+		if !replaced[message] {
+			kept = append(kept, message)
+		} else if !inserted {
+			kept = append(kept, with...)
+			inserted = true
+		}
+	}
+	o.Messages = kept
+	o.vendorMessages = nil
+}
+
+// Fork returns a copy of the first at messages of the session, with their
+// metadata, as a new session called name. An at of 0 copies all messages.
+func (o *Session) Fork(name string, at int) (ret *Session, err error) {
+	if at == 0 {
+		at = len(o.Messages)
+	}
+	if at < 0 || at > len(o.Messages) {
+		err = fmt.Errorf("cannot fork session %s at message %d, it has %d messages", o.Name, at, len(o.Messages))
+		return
+	}
+	ret = &Session{Name: name, Vendor: o.Vendor, Model: o.Model, attachments: o.attachments}
+	for _, message := range o.Messages[:at] {
+		forked := *message
+		ret.Messages = append(ret.Messages, &forked)
+		if metadata := o.Metadata(message); metadata != nil {
+			copied := *metadata
+			ret.SetMetadata(&forked, &copied)
+		}
+	}
+	return
+}
+
+// Rewind drops the last turns of the session. A turn is a request, such as
+// a user message, and the answer to it.
+func (o *Session) Rewind(turns int) (err error) {
+	if turns <= 0 {
+		return fmt.Errorf("cannot rewind session %s by %d turns", o.Name, turns)
+	}
+	left := turns
+	for i := len(o.Messages) - 1; i >= 0; i-- {
+		if !isAnswer(o.Messages[i]) && (i == 0 || isAnswer(o.Messages[i-1])) {
+			if left--; left == 0 {
+				o.truncate(i)
+				return
+			}
+		}
+	}
+	return fmt.Errorf("session %s has fewer than %d turns", o.Name, turns)
+}
+
+// EditMessage replaces the text of the user message at index, counted from 1
+// as printed by String. The messages after it answered the original text and
+// are dropped.
+func (o *Session) EditMessage(index int, content string) (err error) {
+	if index < 1 || index > len(o.Messages) {
+		return fmt.Errorf("session %s has no message %d", o.Name, index)
+	}
+	message := o.Messages[index-1]
+	if message.Role != chat.ChatMessageRoleUser {
+		return fmt.Errorf("message %d of session %s is a %s message, only user messages can be edited", index, o.Name, message.Role)
+	}
+
+	if len(message.MultiContent) > 0 {
+		// The text takes the place of the text parts, attachments stay
+		parts := []chat.ChatMessagePart{{Type: chat.ChatMessagePartTypeText, Text: content}}
+		for _, part := range message.MultiContent {
+			if part.Type != chat.ChatMessagePartTypeText {
+				parts = append(parts, part)
+			}
+		}
+		message.MultiContent = parts
+	} else {
+		message.Content = content
+	}
+	o.truncate(index)
+	o.stamp([]*chat.ChatCompletionMessage{message})
+	o.Metadata(message).Edited = time.Now()
+	return
+}
+
+// DropAnswer drops the answer at the end of the session, so that the request
+// before it can be sent again for a new answer.
+func (o *Session) DropAnswer() (err error) {
+	n := len(o.Messages)
+	for n > 0 && isAnswer(o.Messages[n-1]) {
+		n--
+	}
+	if n == 0 {
+		return fmt.Errorf("session %s has no request to answer", o.Name)
+	}
+	o.truncate(n)
+	return
+}
+
+// isAnswer reports whether message is part of an answer: the response of
+// the model or the result of a tool it called.
+func isAnswer(message *chat.ChatCompletionMessage) bool {
+	return message.Role == chat.ChatMessageRoleAssistant || message.Role == chat.ChatMessageRoleTool
+}
+
+// truncate keeps the first n messages of the session.
+func (o *Session) truncate(n int) {
+	for _, message := range o.Messages[n:] {
+		o.SetMetadata(message, nil)
+		delete(o.excluded, message)
+	}
+	o.Messages = o.Messages[:n]
+	o.vendorMessages = nil
+}
+
 func (o *Session) appendVendorMessage(message *chat.ChatCompletionMessage) {
-	if message.Role != domain.ChatMessageRoleMeta {
-		o.vendorMessages = append(o.vendorMessages, message)
+	if message.Role != domain.ChatMessageRoleMeta && !o.excluded[message] {
+		o.vendorMessages = append(o.vendorMessages, o.vendorMessage(message))
 	}
 }
 
@@ -82,8 +564,17 @@ func (o *Session) GetLastMessage() (ret *chat.ChatCompletionMessage) {
 }
 
 func (o *Session) String() (ret string) {
-	for _, message := range o.Messages {
-		ret += fmt.Sprintf("\n--- \n[%v]\n%v", message.Role, message.Content)
+	for i, message := range o.Messages {
+		ret += fmt.Sprintf("\n--- #%d\n[%v]", i+1, message.Role)
+		if metadata := o.Metadata(message); metadata != nil {
+			if description := metadata.String(); description != "" {
+				ret += " (" + description + ")"
+			}
+		}
+		ret += fmt.Sprintf("\n%v", message.Content)
+		for _, call := range message.ToolCalls {
+			ret += fmt.Sprintf("\ntool_call %v: %v(%v)", call.ID, call.Function.Name, call.Function.Arguments)
+		}
 		if message.MultiContent != nil {
 			for _, part := range message.MultiContent {
 				switch part.Type {
@@ -95,5 +586,12 @@ func (o *Session) String() (ret string) {
 			}
 		}
 	}
+	if o.Model != "" {
+		ret += fmt.Sprintf("\n--- \n[model]\n%v|%v", o.Vendor, o.Model)
+	}
+	if o.Usage != nil {
+		ret += fmt.Sprintf("\n--- \n[usage]\ninput tokens: %d (cached %d), output tokens: %d (reasoning %d), cost: $%.4f",
+			o.Usage.InputTokens, o.Usage.CachedTokens, o.Usage.OutputTokens, o.Usage.ReasoningTokens, o.Usage.Cost)
+	}
 	return
 }
@@ -0,0 +1,218 @@
+package fsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+)
+
+// Session export formats
+const (
+	SessionFormatMarkdown = "md"
+	SessionFormatHTML     = "html"
+	SessionFormatJSONL    = "jsonl"
+	SessionFormatOpenAI   = "openai"
+)
+
+// SessionFormats are the formats a session can be exported to.
+var SessionFormats = []string{SessionFormatMarkdown, SessionFormatHTML, SessionFormatJSONL, SessionFormatOpenAI}
+
+// Export renders the session in format.
+//
+//   - md: Markdown with a section per message and images embedded as data URLs
+//   - html: a standalone HTML page with the same content
+//   - jsonl: one OpenAI style message per line, with its metadata under "meta"
+//   - openai: an OpenAI chat completions request with the messages of the session
+//
+// Attachments are embedded in every format.
+func (o *Session) Export(format string) (ret []byte, err error) {
+	var session *Session
+	if session, err = o.hydrated(); err != nil {
+		return
+	}
+	switch format {
+	case SessionFormatMarkdown:
+		ret = []byte(session.markdown())
+	case SessionFormatHTML:
+		ret, err = session.html()
+	case SessionFormatJSONL:
+		ret, err = session.jsonl()
+	case SessionFormatOpenAI:
+		ret, err = session.openAI()
+	default:
+		err = fmt.Errorf("unknown session format %q, use one of %s", format, strings.Join(SessionFormats, ", "))
+	}
+	return
+}
+
+func (o *Session) markdown() string {
+	var b strings.Builder
+	if o.Name != "" {
+		fmt.Fprintf(&b, "# %s\n", o.Name)
+	}
+	for _, message := range o.Messages {
+		fmt.Fprintf(&b, "\n## %s\n\n", roleTitle(message.Role))
+		if metadata := o.Metadata(message); metadata != nil {
+			if description := metadata.String(); description != "" {
+				fmt.Fprintf(&b, "_%s_\n\n", description)
+			}
+		}
+
+		text, images := messageParts(message)
+		if text != "" {
+			if message.Role == chat.ChatMessageRoleTool {
+				fmt.Fprintf(&b, "```\n%s\n```\n\n", text)
+			} else {
+				fmt.Fprintf(&b, "%s\n\n", text)
+			}
+		}
+		for i, image := range images {
+			fmt.Fprintf(&b, "![attachment %d](%s)\n\n", i+1, image)
+		}
+		for _, call := range message.ToolCalls {
+			fmt.Fprintf(&b, "**Tool call** `%s`\n\n```json\n%s\n```\n\n", call.Function.Name, call.Function.Arguments)
+		}
+	}
+	if o.Usage != nil {
+		fmt.Fprintf(&b, "---\n\n_%d input tokens, %d output tokens, $%.4f_\n",
+			o.Usage.InputTokens, o.Usage.OutputTokens, o.Usage.Cost)
+	}
+	return b.String()
+}
+
+// htmlMessage is a message as the HTML template shows it.
+type htmlMessage struct {
+	Role      string
+	Meta      string
+	Content   template.HTML
+	Images    []template.URL
+	ToolCalls []chat.ToolCall
+}
+
+var sessionTemplate = template.Must(template.New("session").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; line-height: 1.5; }
+section { border-top: 1px solid #ddd; padding: 0.5em 0; }
+.meta { color: #777; font-size: 0.85em; }
+pre { background: #f6f8fa; padding: 0.75em; overflow-x: auto; }
+img { max-width: 100%; }
+</style>
+</head>
+<body>
+{{if .Name}}<h1>{{.Name}}</h1>
+{{end}}{{range .Messages}}<section>
+<h2>{{.Role}}</h2>
+{{if .Meta}}<p class="meta">{{.Meta}}</p>
+{{end}}{{.Content}}
+{{range .Images}}<p><img src="{{.}}" alt="attachment"></p>
+{{end}}{{range .ToolCalls}}<p><strong>Tool call</strong> <code>{{.Function.Name}}</code></p>
+<pre><code>{{.Function.Arguments}}</code></pre>
+{{end}}</section>
+{{end}}</body>
+</html>
+`))
+
+func (o *Session) html() (ret []byte, err error) {
+	var messages []htmlMessage
+	for _, message := range o.Messages {
+		text, images := messageParts(message)
+		item := htmlMessage{Role: roleTitle(message.Role), ToolCalls: message.ToolCalls}
+		if metadata := o.Metadata(message); metadata != nil {
+			item.Meta = metadata.String()
+		}
+		if message.Role == chat.ChatMessageRoleTool {
+			item.Content = template.HTML("<pre><code>" + template.HTMLEscapeString(text) + "</code></pre>")
+		} else {
+			item.Content = renderHTML(text)
+		}
+		for _, image := range images {
+			// Attachments are embedded as data URLs, which the template only allows when told so
+			if strings.HasPrefix(image, "data:image/") || strings.HasPrefix(image, "https://") || strings.HasPrefix(image, "http://") {
+				item.Images = append(item.Images, template.URL(image))
+			}
+		}
+		messages = append(messages, item)
+	}
+
+	var b bytes.Buffer
+	err = sessionTemplate.Execute(&b, struct {
+		Name     string
+		Messages []htmlMessage
+	}{o.Name, messages})
+	ret = b.Bytes()
+	return
+}
+
+func (o *Session) jsonl() (ret []byte, err error) {
+	var b bytes.Buffer
+	for _, message := range o.Messages {
+		var line []byte
+		if line, err = json.Marshal(message); err != nil {
+			return
+		}
+		if metadata := o.Metadata(message); metadata != nil {
+			var meta []byte
+			if meta, err = json.Marshal(metadata); err != nil {
+				return
+			}
+			line = append(append(append(line[:len(line)-1], `,"meta":`...), meta...), '}')
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+	ret = b.Bytes()
+	return
+}
+
+// openAIChat is the body of an OpenAI chat completions request.
+type openAIChat struct {
+	Model    string                        `json:"model,omitempty"`
+	Messages []*chat.ChatCompletionMessage `json:"messages"`
+}
+
+func (o *Session) openAI() (ret []byte, err error) {
+	request := openAIChat{Model: o.Model, Messages: []*chat.ChatCompletionMessage{}}
+	for _, message := range o.Messages {
+		// Meta messages are fabric's own and not understood by other tools
+		if message.Role != domain.ChatMessageRoleMeta {
+			request.Messages = append(request.Messages, message)
+		}
+	}
+	return json.MarshalIndent(request, "", "  ")
+}
+
+// messageParts returns the text and the image URLs of message.
+func messageParts(message *chat.ChatCompletionMessage) (text string, images []string) {
+	if len(message.MultiContent) == 0 {
+		return message.Content, nil
+	}
+	var texts []string
+	for _, part := range message.MultiContent {
+		switch part.Type {
+		case chat.ChatMessagePartTypeText:
+			texts = append(texts, part.Text)
+		case chat.ChatMessagePartTypeImageURL:
+			if part.ImageURL != nil {
+				images = append(images, part.ImageURL.URL)
+			}
+		}
+	}
+	text = strings.Join(texts, "\n\n")
+	return
+}
+
+func roleTitle(role string) string {
+	if role == "" {
+		return "Message"
+	}
+	return strings.ToUpper(role[:1]) + role[1:]
+}
@@ -0,0 +1,118 @@
+package fsdb
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+)
+
+func newExportSession() *Session {
+	session := &Session{Name: "export", Vendor: "OpenAI", Model: "gpt-4o"}
+	session.Append(
+		&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, MultiContent: []chat.ChatMessagePart{
+			{Type: chat.ChatMessagePartTypeText, Text: "What is <this>?"},
+			{Type: chat.ChatMessagePartTypeImageURL, ImageURL: &chat.ChatMessageImageURL{URL: "data:image/png;base64,AAAA"}},
+		}},
+		&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleAssistant, Content: "A picture.\n\n```go\nfmt.Println(1 < 2)\n```"},
+	)
+	session.Metadata(session.Messages[1]).Model = "gpt-4o"
+	return session
+}
+
+func TestSession_Export(t *testing.T) {
+	session := newExportSession()
+
+	markdown, err := session.Export(SessionFormatMarkdown)
+	if err != nil {
+		t.Fatalf("failed to export Markdown: %v", err)
+	}
+	for _, want := range []string{"# export", "## User", "What is <this>?", "![attachment 1](data:image/png;base64,AAAA)", "## Assistant", "|gpt-4o"} {
+		if !strings.Contains(string(markdown), want) {
+			t.Errorf("expected Markdown to contain %q:\n%s", want, markdown)
+		}
+	}
+
+	html, err := session.Export(SessionFormatHTML)
+	if err != nil {
+		t.Fatalf("failed to export HTML: %v", err)
+	}
+	for _, want := range []string{"What is &lt;this&gt;?", `<img src="data:image/png;base64,AAAA"`, "<pre><code>fmt.Println(1 &lt; 2)</code></pre>"} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("expected HTML to contain %q:\n%s", want, html)
+		}
+	}
+
+	openAI, err := session.Export(SessionFormatOpenAI)
+	if err != nil {
+		t.Fatalf("failed to export OpenAI: %v", err)
+	}
+	var request openAIChat
+	if err = json.Unmarshal(openAI, &request); err != nil || request.Model != "gpt-4o" || len(request.Messages) != 2 {
+		t.Errorf("unexpected OpenAI export %s: %v", openAI, err)
+	}
+
+	if _, err = session.Export("pdf"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestSession_ExportImportJSONL(t *testing.T) {
+	jsonl, err := newExportSession().Export(SessionFormatJSONL)
+	if err != nil {
+		t.Fatalf("failed to export JSONL: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(jsonl)), "\n"); len(lines) != 2 {
+		t.Fatalf("expected one line per message, got %d", len(lines))
+	}
+
+	sessions, err := ImportSessions("imported", jsonl, "")
+	if err != nil {
+		t.Fatalf("failed to import JSONL: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Name != "imported" || len(sessions[0].Messages) != 2 {
+		t.Fatalf("unexpected import: %+v", sessions)
+	}
+	imported := sessions[0]
+	if len(imported.Messages[0].MultiContent) != 2 {
+		t.Errorf("expected the attachment to be imported, got %+v", imported.Messages[0])
+	}
+	if metadata := imported.Metadata(imported.Messages[1]); metadata == nil || metadata.Model != "gpt-4o" {
+		t.Errorf("expected the metadata to be imported, got %+v", metadata)
+	}
+}
+
+func TestImportSessions_OpenAI(t *testing.T) {
+	sessions, err := ImportSessions("chat", []byte(`{"model":"gpt-4o","messages":[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"}]}`), "")
+	if err != nil || len(sessions) != 1 || len(sessions[0].Messages) != 2 || sessions[0].Model != "gpt-4o" {
+		t.Fatalf("unexpected import of a chat request: %+v, %v", sessions, err)
+	}
+
+	// A ChatGPT export keeps the branch that was shown last
+	export := `[{"title":"First","current_node":"c","mapping":{
+		"root":{"parent":null,"message":null},
+		"a":{"parent":"root","message":{"author":{"role":"user"},"create_time":1700000000.5,"content":{"content_type":"text","parts":["question"]}}},
+		"b":{"parent":"a","message":{"author":{"role":"assistant"},"content":{"content_type":"text","parts":["old answer"]},"metadata":{"model_slug":"gpt-4"}}},
+		"c":{"parent":"a","message":{"author":{"role":"assistant"},"content":{"content_type":"text","parts":["new answer"]},"metadata":{"model_slug":"gpt-4o"}}}
+	}},{"title":"Second","current_node":"x","mapping":{
+		"x":{"parent":"","message":{"author":{"role":"user"},"content":{"content_type":"text","parts":["another"]}}}
+	}}]`
+	if sessions, err = ImportSessions("chatgpt", []byte(export), SessionFormatOpenAI); err != nil {
+		t.Fatalf("failed to import the ChatGPT export: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].Name != "chatgpt" || sessions[1].Name != "chatgpt-2" {
+		t.Fatalf("expected a session per conversation, got %+v", sessions)
+	}
+	first := sessions[0]
+	if len(first.Messages) != 2 || first.Messages[1].Content != "new answer" || first.Model != "gpt-4o" {
+		t.Errorf("unexpected conversation: %+v", first.Messages)
+	}
+	if metadata := first.Metadata(first.Messages[0]); metadata == nil || metadata.Time.Unix() != 1700000000 {
+		t.Errorf("expected the time of the message to be imported, got %+v", metadata)
+	}
+
+	if _, err = ImportSessions("empty", []byte(`[]`), ""); err == nil {
+		t.Error("expected an error for a file without conversations")
+	}
+}
@@ -0,0 +1,220 @@
+package fsdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+)
+
+// ImportSessions reads conversations exported by fabric or other tools:
+//
+//   - openai: an OpenAI chat completions request, a plain array of messages,
+//     or the conversations.json of a ChatGPT data export
+//   - jsonl: one OpenAI style message per line, optionally with fabric's "meta"
+//
+// An empty format detects which of the two data is in. A ChatGPT export may
+// hold several conversations; every conversation becomes a session, the first
+// called name and the others name-2, name-3 and so on.
+func ImportSessions(name string, data []byte, format string) (ret []*Session, err error) {
+	trimmed := bytes.TrimSpace(data)
+	if format == "" {
+		format = SessionFormatJSONL
+		if json.Valid(trimmed) {
+			format = SessionFormatOpenAI
+		}
+	}
+
+	switch format {
+	case SessionFormatOpenAI:
+		ret, err = importOpenAI(trimmed)
+	case SessionFormatJSONL:
+		var session *Session
+		if session, err = importJSONL(trimmed); err == nil {
+			ret = []*Session{session}
+		}
+	default:
+		err = fmt.Errorf("cannot import sessions from %q, use %s or %s", format, SessionFormatOpenAI, SessionFormatJSONL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("found no conversation to import")
+	}
+
+	for i, session := range ret {
+		if session.IsEmpty() {
+			return nil, fmt.Errorf("conversation %d has no messages to import", i+1)
+		}
+		session.Name = name
+		if i > 0 {
+			session.Name = fmt.Sprintf("%s-%d", name, i+1)
+		}
+	}
+	return
+}
+
+func importJSONL(data []byte) (ret *Session, err error) {
+	ret = &Session{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), math.MaxInt32)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		message := &chat.ChatCompletionMessage{}
+		var entry struct {
+			Meta *MessageMetadata `json:"meta"`
+		}
+		if err = json.Unmarshal(text, message); err == nil {
+			err = json.Unmarshal(text, &entry)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ret.Messages = append(ret.Messages, message)
+		ret.SetMetadata(message, entry.Meta)
+	}
+	err = scanner.Err()
+	return
+}
+
+func importOpenAI(data []byte) (ret []*Session, err error) {
+	// A plain array holds either messages or ChatGPT conversations
+	if len(data) > 0 && data[0] == '[' {
+		var items []json.RawMessage
+		if err = json.Unmarshal(data, &items); err != nil || len(items) == 0 {
+			return
+		}
+		var first struct {
+			Mapping json.RawMessage `json:"mapping"`
+		}
+		if err = json.Unmarshal(items[0], &first); err == nil && first.Mapping == nil {
+			session := &Session{}
+			err = json.Unmarshal(data, &session.Messages)
+			return []*Session{session}, err
+		}
+		for _, item := range items {
+			var session *Session
+			if session, err = importChatGPT(item); err != nil {
+				return
+			}
+			ret = append(ret, session)
+		}
+		return
+	}
+
+	var request struct {
+		Model    string                        `json:"model"`
+		Messages []*chat.ChatCompletionMessage `json:"messages"`
+		Mapping  json.RawMessage               `json:"mapping"`
+		Role     string                        `json:"role"`
+	}
+	if err = json.Unmarshal(data, &request); err != nil {
+		return
+	}
+	// A single line of JSONL is valid JSON too
+	if request.Role != "" {
+		var session *Session
+		if session, err = importJSONL(data); err == nil {
+			ret = []*Session{session}
+		}
+		return
+	}
+	if request.Mapping != nil {
+		var session *Session
+		if session, err = importChatGPT(data); err == nil {
+			ret = []*Session{session}
+		}
+		return
+	}
+	session := &Session{Messages: request.Messages, Model: request.Model}
+	ret = []*Session{session}
+	return
+}
+
+// chatGPTConversation is a conversation of a ChatGPT data export. Its
+// messages form a tree, of which current_node is the leaf that was shown last.
+type chatGPTConversation struct {
+	Title       string                 `json:"title"`
+	CurrentNode string                 `json:"current_node"`
+	Mapping     map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	Parent  string `json:"parent"`
+	Message *struct {
+		Author struct {
+			Role string `json:"role"`
+		} `json:"author"`
+		CreateTime float64 `json:"create_time"`
+		Content    struct {
+			ContentType string `json:"content_type"`
+			Parts       []any  `json:"parts"`
+		} `json:"content"`
+		Metadata struct {
+			ModelSlug string `json:"model_slug"`
+			Hidden    bool   `json:"is_visually_hidden_from_conversation"`
+		} `json:"metadata"`
+	} `json:"message"`
+}
+
+func importChatGPT(data []byte) (ret *Session, err error) {
+	var conversation chatGPTConversation
+	if err = json.Unmarshal(data, &conversation); err != nil {
+		return
+	}
+
+	var path []chatGPTNode
+	for id := conversation.CurrentNode; id != ""; {
+		node, ok := conversation.Mapping[id]
+		if !ok || len(path) > len(conversation.Mapping) {
+			return nil, fmt.Errorf("conversation %q has a broken message tree", conversation.Title)
+		}
+		path = append(path, node)
+		id = node.Parent
+	}
+	slices.Reverse(path)
+
+	ret = &Session{}
+	for _, node := range path {
+		source := node.Message
+		if source == nil || source.Metadata.Hidden {
+			continue
+		}
+		var text string
+		for _, part := range source.Content.Parts {
+			// Other parts point to uploaded files, which are not in the export file
+			if value, ok := part.(string); ok && value != "" {
+				if text != "" {
+					text += "\n\n"
+				}
+				text += value
+			}
+		}
+		if text == "" {
+			continue
+		}
+
+		message := &chat.ChatCompletionMessage{Role: source.Author.Role, Content: text}
+		ret.Messages = append(ret.Messages, message)
+		metadata := &MessageMetadata{}
+		if source.CreateTime > 0 {
+			sec, frac := math.Modf(source.CreateTime)
+			metadata.Time = time.Unix(int64(sec), int64(frac*1e9))
+		}
+		if source.Metadata.ModelSlug != "" && message.Role == chat.ChatMessageRoleAssistant {
+			metadata.Vendor, metadata.Model = "OpenAI", source.Metadata.ModelSlug
+			ret.Vendor, ret.Model = metadata.Vendor, metadata.Model
+		}
+		ret.SetMetadata(message, metadata)
+	}
+	return
+}
@@ -0,0 +1,323 @@
+package fsdb
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// The Markdown of session messages is rendered by a small renderer of its own,
+// which covers what models write: headings, lists, block quotes, rules,
+// tables, fenced code and, inline, code, links, images, emphasis and
+// strikethrough. Raw HTML is never passed through, every text is escaped, and
+// only links to web, mail and relative URLs are kept.
+
+var (
+	fencePattern      = regexp.MustCompile("^ {0,3}(```|~~~)")
+	headingPattern    = regexp.MustCompile(`^ {0,3}(#{1,6})(?:\s+(.*?))?(?:\s+#+)?\s*$`)
+	rulePattern       = regexp.MustCompile(`^ {0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	listItemPattern   = regexp.MustCompile(`^( {0,3})([-*+]|\d{1,9}[.)])(?:\s+(.*))?$`)
+	tableRulePattern  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(?:\|\s*:?-+:?\s*)*\|?\s*$`)
+	codeSpanPattern   = regexp.MustCompile("``(.+?)``|`([^`]+)`")
+	imagePattern      = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+	linkPattern       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongPattern     = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	emphasisPattern   = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*|(^|[^\pL\pN_])_(\S(?:.*?\S)?)_($|[^\pL\pN_])`)
+	strikePattern     = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	placeholderRegexp = regexp.MustCompile("\x1a(\\d+)\x1a")
+)
+
+// renderHTML renders Markdown text as HTML. Headings start at <h3>, below the
+// name of the session and the roles of its messages.
+func renderHTML(text string) template.HTML {
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n"))
+	return template.HTML(b.String())
+}
+
+// renderBlocks renders the blocks of lines.
+func renderBlocks(b *strings.Builder, lines []string) {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			i++
+		case fencePattern.MatchString(line):
+			i = renderFence(b, lines, i)
+		case headingPattern.MatchString(line):
+			match := headingPattern.FindStringSubmatch(line)
+			level := min(len(match[1])+2, 6)
+			fmt.Fprintf(b, "<h%d>%s</h%d>\n", level, renderInline(match[2]), level)
+			i++
+		case rulePattern.MatchString(line):
+			b.WriteString("<hr>\n")
+			i++
+		case strings.HasPrefix(strings.TrimLeft(line, " "), ">"):
+			i = renderQuote(b, lines, i)
+		case listItemPattern.MatchString(line):
+			i = renderList(b, lines, i)
+		case isTableStart(lines, i):
+			i = renderTable(b, lines, i)
+		default:
+			i = renderParagraph(b, lines, i)
+		}
+	}
+}
+
+func renderFence(b *strings.Builder, lines []string, start int) (next int) {
+	fence := fencePattern.FindStringSubmatch(lines[start])[1]
+	var code []string
+	for next = start + 1; next < len(lines); next++ {
+		if strings.HasPrefix(strings.TrimLeft(lines[next], " "), fence) {
+			next++
+			break
+		}
+		code = append(code, lines[next])
+	}
+	b.WriteString("<pre><code>" + template.HTMLEscapeString(strings.Join(code, "\n")) + "</code></pre>\n")
+	return
+}
+
+func renderQuote(b *strings.Builder, lines []string, start int) (next int) {
+	var quoted []string
+	for next = start; next < len(lines); next++ {
+		trimmed := strings.TrimLeft(lines[next], " ")
+		if !strings.HasPrefix(trimmed, ">") {
+			break
+		}
+		trimmed = strings.TrimPrefix(trimmed, ">")
+		quoted = append(quoted, strings.TrimPrefix(trimmed, " "))
+	}
+	b.WriteString("<blockquote>\n")
+	renderBlocks(b, quoted)
+	b.WriteString("</blockquote>\n")
+	return
+}
+
+// renderList renders the items of a list. The lines indented past the marker
+// of an item, and the blank lines between them, belong to it; they may hold
+// lists of their own.
+func renderList(b *strings.Builder, lines []string, start int) (next int) {
+	first := listItemPattern.FindStringSubmatch(lines[start])
+	ordered := first[2][0] >= '0' && first[2][0] <= '9'
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+		if number, _ := strconv.Atoi(strings.TrimRight(first[2], ".)")); number != 1 {
+			tag = fmt.Sprintf(`ol start="%d"`, number)
+		}
+	}
+
+	var items [][]string
+	loose := false
+	next = start
+	for next < len(lines) {
+		match := listItemPattern.FindStringSubmatch(lines[next])
+		if match == nil || (match[2][0] >= '0' && match[2][0] <= '9') != ordered {
+			break
+		}
+		indent := len(match[1]) + len(match[2]) + 1
+		item := []string{match[3]}
+		for next++; next < len(lines); next++ {
+			line := lines[next]
+			if strings.TrimSpace(line) == "" {
+				// A blank line ends the list unless the item or the list goes on after it
+				if next+1 < len(lines) && (leadingSpaces(lines[next+1]) >= min(indent, 2) || listItemPattern.MatchString(lines[next+1])) &&
+					strings.TrimSpace(lines[next+1]) != "" {
+					loose = loose || leadingSpaces(lines[next+1]) < min(indent, 2)
+					item = append(item, "")
+					continue
+				}
+				break
+			}
+			if leadingSpaces(line) < min(indent, 2) {
+				break
+			}
+			item = append(item, line[min(leadingSpaces(line), indent):])
+		}
+		items = append(items, item)
+		if next < len(lines) && strings.TrimSpace(lines[next]) == "" {
+			break
+		}
+	}
+
+	b.WriteString("<" + tag + ">\n")
+	for _, item := range items {
+		var content strings.Builder
+		renderBlocks(&content, item)
+		rendered := content.String()
+		// The first paragraph of the items of tight lists is their text
+		if !loose && strings.HasPrefix(rendered, "<p>") {
+			end := strings.Index(rendered, "</p>\n")
+			rendered = rendered[len("<p>"):end] + "\n" + rendered[end+len("</p>\n"):]
+		}
+		b.WriteString("<li>" + strings.TrimSuffix(rendered, "\n") + "</li>\n")
+	}
+	b.WriteString("</" + strings.Fields(tag)[0] + ">\n")
+	return
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// isTableStart tells whether a table header and its rule of dashes start at lines[i].
+func isTableStart(lines []string, i int) bool {
+	return strings.Contains(lines[i], "|") && i+1 < len(lines) && strings.Contains(lines[i+1], "-") &&
+		tableRulePattern.MatchString(lines[i+1])
+}
+
+func renderTable(b *strings.Builder, lines []string, start int) (next int) {
+	var aligns []string
+	for _, cell := range tableCells(lines[start+1]) {
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "center")
+		case strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "right")
+		case strings.HasPrefix(cell, ":"):
+			aligns = append(aligns, "left")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+	row := func(line, cellTag string) {
+		b.WriteString("<tr>")
+		for i, cell := range tableCells(line) {
+			open := cellTag
+			if i < len(aligns) && aligns[i] != "" {
+				open += ` style="text-align: ` + aligns[i] + `"`
+			}
+			b.WriteString("<" + open + ">" + renderInline(cell) + "</" + cellTag + ">")
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	b.WriteString("<table>\n<thead>\n")
+	row(lines[start], "th")
+	b.WriteString("</thead>\n<tbody>\n")
+	for next = start + 2; next < len(lines) && strings.Contains(lines[next], "|") && strings.TrimSpace(lines[next]) != ""; next++ {
+		row(lines[next], "td")
+	}
+	b.WriteString("</tbody>\n</table>\n")
+	return
+}
+
+// tableCells splits a table row at the pipes that are not escaped.
+func tableCells(line string) (ret []string) {
+	line = strings.TrimSpace(line)
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case line[i] == '|':
+			ret = append(ret, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(ret, strings.TrimSpace(cell.String()))
+}
+
+// renderParagraph renders the lines up to a blank one or the start of another
+// block, keeping their line breaks.
+func renderParagraph(b *strings.Builder, lines []string, start int) (next int) {
+	var texts []string
+	for next = start; next < len(lines); next++ {
+		line := lines[next]
+		if next > start && (strings.TrimSpace(line) == "" || fencePattern.MatchString(line) || headingPattern.MatchString(line) ||
+			rulePattern.MatchString(line) || strings.HasPrefix(strings.TrimLeft(line, " "), ">") ||
+			listItemPattern.MatchString(line) || isTableStart(lines, next)) {
+			break
+		}
+		texts = append(texts, renderInline(strings.TrimSpace(line)))
+	}
+	b.WriteString("<p>" + strings.Join(texts, "<br>\n") + "</p>\n")
+	return
+}
+
+// renderInline renders the code spans, images, links, emphasis and
+// strikethrough of text and escapes the rest. What is rendered already is
+// held out of the text as a placeholder, so that it is not rendered again.
+func renderInline(text string) string {
+	var held []string
+	hold := func(rendered string) string {
+		held = append(held, rendered)
+		return fmt.Sprintf("\x1a%d\x1a", len(held)-1)
+	}
+
+	text = strings.ReplaceAll(text, "\x1a", "")
+	text = codeSpanPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := codeSpanPattern.FindStringSubmatch(match)
+		return hold("<code>" + template.HTMLEscapeString(strings.TrimSpace(groups[1]+groups[2])) + "</code>")
+	})
+	text = template.HTMLEscapeString(text)
+
+	text = imagePattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := imagePattern.FindStringSubmatch(match)
+		if url, ok := safeURL(groups[2], true); ok {
+			return hold(`<img src="` + url + `" alt="` + groups[1] + `">`)
+		}
+		return hold(groups[1])
+	})
+	text = linkPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := linkPattern.FindStringSubmatch(match)
+		if url, ok := safeURL(groups[2], false); ok {
+			return hold(`<a href="` + url + `">` + renderEmphasis(groups[1]) + `</a>`)
+		}
+		return hold(renderEmphasis(groups[1]))
+	})
+	text = renderEmphasis(text)
+
+	// Placeholders may hold others, as links do code spans
+	for placeholderRegexp.MatchString(text) {
+		text = placeholderRegexp.ReplaceAllStringFunc(text, func(match string) string {
+			index, _ := strconv.Atoi(match[1 : len(match)-1])
+			return held[index]
+		})
+	}
+	return text
+}
+
+// renderEmphasis renders the bold, italic and struck out text of escaped text.
+func renderEmphasis(text string) string {
+	text = strongPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := strongPattern.FindStringSubmatch(match)
+		return "<strong>" + groups[1] + groups[2] + "</strong>"
+	})
+	text = emphasisPattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := emphasisPattern.FindStringSubmatch(match)
+		if groups[1] != "" {
+			return "<em>" + groups[1] + "</em>"
+		}
+		return groups[2] + "<em>" + groups[3] + "</em>" + groups[4]
+	})
+	return strikePattern.ReplaceAllString(text, "<del>$1</del>")
+}
+
+// safeURL returns the escaped url when it is a web, mail or relative URL, or
+// for images also a data URL of an image. Other schemes, like javascript:,
+// are dropped.
+func safeURL(escaped string, image bool) (string, bool) {
+	url := strings.ToLower(strings.TrimSpace(html.UnescapeString(escaped)))
+	scheme, _, found := strings.Cut(url, ":")
+	if !found || strings.ContainsAny(scheme, "/?#") {
+		return escaped, true
+	}
+	switch {
+	case scheme == "http" || scheme == "https":
+		return escaped, true
+	case scheme == "mailto" && !image:
+		return escaped, true
+	case image && strings.HasPrefix(url, "data:image/"):
+		return escaped, true
+	}
+	return "", false
+}
@@ -0,0 +1,97 @@
+package fsdb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "headings below the roles",
+			markdown: "# Title\n\n### Section ###\n\n####### seven",
+			want:     []string{"<h3>Title</h3>", "<h5>Section</h5>", "<p>####### seven</p>"},
+		},
+		{
+			name:     "paragraphs keep their line breaks",
+			markdown: "one\ntwo\n\nthree",
+			want:     []string{"<p>one<br>\ntwo</p>", "<p>three</p>"},
+		},
+		{
+			name:     "tight and nested lists",
+			markdown: "- one\n- two\n  - nested\n- three",
+			want:     []string{"<ul>\n<li>one</li>\n<li>two\n<ul>\n<li>nested</li>\n</ul></li>\n<li>three</li>\n</ul>"},
+			notWant:  []string{"<p>"},
+		},
+		{
+			name:     "ordered lists keep their start",
+			markdown: "3. three\n4. four",
+			want:     []string{`<ol start="3">`, "<li>three</li>", "<li>four</li>", "</ol>"},
+		},
+		{
+			name:     "loose lists",
+			markdown: "1. one\n\n2. two",
+			want:     []string{"<ol>", "<li><p>one</p></li>", "<li><p>two</p></li>"},
+		},
+		{
+			name:     "inline code is not rendered",
+			markdown: "run `go test ./... <x> **not bold**` now",
+			want:     []string{"<code>go test ./... &lt;x&gt; **not bold**</code>"},
+			notWant:  []string{"<strong>"},
+		},
+		{
+			name:     "links, emphasis and strikethrough",
+			markdown: "see [the **docs**](https://example.com/a?b=1&c=2), *this*, __that__ and ~~old~~ but not snake_case_name",
+			want: []string{
+				`<a href="https://example.com/a?b=1&amp;c=2">the <strong>docs</strong></a>`,
+				"<em>this</em>", "<strong>that</strong>", "<del>old</del>", "snake_case_name",
+			},
+		},
+		{
+			name:     "unsafe links are dropped",
+			markdown: "[click](javascript:alert(1)) [mail](mailto:a@b.c) ![x](javascript:alert(1)) [rel](docs/a.md)",
+			want:     []string{"click", `<a href="mailto:a@b.c">mail</a>`, `<a href="docs/a.md">rel</a>`},
+			notWant:  []string{"javascript:", "<img"},
+		},
+		{
+			name:     "raw HTML is escaped",
+			markdown: "<script>alert(1)</script>\n\n> <b>quoted</b>",
+			want:     []string{"&lt;script&gt;", "<blockquote>\n<p>&lt;b&gt;quoted&lt;/b&gt;</p>\n</blockquote>"},
+			notWant:  []string{"<script>", "<b>"},
+		},
+		{
+			name:     "tables",
+			markdown: "| Name | Size |\n|:-----|-----:|\n| a `b|c` | 1 |\n| x \\| y | 2 |",
+			want: []string{
+				"<table>", `<th style="text-align: left">Name</th>`, `<th style="text-align: right">Size</th>`,
+				"<td style=\"text-align: left\">x | y</td>", "</table>",
+			},
+		},
+		{
+			name:     "fenced code and rules",
+			markdown: "~~~\n# not a heading\n~~~\n\n---",
+			want:     []string{"<pre><code># not a heading</code></pre>", "<hr>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(renderHTML(tt.markdown))
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("expected %q in:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("did not expect %q in:\n%s", notWant, got)
+				}
+			}
+		})
+	}
+}
@@ -1,15 +1,21 @@
 package fsdb
 
 import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/domain"
+	"github.com/danielmiessler/fabric/internal/plugins/db/sqlitedb"
 )
 
 func TestSessions_GetOrCreateSession(t *testing.T) {
 	dir := t.TempDir()
 	sessions := &SessionsEntity{
-		StorageEntity: &StorageEntity{Dir: dir, FileExtension: ".json"},
+		Store: &StorageEntity{Dir: dir, FileExtension: ".json"},
 	}
 	sessionName := "testSession"
 	session, err := sessions.Get(sessionName)
@@ -24,7 +30,7 @@ func TestSessions_GetOrCreateSession(t *testing.T) {
 func TestSessions_SaveSession(t *testing.T) {
 	dir := t.TempDir()
 	sessions := &SessionsEntity{
-		StorageEntity: &StorageEntity{Dir: dir, FileExtension: ".json"},
+		Store: &StorageEntity{Dir: dir, FileExtension: ".json"},
 	}
 	sessionName := "testSession"
 	session := &Session{Name: sessionName, Messages: []*chat.ChatCompletionMessage{{Content: "message1"}}}
@@ -36,3 +42,209 @@ func TestSessions_SaveSession(t *testing.T) {
 		t.Errorf("expected session to be saved")
 	}
 }
+
+func TestSessions_UsageRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	sessions := &SessionsEntity{
+		Store: &StorageEntity{Dir: dir, FileExtension: ".json"},
+	}
+
+	// Sessions saved before usage tracking are a plain message array
+	if err := sessions.Save("legacy", []byte(`[{"role":"user","content":"hi"}]`)); err != nil {
+		t.Fatalf("failed to save legacy session: %v", err)
+	}
+	session, err := sessions.Get("legacy")
+	if err != nil {
+		t.Fatalf("failed to load legacy session: %v", err)
+	}
+	if len(session.Messages) != 1 || session.Usage != nil {
+		t.Fatalf("unexpected legacy session: %+v", session)
+	}
+
+	session.AddUsage(&domain.UsageMetadata{InputTokens: 10, OutputTokens: 5, Cost: 0.01})
+	session.AddUsage(&domain.UsageMetadata{InputTokens: 20, OutputTokens: 5})
+	if err = sessions.SaveSession(session); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	loaded, err := sessions.Get("legacy")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	if len(loaded.Messages) != 1 {
+		t.Errorf("expected 1 message, got %d", len(loaded.Messages))
+	}
+	if loaded.Usage == nil || loaded.Usage.InputTokens != 30 || loaded.Usage.OutputTokens != 10 || loaded.Usage.Cost != 0.01 {
+		t.Errorf("unexpected usage totals: %+v", loaded.Usage)
+	}
+}
+
+func TestSession_ExcludeAndReplace(t *testing.T) {
+	first := &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "first"}
+	answer := &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleAssistant, Content: "answer"}
+	last := &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "last"}
+	session := &Session{}
+	session.Append(first, answer, last)
+	session.GetVendorMessages()
+
+	session.Exclude(first, answer)
+	if got := session.GetVendorMessages(); len(got) != 1 || got[0] != last {
+		t.Errorf("expected only the last message to be sent, got %v", got)
+	}
+	if len(session.Messages) != 3 {
+		t.Errorf("expected excluded messages to stay in the session, got %d", len(session.Messages))
+	}
+
+	summary := &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleAssistant, Content: "summary"}
+	session = &Session{}
+	session.Append(first, answer, last)
+	session.Replace([]*chat.ChatCompletionMessage{first, answer}, summary)
+	if len(session.Messages) != 2 || session.Messages[0] != summary || session.GetVendorMessages()[1] != last {
+		t.Errorf("expected the summary in place of the replaced messages, got %v", session.Messages)
+	}
+}
+
+func TestSessions_MessageMetadata(t *testing.T) {
+	dir := t.TempDir()
+	sessions := &SessionsEntity{
+		Store: &StorageEntity{Dir: dir, FileExtension: ".json"},
+	}
+
+	// Sessions saved before message metadata only know the model that answered last
+	if err := sessions.Save("v1", []byte(`{"messages":[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"}],"vendor":"OpenAI","model":"gpt-4o"}`)); err != nil {
+		t.Fatalf("failed to save v1 session: %v", err)
+	}
+	session, err := sessions.Get("v1")
+	if err != nil {
+		t.Fatalf("failed to load v1 session: %v", err)
+	}
+	if len(session.Messages) != 2 || session.Metadata(session.Messages[0]) != nil {
+		t.Fatalf("unexpected v1 session: %+v", session)
+	}
+	if content, _ := sessions.Load("v1"); !strings.HasPrefix(string(content), `{"messages"`) {
+		t.Errorf("expected loading to leave the v1 session as it was, got %s", content)
+	}
+	if metadata := session.Metadata(session.Messages[1]); metadata == nil || metadata.Model != "gpt-4o" || metadata.Vendor != "OpenAI" {
+		t.Errorf("expected the last answer to be attributed to OpenAI|gpt-4o, got %+v", metadata)
+	}
+
+	answer := &chat.ChatCompletionMessage{Role: chat.ChatMessageRoleAssistant, Content: "again"}
+	session.Append(answer)
+	metadata := session.Metadata(answer)
+	if metadata == nil || metadata.Time.IsZero() {
+		t.Fatalf("expected appended messages to be stamped, got %+v", metadata)
+	}
+	metadata.Model, metadata.Pattern = "claude", "summarize"
+	metadata.Usage = &domain.UsageMetadata{InputTokens: 3, OutputTokens: 4}
+	if err = sessions.SaveSession(session); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	loaded, err := sessions.Get("v1")
+	if err != nil {
+		t.Fatalf("failed to load session: %v", err)
+	}
+	if len(loaded.Messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(loaded.Messages))
+	}
+	got := loaded.Metadata(loaded.Messages[2])
+	if got == nil || got.Model != "claude" || got.Pattern != "summarize" || got.Usage == nil || got.Usage.OutputTokens != 4 {
+		t.Errorf("unexpected metadata after round trip: %+v", got)
+	}
+
+	if err = sessions.Save("future", []byte(`{"version":99,"messages":[]}`)); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+	if _, err = sessions.Get("future"); err == nil {
+		t.Errorf("expected an error for a newer session format")
+	}
+}
+
+func TestSession_ForkRewindEdit(t *testing.T) {
+	newSession := func() *Session {
+		session := &Session{Name: "original"}
+		session.Append(
+			&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleSystem, Content: "system"},
+			&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "first"},
+			&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleAssistant, Content: "answer 1"},
+			&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "second"},
+			&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleAssistant, Content: "answer 2"},
+		)
+		return session
+	}
+
+	session := newSession()
+	fork, err := session.Fork("fork", 3)
+	if err != nil {
+		t.Fatalf("failed to fork: %v", err)
+	}
+	if fork.Name != "fork" || len(fork.Messages) != 3 || fork.Metadata(fork.Messages[2]) == nil {
+		t.Fatalf("unexpected fork: %+v", fork)
+	}
+	fork.Messages[1].Content = "changed"
+	if session.Messages[1].Content != "first" {
+		t.Error("expected the fork not to share messages with the original")
+	}
+	if _, err = session.Fork("fork", 6); err == nil {
+		t.Error("expected an error when forking past the last message")
+	}
+
+	if err = session.Rewind(1); err != nil || len(session.Messages) != 3 {
+		t.Errorf("expected one turn to be dropped, got %d messages, %v", len(session.Messages), err)
+	}
+	if err = session.Rewind(2); err == nil {
+		t.Error("expected an error when rewinding past the first turn")
+	}
+
+	session = newSession()
+	if err = session.EditMessage(3, "edited"); err == nil {
+		t.Error("expected an error when editing an answer")
+	}
+	if err = session.EditMessage(2, "edited"); err != nil {
+		t.Fatalf("failed to edit: %v", err)
+	}
+	if len(session.Messages) != 2 || session.Messages[1].Content != "edited" || session.Metadata(session.Messages[1]).Edited.IsZero() {
+		t.Errorf("expected the edited message to be the last one, got %v", session.Messages)
+	}
+
+	if err = session.DropAnswer(); err != nil || len(session.Messages) != 2 {
+		t.Errorf("expected nothing to drop after the edit, got %d messages, %v", len(session.Messages), err)
+	}
+	session = newSession()
+	if err = session.DropAnswer(); err != nil || session.GetLastMessage().Content != "second" {
+		t.Errorf("expected the last answer to be dropped, got %v", session.Messages)
+	}
+}
+
+func TestSessions_LockConcurrentChats(t *testing.T) {
+	sqlite := sqlitedb.NewDb(filepath.Join(t.TempDir(), "fabric.db"))
+	defer sqlite.Close()
+	sessions := &SessionsEntity{Store: sqlite.NewStorageEntity("Sessions", "session")}
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer sessions.Lock("shared")()
+			session, err := sessions.Get("shared")
+			if err != nil {
+				t.Errorf("failed to get session: %v", err)
+				return
+			}
+			session.Append(&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: fmt.Sprint(i)})
+			if err = sessions.SaveSession(session); err != nil {
+				t.Errorf("failed to save session: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	session, err := sessions.Get("shared")
+	if err != nil || len(session.Messages) != 20 {
+		t.Errorf("expected every chat to keep its message, got %d: %v", len(session.Messages), err)
+	}
+	if len(sessions.locks.locks) != 0 {
+		t.Errorf("expected the locks to be released")
+	}
+}
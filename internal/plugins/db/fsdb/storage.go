@@ -2,20 +2,37 @@ package fsdb
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/danielmiessler/fabric/internal/i18n"
 	"github.com/danielmiessler/fabric/internal/util"
 )
 
+// ErrInvalidName is the error of names that are not the name of a file, so
+// that their item would be outside of the directory of the items.
+var ErrInvalidName = errors.New("invalid name")
+
+// ValidateName fails with ErrInvalidName for empty names and names with a
+// path separator or "..".
+func ValidateName(name string) error {
+	if name == "" || name == "." || strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") || filepath.Base(name) != name {
+		return fmt.Errorf("%w %q", ErrInvalidName, name)
+	}
+	return nil
+}
+
 type StorageEntity struct {
 	Label         string
 	Dir           string
 	ItemIsDir     bool
 	FileExtension string
+	// FileMode of the saved files, 0644 when it is not set
+	FileMode os.FileMode
 }
 
 func (o *StorageEntity) Configure() (err error) {
@@ -56,7 +73,7 @@ func (o *StorageEntity) GetNames() (ret []string, err error) {
 			}
 		} else {
 			// Include files, optionally filtering by extension
-			if !fileInfo.IsDir() {
+			if !fileInfo.IsDir() && !isSaveTempFile(entry.Name()) {
 				if o.FileExtension == "" || filepath.Ext(entry.Name()) == o.FileExtension {
 					ret = append(ret, strings.TrimSuffix(entry.Name(), o.FileExtension))
 				}
@@ -81,19 +98,62 @@ func (o *StorageEntity) Exists(name string) (ret bool) {
 }
 
 func (o *StorageEntity) Rename(oldName, newName string) (err error) {
-	if err = os.Rename(o.BuildFilePathByName(oldName), o.BuildFilePathByName(newName)); err != nil {
+	if err = ValidateName(newName); err == nil {
+		err = os.Rename(o.BuildFilePathByName(oldName), o.BuildFilePathByName(newName))
+	}
+	if err != nil {
 		err = fmt.Errorf("could not rename %s to %s: %v", oldName, newName, err)
 	}
 	return
 }
 
+// saveTempSuffix ends the names of the temporary files of Save, which are not items
+const saveTempSuffix = ".tmp"
+
+// Save writes the content to a temporary file first and renames it over the
+// item, so that readers never see a partly written item.
 func (o *StorageEntity) Save(name string, content []byte) (err error) {
-	if err = os.WriteFile(o.BuildFilePathByName(name), content, 0644); err != nil {
+	var temp string
+	if temp, err = o.writeTemp(name, content); err == nil {
+		if err = os.Rename(temp, o.BuildFilePathByName(name)); err != nil {
+			os.Remove(temp)
+		}
+	}
+	if err != nil {
 		err = fmt.Errorf("could not save %s: %v", name, err)
 	}
 	return
 }
 
+// writeTemp writes content to a temporary file next to the file of the item,
+// which takes its place when it is renamed to it.
+func (o *StorageEntity) writeTemp(name string, content []byte) (ret string, err error) {
+	if err = ValidateName(name); err != nil {
+		return
+	}
+	path := o.BuildFilePathByName(name)
+	var file *os.File
+	if file, err = os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*"+saveTempSuffix); err != nil {
+		return
+	}
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		mode := o.FileMode
+		if mode == 0 {
+			mode = 0644
+		}
+		err = os.Chmod(file.Name(), mode)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return
+	}
+	return file.Name(), nil
+}
+
 func (o *StorageEntity) Load(name string) (ret []byte, err error) {
 	if ret, err = os.ReadFile(o.BuildFilePathByName(name)); err != nil {
 		err = fmt.Errorf("could not load %s: %v", name, err)
@@ -101,6 +161,27 @@ func (o *StorageEntity) Load(name string) (ret []byte, err error) {
 	return
 }
 
+func isSaveTempFile(name string) bool {
+	return strings.HasPrefix(name, ".") && strings.HasSuffix(name, saveTempSuffix)
+}
+
+func (o *StorageEntity) Stat(name string) (modTime time.Time, size int64, err error) {
+	var info os.FileInfo
+	if info, err = os.Stat(o.BuildFilePathByName(name)); err != nil {
+		err = fmt.Errorf("could not stat %s: %v", name, err)
+		return
+	}
+	return info.ModTime(), info.Size(), nil
+}
+
+func (o *StorageEntity) Touch(name string) (err error) {
+	now := time.Now()
+	if err = os.Chtimes(o.BuildFilePathByName(name), now, now); err != nil {
+		err = fmt.Errorf("could not touch %s: %v", name, err)
+	}
+	return
+}
+
 func (o *StorageEntity) ListNames(shellCompleteList bool) (err error) {
 	var names []string
 	if names, err = o.GetNames(); err != nil {
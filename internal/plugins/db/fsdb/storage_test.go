@@ -1,6 +1,8 @@
 package fsdb
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -50,3 +52,22 @@ func TestStorage_Delete(t *testing.T) {
 		t.Errorf("expected file to be deleted")
 	}
 }
+
+func TestStorage_SaveInvalidName(t *testing.T) {
+	dir := t.TempDir()
+	storage := &StorageEntity{Dir: filepath.Join(dir, "sessions"), FileExtension: ".json"}
+	if err := storage.Configure(); err != nil {
+		t.Fatalf("failed to configure storage: %v", err)
+	}
+	for _, name := range []string{"", "../outside", "a/b", `a\b`, "..", "."} {
+		if err := storage.Save(name, []byte("{}")); err == nil {
+			t.Errorf("expected saving %q to fail", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside.json")); !os.IsNotExist(err) {
+		t.Errorf("expected no file outside the directory, got %v", err)
+	}
+	if err := storage.Rename("missing", "../outside"); err == nil {
+		t.Error("expected renaming to a path to fail")
+	}
+}
@@ -0,0 +1,168 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// DataSource returns the data source name opening the SQLite database at path
+// in WAL mode, so that readers do not wait for writers. Transactions take the
+// write lock when they begin, and wait for each other instead of failing.
+func DataSource(path string) string {
+	return path + "?_busy_timeout=10000&_journal_mode=WAL&_txlock=immediate"
+}
+
+// NewDb returns the database in the file at path. It is opened when it is first used.
+func NewDb(path string) *Db {
+	return &Db{Path: path}
+}
+
+// Db keeps the items of several entities, such as sessions and contexts, in
+// one SQLite database. Every change is a transaction of its own, and the
+// database is in WAL mode so that readers do not wait for writers.
+type Db struct {
+	Path string
+
+	mu   sync.Mutex
+	conn *sql.DB
+}
+
+// Item is an item of an entity, with the time it was saved last.
+type Item struct {
+	Kind    string
+	Name    string
+	Content []byte
+	ModTime time.Time
+}
+
+func (o *Db) Configure() (err error) {
+	_, err = o.connection()
+	return
+}
+
+// Close closes the database, the next use opens it again.
+func (o *Db) Close() (err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.conn != nil {
+		err = o.conn.Close()
+		o.conn = nil
+	}
+	return
+}
+
+// NewStorageEntity returns the storage of the items of kind, which ListNames calls label.
+func (o *Db) NewStorageEntity(label, kind string) *StorageEntity {
+	return &StorageEntity{Label: label, Kind: kind, Db: o}
+}
+
+// Import adds items in one transaction. Nothing is added when one of them exists already.
+func (o *Db) Import(items []Item) (err error) {
+	var conn *sql.DB
+	if conn, err = o.connection(); err != nil {
+		return
+	}
+	var tx *sql.Tx
+	if tx, err = conn.Begin(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	for _, item := range items {
+		var exists bool
+		if exists, err = itemExists(tx, item.Kind, item.Name); err != nil {
+			return
+		}
+		if exists {
+			return fmt.Errorf("%s %s exists already", item.Kind, item.Name)
+		}
+		if _, err = tx.Exec(`INSERT INTO items (kind, name, content, mod_time) VALUES (?, ?, ?, ?)`,
+			item.Kind, item.Name, item.Content, item.ModTime.UnixNano()); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Replace saves the content of items that exist already in one transaction,
+// so that either all of them change or none does.
+func (o *Db) Replace(items []Item) (err error) {
+	var conn *sql.DB
+	if conn, err = o.connection(); err != nil {
+		return
+	}
+	var tx *sql.Tx
+	if tx, err = conn.Begin(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	now := time.Now().UnixNano()
+	for _, item := range items {
+		var result sql.Result
+		if result, err = tx.Exec(`UPDATE items SET content = ?, mod_time = ? WHERE kind = ? AND name = ?`,
+			item.Content, now, item.Kind, item.Name); err != nil {
+			return
+		}
+		if updated, _ := result.RowsAffected(); updated == 0 {
+			return fmt.Errorf("%s %s does not exist", item.Kind, item.Name)
+		}
+	}
+	return
+}
+
+// connection opens the database and creates its tables on the first call.
+func (o *Db) connection() (ret *sql.DB, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.conn != nil {
+		return o.conn, nil
+	}
+
+	if err = os.MkdirAll(filepath.Dir(o.Path), os.ModePerm); err != nil {
+		return
+	}
+	if ret, err = sql.Open("sqlite3", DataSource(o.Path)); err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", o.Path, err)
+	}
+	if _, err = ret.Exec(`CREATE TABLE IF NOT EXISTS items (
+		kind TEXT NOT NULL,
+		name TEXT NOT NULL,
+		content BLOB NOT NULL,
+		mod_time INTEGER NOT NULL,
+		PRIMARY KEY (kind, name)
+	)`); err != nil {
+		ret.Close()
+		return nil, fmt.Errorf("could not open %s: %w", o.Path, err)
+	}
+	o.conn = ret
+	return
+}
+
+// querier is what *sql.DB and *sql.Tx have in common.
+type querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func itemExists(q querier, kind, name string) (ret bool, err error) {
+	err = q.QueryRow(`SELECT EXISTS (SELECT 1 FROM items WHERE kind = ? AND name = ?)`, kind, name).Scan(&ret)
+	return
+}
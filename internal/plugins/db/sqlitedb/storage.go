@@ -0,0 +1,183 @@
+package sqlitedb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/danielmiessler/fabric/internal/i18n"
+)
+
+// StorageEntity keeps the items of one kind as rows of the database.
+type StorageEntity struct {
+	Label string
+	Kind  string
+	Db    *Db
+}
+
+func (o *StorageEntity) Configure() (err error) {
+	return o.Db.Configure()
+}
+
+func (o *StorageEntity) GetNames() (ret []string, err error) {
+	var conn *sql.DB
+	if conn, err = o.Db.connection(); err != nil {
+		return
+	}
+	var rows *sql.Rows
+	if rows, err = conn.Query(`SELECT name FROM items WHERE kind = ? ORDER BY name`, o.Kind); err != nil {
+		return nil, fmt.Errorf("could not read items from database: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		ret = append(ret, name)
+	}
+	err = rows.Err()
+	return
+}
+
+func (o *StorageEntity) Delete(name string) (err error) {
+	var conn *sql.DB
+	if conn, err = o.Db.connection(); err == nil {
+		_, err = conn.Exec(`DELETE FROM items WHERE kind = ? AND name = ?`, o.Kind, name)
+	}
+	if err != nil {
+		err = fmt.Errorf("could not delete %s: %v", name, err)
+	}
+	return
+}
+
+func (o *StorageEntity) Exists(name string) (ret bool) {
+	conn, err := o.Db.connection()
+	if err != nil {
+		return false
+	}
+	ret, _ = itemExists(conn, o.Kind, name)
+	return
+}
+
+// Rename fails when an item called newName exists already, unlike renaming a file.
+func (o *StorageEntity) Rename(oldName, newName string) (err error) {
+	var conn *sql.DB
+	var tx *sql.Tx
+	if conn, err = o.Db.connection(); err == nil {
+		tx, err = conn.Begin()
+	}
+	if err == nil {
+		err = rename(tx, o.Kind, oldName, newName)
+		if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("could not rename %s to %s: %v", oldName, newName, err)
+	}
+	return
+}
+
+func rename(tx *sql.Tx, kind, oldName, newName string) (err error) {
+	var exists bool
+	if exists, err = itemExists(tx, kind, newName); err != nil {
+		return
+	}
+	if exists {
+		return errors.New("the new name is taken")
+	}
+	var result sql.Result
+	if result, err = tx.Exec(`UPDATE items SET name = ?, mod_time = ? WHERE kind = ? AND name = ?`,
+		newName, time.Now().UnixNano(), kind, oldName); err != nil {
+		return
+	}
+	if renamed, _ := result.RowsAffected(); renamed == 0 {
+		return errors.New("it does not exist")
+	}
+	return
+}
+
+func (o *StorageEntity) Save(name string, content []byte) (err error) {
+	var conn *sql.DB
+	if conn, err = o.Db.connection(); err == nil {
+		_, err = conn.Exec(`INSERT INTO items (kind, name, content, mod_time) VALUES (?, ?, ?, ?)
+			ON CONFLICT (kind, name) DO UPDATE SET content = excluded.content, mod_time = excluded.mod_time`,
+			o.Kind, name, content, time.Now().UnixNano())
+	}
+	if err != nil {
+		err = fmt.Errorf("could not save %s: %v", name, err)
+	}
+	return
+}
+
+func (o *StorageEntity) Load(name string) (ret []byte, err error) {
+	var conn *sql.DB
+	if conn, err = o.Db.connection(); err == nil {
+		err = conn.QueryRow(`SELECT content FROM items WHERE kind = ? AND name = ?`, o.Kind, name).Scan(&ret)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = errors.New("it does not exist")
+		}
+		err = fmt.Errorf("could not load %s: %v", name, err)
+	}
+	return
+}
+
+func (o *StorageEntity) Stat(name string) (modTime time.Time, size int64, err error) {
+	var conn *sql.DB
+	if conn, err = o.Db.connection(); err == nil {
+		var nanos int64
+		if err = conn.QueryRow(`SELECT mod_time, length(content) FROM items WHERE kind = ? AND name = ?`,
+			o.Kind, name).Scan(&nanos, &size); err == nil {
+			modTime = time.Unix(0, nanos)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = errors.New("it does not exist")
+		}
+		err = fmt.Errorf("could not stat %s: %v", name, err)
+	}
+	return
+}
+
+func (o *StorageEntity) Touch(name string) (err error) {
+	var conn *sql.DB
+	if conn, err = o.Db.connection(); err == nil {
+		var result sql.Result
+		if result, err = conn.Exec(`UPDATE items SET mod_time = ? WHERE kind = ? AND name = ?`,
+			time.Now().UnixNano(), o.Kind, name); err == nil {
+			if rows, _ := result.RowsAffected(); rows == 0 {
+				err = errors.New("it does not exist")
+			}
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("could not touch %s: %v", name, err)
+	}
+	return
+}
+
+func (o *StorageEntity) ListNames(shellCompleteList bool) (err error) {
+	var names []string
+	if names, err = o.GetNames(); err != nil {
+		return
+	}
+
+	if len(names) == 0 {
+		if !shellCompleteList {
+			fmt.Printf("%s\n", fmt.Sprintf(i18n.T("no_items_found"), o.Label))
+		}
+		return
+	}
+
+	for _, item := range names {
+		fmt.Printf("%s\n", item)
+	}
+	return
+}
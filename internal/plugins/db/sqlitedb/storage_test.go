@@ -0,0 +1,134 @@
+package sqlitedb
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestStorage(t *testing.T) *StorageEntity {
+	t.Helper()
+	db := NewDb(filepath.Join(t.TempDir(), "fabric.db"))
+	t.Cleanup(func() { db.Close() })
+	storage := db.NewStorageEntity("Sessions", "session")
+	if err := storage.Configure(); err != nil {
+		t.Fatalf("failed to open the database: %v", err)
+	}
+	return storage
+}
+
+func TestStorage_SaveLoadDelete(t *testing.T) {
+	storage := newTestStorage(t)
+	if storage.Exists("test") {
+		t.Errorf("expected the item to not exist")
+	}
+	if _, err := storage.Load("test"); err == nil {
+		t.Errorf("expected an error when loading a missing item")
+	}
+
+	for _, content := range []string{"first", "second"} {
+		if err := storage.Save("test", []byte(content)); err != nil {
+			t.Fatalf("failed to save content: %v", err)
+		}
+	}
+	loaded, err := storage.Load("test")
+	if err != nil || string(loaded) != "second" {
+		t.Errorf("expected the last content, got %q, %v", loaded, err)
+	}
+	if _, size, err := storage.Stat("test"); err != nil || size != int64(len("second")) {
+		t.Errorf("unexpected stat %d, %v", size, err)
+	}
+
+	before, _, _ := storage.Stat("test")
+	if err = storage.Touch("test"); err != nil {
+		t.Fatalf("failed to touch: %v", err)
+	}
+	if modTime, _, _ := storage.Stat("test"); !modTime.After(before) {
+		t.Errorf("expected touching to move the mod time, got %v after %v", modTime, before)
+	}
+	if err = storage.Touch("missing"); err == nil {
+		t.Errorf("expected an error when touching a missing item")
+	}
+
+	// Items of other kinds share the database but not the names
+	contexts := storage.Db.NewStorageEntity("Contexts", "context")
+	if contexts.Exists("test") {
+		t.Errorf("expected the item to be of its kind only")
+	}
+
+	if err = storage.Delete("test"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if names, _ := storage.GetNames(); len(names) != 0 {
+		t.Errorf("expected no items left, got %v", names)
+	}
+}
+
+func TestStorage_Rename(t *testing.T) {
+	storage := newTestStorage(t)
+	for _, name := range []string{"a", "b"} {
+		if err := storage.Save(name, []byte(name)); err != nil {
+			t.Fatalf("failed to save content: %v", err)
+		}
+	}
+	if err := storage.Rename("a", "b"); err == nil {
+		t.Errorf("expected an error when the new name is taken")
+	}
+	if err := storage.Rename("missing", "c"); err == nil {
+		t.Errorf("expected an error when the item does not exist")
+	}
+	if err := storage.Rename("a", "c"); err != nil {
+		t.Fatalf("failed to rename: %v", err)
+	}
+	if names, _ := storage.GetNames(); len(names) != 2 || names[0] != "b" || names[1] != "c" {
+		t.Errorf("unexpected names after rename: %v", names)
+	}
+}
+
+func TestDb_Import(t *testing.T) {
+	storage := newTestStorage(t)
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := storage.Db.Import([]Item{{Kind: "session", Name: "old", Content: []byte("x"), ModTime: modTime}}); err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if saved, _, err := storage.Stat("old"); err != nil || !saved.Equal(modTime) {
+		t.Errorf("expected the time of the item to be kept, got %v, %v", saved, err)
+	}
+
+	// A clash leaves the database as it was
+	err := storage.Db.Import([]Item{
+		{Kind: "session", Name: "new", Content: []byte("y"), ModTime: modTime},
+		{Kind: "session", Name: "old", Content: []byte("z"), ModTime: modTime},
+	})
+	if err == nil || storage.Exists("new") {
+		t.Errorf("expected the import to fail as a whole, got %v", err)
+	}
+}
+
+func TestDb_Replace(t *testing.T) {
+	storage := newTestStorage(t)
+	for _, name := range []string{"a", "b"} {
+		if err := storage.Save(name, []byte("old")); err != nil {
+			t.Fatalf("failed to save: %v", err)
+		}
+	}
+
+	// A missing item leaves the others as they were
+	err := storage.Db.Replace([]Item{
+		{Kind: "session", Name: "a", Content: []byte("new")},
+		{Kind: "session", Name: "missing", Content: []byte("new")},
+	})
+	if content, _ := storage.Load("a"); err == nil || string(content) != "old" {
+		t.Errorf("expected the replace to fail as a whole, got %q, %v", content, err)
+	}
+
+	if err = storage.Db.Replace([]Item{
+		{Kind: "session", Name: "a", Content: []byte("new")},
+		{Kind: "session", Name: "b", Content: []byte("new")},
+	}); err != nil {
+		t.Fatalf("failed to replace: %v", err)
+	}
+	if content, _ := storage.Load("b"); string(content) != "new" {
+		t.Errorf("expected the new content, got %q", content)
+	}
+}
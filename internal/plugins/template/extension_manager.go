@@ -26,6 +26,19 @@ func NewExtensionManager(configDir string) *ExtensionManager {
 	}
 }
 
+// CheckExtension tells whether {{ext:name:operation}} can run: the extension
+// is registered, its files are unchanged and it has the operation.
+func (em *ExtensionManager) CheckExtension(name, operation string) error {
+	ext, err := em.registry.GetExtension(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := ext.Operations[operation]; !ok {
+		return fmt.Errorf("extension %s has no operation %s", name, operation)
+	}
+	return nil
+}
+
 // ListExtensions handles the listextensions flag action
 func (em *ExtensionManager) ListExtensions() error {
 	if em.registry == nil || em.registry.registry.Extensions == nil {
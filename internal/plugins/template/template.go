@@ -2,9 +2,11 @@ package template
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 
 	debuglog "github.com/danielmiessler/fabric/internal/log"
@@ -18,6 +20,31 @@ var (
 	sysPlugin      = &SysPlugin{}
 )
 
+// plugin runs the operations of a namespace of {{plugin:namespace:operation:value}} calls.
+type plugin interface {
+	Apply(operation string, value string) (string, error)
+}
+
+// plugins are the plugins of the template calls, by namespace.
+var plugins = map[string]plugin{
+	"text":     textPlugin,
+	"datetime": datetimePlugin,
+	"file":     filePlugin,
+	"fetch":    fetchPlugin,
+	"sys":      sysPlugin,
+}
+
+// PluginNamespaces returns the namespaces of plugin calls, sorted.
+func PluginNamespaces() []string {
+	return slices.Sorted(maps.Keys(plugins))
+}
+
+// IsPluginNamespace tells whether a plugin call can use namespace.
+func IsPluginNamespace(namespace string) bool {
+	_, ok := plugins[namespace]
+	return ok
+}
+
 var extensionManager *ExtensionManager
 
 func init() {
@@ -51,9 +78,9 @@ func matchTriple(r *regexp.Regexp, full string) (string, string, string, bool) {
 	return "", "", "", false
 }
 
+// ApplyTemplate replaces the tokens of content. A variable written
+// {{name|default}} takes the default when it is not among variables.
 func ApplyTemplate(content string, variables map[string]string, input string) (string, error) {
-	tokenPattern := regexp.MustCompile(`\{\{([^{}]+)\}\}`)
-
 	debugf("Starting template processing with input='%s'\n", input)
 
 	for {
@@ -92,30 +119,13 @@ func ApplyTemplate(content string, variables map[string]string, input string) (s
 			if strings.HasPrefix(raw, "plugin:") {
 				if namespace, operation, value, ok := matchTriple(pluginPattern, full); ok {
 					debugf("Plugin call: namespace=%s operation=%s value=%s\n", namespace, operation, value)
-					var (
-						result string
-						err    error
-					)
-					switch namespace {
-					case "text":
-						debugf("Executing text plugin\n")
-						result, err = textPlugin.Apply(operation, value)
-					case "datetime":
-						debugf("Executing datetime plugin\n")
-						result, err = datetimePlugin.Apply(operation, value)
-					case "file":
-						debugf("Executing file plugin\n")
-						result, err = filePlugin.Apply(operation, value)
-						debugf("File plugin result: %#v\n", result)
-					case "fetch":
-						debugf("Executing fetch plugin\n")
-						result, err = fetchPlugin.Apply(operation, value)
-					case "sys":
-						debugf("Executing sys plugin\n")
-						result, err = sysPlugin.Apply(operation, value)
-					default:
+					p, found := plugins[namespace]
+					if !found {
 						return "", fmt.Errorf("unknown plugin namespace: %s", namespace)
 					}
+					debugf("Executing %s plugin\n", namespace)
+					result, err := p.Apply(operation, value)
+					debugf("%s plugin result: %#v\n", namespace, result)
 					if err != nil {
 						debugf("Plugin error: %v\n", err)
 						return "", fmt.Errorf("plugin %s error: %v", namespace, err)
@@ -132,9 +142,13 @@ func ApplyTemplate(content string, variables map[string]string, input string) (s
 				content = strings.ReplaceAll(content, full, input)
 				progress = true
 			default:
-				val, ok := variables[raw]
+				name, defaultValue, hasDefault := splitDefault(raw)
+				val, ok := variables[name]
 				if !ok {
-					return "", fmt.Errorf("missing required variable: %s", raw)
+					if !hasDefault {
+						return "", fmt.Errorf("missing required variable: %s", name)
+					}
+					val = defaultValue
 				}
 				content = strings.ReplaceAll(content, full, val)
 				progress = true
@@ -79,6 +79,22 @@ func TestApplyTemplate(t *testing.T) {
 			template: "{{plugin:text:upper:{{plugin:text:lower:HELLO}}}}",
 			want:     "HELLO",
 		},
+		{
+			name:     "variable default",
+			template: "Answer in {{lang|en}}",
+			want:     "Answer in en",
+		},
+		{
+			name:     "variable default overridden",
+			template: "Answer in {{lang|en}}",
+			vars:     map[string]string{"lang": "de"},
+			want:     "Answer in de",
+		},
+		{
+			name:     "empty variable default",
+			template: "[{{note|}}]",
+			want:     "[]",
+		},
 
 		// Error cases
 		{
@@ -87,6 +103,12 @@ func TestApplyTemplate(t *testing.T) {
 			wantErr:     true,
 			errContains: "missing required variable",
 		},
+		{
+			name:        "no default for other syntaxes",
+			template:    "{{ a || b }}",
+			wantErr:     true,
+			errContains: "missing required variable",
+		},
 		{
 			name:        "unknown plugin",
 			template:    "{{plugin:invalid:op:value}}",
@@ -143,3 +165,18 @@ func TestApplyTemplate(t *testing.T) {
 		})
 	}
 }
+
+func TestPluginNamespaces(t *testing.T) {
+	want := []string{"datetime", "fetch", "file", "sys", "text"}
+	if got := PluginNamespaces(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("PluginNamespaces() = %v, want %v", got, want)
+	}
+	for _, namespace := range want {
+		if !IsPluginNamespace(namespace) {
+			t.Errorf("IsPluginNamespace(%q) = false", namespace)
+		}
+	}
+	if IsPluginNamespace("math") {
+		t.Error("IsPluginNamespace(\"math\") = true")
+	}
+}
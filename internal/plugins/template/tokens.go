@@ -0,0 +1,96 @@
+package template
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TokenKind tells what a {{...}} token of a template stands for.
+type TokenKind int
+
+const (
+	TokenVariable TokenKind = iota
+	TokenInput
+	TokenPlugin
+	TokenExtension
+	// TokenInclude stands for {{include:pattern}} and {{include:pattern#section}},
+	// which patterns resolve before their template is applied
+	TokenInclude
+)
+
+var tokenPattern = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+var variableNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// IsVariableName tells whether name can be given with -v and take a default.
+func IsVariableName(name string) bool {
+	return variableNamePattern.MatchString(name)
+}
+
+// splitDefault splits {{name|default}} into its name and default. Only plain
+// names take a default, so that the | of other template languages is left alone.
+func splitDefault(raw string) (name, defaultValue string, hasDefault bool) {
+	if name, defaultValue, hasDefault = strings.Cut(raw, "|"); !hasDefault || !IsVariableName(name) {
+		return raw, "", false
+	}
+	return
+}
+
+// Token is one {{...}} of a template, as ApplyTemplate reads it.
+type Token struct {
+	Kind TokenKind
+	// Raw is the text between the braces
+	Raw string
+	// Name is the name of a variable, the namespace of a plugin, the name of an
+	// extension or the pattern an include names
+	Name string
+	// Default follows the | of a variable written {{name|default}}
+	Default    string
+	HasDefault bool
+	// Operation and Value are those of plugin and extension calls; Value is
+	// also the section an include names
+	Operation string
+	Value     string
+	// Malformed calls and includes lack the name they need
+	Malformed bool
+	// Line and Column locate the token in the template, counted from 1
+	Line   int
+	Column int
+}
+
+// Tokenize returns the tokens of content in the order they appear.
+func Tokenize(content string) (ret []Token) {
+	for _, match := range tokenPattern.FindAllStringSubmatchIndex(content, -1) {
+		token := parseToken(content[match[2]:match[3]])
+		before := content[:match[0]]
+		token.Line = strings.Count(before, "\n") + 1
+		token.Column = len(before) - strings.LastIndex(before, "\n")
+		ret = append(ret, token)
+	}
+	return
+}
+
+func parseToken(raw string) (ret Token) {
+	ret.Raw = raw
+	full := "{{" + raw + "}}"
+	switch {
+	case raw == "input" || raw == InputSentinel:
+		ret.Kind = TokenInput
+	case strings.HasPrefix(raw, "plugin:"):
+		ret.Kind = TokenPlugin
+		ret.Name, ret.Operation, ret.Value, _ = matchTriple(pluginPattern, full)
+		ret.Malformed = ret.Name == ""
+	case strings.HasPrefix(raw, "ext:"):
+		ret.Kind = TokenExtension
+		ret.Name, ret.Operation, ret.Value, _ = matchTriple(extensionPattern, full)
+		ret.Malformed = ret.Name == ""
+	case strings.HasPrefix(raw, "include:"):
+		ret.Kind = TokenInclude
+		ret.Name, ret.Value, _ = strings.Cut(strings.TrimPrefix(raw, "include:"), "#")
+		ret.Malformed = ret.Name == ""
+	default:
+		ret.Kind = TokenVariable
+		ret.Name, ret.Default, ret.HasDefault = splitDefault(raw)
+	}
+	return
+}
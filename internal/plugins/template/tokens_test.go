@@ -0,0 +1,27 @@
+package template
+
+import (
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	content := "# {{title}}\nIn {{lang|en}}: {{plugin:text:upper:x}} {{ext:word:count}}\n{{plugin:bad}}{{input}}{{include:style#TONE}}"
+	tokens := Tokenize(content)
+	want := []Token{
+		{Kind: TokenVariable, Raw: "title", Name: "title", Line: 1, Column: 3},
+		{Kind: TokenVariable, Raw: "lang|en", Name: "lang", Default: "en", HasDefault: true, Line: 2, Column: 4},
+		{Kind: TokenPlugin, Raw: "plugin:text:upper:x", Name: "text", Operation: "upper", Value: "x", Line: 2, Column: 17},
+		{Kind: TokenExtension, Raw: "ext:word:count", Name: "word", Operation: "count", Line: 2, Column: 41},
+		{Kind: TokenPlugin, Raw: "plugin:bad", Malformed: true, Line: 3, Column: 1},
+		{Kind: TokenInput, Raw: "input", Line: 3, Column: 15},
+		{Kind: TokenInclude, Raw: "include:style#TONE", Name: "style", Value: "TONE", Line: 3, Column: 24},
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("expected %d tokens, got %d: %+v", len(want), len(tokens), tokens)
+	}
+	for i := range want {
+		if tokens[i] != want[i] {
+			t.Errorf("token %d: expected %+v, got %+v", i, want[i], tokens[i])
+		}
+	}
+}
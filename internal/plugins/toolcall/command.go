@@ -0,0 +1,67 @@
+package toolcall
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const defaultCommandTimeout = 30 * time.Second
+
+// runCommand executes a YAML declared tool and returns its standard output.
+func (t *Tool) runCommand(ctx context.Context, arguments string) (ret string, err error) {
+	timeout := defaultCommandTimeout
+	if t.Timeout != "" {
+		if timeout, err = time.ParseDuration(t.Timeout); err != nil {
+			err = fmt.Errorf("tool %s: invalid timeout %q: %w", t.Name, t.Timeout, err)
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", t.Command)
+	cmd.Stdin = strings.NewReader(arguments)
+	cmd.Env = append(os.Environ(), t.Env...)
+	cmd.Env = append(cmd.Env, "FABRIC_TOOL_ARGS="+arguments)
+	cmd.Env = append(cmd.Env, argumentsEnv(arguments)...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err = cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("tool %s timed out after %v", t.Name, timeout)
+			return
+		}
+		err = fmt.Errorf("tool %s failed: %w: %s", t.Name, err, strings.TrimSpace(stderr.String()))
+		return
+	}
+	ret = strings.TrimRight(stdout.String(), "\n")
+	return
+}
+
+// argumentsEnv turns the top level scalar arguments into FABRIC_ARG_<NAME> variables.
+func argumentsEnv(arguments string) (ret []string) {
+	var args map[string]any
+	if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+		return
+	}
+	for name, value := range args {
+		envName := "FABRIC_ARG_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+		switch v := value.(type) {
+		case string:
+			ret = append(ret, envName+"="+v)
+		case float64, bool:
+			ret = append(ret, fmt.Sprintf("%s=%v", envName, v))
+		}
+	}
+	return
+}
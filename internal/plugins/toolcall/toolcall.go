@@ -0,0 +1,198 @@
+// Package toolcall keeps the registry of tools fabric can offer to a model
+// through native function calling. Tools are either registered in code with a
+// Handler or declared in YAML files that run a shell command.
+package toolcall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Handler runs a tool. arguments is the JSON object produced by the model and
+// the returned string is sent back to the model as the tool result.
+type Handler func(ctx context.Context, arguments string) (string, error)
+
+// Tool describes a single callable tool.
+type Tool struct {
+	Name        string         `yaml:"name"`
+	Description string         `yaml:"description"`
+	Parameters  map[string]any `yaml:"parameters"`
+
+	// Command is run with "sh -c" when the tool has no Handler. The arguments
+	// are passed as JSON on stdin and in FABRIC_TOOL_ARGS; top level scalar
+	// arguments are also exported as FABRIC_ARG_<NAME>.
+	Command string   `yaml:"command"`
+	Timeout string   `yaml:"timeout"`
+	Env     []string `yaml:"env"`
+
+	Handler Handler `yaml:"-"`
+}
+
+var toolNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Definition returns the vendor-neutral definition that is advertised to the model.
+func (t *Tool) Definition() chat.Tool {
+	parameters := t.Parameters
+	if parameters == nil {
+		parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	return chat.Tool{
+		Type: chat.ToolTypeFunction,
+		Function: &chat.FunctionDefinition{
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  parameters,
+		},
+	}
+}
+
+func (t *Tool) validate() error {
+	if !toolNameRegex.MatchString(t.Name) {
+		return fmt.Errorf("invalid tool name %q: use 1-64 letters, digits, '_' or '-'", t.Name)
+	}
+	if t.Handler == nil && strings.TrimSpace(t.Command) == "" {
+		return fmt.Errorf("tool %s has neither a handler nor a command", t.Name)
+	}
+	if t.Parameters != nil {
+		if typ, ok := t.Parameters["type"]; ok && typ != "object" {
+			return fmt.Errorf("tool %s: parameters must be a JSON schema of type object", t.Name)
+		}
+	}
+	return nil
+}
+
+// Registry holds the tools known to fabric. It is safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]*Tool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]*Tool)}
+}
+
+// Register adds a tool, replacing any tool with the same name.
+func (r *Registry) Register(tool *Tool) (err error) {
+	if err = tool.validate(); err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[tool.Name] = tool
+	return
+}
+
+// RegisterFunc is a shortcut for registering a tool implemented in Go.
+func (r *Registry) RegisterFunc(name, description string, parameters map[string]any, handler Handler) error {
+	return r.Register(&Tool{Name: name, Description: description, Parameters: parameters, Handler: handler})
+}
+
+func (r *Registry) Get(name string) (ret *Tool, err error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var ok bool
+	if ret, ok = r.tools[name]; !ok {
+		err = fmt.Errorf("tool %s is not registered", name)
+	}
+	return
+}
+
+// Names returns the registered tool names in sorted order.
+func (r *Registry) Names() (ret []string) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for name := range r.tools {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return
+}
+
+// Definitions returns the definitions of the named tools, or of every
+// registered tool when no names are given.
+func (r *Registry) Definitions(names ...string) (ret []chat.Tool, err error) {
+	if len(names) == 0 {
+		names = r.Names()
+	}
+	for _, name := range names {
+		var tool *Tool
+		if tool, err = r.Get(name); err != nil {
+			return nil, err
+		}
+		ret = append(ret, tool.Definition())
+	}
+	return
+}
+
+// Call runs the tool requested by the model and returns its output.
+func (r *Registry) Call(ctx context.Context, call chat.ToolCall) (ret string, err error) {
+	var tool *Tool
+	if tool, err = r.Get(call.Function.Name); err != nil {
+		return
+	}
+	arguments := call.Function.Arguments
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	if tool.Handler != nil {
+		return tool.Handler(ctx, arguments)
+	}
+	return tool.runCommand(ctx, arguments)
+}
+
+// LoadFile reads a single YAML tool declaration.
+func LoadFile(path string) (ret *Tool, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return
+	}
+	ret = &Tool{}
+	if err = yaml.Unmarshal(data, ret); err != nil {
+		err = fmt.Errorf("failed to parse tool file %s: %w", path, err)
+		return nil, err
+	}
+	if ret.Name == "" {
+		ret.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return
+}
+
+// LoadDir registers every *.yaml and *.yml tool declaration found in dir.
+// A missing directory is not an error. Invalid files are skipped and reported
+// together in the returned error.
+func (r *Registry) LoadDir(dir string) (err error) {
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(dir); err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return
+	}
+
+	var errs []error
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			continue
+		}
+		var tool *Tool
+		if tool, err = LoadFile(filepath.Join(dir, entry.Name())); err == nil {
+			err = r.Register(tool)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
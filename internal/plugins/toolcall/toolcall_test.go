@@ -0,0 +1,132 @@
+package toolcall
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_RegisterFuncAndCall(t *testing.T) {
+	registry := NewRegistry()
+	err := registry.RegisterFunc("echo", "Echo the arguments", nil,
+		func(_ context.Context, arguments string) (string, error) {
+			return "got " + arguments, nil
+		})
+	require.NoError(t, err)
+
+	result, err := registry.Call(context.Background(), chat.ToolCall{
+		ID:       "call_1",
+		Type:     chat.ToolTypeFunction,
+		Function: chat.FunctionCall{Name: "echo", Arguments: `{"a":1}`},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, `got {"a":1}`, result)
+
+	result, err = registry.Call(context.Background(), chat.ToolCall{Function: chat.FunctionCall{Name: "echo"}})
+	require.NoError(t, err)
+	assert.Equal(t, "got {}", result)
+}
+
+func TestRegistry_RegisterValidation(t *testing.T) {
+	registry := NewRegistry()
+
+	tests := []struct {
+		name string
+		tool *Tool
+	}{
+		{"invalid name", &Tool{Name: "bad name", Command: "true"}},
+		{"no handler or command", &Tool{Name: "empty"}},
+		{"non object parameters", &Tool{Name: "str", Command: "true", Parameters: map[string]any{"type": "string"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, registry.Register(tt.tool))
+		})
+	}
+	assert.Empty(t, registry.Names())
+}
+
+func TestRegistry_Definitions(t *testing.T) {
+	registry := NewRegistry()
+	params := map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"city": map[string]any{"type": "string"}},
+		"required":   []any{"city"},
+	}
+	require.NoError(t, registry.RegisterFunc("weather", "Current weather", params, noopHandler))
+	require.NoError(t, registry.RegisterFunc("time", "Current time", nil, noopHandler))
+
+	defs, err := registry.Definitions()
+	require.NoError(t, err)
+	require.Len(t, defs, 2)
+	assert.Equal(t, "time", defs[0].Function.Name)
+	assert.Equal(t, "object", defs[0].Function.Parameters["type"])
+	assert.Equal(t, params, defs[1].Function.Parameters)
+
+	defs, err = registry.Definitions("weather")
+	require.NoError(t, err)
+	require.Len(t, defs, 1)
+	assert.Equal(t, chat.ToolTypeFunction, defs[0].Type)
+
+	_, err = registry.Definitions("missing")
+	assert.Error(t, err)
+}
+
+func TestRegistry_LoadDirAndRunCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("command tools require sh")
+	}
+	dir := t.TempDir()
+	yamlContent := `name: greet
+description: Greets someone
+parameters:
+  type: object
+  properties:
+    who:
+      type: string
+  required: [who]
+command: 'echo "hello $FABRIC_ARG_WHO"'
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "greet.yaml"), []byte(yamlContent), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0644))
+
+	registry := NewRegistry()
+	require.NoError(t, registry.LoadDir(dir))
+	assert.Equal(t, []string{"greet"}, registry.Names())
+
+	result, err := registry.Call(context.Background(), chat.ToolCall{
+		Function: chat.FunctionCall{Name: "greet", Arguments: `{"who":"fabric"}`},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "hello fabric", result)
+}
+
+func TestRegistry_LoadDirReportsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.yaml"), []byte("name: [oops"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ok.yml"), []byte("command: date\n"), 0644))
+
+	registry := NewRegistry()
+	assert.Error(t, registry.LoadDir(dir))
+	assert.Equal(t, []string{"ok"}, registry.Names())
+
+	assert.NoError(t, registry.LoadDir(filepath.Join(dir, "missing")))
+}
+
+func TestRunCommand_Failure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("command tools require sh")
+	}
+	tool := &Tool{Name: "fail", Command: "echo boom >&2; exit 3"}
+	_, err := tool.runCommand(context.Background(), "{}")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+}
+
+func noopHandler(context.Context, string) (string, error) { return "", nil }
@@ -1,6 +1,7 @@
 package restapi
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -29,6 +30,7 @@ type PromptRequest struct {
 	ContextName  string            `json:"contextName"`
 	PatternName  string            `json:"patternName"`
 	StrategyName string            `json:"strategyName"`        // Optional strategy name
+	SessionName  string            `json:"sessionName"`         // Optional session to continue and save the answer to
 	Variables    map[string]string `json:"variables,omitempty"` // Pattern variables
 }
 
@@ -36,12 +38,15 @@ type ChatRequest struct {
 	Prompts            []PromptRequest `json:"prompts"`
 	Language           string          `json:"language"` // Add Language field to bind from request
 	domain.ChatOptions                 // Embed the ChatOptions from common package
+	// Temperature is nil when the request leaves it to the pattern
+	Temperature *float64 `json:"temperature,omitempty"`
 }
 
 type StreamResponse struct {
-	Type    string `json:"type"`    // "content", "error", "complete"
-	Format  string `json:"format"`  // "markdown", "mermaid", "plain"
-	Content string `json:"content"` // The actual content
+	Type    string                `json:"type"`            // "content", "usage", "error", "complete"
+	Format  string                `json:"format"`          // "markdown", "mermaid", "plain", "json"
+	Content string                `json:"content"`         // The actual content
+	Usage   *domain.UsageMetadata `json:"usage,omitempty"` // Token usage of the prompt, on "usage" responses
 }
 
 func NewChatHandler(r *gin.Engine, registry *core.PluginRegistry, db *fsdb.Db) *ChatHandler {
@@ -88,20 +93,46 @@ func (h *ChatHandler) HandleChat(c *gin.Context) {
 
 	clientGone := c.Writer.CloseNotify()
 
-	for i, prompt := range request.Prompts {
+	// Cancel in-flight generations as soon as the client disconnects
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	go func() {
 		select {
 		case <-clientGone:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for i, prompt := range request.Prompts {
+		select {
+		case <-ctx.Done():
 			log.Printf("Client disconnected")
 			return
 		default:
 			log.Printf("Processing prompt %d: Model=%s Pattern=%s Context=%s",
 				i+1, prompt.Model, prompt.PatternName, prompt.ContextName)
 
-			streamChan := make(chan string)
+			streamChan := make(chan StreamResponse)
 
 			go func(p PromptRequest) {
 				defer close(streamChan)
 
+				// Nobody reads streamChan once the client is gone
+				sendResponse := func(response StreamResponse) {
+					select {
+					case streamChan <- response:
+					case <-ctx.Done():
+					}
+				}
+				send := func(content string) {
+					if strings.HasPrefix(content, "Error:") {
+						sendResponse(StreamResponse{Type: "error", Format: "plain", Content: content})
+					} else {
+						sendResponse(StreamResponse{Type: "content", Format: detectFormat(content), Content: content})
+					}
+				}
+
 				// Load and prepend strategy prompt if strategyName is set
 				if p.StrategyName != "" {
 					strategyFile := filepath.Join(os.Getenv("HOME"), ".config", "fabric", "strategies", p.StrategyName+".json")
@@ -116,12 +147,39 @@ func (h *ChatHandler) HandleChat(c *gin.Context) {
 					}
 				}
 
-				chatter, err := h.registry.GetChatter(p.Model, 2048, p.Vendor, "", false, false)
+				// A pattern may choose the model when the request does not
+				if p.Model == "" && p.PatternName != "" {
+					if vendor, model := h.registry.PatternModel(p.PatternName); model != "" {
+						p.Model = model
+						if vendor != "" {
+							p.Vendor = vendor
+						}
+					}
+				}
+
+				chatter, err := h.registry.GetChatter(p.Model, 0, p.Vendor, "", false, false)
 				if err != nil {
 					log.Printf("Error creating chatter: %v", err)
-					streamChan <- fmt.Sprintf("Error: %v", err)
+					send(fmt.Sprintf("Error: %v", err))
 					return
 				}
+				if p.PatternName != "" {
+					if spec := h.registry.PatternFallback(p.PatternName); spec != "" {
+						if chatter.Fallbacks, err = h.registry.GetFallbacks(spec); err != nil {
+							log.Printf("Error creating fallbacks: %v", err)
+							send(fmt.Sprintf("Error: %v", err))
+							return
+						}
+					}
+				}
+				// Usage is sent after the content, which clients such as the
+				// Ollama emulation expect on the first line
+				var usage *domain.UsageMetadata
+				chatter.Sink = core.OutputSinkFunc(func(update domain.StreamUpdate) {
+					if update.Type == domain.StreamTypeUsage {
+						usage = update.Usage
+					}
+				})
 
 				// Pass the language received in the initial request to the domain.ChatRequest
 				chatReq := &domain.ChatRequest{
@@ -131,60 +189,53 @@ func (h *ChatHandler) HandleChat(c *gin.Context) {
 					},
 					PatternName:      p.PatternName,
 					ContextName:      p.ContextName,
+					SessionName:      p.SessionName,
 					PatternVariables: p.Variables,      // Pass pattern variables
 					Language:         request.Language, // Pass the language field
 				}
 
 				opts := &domain.ChatOptions{
 					Model:            p.Model,
-					Temperature:      request.Temperature,
 					TopP:             request.TopP,
 					FrequencyPenalty: request.FrequencyPenalty,
 					PresencePenalty:  request.PresencePenalty,
 					Thinking:         request.Thinking,
 				}
 
-				session, err := chatter.Send(chatReq, opts)
+				if request.Temperature != nil {
+					opts.Temperature, opts.TemperatureSet = *request.Temperature, true
+				}
+
+				session, err := chatter.Send(ctx, chatReq, opts)
 				if err != nil {
 					log.Printf("Error from chatter.Send: %v", err)
-					streamChan <- fmt.Sprintf("Error: %v", err)
+					send(fmt.Sprintf("Error: %v", err))
 					return
 				}
 
 				if session == nil {
 					log.Printf("No session returned from chatter.Send")
-					streamChan <- "Error: No response from model"
+					send("Error: No response from model")
 					return
 				}
 
 				lastMsg := session.GetLastMessage()
 				if lastMsg != nil {
-					streamChan <- lastMsg.Content
+					send(lastMsg.Content)
 				} else {
 					log.Printf("No message content in session")
-					streamChan <- "Error: No response content"
+					send("Error: No response content")
+				}
+				if usage != nil {
+					sendResponse(StreamResponse{Type: "usage", Format: "json", Usage: usage})
 				}
 			}(prompt)
 
-			for content := range streamChan {
+			for response := range streamChan {
 				select {
-				case <-clientGone:
+				case <-ctx.Done():
 					return
 				default:
-					var response StreamResponse
-					if strings.HasPrefix(content, "Error:") {
-						response = StreamResponse{
-							Type:    "error",
-							Format:  "plain",
-							Content: content,
-						}
-					} else {
-						response = StreamResponse{
-							Type:    "content",
-							Format:  detectFormat(content),
-							Content: content,
-						}
-					}
 					if err := writeSSEResponse(c.Writer, response); err != nil {
 						log.Printf("Error writing response: %v", err)
 						return
@@ -1,6 +1,8 @@
 package restapi
 
 import (
+	"fmt"
+
 	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
 	"github.com/gin-gonic/gin"
 )
@@ -14,6 +16,26 @@ type ContextsHandler struct {
 // NewContextsHandler creates a new ContextsHandler
 func NewContextsHandler(r *gin.Engine, contexts *fsdb.ContextsEntity) (ret *ContextsHandler) {
 	ret = &ContextsHandler{
-		StorageHandler: NewStorageHandler(r, "contexts", contexts), contexts: contexts}
+		StorageHandler: NewStorageHandler(r, "contexts", &contextsStorage{contexts}), contexts: contexts}
 	return
 }
+
+// contextsStorage keeps clients of the API from creating context definitions,
+// which read local files and run their refresh command.
+type contextsStorage struct {
+	*fsdb.ContextsEntity
+}
+
+func (o *contextsStorage) Save(name string, content []byte) error {
+	if fsdb.IsContextDefinition(name) {
+		return fmt.Errorf("context definitions cannot be saved through the API: %s", name)
+	}
+	return o.ContextsEntity.Save(name, content)
+}
+
+func (o *contextsStorage) Rename(oldName, newName string) error {
+	if fsdb.IsContextDefinition(newName) {
+		return fmt.Errorf("context definitions cannot be saved through the API: %s", newName)
+	}
+	return o.ContextsEntity.Rename(oldName, newName)
+}
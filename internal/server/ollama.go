@@ -2,7 +2,6 @@ package restapi
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -87,9 +86,12 @@ func ServeOllama(registry *core.PluginRegistry, address string, version string)
 
 	// Register routes
 	fabricDb := registry.Db
+	// Requests never run the refresh command of context definitions
+	fabricDb.Contexts.Refresh = false
 	NewPatternsHandler(r, fabricDb.Patterns)
 	NewContextsHandler(r, fabricDb.Contexts)
-	NewSessionsHandler(r, fabricDb.Sessions)
+	NewSessionsHandler(r, registry, fabricDb.Sessions)
+	NewSearchHandler(r, fabricDb)
 	NewChatHandler(r, registry, fabricDb)
 	NewConfigHandler(r, fabricDb)
 	NewModelsHandler(r, registry.VendorManager)
@@ -190,7 +192,8 @@ func (f APIConvert) ollamaChat(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err})
 		return
 	}
-	ctx := context.Background()
+	// Stop the forwarded /chat request when the Ollama client goes away
+	ctx := c.Request.Context()
 	var req *http.Request
 	if strings.Contains(*f.addr, "http") {
 		req, err = http.NewRequest("POST", fmt.Sprintf("%s/chat", *f.addr), bytes.NewBuffer(fabricChatReq))
@@ -29,12 +29,13 @@ func NewPatternsHandler(r *gin.Engine, patterns *fsdb.PatternsEntity) (ret *Patt
 	r.POST("/patterns/:name", ret.Save)                     // From StorageHandler
 	// Add POST route for patterns with variables in request body
 	r.POST("/patterns/:name/apply", ret.ApplyPattern)
+	r.GET("/patterns/:name/variables", ret.GetVariables)
 	return
 }
 
 // Get handles the GET /patterns/:name route - returns raw pattern without variable processing
 // @Summary Get a pattern
-// @Description Retrieve a pattern by name
+// @Description Retrieve a pattern by name, with the description, tags, variables and preferences of its front matter
 // @Tags patterns
 // @Accept json
 // @Produce json
@@ -53,15 +54,46 @@ func (h *PatternsHandler) Get(c *gin.Context) {
 		return
 	}
 
-	// Return raw pattern in the same format as the processed patterns
+	// Return raw pattern in the same format as the processed patterns, with
+	// the front matter kept in place so that it can be saved back as it is
+	metadata, _, err := fsdb.ParseFrontMatter(string(content))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
 	pattern := &fsdb.Pattern{
-		Name:        name,
-		Description: "",
-		Pattern:     string(content),
+		Name:     name,
+		Pattern:  string(content),
+		Metadata: metadata,
+	}
+	if metadata != nil {
+		pattern.Description = metadata.Description
 	}
 	c.JSON(http.StatusOK, pattern)
 }
 
+// GetVariables handles the GET /patterns/:name/variables route
+// @Summary Get pattern variables
+// @Description List the variables a pattern takes, with their types, values and defaults, so that forms can be built for them
+// @Tags patterns
+// @Produce json
+// @Param name path string true "Pattern name"
+// @Success 200 {array} fsdb.PatternVariable
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /patterns/{name}/variables [get]
+func (h *PatternsHandler) GetVariables(c *gin.Context) {
+	variables, err := h.patterns.GetVariables(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, err.Error())
+		return
+	}
+	if variables == nil {
+		variables = []fsdb.PatternVariable{}
+	}
+	c.JSON(http.StatusOK, variables)
+}
+
 // PatternApplyRequest represents the request body for applying a pattern
 type PatternApplyRequest struct {
 	Input     string            `json:"input"`
@@ -0,0 +1,59 @@
+package restapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+	"github.com/gin-gonic/gin"
+)
+
+// SearchHandler defines the handler for full-text search
+type SearchHandler struct {
+	index *fsdb.SearchIndex
+}
+
+// NewSearchHandler creates a new SearchHandler
+func NewSearchHandler(r *gin.Engine, db *fsdb.Db) (ret *SearchHandler) {
+	ret = &SearchHandler{index: fsdb.NewSearchIndex(db)}
+	r.GET("/search", ret.Search)
+	return
+}
+
+// Search handles the GET /search route
+// @Summary Search sessions, contexts and patterns
+// @Description Full-text search over session messages, contexts and pattern system prompts, best matches first
+// @Tags search
+// @Produce json
+// @Param q query string true "Search query: words, \"phrases\", prefix* and AND, OR, NOT"
+// @Param limit query int false "Maximum number of results" default(20)
+// @Success 200 {array} fsdb.SearchResult
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /search [get]
+func (h *SearchHandler) Search(c *gin.Context) {
+	limit := 20
+	if value := c.Query("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+			return
+		}
+	}
+
+	results, err := h.index.Search(c.Query("q"), limit)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, fsdb.ErrInvalidSearchQuery) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if results == nil {
+		results = []fsdb.SearchResult{}
+	}
+	c.JSON(http.StatusOK, results)
+}
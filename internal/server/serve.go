@@ -67,9 +67,12 @@ func Serve(registry *core.PluginRegistry, address string, apiKey string) (err er
 
 	// Register routes
 	fabricDb := registry.Db
+	// Requests never run the refresh command of context definitions
+	fabricDb.Contexts.Refresh = false
 	NewPatternsHandler(r, fabricDb.Patterns)
 	NewContextsHandler(r, fabricDb.Contexts)
-	NewSessionsHandler(r, fabricDb.Sessions)
+	NewSessionsHandler(r, registry, fabricDb.Sessions)
+	NewSearchHandler(r, fabricDb)
 	NewChatHandler(r, registry, fabricDb)
 	NewYouTubeHandler(r, registry)
 	NewConfigHandler(r, fabricDb)
@@ -1,6 +1,11 @@
 package restapi
 
 import (
+	"fmt"
+	"net/http"
+
+	"github.com/danielmiessler/fabric/internal/core"
+	"github.com/danielmiessler/fabric/internal/domain"
 	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
 	"github.com/gin-gonic/gin"
 )
@@ -9,11 +14,210 @@ import (
 type SessionsHandler struct {
 	*StorageHandler[fsdb.Session]
 	sessions *fsdb.SessionsEntity
+	registry *core.PluginRegistry
 }
 
 // NewSessionsHandler creates a new SessionsHandler
-func NewSessionsHandler(r *gin.Engine, sessions *fsdb.SessionsEntity) (ret *SessionsHandler) {
+func NewSessionsHandler(r *gin.Engine, registry *core.PluginRegistry, sessions *fsdb.SessionsEntity) (ret *SessionsHandler) {
 	ret = &SessionsHandler{
-		StorageHandler: NewStorageHandler(r, "sessions", sessions), sessions: sessions}
+		StorageHandler: NewStorageHandler(r, "sessions", sessions), sessions: sessions, registry: registry}
+	r.POST("/sessions/:name/fork", ret.Fork)
+	r.POST("/sessions/:name/rewind", ret.Rewind)
+	r.POST("/sessions/:name/edit", ret.EditMessage)
+	r.POST("/sessions/:name/regenerate", ret.Regenerate)
 	return ret
 }
+
+// SessionForkRequest represents the request body for forking a session
+type SessionForkRequest struct {
+	Name string `json:"name" binding:"required"`
+	At   int    `json:"at"` // Number of messages to keep, 0 for all
+}
+
+// SessionRewindRequest represents the request body for rewinding a session
+type SessionRewindRequest struct {
+	Turns int `json:"turns" binding:"required"`
+}
+
+// SessionEditRequest represents the request body for editing a message of a session
+type SessionEditRequest struct {
+	Index   int    `json:"index" binding:"required"` // Counted from 1
+	Content string `json:"content" binding:"required"`
+}
+
+// SessionRegenerateRequest represents the request body for regenerating the last answer of a session
+type SessionRegenerateRequest struct {
+	Vendor             string `json:"vendor"`
+	Model              string `json:"model"`
+	domain.ChatOptions        // Embed the ChatOptions from common package
+}
+
+// Fork handles the POST /sessions/:name/fork route
+// @Summary Fork a session
+// @Description Copy the first messages of a session into a new session
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Param name path string true "Session name"
+// @Param request body SessionForkRequest true "Name of the new session and number of messages to keep"
+// @Success 200 {object} fsdb.Session
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /sessions/{name}/fork [post]
+func (h *SessionsHandler) Fork(c *gin.Context) {
+	var request SessionForkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Unlike the names of the routes, the new name may hold a path
+	if err := fsdb.ValidateName(request.Name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := c.Param("name")
+	if request.Name == name {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("session %s already exists", request.Name)})
+		return
+	}
+	// The new session is locked as well, so that no other request takes its
+	// name between the check and the save. Both are locked in the order of
+	// their names, so that two sessions forked into each other do not wait on
+	// each other forever.
+	first, second := name, request.Name
+	if second < first {
+		first, second = second, first
+	}
+	defer h.sessions.Lock(first)()
+	defer h.sessions.Lock(second)()
+	if h.sessions.Exists(request.Name) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("session %s already exists", request.Name)})
+		return
+	}
+	h.updateLocked(c, name, func(session *fsdb.Session) (*fsdb.Session, error) {
+		return session.Fork(request.Name, request.At)
+	})
+}
+
+// Rewind handles the POST /sessions/:name/rewind route
+// @Summary Rewind a session
+// @Description Drop the last turns of a session
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Param name path string true "Session name"
+// @Param request body SessionRewindRequest true "Number of turns to drop"
+// @Success 200 {object} fsdb.Session
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /sessions/{name}/rewind [post]
+func (h *SessionsHandler) Rewind(c *gin.Context) {
+	var request SessionRewindRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.update(c, func(session *fsdb.Session) (*fsdb.Session, error) {
+		return session, session.Rewind(request.Turns)
+	})
+}
+
+// EditMessage handles the POST /sessions/:name/edit route
+// @Summary Edit a message of a session
+// @Description Replace the text of a user message and drop the messages after it
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Param name path string true "Session name"
+// @Param request body SessionEditRequest true "Message number and its new text"
+// @Success 200 {object} fsdb.Session
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /sessions/{name}/edit [post]
+func (h *SessionsHandler) EditMessage(c *gin.Context) {
+	var request SessionEditRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.update(c, func(session *fsdb.Session) (*fsdb.Session, error) {
+		return session, session.EditMessage(request.Index, request.Content)
+	})
+}
+
+// Regenerate handles the POST /sessions/:name/regenerate route
+// @Summary Regenerate the last answer of a session
+// @Description Send the session again for a new answer to its last request, in place of the answer it has
+// @Tags sessions
+// @Accept json
+// @Produce json
+// @Param name path string true "Session name"
+// @Param request body SessionRegenerateRequest false "Vendor, model and chat options"
+// @Success 200 {object} fsdb.Session
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Router /sessions/{name}/regenerate [post]
+func (h *SessionsHandler) Regenerate(c *gin.Context) {
+	name := c.Param("name")
+	var request SessionRegenerateRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if !h.sessions.Exists(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("session %s does not exist", name)})
+		return
+	}
+
+	chatter, err := h.registry.GetChatter(request.Model, request.ModelContextLength, request.Vendor, "", false, false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	opts := request.ChatOptions
+	opts.Model = request.Model
+
+	session, err := chatter.Send(c.Request.Context(), &domain.ChatRequest{SessionName: name, Regenerate: true}, &opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// update loads the session of the route, changes it with change and saves the result.
+func (h *SessionsHandler) update(c *gin.Context, change func(session *fsdb.Session) (*fsdb.Session, error)) {
+	name := c.Param("name")
+	defer h.sessions.Lock(name)()
+	h.updateLocked(c, name, change)
+}
+
+// updateLocked is update for a session that is locked already.
+func (h *SessionsHandler) updateLocked(c *gin.Context, name string, change func(session *fsdb.Session) (*fsdb.Session, error)) {
+	if !h.sessions.Exists(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("session %s does not exist", name)})
+		return
+	}
+	session, err := h.sessions.Get(name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if session, err = change(session); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err = h.sessions.SaveSession(session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
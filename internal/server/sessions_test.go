@@ -0,0 +1,51 @@
+package restapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/danielmiessler/fabric/internal/chat"
+	"github.com/danielmiessler/fabric/internal/plugins/db/fsdb"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSessionsHandler_ForkInvalidName(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	db := fsdb.NewDb(filepath.Join(dir, "fabric"))
+	if err := db.Sessions.Configure(); err != nil {
+		t.Fatalf("failed to create sessions dir: %v", err)
+	}
+	session := &fsdb.Session{Name: "source"}
+	session.Append(&chat.ChatCompletionMessage{Role: chat.ChatMessageRoleUser, Content: "hello"})
+	if err := db.Sessions.SaveSession(session); err != nil {
+		t.Fatalf("failed to save session: %v", err)
+	}
+
+	r := gin.New()
+	NewSessionsHandler(r, nil, db.Sessions)
+	for _, name := range []string{"../../outside", "sub/fork", ".."} {
+		w := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/sessions/source/fork", strings.NewReader(`{"name":"`+name+`"}`))
+		request.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, request)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %q, got %d: %s", name, w.Code, w.Body)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "outside.json")); !os.IsNotExist(err) {
+		t.Errorf("expected no session outside the sessions directory, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/sessions/source/fork", strings.NewReader(`{"name":"copy"}`))
+	request.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, request)
+	if w.Code != http.StatusOK || !db.Sessions.Exists("copy") {
+		t.Errorf("expected a valid name to fork, got %d: %s", w.Code, w.Body)
+	}
+}